@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/config"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/notifier"
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
+)
+
+// checkCompanyForm verifies that YCLIENTS_FORM_ID belongs to
+// YCLIENTS_COMPANY_ID. A mismatch is fatal in strict mode; everything else
+// only warns so that a flaky API doesn't block startup.
+func checkCompanyForm(ctx context.Context, yc *yclients.Client, cfg config.Config, log *logger.Logger) error {
+	if cfg.YClientsConsistencyCheck == config.ConsistencyOff {
+		log.Info("Company/form consistency check disabled")
+		return nil
+	}
+
+	company, form, err := yc.VerifyCompanyForm(ctx)
+	notifier.SeedCompanyName(cfg.YClientsCompanyID, company.Title)
+	notifier.SeedFormName(cfg.YClientsFormID, form.Title)
+
+	fields := logger.Fields{
+		"company_id":    cfg.YClientsCompanyID,
+		"company_title": company.Title,
+		"form_id":       cfg.YClientsFormID,
+		"form_title":    form.Title,
+	}
+	switch {
+	case err == nil:
+		log.InfoWithFields("Company and booking form are consistent", fields)
+		return nil
+	case errors.Is(err, yclients.ErrFormMismatch):
+		if cfg.YClientsConsistencyCheck == config.ConsistencyStrict {
+			return fmt.Errorf("check YCLIENTS_COMPANY_ID and YCLIENTS_FORM_ID: %w", err)
+		}
+		log.WithError(err).WarnWithFields("YCLIENTS_FORM_ID does not belong to YCLIENTS_COMPANY_ID; bookings will likely fail", fields)
+	default:
+		log.WithError(err).WarnWithFields("Could not verify company and booking form", fields)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+const defaultDBPath = "/data/notifier.db"
+
+// runDBCommand implements maintenance subcommands: notifier db <command> [flags].
+func runDBCommand(args []string, log *logger.Logger) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: notifier db rotate-key --old KEY --new KEY [--db PATH] [--batch N]")
+		fmt.Fprintln(os.Stderr, "       notifier db export [--db PATH] [--out FILE]")
+		fmt.Fprintln(os.Stderr, "       notifier db import [--db PATH] FILE")
+		return 2
+	}
+
+	switch args[0] {
+	case "rotate-key":
+		return runRotateKey(args[1:], log)
+	case "export":
+		return runExport(args[1:], log)
+	case "import":
+		return runImport(args[1:], log)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown db command %q\n", args[0])
+		return 2
+	}
+}
+
+// runRotateKey re-encrypts personal data columns with a new key. An empty
+// --old encrypts existing plaintext data, an empty --new decrypts it.
+func runRotateKey(args []string, log *logger.Logger) int {
+	fs := flag.NewFlagSet("rotate-key", flag.ContinueOnError)
+	dbPath := fs.String("db", defaultDBPath, "path to the SQLite database")
+	oldKey := fs.String("old", "", "current encryption key (empty for plaintext data)")
+	newKey := fs.String("new", "", "new encryption key (empty to decrypt)")
+	batch := fs.Int("batch", 500, "rows per transaction")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *oldKey == *newKey {
+		fmt.Fprintln(os.Stderr, "--old and --new must differ")
+		return 2
+	}
+
+	store, err := storage.New(*dbPath, log.WithField("component", "storage"))
+	if err != nil {
+		log.WithError(err).Error("Failed to open storage")
+		return 1
+	}
+	defer store.Close()
+
+	n, err := store.RotateEncryptionKey(*oldKey, *newKey, *batch)
+	if err != nil {
+		log.WithError(err).ErrorWithFields("Key rotation failed", logger.Fields{"rows_rewritten": n})
+		return 1
+	}
+	log.InfoWithFields("Key rotation completed", logger.Fields{"rows_rewritten": n})
+	return 0
+}
+
+// runExport writes a JSON backup of subscribers, seen slots and
+// preferences to --out or stdout. Logs go to stderr so stdout carries only
+// the document.
+func runExport(args []string, log *logger.Logger) int {
+	log = log.WithOutput(os.Stderr)
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	dbPath := fs.String("db", defaultDBPath, "path to the SQLite database")
+	out := fs.String("out", "", "file to write (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	store, err := storage.New(*dbPath, log.WithField("component", "storage"))
+	if err != nil {
+		log.WithError(err).Error("Failed to open storage")
+		return 1
+	}
+	defer store.Close()
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.WithError(err).Error("Failed to create export file")
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := store.Export(w); err != nil {
+		log.WithError(err).Error("Export failed")
+		return 1
+	}
+	if *out != "" {
+		if err := w.Close(); err != nil {
+			log.WithError(err).Error("Failed to write export file")
+			return 1
+		}
+	}
+	return 0
+}
+
+// runImport adds the rows of a backup written by export. Rows already in
+// the database are kept, so importing twice is harmless.
+func runImport(args []string, log *logger.Logger) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	dbPath := fs.String("db", defaultDBPath, "path to the SQLite database")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: notifier db import [--db PATH] FILE")
+		return 2
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.WithError(err).Error("Failed to open import file")
+		return 1
+	}
+	defer f.Close()
+
+	store, err := storage.New(*dbPath, log.WithField("component", "storage"))
+	if err != nil {
+		log.WithError(err).Error("Failed to open storage")
+		return 1
+	}
+	defer store.Close()
+
+	res, err := store.Import(f)
+	if err != nil {
+		log.WithError(err).Error("Import failed")
+		return 1
+	}
+	log.InfoWithFields("Import completed", logger.Fields{
+		"subscribers_added": res.Subscribers,
+		"seen_slots_added":  res.SeenSlots,
+		"preferences_added": res.Preferences,
+	})
+	return 0
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+// fixtureDB writes a database whose personal data is encrypted with key.
+func fixtureDB(t *testing.T, key string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notifier.db")
+	store, err := storage.New(path, testLogger())
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	defer store.Close()
+	if err := store.SetEncryptionKey(key); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+	for chatID, name := range map[int64]string{1: "rider42", 2: "biker7", 3: "moto_fan"} {
+		if err := store.TouchUser(chatID, &storage.UserIdentity{Username: name, FirstName: "Иван"}); err != nil {
+			t.Fatalf("TouchUser: %v", err)
+		}
+	}
+	return path
+}
+
+// openWithKey reopens path and returns chat 1's username read with key.
+func openWithKey(t *testing.T, path, key string) (string, error) {
+	t.Helper()
+	store, err := storage.New(path, testLogger())
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	defer store.Close()
+	if err := store.SetEncryptionKey(key); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+	u, err := store.GetKnownUser(1)
+	return u.Identity.Username, err
+}
+
+func testLogger() *logger.Logger {
+	return logger.New().WithOutput(io.Discard)
+}
+
+func TestRotateKeyCommand(t *testing.T) {
+	path := fixtureDB(t, "old-secret")
+
+	code := runDBCommand([]string{"rotate-key", "--db", path, "--old", "old-secret", "--new", "new-secret", "--batch", "2"}, testLogger())
+	if code != 0 {
+		t.Fatalf("rotate-key exit code = %d, want 0", code)
+	}
+
+	if name, err := openWithKey(t, path, "new-secret"); err != nil || name != "rider42" {
+		t.Errorf("read with the new key = %q, %v; want rider42", name, err)
+	}
+	if _, err := openWithKey(t, path, "old-secret"); !errors.Is(err, storage.ErrEncryptionKey) {
+		t.Errorf("read with the old key: err = %v, want ErrEncryptionKey", err)
+	}
+}
+
+func TestRotateKeyCommandWrongOldKey(t *testing.T) {
+	path := fixtureDB(t, "old-secret")
+
+	code := runDBCommand([]string{"rotate-key", "--db", path, "--old", "guess", "--new", "new-secret"}, testLogger())
+	if code != 1 {
+		t.Fatalf("rotate-key with the wrong --old: exit code = %d, want 1", code)
+	}
+	// Nothing was rewritten with the new key.
+	if name, err := openWithKey(t, path, "old-secret"); err != nil || name != "rider42" {
+		t.Errorf("read with the old key = %q, %v; want rider42", name, err)
+	}
+}
+
+func TestRotateKeyCommandUsage(t *testing.T) {
+	path := fixtureDB(t, "secret")
+	for _, args := range [][]string{
+		{"rotate-key", "--db", path, "--old", "secret", "--new", "secret"},
+		{"rotate-key", "--unknown"},
+		{"unknown"},
+		{},
+	} {
+		if code := runDBCommand(args, testLogger()); code != 2 {
+			t.Errorf("runDBCommand(%q) = %d, want 2", args, code)
+		}
+	}
+}
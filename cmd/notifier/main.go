@@ -7,16 +7,21 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/thatguy/moto_gorod-notifier/internal/bot"
 	"github.com/thatguy/moto_gorod-notifier/internal/config"
 	"github.com/thatguy/moto_gorod-notifier/internal/logger"
 	"github.com/thatguy/moto_gorod-notifier/internal/metrics"
 	"github.com/thatguy/moto_gorod-notifier/internal/notifier"
+	"github.com/thatguy/moto_gorod-notifier/internal/startup"
+	"github.com/thatguy/moto_gorod-notifier/internal/status"
 	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+	"github.com/thatguy/moto_gorod-notifier/internal/version"
 	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
 )
 
@@ -27,7 +32,18 @@ func main() {
 		log = log.WithLevel(logger.LogLevel(level))
 	}
 
-	log.Info("Starting Moto Gorod Slot Notifier")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "db":
+			os.Exit(runDBCommand(os.Args[2:], log))
+		}
+	}
+
+	log.InfoWithFields("Starting Moto Gorod Slot Notifier", logger.Fields{
+		"version":    version.Version,
+		"commit":     version.Commit,
+		"build_date": version.BuildDate,
+	})
 
 	// Load configuration
 	cfg, err := config.Load()
@@ -38,12 +54,14 @@ func main() {
 
 	log.InfoWithFields("Configuration loaded successfully", logger.Fields{
 		"telegram_token_set": cfg.TelegramToken != "",
+		"dry_run":            cfg.DryRun,
 		"yclients_login_set":  cfg.YClientsLogin != "",
 		"company_id":          cfg.YClientsCompanyID,
 		"form_id":             cfg.YClientsFormID,
 		"timezone":            cfg.Timezone,
 		"poll_interval":       cfg.PollInterval.String(),
 		"service_ids":         cfg.ServiceIDs,
+		"lookahead_days":      cfg.LookaheadDays,
 	})
 
 	// Root context with graceful shutdown
@@ -57,73 +75,171 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Initialize metrics
+	metrics := metrics.New()
+
+	// Serve metrics and readiness while the remaining components start up
+	readiness := status.NewReadiness("storage", "telegram", "yclients")
+	go func() {
+		http.Handle("/metrics", metrics.Handler())
+		http.Handle("/readyz", readiness.Handler())
+		log.Info("Starting metrics server on :19092")
+		if err := http.ListenAndServe(":19092", nil); err != nil {
+			log.WithError(err).Error("Metrics server failed")
+		}
+	}()
+
 	// Initialize YCLIENTS client
-	yc := yclients.New(cfg.YClientsLogin, cfg.YClientsPassword, cfg.YClientsPartnerToken, cfg.YClientsCompanyID, cfg.YClientsFormID)
-	st := yc.GetStatus(ctx)
+	yc, err := yclients.New(yclients.Credentials{
+		Login:        cfg.YClientsLogin,
+		Password:     cfg.YClientsPassword,
+		PartnerToken: cfg.YClientsPartnerToken,
+		CompanyID:    cfg.YClientsCompanyID,
+		FormID:       cfg.YClientsFormID,
+	},
+		yclients.WithLogger(log.WithField("component", "yclients_client")),
+		yclients.WithRetryPolicy(cfg.YClientsRetryAttempts, yclients.DefaultRetryBaseDelay),
+		yclients.WithRateLimit(cfg.YClientsRateLimit, cfg.YClientsRateBurst),
+		yclients.WithTimeout(cfg.YClientsHTTPTimeout),
+		yclients.WithRequestTimeout(cfg.YClientsRequestTimeout),
+		yclients.WithTokenTTL(cfg.YClientsTokenTTL),
+		yclients.WithMetrics(metrics),
+	)
+	if err != nil {
+		log.WithError(err).Error("Failed to create YCLIENTS client")
+		os.Exit(1)
+	}
+	// A broken dump directory only disables debugging output
+	if cfg.YClientsDebugDumpDir != "" {
+		if err := yc.SetDumpDir(cfg.YClientsDebugDumpDir); err != nil {
+			log.WithError(err).Warn("YCLIENTS debug dump disabled")
+		} else {
+			log.WarnWithFields("YCLIENTS debug dump enabled, raw responses are written to disk", logger.Fields{
+				"dir": cfg.YClientsDebugDumpDir,
+			})
+		}
+	}
+	st := yc.GetStatus(ctx, false)
 	log.InfoWithFields("YCLIENTS client initialized", logger.Fields{
 		"auth_configured": st.AuthConfigured,
 		"company_id":      st.CompanyID,
 		"form_id":         st.FormID,
 		"notes":           st.Notes,
 	})
-	
-	// Test authentication immediately if we have service IDs
-	if len(cfg.ServiceIDs) > 0 {
-		log.Info("Testing YCLIENTS authentication...")
-		if _, err := yc.GetBookableStaffIDs(ctx, companyIDInt, cfg.ServiceIDs[0]); err != nil {
-			log.WithError(err).Error("YCLIENTS authentication test failed")
-			os.Exit(1)
-		}
-		log.Info("YCLIENTS authentication successful")
-	} else {
-		log.Warn("No service IDs configured, skipping authentication test")
-	}
 
-	// Initialize storage
-	store, err := storage.New("/data/notifier.db", log.WithField("component", "storage"))
-	if err != nil {
-		log.WithError(err).Error("Failed to initialize storage")
+	// Independent network and disk initializations run concurrently
+	var (
+		store *storage.Storage
+		api   *tgbotapi.BotAPI
+	)
+	group := startup.NewGroup(log.WithField("component", "startup"))
+	group.Go("yclients", func() error {
+		if err := checkCompanyForm(ctx, yc, cfg, log.WithField("component", "yclients_check")); err != nil {
+			return err
+		}
+		// Test authentication and connectivity immediately
+		probe := yc.GetStatus(ctx, true)
+		if !probe.AuthOK {
+			return fmt.Errorf("authentication test: %s", probe.LastError)
+		}
+		if probe.LastError != "" {
+			log.WarnWithFields("YCLIENTS probe failed after authentication", logger.Fields{
+				"reachable":  probe.Reachable,
+				"latency_ms": probe.LatencyMS,
+				"error":      probe.LastError,
+			})
+			return nil
+		}
+		log.InfoWithFields("YCLIENTS authentication successful", logger.Fields{"latency_ms": probe.LatencyMS})
+		return nil
+	})
+	group.Go("storage", func() error {
+		var err error
+		store, err = storage.New(defaultDBPath, log.WithField("component", "storage"), storage.WithMetrics(metrics))
+		if err != nil {
+			return err
+		}
+		store.SetNotificationRetention(cfg.NotificationRetention)
+		return store.SetEncryptionKey(cfg.StorageEncryptionKey)
+	})
+	group.Go("telegram", func() error {
+		var err error
+		api, err = tgbotapi.NewBotAPIWithClient(cfg.TelegramToken, tgbotapi.APIEndpoint, bot.NewHTTPClient(cfg.TelegramSendTimeout))
+		return err
+	})
+	if err := group.Wait(); err != nil {
+		log.WithError(err).Error("Failed to initialize components")
+		if store != nil {
+			store.Close()
+		}
 		os.Exit(1)
 	}
 	defer store.Close()
+	for _, name := range []string{"yclients", "storage", "telegram"} {
+		readiness.MarkReady(name)
+	}
 
 	// Show startup statistics
-	subscriberCount, seenSlotsCount, uniqueUsersCount, err := store.GetStats()
+	dbStats, err := store.DetailedStats(ctx, time.Now())
 	if err != nil {
 		log.WithError(err).Warn("Failed to get startup statistics")
 	} else {
 		log.InfoWithFields("Database statistics", logger.Fields{
-			"subscribers":   subscriberCount,
-			"seen_slots":    seenSlotsCount,
-			"unique_users":  uniqueUsersCount,
+			"subscribers":          dbStats.Subscribers,
+			"subscribers_added_7d": dbStats.SubscribersAdded7d,
+			"seen_slots":           dbStats.SeenSlots,
+			"unique_users":         dbStats.KnownUsers,
+			"file_size_bytes":      dbStats.FileSizeBytes,
 		})
 	}
 
-	// Initialize metrics
-	metrics := metrics.New()
-
 	// Initialize Telegram bot
-	tg, err := bot.New(cfg.TelegramToken, store, log.WithField("component", "telegram_bot"))
+	tg := bot.NewFromBotAPI(api, store, log.WithField("component", "telegram_bot"))
+	tg.SetMetrics(metrics)
+	tg.SetCurrentCooldown(cfg.CurrentCooldown)
+	tg.SetAdmins(cfg.AdminChatIDs)
+	tg.SetAccess(cfg.AllowedChatIDs, cfg.InviteCode)
+	tg.SetStartSubscribes(cfg.StartSubscribes)
+	tg.RegisterCommands()
+	botLoc, err := time.LoadLocation(cfg.Timezone)
 	if err != nil {
-		log.WithError(err).Error("Failed to initialize Telegram bot")
-		os.Exit(1)
+		botLoc = time.FixedZone("UTC+3", 3*3600)
+	}
+	tg.SetLocation(botLoc)
+	yc.SetLocation(botLoc)
+	if cfg.SilentHoursFrom != "" {
+		tg.SetSilentHours(cfg.SilentHoursFrom, cfg.SilentHoursTo)
+		log.InfoWithFields("Silent hours enabled", logger.Fields{
+			"from": cfg.SilentHoursFrom,
+			"to":   cfg.SilentHoursTo,
+		})
 	}
-	tg.SetMetrics(metrics)
 
-	// Update interface for all users on startup
-	if subscriberCount > 0 {
+	// Update interface for all users in the background; it is not needed to start polling
+	if dbStats.Subscribers > 0 {
 		log.InfoWithFields("Updating bot interface for existing users", logger.Fields{
-			"users_to_update": subscriberCount,
+			"users_to_update": dbStats.Subscribers,
 		})
-		tg.UpdateInterfaceForAll()
+		go tg.UpdateInterfaceForAll()
 	} else {
 		log.Info("No existing users to update")
 	}
 
-	// Set current slots handler
-	tg.SetCurrentSlotsHandler(func() ([]string, error) {
-		return getCurrentSlots(ctx, yc, companyIDInt, cfg.ServiceIDs, cfg.Timezone)
-	})
+	// Offer configured services in the settings menu
+	serviceOptions := make([]bot.ServiceOption, 0, len(cfg.ServiceIDs))
+	for _, id := range cfg.ServiceIDs {
+		name, ok := notifier.ServiceName(strconv.Itoa(id))
+		if !ok {
+			name = "#" + strconv.Itoa(id)
+		}
+		serviceOptions = append(serviceOptions, bot.ServiceOption{ID: id, Name: name})
+	}
+	tg.SetServiceOptions(serviceOptions)
+	go reconcileRemovedServices(store, tg, cfg, log.WithField("component", "services"))
+	tg.SetBookingURLs(cfg.BookingURL, cfg.BookingURLs)
+	notifier.SetStaffNames(cfg.StaffNames)
+	tg.SetDigestTime(cfg.DigestTime)
+	tg.SetReminderTime(cfg.ReminderTime)
 
 	// Initialize notifier
 	n := notifier.New(tg, yc, notifier.Options{
@@ -131,25 +247,87 @@ func main() {
 		Timezone:   cfg.Timezone,
 		LocationID: companyIDInt,
 		ServiceIDs: cfg.ServiceIDs,
+		LookaheadDays: cfg.LookaheadDays,
+		ScanConcurrency: cfg.ScanConcurrency,
+		StaffCacheTTL: cfg.StaffCacheTTL,
+		CurrentCacheTTL: cfg.CurrentCacheTTL,
+		IntervalJitter: cfg.PollJitter,
+		CheckTimeout: cfg.CheckTimeout,
+		StartupCheckDelay: cfg.StartupCheckDelay,
+		DryRun: cfg.DryRun,
+		DryRunPreview: cfg.DryRunAdminPreview,
+		TemplatesDir: cfg.TemplatesDir,
+		NotifySeatsDecrease: cfg.NotifySeatsDecrease,
+		NotifySlotTaken: cfg.NotifySlotTaken,
+		BulkMinSlots: cfg.BulkMinSlots,
+		BulkMinDates: cfg.BulkMinDates,
+		MaxSlotMessages: cfg.MaxSlotMessages,
+		DigestTime: cfg.DigestTime,
+		DigestSuggestAfter: cfg.DigestSuggestAfter,
+		ReminderTime: cfg.ReminderTime,
+		WeeklyReport: cfg.WeeklyReport,
+		WeeklyReportDay: cfg.WeeklyReportDay,
+		WeeklyReportTime: cfg.WeeklyReportTime,
+		Maintenance: cfg.DBMaintenance,
+		MaintenanceTime: cfg.DBMaintenanceTime,
 	}, store, log.WithField("component", "notifier"))
 	n.SetMetrics(metrics)
+	tg.SetTemplateManager(n)
+	tg.SetCurrentSlotsHandler(n.CurrentSlots)
+
+	// Warn admins when external templates shadow updated embedded ones
+	if diverged := n.DivergedTemplates(); len(diverged) > 0 {
+		tg.NotifyAdmins("⚠️ Внешние шаблоны отличаются от встроенных после обновления:\n\n• " +
+			strings.Join(diverged, "\n• ") + "\n\nЗаменить встроенной версией: /templates adopt <имя>")
+	}
 
 	// Set initial metrics from database stats
-	metrics.SetActiveSubscribers(float64(subscriberCount))
-	metrics.SetSeenSlotsTotal(float64(seenSlotsCount))
-	metrics.SetUniqueUsersTotal(float64(uniqueUsersCount))
+	metrics.SetActiveSubscribers(float64(dbStats.Subscribers))
+	metrics.SetSeenSlotsTotal(float64(dbStats.SeenSlots))
+	metrics.SetUniqueUsersTotal(float64(dbStats.KnownUsers))
 
-	// Start metrics HTTP server
-	go func() {
-		http.Handle("/metrics", metrics.Handler())
-		log.Info("Starting metrics server on :19092")
-		if err := http.ListenAndServe(":19092", nil); err != nil {
-			log.WithError(err).Error("Metrics server failed")
+	// Keep storage-backed gauges in sync with the database
+	go reconcileGauges(ctx, store, metrics, log)
+
+	// Assemble runtime status document
+	statusRegistry := status.NewRegistry()
+	statusRegistry.Register("build", status.ReporterFunc(func(context.Context) interface{} {
+		return status.BuildInfo()
+	}))
+	statusRegistry.Register("config", status.ReporterFunc(func(context.Context) interface{} {
+		return map[string]interface{}{
+			"hash":          cfg.Hash(),
+			"timezone":      cfg.Timezone,
+			"poll_interval": cfg.PollInterval.String(),
+			"service_ids":   cfg.ServiceIDs,
 		}
-	}()
+	}))
+	statusRegistry.Register("features", status.ReporterFunc(func(context.Context) interface{} {
+		return cfg.Features()
+	}))
+	statusRegistry.Register("notifier", n)
+	statusRegistry.Register("yclients", yc)
+	statusRegistry.Register("storage", store)
+	statusRegistry.Register("telegram", tg)
+	statusRegistry.Register("logging", log)
+
+	http.Handle("/api/status", statusRegistry.Handler())
+	http.Handle("/api/loglevel", log.LevelHandler(cfg.AdminAPIToken))
+	http.Handle("/healthz", status.HealthHandler(func() (bool, map[string]interface{}) {
+		h := n.Health()
+		details := map[string]interface{}{"paused": h.Paused, "last_error": h.LastError}
+		if !h.LastSuccess.IsZero() {
+			details["last_successful_check_at"] = h.LastSuccess.UTC().Format(time.RFC3339)
+		}
+		return h.Healthy, details
+	}))
 
 	// Set template renderer for bot
 	tg.SetTemplateRenderer(n)
+	tg.SetNotifierControl(n)
+	tg.SetMissedSlotsSource(n)
+	tg.SetBestTimeSource(n)
+	tg.SetReportSource(n)
 
 	// Start components with proper error handling and graceful shutdown
 	var wg sync.WaitGroup
@@ -167,6 +345,14 @@ func main() {
 		log.Info("Telegram bot stopped")
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tg.RunMaintenance(ctx)
+	}()
+
+	go reloadTemplatesOnSIGHUP(ctx, n, tg, log.WithField("component", "templates"))
+
 	log.Info("Starting notifier")
 	wg.Add(1)
 	go func() {
@@ -202,72 +388,3 @@ func main() {
 		log.Warn("Shutdown timeout reached, forcing exit")
 	}
 }
-
-func getCurrentSlots(ctx context.Context, yc *yclients.Client, locationID int, serviceIDs []int, timezone string) ([]string, error) {
-	loc, err := time.LoadLocation(timezone)
-	if err != nil {
-		loc = time.FixedZone("UTC+3", 3*3600)
-	}
-	
-	today := time.Now().In(loc).Format("2006-01-02")
-	const farFuture = "9999-01-01"
-	
-	var allSlots []string
-	
-	for _, serviceID := range serviceIDs {
-		staffIDs, err := yc.GetBookableStaffIDs(ctx, locationID, serviceID)
-		if err != nil {
-			continue
-		}
-		
-		for _, staffID := range staffIDs {
-			sid := staffID
-			dates, err := yc.GetBookableDates(ctx, locationID, serviceID, today, farFuture, &sid)
-			if err != nil {
-				continue
-			}
-			
-			for _, date := range dates {
-				times, err := yc.GetBookableTimeslots(ctx, locationID, serviceID, date, staffID)
-				if err != nil {
-					continue
-				}
-				
-				for _, timeSlot := range times {
-					t, err := time.Parse(time.RFC3339, timeSlot)
-					if err == nil {
-						tt := t.In(loc)
-						date := tt.Format("02.01.2006")
-						clock := tt.Format("15:04")
-						weekday := getRussianWeekday(tt.Weekday())
-						slot := fmt.Sprintf("📅 %s (%s) в %s - Сотрудник #%d", date, weekday, clock, staffID)
-						allSlots = append(allSlots, slot)
-					}
-				}
-			}
-		}
-	}
-	
-	return allSlots, nil
-}
-
-func getRussianWeekday(wd time.Weekday) string {
-	switch wd {
-	case time.Monday:
-		return "понедельник"
-	case time.Tuesday:
-		return "вторник"
-	case time.Wednesday:
-		return "среда"
-	case time.Thursday:
-		return "четверг"
-	case time.Friday:
-		return "пятница"
-	case time.Saturday:
-		return "суббота"
-	case time.Sunday:
-		return "воскресенье"
-	default:
-		return ""
-	}
-}
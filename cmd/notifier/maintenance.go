@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/metrics"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+// gaugeReconcileInterval is how often gauges are recomputed from storage.
+const gaugeReconcileInterval = time.Minute
+
+// reconcileGauges periodically resets storage-backed gauges to the values
+// in the database. Drift means some code path forgot to update a gauge, so
+// every correction is logged with its delta to help find it.
+func reconcileGauges(ctx context.Context, store *storage.Storage, m *metrics.Metrics, log *logger.Logger) {
+	ticker := time.NewTicker(gaugeReconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reconcileGaugesOnce(ctx, store, m, log)
+		}
+	}
+}
+
+// reconcileGaugesOnce runs one reconciliation pass.
+func reconcileGaugesOnce(ctx context.Context, store *storage.Storage, m *metrics.Metrics, log *logger.Logger) {
+	st, err := store.DetailedStats(ctx, time.Now())
+	if err != nil {
+		log.WithError(err).Warn("Failed to load stats for gauge reconciliation")
+		return
+	}
+	truth := map[string]int{
+		metrics.GaugeActiveSubscribers: st.Subscribers,
+		metrics.GaugeSeenSlots:         st.SeenSlots,
+		metrics.GaugeUniqueUsers:       st.KnownUsers,
+	}
+	for gauge, value := range truth {
+		delta, err := m.Reconcile(gauge, float64(value))
+		if err != nil {
+			log.WithError(err).WarnWithFields("Failed to reconcile gauge", logger.Fields{"gauge": gauge})
+			continue
+		}
+		if delta != 0 {
+			log.WarnWithFields("Gauge drifted from storage, corrected", logger.Fields{
+				"gauge": gauge,
+				"delta": delta,
+				"value": value,
+			})
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/metrics"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+// driftWarnings returns the fields of every drift warning in logs.
+func driftWarnings(t *testing.T, logs *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		if entry["message"] == "Gauge drifted from storage, corrected" {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+func corrections(t *testing.T, m *metrics.Metrics, gauge string) float64 {
+	t.Helper()
+	var metric dto.Metric
+	if err := m.GaugeCorrections.WithLabelValues(gauge).Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func TestReconcileGaugesCorrectsDrift(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.New(filepath.Join(t.TempDir(), "notifier.db"), testLogger())
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	defer store.Close()
+	for _, chatID := range []int64{1, 2} {
+		if err := store.AddSubscriber(ctx, chatID); err != nil {
+			t.Fatalf("AddSubscriber: %v", err)
+		}
+		if err := store.TouchUser(chatID, nil); err != nil {
+			t.Fatalf("TouchUser: %v", err)
+		}
+	}
+
+	m := metrics.NewWithRegistry(prometheus.NewRegistry())
+	// A code path that counted a subscription twice and missed the users.
+	m.SetActiveSubscribers(5)
+	var logs bytes.Buffer
+	log := logger.New().WithOutput(&logs)
+
+	reconcileGaugesOnce(ctx, store, m, log)
+
+	warnings := driftWarnings(t, &logs)
+	want := map[string]float64{metrics.GaugeActiveSubscribers: 3, metrics.GaugeUniqueUsers: -2}
+	if len(warnings) != len(want) {
+		t.Fatalf("%d drift warnings, want %d: %v", len(warnings), len(want), warnings)
+	}
+	for _, w := range warnings {
+		gauge, _ := w["gauge"].(string)
+		if w["level"] != "WARN" || w["delta"] != want[gauge] || w["value"] != float64(2) {
+			t.Errorf("warning %v, want gauge %s with delta %v and value 2", w, gauge, want[gauge])
+		}
+	}
+	for gauge, n := range map[string]float64{
+		metrics.GaugeActiveSubscribers: 1,
+		metrics.GaugeUniqueUsers:       1,
+		metrics.GaugeSeenSlots:         0,
+	} {
+		if got := corrections(t, m, gauge); got != n {
+			t.Errorf("%s corrections = %v, want %v", gauge, got, n)
+		}
+	}
+	var active dto.Metric
+	if err := m.ActiveSubscribers.Write(&active); err != nil {
+		t.Fatal(err)
+	}
+	if got := active.GetGauge().GetValue(); got != 2 {
+		t.Errorf("active subscribers gauge = %v, want 2", got)
+	}
+
+	// Once corrected, the next pass finds nothing to fix.
+	logs.Reset()
+	reconcileGaugesOnce(ctx, store, m, log)
+	if w := driftWarnings(t, &logs); len(w) != 0 {
+		t.Errorf("second pass warned %v", w)
+	}
+	if got := corrections(t, m, metrics.GaugeActiveSubscribers); got != 1 {
+		t.Errorf("active subscribers corrections = %v after the second pass, want 1", got)
+	}
+}
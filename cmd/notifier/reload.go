@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/notifier"
+)
+
+// reloadTemplatesOnSIGHUP re-reads message templates whenever the process
+// gets SIGHUP. Failures keep the previous templates and are sent to admins.
+func reloadTemplatesOnSIGHUP(ctx context.Context, n *notifier.Notifier, tg *bot.Bot, log *logger.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Info("Received SIGHUP, reloading templates")
+			if err := n.ReloadTemplates(); err != nil {
+				tg.NotifyAdmins("❌ Шаблоны не перезагружены по SIGHUP, используются прежние:\n\n" + err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/config"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+// reconcileRemovedServices handles services that are still referenced in
+// storage after being dropped from YCLIENTS_SERVICE_IDS: chats filtering on
+// them are told once (not in dry run), and their seen slots are purged if
+// configured.
+func reconcileRemovedServices(store *storage.Storage, tg *bot.Bot, cfg config.Config, log *logger.Logger) {
+	configured := make(map[int]bool, len(cfg.ServiceIDs))
+	for _, id := range cfg.ServiceIDs {
+		configured[id] = true
+	}
+
+	seenIDs, err := store.SeenSlotServiceIDs()
+	if err != nil {
+		log.WithError(err).Warn("Failed to list services of seen slots")
+	}
+	var orphanedSlots []int
+	for _, id := range seenIDs {
+		if !configured[id] {
+			orphanedSlots = append(orphanedSlots, id)
+		}
+	}
+
+	allPrefs, err := store.AllPreferences()
+	if err != nil {
+		log.WithError(err).Warn("Failed to scan preferences for removed services")
+	}
+	removed := make(map[int]bool)
+	for _, id := range orphanedSlots {
+		removed[id] = true
+	}
+	staleChats := make(map[int64][]int)
+	for chatID, prefs := range allPrefs {
+		for _, id := range prefs.ServiceIDs {
+			if !configured[id] {
+				staleChats[chatID] = append(staleChats[chatID], id)
+				removed[id] = true
+			}
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+
+	ids := make([]int, 0, len(removed))
+	for id := range removed {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	log.WarnWithFields("Services removed from config are still referenced in storage", logger.Fields{
+		"service_ids":      ids,
+		"with_seen_slots":  orphanedSlots,
+		"affected_filters": len(staleChats),
+	})
+
+	notified := 0
+	if cfg.DryRun {
+		staleChats = nil
+	}
+	for chatID, stale := range staleChats {
+		var fresh []int
+		for _, id := range stale {
+			first, err := store.MarkRemovedServiceNoticed(chatID, id)
+			if err != nil {
+				log.WithError(err).WarnWithFields("Failed to record removed service notice", logger.Fields{"chat_id": chatID})
+				continue
+			}
+			if first {
+				fresh = append(fresh, id)
+			}
+		}
+		if len(fresh) == 0 {
+			continue
+		}
+		if err := tg.NotifyRemovedServices(chatID, fresh); err != nil {
+			log.WithError(err).WarnWithFields("Failed to notify about removed services", logger.Fields{"chat_id": chatID})
+			continue
+		}
+		notified++
+	}
+	if notified > 0 {
+		log.InfoWithFields("Notified chats about removed services", logger.Fields{"chats": notified})
+	}
+
+	if cfg.PurgeRemovedServiceSlots && len(orphanedSlots) > 0 {
+		purged, err := store.PurgeSeenSlots(orphanedSlots)
+		if err != nil {
+			log.WithError(err).Warn("Failed to purge seen slots of removed services")
+			return
+		}
+		log.InfoWithFields("Purged seen slots of removed services", logger.Fields{
+			"service_ids": orphanedSlots,
+			"slots":       purged,
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot/bottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/config"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+const removedNoticeText = "⚠️ Бот больше не отслеживает услуги"
+
+// removedServicesEnv is a database with seen slots of services 1 and 2 and
+// filters on services 1 to 3, of which only 1 is still configured.
+func removedServicesEnv(t *testing.T) (*storage.Storage, *bot.Bot, *bottest.FakeAPI) {
+	t.Helper()
+	ctx := context.Background()
+	store, err := storage.New(filepath.Join(t.TempDir(), "notifier.db"), testLogger())
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	for i, serviceID := range []int{1, 1, 2, 2} {
+		slot := storage.ObservedSlot{Key: fmt.Sprintf("svc=%d|staff=1|dt=%d", serviceID, i), ServiceID: serviceID, StaffID: 1}
+		if err := store.MarkSlotSeen(ctx, slot); err != nil {
+			t.Fatalf("MarkSlotSeen: %v", err)
+		}
+	}
+	for chatID, services := range map[int64][]int{10: {1, 2}, 11: {1}, 12: {3}} {
+		if err := store.SetPreferences(chatID, storage.Preferences{ServiceIDs: services}); err != nil {
+			t.Fatalf("SetPreferences: %v", err)
+		}
+	}
+
+	api := bottest.NewFakeAPI()
+	tg := bot.NewWithAPI(api, store, testLogger())
+	tg.SetServiceOptions([]bot.ServiceOption{{ID: 1, Name: "Площадка"}})
+	return store, tg, api
+}
+
+func TestReconcileRemovedServicesNotifiesOnce(t *testing.T) {
+	store, tg, api := removedServicesEnv(t)
+	cfg := config.Config{ServiceIDs: []int{1}}
+
+	reconcileRemovedServices(store, tg, cfg, testLogger())
+
+	for chatID, want := range map[int64]string{10: "#2.", 12: "#3."} {
+		msgs := api.MessagesTo(chatID)
+		if len(msgs) != 1 || !strings.HasPrefix(msgs[0], removedNoticeText) || !strings.Contains(msgs[0], want) {
+			t.Errorf("chat %d got %q, want one notice about %s", chatID, msgs, want)
+		}
+	}
+	if msgs := api.MessagesTo(12); len(msgs) == 1 && !strings.Contains(msgs[0], "не совпадает ни с одной услугой") {
+		t.Errorf("chat 12 notice %q does not say its filter matches nothing", msgs[0])
+	}
+	if msgs := api.MessagesTo(11); len(msgs) != 0 {
+		t.Errorf("chat 11 with only tracked services got %q", msgs)
+	}
+
+	// A restart doesn't repeat the notice.
+	reconcileRemovedServices(store, tg, cfg, testLogger())
+	for _, chatID := range []int64{10, 12} {
+		if n := len(api.MessagesTo(chatID)); n != 1 {
+			t.Errorf("chat %d got %d notices after a restart, want 1", chatID, n)
+		}
+	}
+
+	// Without the purge flag the seen slots stay.
+	ids, err := store.SeenSlotServiceIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[1 2]" {
+		t.Errorf("services with seen slots = %v, want [1 2]", ids)
+	}
+}
+
+func TestReconcileRemovedServicesPurge(t *testing.T) {
+	store, tg, _ := removedServicesEnv(t)
+
+	reconcileRemovedServices(store, tg, config.Config{ServiceIDs: []int{1}, PurgeRemovedServiceSlots: true}, testLogger())
+
+	ids, err := store.SeenSlotServiceIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[1]" {
+		t.Errorf("services with seen slots = %v, want only the configured [1]", ids)
+	}
+}
+
+func TestReconcileRemovedServicesDryRun(t *testing.T) {
+	store, tg, api := removedServicesEnv(t)
+	cfg := config.Config{ServiceIDs: []int{1}, DryRun: true}
+
+	reconcileRemovedServices(store, tg, cfg, testLogger())
+	if sent := api.Sent(); len(sent) != 0 {
+		t.Fatalf("dry run sent %d messages", len(sent))
+	}
+
+	// Chats weren't told, so the first real run still tells them.
+	cfg.DryRun = false
+	reconcileRemovedServices(store, tg, cfg, testLogger())
+	if n := len(api.MessagesTo(10)); n != 1 {
+		t.Errorf("chat 10 got %d notices after the dry run, want 1", n)
+	}
+}
@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// SetAccess restricts the bot to the given chats and to users who send a
+// valid invite code. With no allowed chats and no code the bot stays open.
+func (b *Bot) SetAccess(allowedChatIDs []int64, inviteCode string) {
+	b.allowed = make(map[int64]bool, len(allowedChatIDs))
+	for _, id := range allowedChatIDs {
+		b.allowed[id] = true
+	}
+	b.inviteCode = strings.TrimSpace(inviteCode)
+}
+
+func (b *Bot) accessRestricted() bool {
+	return len(b.allowed) > 0 || b.inviteCode != ""
+}
+
+func (b *Bot) hasAccess(chatID int64) bool {
+	if !b.accessRestricted() || b.isAdmin(chatID) || b.allowed[chatID] {
+		return true
+	}
+	ok, err := b.storage.IsAuthorized(chatID)
+	if err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to check authorization")
+		return false
+	}
+	if ok {
+		return true
+	}
+	// Chats that subscribed before access was restricted keep working.
+	ctx, cancel := b.storageContext()
+	defer cancel()
+	subscribed, err := b.storage.IsSubscribed(ctx, chatID)
+	return err == nil && subscribed
+}
+
+// handleAccessRequest treats any text from an unauthorized user, including
+// the /start deep link payload, as an invite code attempt.
+func (b *Bot) handleAccessRequest(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	code := msg.Text
+	if msg.IsCommand() {
+		code = msg.CommandArguments()
+	}
+	code = strings.TrimSpace(code)
+
+	if code == "" {
+		b.reply(chatID, "🔒 Бот доступен только ученикам автошколы Мото Город.\n\nОтправьте код приглашения, чтобы продолжить.")
+		return
+	}
+
+	if !b.checkInviteCode(chatID, code) {
+		b.log.WarnWithFields("Invalid invite code", logger.Fields{
+			"chat_id":     chatID,
+			"code_prefix": inviteCodePrefix(code),
+		})
+		if b.metrics != nil {
+			b.metrics.RecordError("invite_code_rejected")
+		}
+		b.reply(chatID, "❌ Неверный или уже использованный код приглашения. Попробуйте ещё раз.")
+		return
+	}
+
+	if err := b.storage.Authorize(chatID, code); err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to authorize user")
+		b.reply(chatID, "❌ Не удалось проверить код, попробуйте позже")
+		return
+	}
+	b.log.InfoWithFields("User authorized with invite code", logger.Fields{
+		"chat_id":     chatID,
+		"code_prefix": inviteCodePrefix(code),
+	})
+	var username string
+	if msg.From != nil {
+		username = msg.From.UserName
+	}
+	b.subscribe(chatID, username)
+}
+
+func (b *Bot) checkInviteCode(chatID int64, code string) bool {
+	if b.inviteCode != "" && subtle.ConstantTimeCompare([]byte(code), []byte(b.inviteCode)) == 1 {
+		return true
+	}
+	ok, err := b.storage.RedeemInviteCode(code, chatID)
+	if err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to redeem invite code")
+		return false
+	}
+	return ok
+}
+
+func (b *Bot) handleInviteCommand(chatID int64) {
+	code, err := b.storage.CreateInviteCode(chatID)
+	if err != nil {
+		b.log.WithError(err).Error("Failed to create invite code")
+		b.reply(chatID, "❌ Не удалось создать код приглашения")
+		return
+	}
+	b.log.InfoWithFields("Invite code created", logger.Fields{
+		"chat_id":     chatID,
+		"code_prefix": inviteCodePrefix(code),
+	})
+
+	text := fmt.Sprintf("🎟 Одноразовый код приглашения: %s", code)
+	if b.username != "" {
+		text += fmt.Sprintf("\n\nСсылка: https://t.me/%s?start=%s", b.username, code)
+	}
+	// Not b.reply, which would log the whole code with the message.
+	_, err = b.send(tgbotapi.NewMessage(chatID, text))
+	b.recordSend(err)
+	if err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to send invite code")
+	}
+}
+
+// inviteCodePrefix returns enough of a code to tell attempts apart in the
+// logs without making the code usable.
+func inviteCodePrefix(code string) string {
+	r := []rune(code)
+	if len(r) <= 3 {
+		return strings.Repeat("*", len(r))
+	}
+	return string(r[:3]) + "…"
+}
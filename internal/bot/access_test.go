@@ -0,0 +1,46 @@
+package bot_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot/bottest"
+)
+
+func TestInviteCodesNotLogged(t *testing.T) {
+	e := newTestEnv(t, func(b *bot.Bot) {
+		b.SetAdmins([]int64{testAdminID})
+		b.SetAccess(nil, "STATICCODE1")
+	})
+
+	e.handle(t, bottest.NewCommand(testAdminID, "invite"))
+	reply := e.lastMessage(t, testAdminID)
+	const label = "код приглашения: "
+	i := strings.Index(reply, label)
+	if i < 0 {
+		t.Fatalf("invite reply = %q, want a code", reply)
+	}
+	code := strings.Fields(reply[i+len(label):])[0]
+
+	e.handle(t, bottest.NewText(testChatID, "WRONGCODE99"))
+	if e.subscribed(t, testChatID) {
+		t.Fatal("wrong code let the chat in")
+	}
+	e.handle(t, bottest.NewText(testChatID, code))
+	if !e.subscribed(t, testChatID) {
+		t.Fatal("issued code was rejected")
+	}
+
+	logs := e.logs.String()
+	for _, secret := range []string{code, "WRONGCODE99"} {
+		if strings.Contains(logs, secret) {
+			t.Errorf("logs contain the code %q", secret)
+		}
+	}
+	for _, prefix := range []string{code[:3] + "…", "WRO…"} {
+		if !strings.Contains(logs, prefix) {
+			t.Errorf("logs lack the code prefix %q", prefix)
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+	"github.com/thatguy/moto_gorod-notifier/internal/version"
+)
+
+// TemplateManager exposes external template maintenance to admin commands.
+type TemplateManager interface {
+	DivergedTemplates() []string
+	AdoptTemplate(name string) error
+	ReloadTemplates() error
+}
+
+// SetAdmins configures chats allowed to run admin commands and receive admin notices.
+func (b *Bot) SetAdmins(chatIDs []int64) {
+	b.admins = make(map[int64]bool, len(chatIDs))
+	for _, id := range chatIDs {
+		b.admins[id] = true
+	}
+}
+
+func (b *Bot) SetTemplateManager(tm TemplateManager) {
+	b.templateManager = tm
+}
+
+func (b *Bot) isAdmin(chatID int64) bool {
+	return b.admins[chatID]
+}
+
+// NotifyAdmins sends text to every configured admin chat.
+func (b *Bot) NotifyAdmins(text string) {
+	for chatID := range b.admins {
+		if _, err := b.send(tgbotapi.NewMessage(chatID, text)); err != nil {
+			b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to send admin notice")
+		}
+	}
+}
+
+// NotifierControl lets admins pause the notifier and trigger checks.
+type NotifierControl interface {
+	Paused() bool
+	SetPaused(paused bool)
+	TriggerCheck() (result <-chan CheckReport, queued bool)
+	Health() HealthView
+	Maintain(ctx context.Context) (storage.MaintenanceResult, error)
+}
+
+// HealthView describes whether availability checks keep succeeding.
+type HealthView struct {
+	Healthy     bool
+	Paused      bool
+	LastSuccess time.Time // zero when no check has succeeded yet
+	LastError   string    // empty after a successful check
+}
+
+// CheckReport summarizes a check run on request. Skipped means the
+// notifier is not configured to check anything.
+type CheckReport struct {
+	Skipped  bool
+	Duration time.Duration
+	NewSlots int
+	Errors   int
+}
+
+// Admin keyboard buttons, shown only to admin chats.
+const (
+	adminStatsButton  = "📊 Статистика"
+	adminPauseButton  = "⏸ Пауза"
+	adminResumeButton = "▶️ Возобновить"
+	adminCheckButton  = "🧪 Проверить сейчас"
+)
+
+// adminButtons maps admin keyboard buttons to the commands they run.
+var adminButtons = map[string]string{
+	adminStatsButton:  "stats",
+	adminPauseButton:  "pause",
+	adminResumeButton: "resume",
+	adminCheckButton:  "checknow",
+}
+
+func (b *Bot) SetNotifierControl(c NotifierControl) {
+	b.notifierControl = c
+}
+
+// handleAdminCommand processes admin-only commands and reports whether
+// the command was recognized.
+func (b *Bot) handleAdminCommand(msg *tgbotapi.Message) bool {
+	return b.runAdminCommand(msg.Chat.ID, msg.Command(), msg.CommandArguments())
+}
+
+// handleAdminButton processes admin keyboard buttons with the same
+// authorization as the slash commands, since a keyboard can leak to other
+// chats through forwarded messages.
+func (b *Bot) handleAdminButton(chatID int64, text string) bool {
+	command, ok := adminButtons[text]
+	if !ok {
+		return false
+	}
+	return b.runAdminCommand(chatID, command, "")
+}
+
+func (b *Bot) runAdminCommand(chatID int64, command, args string) bool {
+	switch command {
+	case "templates", "reload", "stats", "invite", "loglevel", "pause", "resume", "checknow", "report", "version", "diagnose", "maintain":
+	default:
+		return false
+	}
+
+	if !b.isAdmin(chatID) {
+		b.log.WarnWithFields("Admin command from non-admin chat", logger.Fields{
+			"chat_id": chatID,
+			"command": command,
+		})
+		b.sendHelpMessage(chatID)
+		return true
+	}
+
+	switch command {
+	case "templates":
+		b.handleTemplatesCommand(chatID, strings.Fields(args))
+	case "reload":
+		b.handleReloadCommand(chatID)
+	case "stats":
+		b.handleStatsCommand(chatID)
+	case "invite":
+		b.handleInviteCommand(chatID)
+	case "loglevel":
+		b.handleLogLevelCommand(chatID, strings.Fields(args))
+	case "pause", "resume":
+		b.handlePauseCommand(chatID, command == "pause")
+	case "checknow":
+		b.handleCheckNowCommand(chatID)
+	case "maintain":
+		b.handleMaintainCommand(chatID)
+	case "report":
+		b.handleReportCommand(chatID)
+	case "diagnose":
+		b.handleDiagnoseCommand(chatID, strings.TrimSpace(args))
+	case "version":
+		b.reply(chatID, fmt.Sprintf("🏷 Версия: %s\nКоммит: %s\nСборка: %s",
+			version.Version, version.ShortCommit(), firstNonEmpty(version.BuildDate, "неизвестно")))
+	}
+	return true
+}
+
+// adminKeyboardRow returns the extra keyboard row for admins; the pause
+// button reflects the notifier's current state.
+func (b *Bot) adminKeyboardRow() []tgbotapi.KeyboardButton {
+	pause := adminPauseButton
+	if b.notifierControl != nil && b.notifierControl.Paused() {
+		pause = adminResumeButton
+	}
+	return tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButton(adminStatsButton),
+		tgbotapi.NewKeyboardButton(pause),
+		tgbotapi.NewKeyboardButton(adminCheckButton),
+	)
+}
+
+func (b *Bot) handlePauseCommand(chatID int64, pause bool) {
+	if b.notifierControl == nil {
+		b.reply(chatID, "⚠️ Управление проверками недоступно")
+		return
+	}
+	b.notifierControl.SetPaused(pause)
+	b.log.WarnWithFields("Notifier pause toggled by admin", logger.Fields{
+		"chat_id": chatID,
+		"paused":  pause,
+	})
+
+	text := "▶️ Проверки возобновлены"
+	if pause {
+		text = "⏸ Проверки приостановлены. Уведомления не будут отправляться до возобновления."
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = b.createMainKeyboard(chatID)
+	if _, err := b.send(msg); err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to confirm pause state")
+	}
+}
+
+func (b *Bot) handleCheckNowCommand(chatID int64) {
+	if b.notifierControl == nil {
+		b.reply(chatID, "⚠️ Управление проверками недоступно")
+		return
+	}
+	result, queued := b.notifierControl.TriggerCheck()
+	if queued {
+		b.reply(chatID, "🧪 Проверка запущена")
+	} else {
+		b.reply(chatID, "⏳ Проверка уже запланирована, пришлю её результат")
+	}
+	go func() {
+		if report, ok := <-result; ok {
+			b.reply(chatID, formatCheckReport(report))
+		}
+	}()
+}
+
+// handleMaintainCommand runs database maintenance in the background and
+// replies with the outcome.
+func (b *Bot) handleMaintainCommand(chatID int64) {
+	if b.notifierControl == nil {
+		b.reply(chatID, "⚠️ Управление проверками недоступно")
+		return
+	}
+	b.reply(chatID, "🧹 Обслуживание базы данных запущено")
+	go func() {
+		res, err := b.notifierControl.Maintain(context.Background())
+		b.reply(chatID, formatMaintenance(res, err))
+	}()
+}
+
+func formatMaintenance(r storage.MaintenanceResult, err error) string {
+	sizes := fmt.Sprintf("Размер: %.1f → %.1f МБ", float64(r.SizeBefore)/(1<<20), float64(r.SizeAfter)/(1<<20))
+	if err != nil {
+		return fmt.Sprintf("❌ Обслуживание базы завершилось ошибкой за %s\n%s\n\n%s",
+			r.Duration.Round(100*time.Millisecond), sizes, err.Error())
+	}
+	vacuum := "не требовалось"
+	if r.Vacuumed {
+		vacuum = "выполнено"
+	}
+	return fmt.Sprintf("✅ Обслуживание базы завершено за %s\nЦелостность: %s\nСвободных страниц: %.0f%%, сжатие: %s\n%s",
+		r.Duration.Round(100*time.Millisecond), r.Integrity, r.FreeRatio*100, vacuum, sizes)
+}
+
+func formatCheckReport(r CheckReport) string {
+	if r.Skipped {
+		return "⚠️ Проверка пропущена: не настроены компания или услуги"
+	}
+	text := fmt.Sprintf("✅ Проверка завершена за %s\nНовых слотов: %d", r.Duration.Round(100*time.Millisecond), r.NewSlots)
+	if r.Errors > 0 {
+		text += fmt.Sprintf("\n⚠️ Ошибок запросов: %d", r.Errors)
+	}
+	return text
+}
+
+func (b *Bot) handleTemplatesCommand(chatID int64, args []string) {
+	if b.templateManager == nil {
+		b.reply(chatID, "⚠️ Управление шаблонами недоступно")
+		return
+	}
+
+	if len(args) == 2 && args[0] == "adopt" {
+		if err := b.templateManager.AdoptTemplate(args[1]); err != nil {
+			b.log.WithError(err).WithField("template", args[1]).Error("Failed to adopt template")
+			b.reply(chatID, fmt.Sprintf("❌ Не удалось обновить шаблон %s: %v", args[1], err))
+			return
+		}
+		b.reply(chatID, fmt.Sprintf("✅ Шаблон %s заменён встроенной версией", args[1]))
+		return
+	}
+
+	diverged := b.templateManager.DivergedTemplates()
+	if len(diverged) == 0 {
+		b.reply(chatID, "✅ Внешние шаблоны совпадают со встроенными")
+		return
+	}
+	b.reply(chatID, "⚠️ Внешние шаблоны отличаются от встроенных:\n\n• "+strings.Join(diverged, "\n• ")+
+		"\n\nЧтобы заменить шаблон встроенной версией: /templates adopt <имя>")
+}
+
+func (b *Bot) handleReloadCommand(chatID int64) {
+	if b.templateManager == nil {
+		b.reply(chatID, "⚠️ Управление шаблонами недоступно")
+		return
+	}
+	if err := b.templateManager.ReloadTemplates(); err != nil {
+		b.reply(chatID, fmt.Sprintf("❌ Шаблоны не перезагружены, используются прежние:\n\n%v", err))
+		return
+	}
+	b.reply(chatID, "✅ Шаблоны перезагружены")
+}
+
+func (b *Bot) handleStatsCommand(chatID int64) {
+	ctx, cancel := b.storageContext()
+	stats, err := b.storage.DetailedStats(ctx, b.now().In(b.loc))
+	cancel()
+	if err != nil {
+		b.log.WithError(err).Error("Failed to get stats")
+		b.reply(chatID, "❌ Не удалось получить статистику")
+		return
+	}
+	text := fmt.Sprintf("📊 Статистика\n\nПодписчиков: %d (+%d за 7 дней, +%d за 30 дней)\nОтписались и не вернулись: %d за 7 дней, %d за 30 дней\nПользователей всего: %d\nИзвестных слотов: %d",
+		stats.Subscribers, stats.SubscribersAdded7d, stats.SubscribersAdded30d, stats.Churned7d, stats.Churned30d, stats.KnownUsers, stats.SeenSlots)
+	ids := make([]int, 0, len(stats.SeenSlotsByService))
+	for id := range stats.SeenSlotsByService {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		text += fmt.Sprintf("\n  • %s: %d", b.serviceName(id), stats.SeenSlotsByService[id])
+	}
+	text += fmt.Sprintf("\nУведомлений сегодня: %d, за неделю: %d\nРазмер базы: %.1f МБ",
+		stats.NotificationsToday, stats.NotificationsThisWeek, float64(stats.FileSizeBytes)/(1<<20))
+	if st, err := b.storage.NotificationStats(b.now().Add(-24 * time.Hour)); err != nil {
+		b.log.WithError(err).Warn("Failed to get notification stats")
+	} else {
+		text += fmt.Sprintf("\n\nЗа сутки: отправлено %d уведомлений в %d чатов, ошибок доставки: %d",
+			st.Sent, st.Chats, st.Failed)
+	}
+	b.reply(chatID, text)
+}
+
+// handleLogLevelCommand shows or changes the runtime log level:
+// /loglevel DEBUG 30m switches to DEBUG and reverts after 30 minutes.
+func (b *Bot) handleLogLevelCommand(chatID int64, args []string) {
+	if len(args) == 0 {
+		b.reply(chatID, formatLevelStatus(b.log.LevelStatus(), b.loc)+"\n\nИзменить: /loglevel DEBUG [30m]")
+		return
+	}
+
+	var revertAfter time.Duration
+	if len(args) > 1 {
+		d, err := time.ParseDuration(args[1])
+		if err != nil || d <= 0 {
+			b.reply(chatID, "⚠️ Неверная длительность, примеры: 30m, 2h")
+			return
+		}
+		revertAfter = d
+	}
+
+	st, err := b.log.ChangeLevel(logger.LogLevel(args[0]), revertAfter)
+	if err != nil {
+		b.reply(chatID, "⚠️ Уровень должен быть одним из: DEBUG, INFO, WARN, ERROR")
+		return
+	}
+	b.log.WarnWithFields("Log level changed by admin", logger.Fields{
+		"chat_id":   chatID,
+		"level":     st.Level,
+		"revert_at": st.RevertAt,
+	})
+	b.reply(chatID, "✅ "+formatLevelStatus(st, b.loc))
+}
+
+func formatLevelStatus(st logger.LevelStatus, loc *time.Location) string {
+	text := fmt.Sprintf("Уровень логирования: %s", st.Level)
+	if st.RevertAt != nil {
+		text += fmt.Sprintf("\nВернётся к %s в %s", st.RevertTo, st.RevertAt.In(loc).Format("15:04 02.01"))
+	}
+	return text
+}
+
+// handleDiagnoseCommand shows what is stored about a chat, including its
+// identity change history: /diagnose <chat_id>.
+func (b *Bot) handleDiagnoseCommand(chatID int64, arg string) {
+	target, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		b.reply(chatID, "⚠️ Использование: /diagnose <chat_id>")
+		return
+	}
+
+	user, err := b.storage.GetKnownUser(target)
+	if errors.Is(err, sql.ErrNoRows) {
+		b.reply(chatID, fmt.Sprintf("🤷 Чат %d не найден", target))
+		return
+	}
+	if err != nil {
+		b.log.WithError(err).WithField("target_chat_id", target).Error("Failed to load known user")
+		b.reply(chatID, "❌ Не удалось получить данные пользователя")
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🔎 Чат %d\n\nUsername: %s\nИмя: %s\nПервый визит: %s\nПоследний визит: %s\n",
+		target, firstNonEmpty(user.Identity.Username, "—"), firstNonEmpty(user.Identity.FirstName, "—"),
+		user.FirstSeen.In(b.loc).Format("02.01.2006 15:04"), user.LastSeen.In(b.loc).Format("02.01.2006 15:04"))
+
+	if sub, err := b.storage.GetSubscription(target); err == nil {
+		view := b.subscriptionView(&sub)
+		if view.UntilDate {
+			fmt.Fprintf(&sb, "Подписка: до %s\n", view.ExpiresAt)
+		} else {
+			sb.WriteString("Подписка: без срока\n")
+		}
+	} else {
+		sb.WriteString("Подписка: нет\n")
+	}
+
+	changes, err := b.storage.UserChanges(target, 20)
+	if err != nil {
+		b.log.WithError(err).WithField("target_chat_id", target).Error("Failed to load user changes")
+	}
+	if len(changes) > 0 {
+		sb.WriteString("\nИстория изменений:")
+		for _, c := range changes {
+			fmt.Fprintf(&sb, "\n%s %s: %s → %s", c.ChangedAt.In(b.loc).Format("02.01.2006 15:04"), c.Field, c.OldValue, c.NewValue)
+		}
+	}
+	b.reply(chatID, sb.String())
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
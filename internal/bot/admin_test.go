@@ -0,0 +1,235 @@
+package bot_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot/bottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+const testAdminID = 42
+
+// fakeControl records what admin commands asked of the notifier.
+type fakeControl struct {
+	mu       sync.Mutex
+	paused   bool
+	setCalls int
+	checks   int
+}
+
+func (c *fakeControl) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+func (c *fakeControl) SetPaused(paused bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = paused
+	c.setCalls++
+}
+
+func (c *fakeControl) TriggerCheck() (<-chan bot.CheckReport, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks++
+	result := make(chan bot.CheckReport)
+	close(result)
+	return result, true
+}
+
+func (c *fakeControl) Health() bot.HealthView {
+	return bot.HealthView{Healthy: true, Paused: c.Paused()}
+}
+
+func (c *fakeControl) Maintain(ctx context.Context) (storage.MaintenanceResult, error) {
+	return storage.MaintenanceResult{}, nil
+}
+
+func newAdminEnv(t *testing.T) (*testEnv, *fakeControl) {
+	t.Helper()
+	control := &fakeControl{}
+	e := newTestEnv(t, func(b *bot.Bot) {
+		b.SetAdmins([]int64{testAdminID})
+		b.SetNotifierControl(control)
+	})
+	return e, control
+}
+
+// lastKeyboard returns the reply keyboard of the last message to chatID
+// that carried one, as rows of button labels.
+func (e *testEnv) lastKeyboard(t *testing.T, chatID int64) [][]string {
+	t.Helper()
+	sent := e.api.Sent()
+	for i := len(sent) - 1; i >= 0; i-- {
+		m, ok := sent[i].(tgbotapi.MessageConfig)
+		if !ok || m.ChatID != chatID {
+			continue
+		}
+		k, ok := m.ReplyMarkup.(tgbotapi.ReplyKeyboardMarkup)
+		if !ok {
+			continue
+		}
+		var rows [][]string
+		for _, row := range k.Keyboard {
+			var labels []string
+			for _, button := range row {
+				labels = append(labels, button.Text)
+			}
+			rows = append(rows, labels)
+		}
+		return rows
+	}
+	t.Fatalf("no keyboard sent to %d", chatID)
+	return nil
+}
+
+func hasButton(rows [][]string, label string) bool {
+	for _, row := range rows {
+		for _, l := range row {
+			if l == label {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestAdminKeyboardPauseLabelFollowsState(t *testing.T) {
+	e, control := newAdminEnv(t)
+
+	e.handle(t, bottest.NewCommand(testAdminID, "start"))
+	rows := e.lastKeyboard(t, testAdminID)
+	if !hasButton(rows, "📊 Статистика") || !hasButton(rows, "🧪 Проверить сейчас") {
+		t.Fatalf("admin keyboard %q lacks the admin row", rows)
+	}
+	if !hasButton(rows, "⏸ Пауза") || hasButton(rows, "▶️ Возобновить") {
+		t.Fatalf("running notifier: keyboard %q, want the pause button", rows)
+	}
+
+	e.handle(t, bottest.NewText(testAdminID, "⏸ Пауза"))
+	if !control.Paused() {
+		t.Fatal("pause button did not pause the notifier")
+	}
+	rows = e.lastKeyboard(t, testAdminID)
+	if !hasButton(rows, "▶️ Возобновить") || hasButton(rows, "⏸ Пауза") {
+		t.Fatalf("paused notifier: keyboard %q, want the resume button", rows)
+	}
+
+	e.handle(t, bottest.NewText(testAdminID, "▶️ Возобновить"))
+	if control.Paused() {
+		t.Fatal("resume button did not resume the notifier")
+	}
+	if rows = e.lastKeyboard(t, testAdminID); !hasButton(rows, "⏸ Пауза") {
+		t.Errorf("resumed notifier: keyboard %q, want the pause button", rows)
+	}
+
+	// A pause made elsewhere, e.g. with /pause, shows up on the next keyboard.
+	control.SetPaused(true)
+	e.handle(t, bottest.NewCommand(testAdminID, "help"))
+	if rows = e.lastKeyboard(t, testAdminID); !hasButton(rows, "▶️ Возобновить") {
+		t.Errorf("keyboard %q after an external pause, want the resume button", rows)
+	}
+}
+
+func TestAdminKeyboardHiddenFromOthers(t *testing.T) {
+	e, _ := newAdminEnv(t)
+
+	e.handle(t, bottest.NewCommand(testChatID, "start"))
+	rows := e.lastKeyboard(t, testChatID)
+	for _, label := range []string{"📊 Статистика", "⏸ Пауза", "▶️ Возобновить", "🧪 Проверить сейчас"} {
+		if hasButton(rows, label) {
+			t.Errorf("non-admin keyboard %q has %q", rows, label)
+		}
+	}
+}
+
+func TestLeakedAdminButtonsRejectedForNonAdmins(t *testing.T) {
+	e, control := newAdminEnv(t)
+	control.SetPaused(true)
+	setCalls := control.setCalls
+
+	for _, label := range []string{"📊 Статистика", "⏸ Пауза", "▶️ Возобновить", "🧪 Проверить сейчас"} {
+		e.handle(t, bottest.NewText(testChatID, label))
+		if got := e.lastMessage(t, testChatID); !strings.HasPrefix(got, "ℹ️ Доступные команды") {
+			t.Errorf("non-admin pressing %q got %q, want the help message", label, got)
+		}
+	}
+
+	control.mu.Lock()
+	defer control.mu.Unlock()
+	if control.setCalls != setCalls || !control.paused {
+		t.Error("a non-admin changed the pause state")
+	}
+	if control.checks != 0 {
+		t.Errorf("a non-admin triggered %d checks", control.checks)
+	}
+}
+
+// fakeTemplates is a template manager with a fixed set of diverged files.
+type fakeTemplates struct {
+	mu       sync.Mutex
+	diverged []string
+	adopted  []string
+}
+
+func (m *fakeTemplates) DivergedTemplates() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.diverged...)
+}
+
+func (m *fakeTemplates) AdoptTemplate(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, d := range m.diverged {
+		if d == name {
+			m.diverged = append(m.diverged[:i], m.diverged[i+1:]...)
+			m.adopted = append(m.adopted, name)
+			return nil
+		}
+	}
+	return bottest.ErrFake
+}
+
+func (m *fakeTemplates) ReloadTemplates() error { return nil }
+
+func TestTemplatesCommand(t *testing.T) {
+	templates := &fakeTemplates{diverged: []string{"no_slots.tmpl"}}
+	e := newTestEnv(t, func(b *bot.Bot) {
+		b.SetAdmins([]int64{testAdminID})
+		b.SetTemplateManager(templates)
+	})
+
+	e.handle(t, bottest.NewCommand(testAdminID, "templates"))
+	if got := e.lastMessage(t, testAdminID); !strings.Contains(got, "• no_slots.tmpl") || !strings.Contains(got, "/templates adopt") {
+		t.Errorf("/templates = %q, want the diverged template and the adopt hint", got)
+	}
+
+	e.handle(t, bottest.NewCommand(testAdminID, "templates", "adopt", "no_slots.tmpl"))
+	if got := e.lastMessage(t, testAdminID); !strings.HasPrefix(got, "✅ Шаблон no_slots.tmpl заменён") {
+		t.Errorf("adopt reply = %q, want a confirmation", got)
+	}
+	e.handle(t, bottest.NewCommand(testAdminID, "templates", "adopt", "missing.tmpl"))
+	if got := e.lastMessage(t, testAdminID); !strings.HasPrefix(got, "❌") {
+		t.Errorf("adopting an unknown template replied %q, want an error", got)
+	}
+	e.handle(t, bottest.NewCommand(testAdminID, "templates"))
+	if got := e.lastMessage(t, testAdminID); !strings.HasPrefix(got, "✅ Внешние шаблоны совпадают") {
+		t.Errorf("/templates after adopting = %q, want no divergences", got)
+	}
+
+	// Non-admins can't overwrite templates.
+	e.handle(t, bottest.NewCommand(testChatID, "templates", "adopt", "no_slots.tmpl"))
+	templates.mu.Lock()
+	defer templates.mu.Unlock()
+	if len(templates.adopted) != 1 {
+		t.Errorf("adopted %v, want only the admin's request", templates.adopted)
+	}
+}
@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// LinkStyle selects how per-item links are attached to a batched message.
+type LinkStyle int
+
+const (
+	// LinkButtons adds one URL button per item under the message.
+	LinkButtons LinkStyle = iota
+	// LinkInline turns each item line into an HTML link.
+	LinkInline
+)
+
+// MaxMessageEntities is how many formatting entities, links included,
+// Telegram accepts in one message.
+const MaxMessageEntities = 100
+
+const widgetButtonText = "🌐 Открыть виджет"
+
+// BatchItem is one line of a batched message.
+type BatchItem struct {
+	Text string
+	URL  string // empty means the item has no link of its own
+}
+
+// Batch is a list of items sent as one or more messages. Header opens the
+// first message and Footer closes the last one.
+type Batch struct {
+	Header      string
+	Items       []BatchItem
+	Footer      string
+	Style       LinkStyle
+	FallbackURL string
+}
+
+// BuildBatch splits batch into messages that each pass Validate: lines are
+// packed until the text, button or entity limit is reached and the rest
+// overflows into continuation messages. If per-item links would need more
+// messages than the plain list, or a single linked line can't fit at all,
+// the links are dropped and the last message gets one button opening
+// FallbackURL instead.
+func BuildBatch(chatID int64, batch Batch) []tgbotapi.MessageConfig {
+	linked := packBatch(chatID, batch, true)
+	plain := packBatch(chatID, batch, false)
+	if linked != nil && len(linked) <= len(plain) {
+		return linked
+	}
+	if batch.FallbackURL != "" && len(plain) > 0 {
+		last := &plain[len(plain)-1]
+		last.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL(widgetButtonText, batch.FallbackURL),
+		))
+	}
+	return plain
+}
+
+// packBatch lays items out into messages, with or without per-item links.
+// It returns nil when withLinks is set and some item can't fit even alone.
+func packBatch(chatID int64, batch Batch, withLinks bool) []tgbotapi.MessageConfig {
+	inline := withLinks && batch.Style == LinkInline
+	text := func(s string) string {
+		if inline {
+			return html.EscapeString(s)
+		}
+		return s
+	}
+	header, footer := text(batch.Header), text(batch.Footer)
+
+	var (
+		out     []tgbotapi.MessageConfig
+		lines   []string
+		buttons [][]tgbotapi.InlineKeyboardButton
+		size    int
+		links   int
+	)
+	linkCap := MaxKeyboardButtons
+	if inline {
+		linkCap = MaxMessageEntities
+	}
+	flush := func() {
+		msg := tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+		if inline {
+			msg.ParseMode = tgbotapi.ModeHTML
+		}
+		if len(buttons) > 0 {
+			msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+		}
+		out = append(out, msg)
+		lines, buttons, size, links = nil, nil, 0, 0
+	}
+	add := func(line string) {
+		if len(lines) > 0 {
+			size++ // line break
+		}
+		lines = append(lines, line)
+		size += utf8.RuneCountInString(line)
+	}
+	fits := func(line string, extraLinks int) bool {
+		n := utf8.RuneCountInString(line)
+		if len(lines) > 0 {
+			n++
+		}
+		return size+n <= MaxMessageLength && links+extraLinks <= linkCap
+	}
+
+	if header != "" {
+		add(header + "\n")
+	}
+	for _, item := range batch.Items {
+		line := text(item.Text)
+		hasLink := withLinks && item.URL != ""
+		if hasLink && inline {
+			line = `<a href="` + html.EscapeString(item.URL) + `">` + line + "</a>"
+		}
+		extra := 0
+		if hasLink {
+			extra = 1
+		}
+		if !fits(line, extra) {
+			if len(lines) == 0 || utf8.RuneCountInString(line) > MaxMessageLength {
+				if withLinks {
+					return nil
+				}
+				line = string([]rune(line)[:MaxMessageLength-1]) + "…"
+			}
+			if len(lines) > 0 {
+				flush()
+			}
+		}
+		add(line)
+		if hasLink {
+			links++
+			if !inline {
+				buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonURL(truncateRunes(item.Text, 64), item.URL),
+				))
+			}
+		}
+	}
+	if footer != "" {
+		line := "\n" + footer
+		if !fits(line, 0) && len(lines) > 0 {
+			flush()
+			line = footer
+		}
+		add(line)
+	}
+	if len(lines) > 0 {
+		flush()
+	}
+	return out
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+// NotifyBatch delivers batch to chatID, stopping at the first failed message.
+func (b *Bot) NotifyBatch(chatID int64, batch Batch) error {
+	for _, msg := range BuildBatch(chatID, batch) {
+		if err := b.deliver(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package bot
+
+// BestTimeSource renders the statistics of when new slots usually appear.
+type BestTimeSource interface {
+	BestTimeMessage() (string, error)
+}
+
+func (b *Bot) SetBestTimeSource(src BestTimeSource) {
+	b.bestTime = src
+}
+
+func (b *Bot) handleBestTime(chatID int64) {
+	if b.bestTime == nil {
+		b.reply(chatID, "⚠️ Статистика недоступна")
+		return
+	}
+	text, err := b.bestTime.BestTimeMessage()
+	if err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to build best time message")
+		b.reply(chatID, "❌ Не удалось получить статистику")
+		return
+	}
+	b.reply(chatID, text)
+}
@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DefaultBookingURL is used when no booking links are configured.
+const DefaultBookingURL = "https://n841217.yclients.com/"
+
+// SetBookingURLs configures booking links per service ID. fallback is used
+// for services without their own link; empty keeps DefaultBookingURL.
+func (b *Bot) SetBookingURLs(fallback string, byService map[int]string) {
+	if fallback != "" {
+		b.bookingURL = fallback
+	}
+	b.bookingURLs = byService
+}
+
+// BookingURL returns the booking link for a service.
+func (b *Bot) BookingURL(serviceID int) string {
+	if url, ok := b.bookingURLs[serviceID]; ok && url != "" {
+		return url
+	}
+	return b.bookingURL
+}
+
+func (b *Bot) handleBooking(chatID int64) {
+	options := b.bookingOptions()
+	if len(options) <= 1 {
+		url := b.bookingURL
+		if len(options) == 1 {
+			url = options[0].url
+		}
+		b.reply(chatID, "📝 Для записи перейдите по ссылке:\n\n"+url)
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, o := range options {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL(o.label, o.url),
+		))
+	}
+	msg := tgbotapi.NewMessage(chatID, "📝 Выберите услугу для записи:")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := b.send(msg); err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to send booking links")
+	}
+}
+
+type bookingOption struct {
+	label string
+	url   string
+}
+
+// bookingOptions groups configured services by booking link so that
+// services sharing a form appear as one button.
+func (b *Bot) bookingOptions() []bookingOption {
+	ids := b.serviceIDs()
+	for id := range b.bookingURLs {
+		if !containsInt(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	var (
+		urls   []string
+		labels = make(map[string][]string)
+	)
+	for _, id := range ids {
+		url := b.BookingURL(id)
+		if _, seen := labels[url]; !seen {
+			urls = append(urls, url)
+		}
+		labels[url] = append(labels[url], b.serviceName(id))
+	}
+
+	options := make([]bookingOption, 0, len(urls))
+	for _, url := range urls {
+		options = append(options, bookingOption{label: strings.Join(labels[url], ", "), url: url})
+	}
+	return options
+}
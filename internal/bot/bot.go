@@ -3,64 +3,163 @@ package bot
 import (
 	"context"
 	"strings"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
 )
 
+// TelegramAPI is the subset of *tgbotapi.BotAPI used by the bot.
+type TelegramAPI interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
+	StopReceivingUpdates()
+}
+
+var _ TelegramAPI = (*tgbotapi.BotAPI)(nil)
+
 // Bot wraps Telegram bot operations and stores subscriptions in database.
 type Bot struct {
-	api          *tgbotapi.BotAPI
-	log          *logger.Logger
-	currentSlotsFn func() ([]string, error)
-	bookingURL   string
+	api              TelegramAPI
+	username         string
+	log              *logger.Logger
+	currentSlotsFn   func(ctx context.Context) ([]slots.Slot, time.Time, error)
+	bookingURL       string
+	bookingURLs      map[int]string
 	templateRenderer TemplateRenderer
-	storage      Storage
-	metrics      MetricsRecorder
+	storage          Storage
+	metrics          MetricsRecorder
+	services         []ServiceOption
+	silentFrom       string
+	silentTo         string
+	loc              *time.Location
+	now              func() time.Time
+	admins           map[int64]bool
+	templateManager  TemplateManager
+	missedSlots      MissedSlotsSource
+	bestTime         BestTimeSource
+	report           ReportSource
+	notifierControl  NotifierControl
+	startSubscribes  bool
+	allowed          map[int64]bool
+	inviteCode       string
+	digestTime       string
+	reminderTime     string
+
+	pinnedMu   sync.RWMutex
+	pinnedText string
+
+	currentMu       sync.Mutex
+	currentCooldown time.Duration
+	currentReplies  map[int64]currentReply
+	currentPruned   time.Time
+
+	transport     *deadlineClient // nil unless built with NewHTTPClient
+	stateMu       sync.RWMutex
+	lastSendAt    time.Time
+	lastSendErr   string
+	lastSendErrAt time.Time
 }
 
 type MetricsRecorder interface {
 	RecordSubscription()
-	RecordUnsubscription()
-	RecordUniqueUser()
+	RecordUnsubscription(reason string)
 	RecordNotificationSent()
 	RecordError(errorType string)
 	SetActiveSubscribers(count float64)
 }
 
+// storageTimeout bounds a single subscription query made while handling an
+// update, so a locked database can't stall the update loop indefinitely.
+const storageTimeout = 5 * time.Second
+
 type Storage interface {
-	AddSubscriber(chatID int64) error
-	RemoveSubscriber(chatID int64) error
-	GetSubscribers() ([]int64, error)
-	IsSubscribed(chatID int64) (bool, error)
-	AddUniqueUser(chatID int64) error
+	AddSubscriber(ctx context.Context, chatID int64) error
+	RemoveSubscriber(ctx context.Context, chatID int64, reason string) error
+	GetSubscribers(ctx context.Context) ([]int64, error)
+	IsSubscribed(ctx context.Context, chatID int64) (bool, error)
+	TouchUser(chatID int64, identity *storage.UserIdentity) error
+	MigrateChat(oldChatID, newChatID int64) error
+	GetKnownUser(chatID int64) (storage.KnownUser, error)
+	UserChanges(chatID int64, limit int) ([]storage.UserChange, error)
+	DetailedStats(ctx context.Context, now time.Time) (storage.Stats, error)
+	GetPreferences(chatID int64) (storage.Preferences, error)
+	SetPreferences(chatID int64, prefs storage.Preferences) error
+	ResetPreferences(chatID int64) error
+	GetSubscription(chatID int64) (storage.Subscription, error)
+	SetSubscriptionExpiry(chatID int64, expiresAt *time.Time) error
+	SubscriptionsExpiringBefore(t time.Time) ([]storage.Subscription, error)
+	ExpiredSubscriptions(now time.Time) ([]storage.Subscription, error)
+	MarkExpiryWarned(chatID int64) error
+	IsAuthorized(chatID int64) (bool, error)
+	Authorize(chatID int64, code string) error
+	CreateInviteCode(createdBy int64) (string, error)
+	RedeemInviteCode(code string, chatID int64) (bool, error)
+	SetPinnedMessage(chatID int64, messageID int) error
+	GetPinnedMessage(chatID int64) (int, error)
+	GetPinnedMessages() (map[int64]int, error)
+	RemovePinnedMessage(chatID int64) error
+	RecentNotifications(chatID int64, limit int) ([]storage.NotificationRecord, error)
+	NotificationStats(since time.Time) (storage.NotificationStats, error)
+	LastUnsubscribedAt(chatID int64) (time.Time, bool, error)
+	AddDailyStat(t time.Time, name string, value float64) error
 }
 
+// TemplateRenderer renders user-facing messages. On error the bot falls
+// back to its built-in texts.
 type TemplateRenderer interface {
-	GetWelcomeMessage() string
-	GetGoodbyeMessage() string
-	GetCurrentSlotsMessage(slots []string) string
+	GetWelcomeMessage(view WelcomeView) (string, error)
+	GetGoodbyeMessage() (string, error)
+	GetCurrentSlotsMessage(available []slots.Slot) (string, error)
+	GetSettingsMessage(view SettingsView) (string, error)
+	GetStatusMessage(view SubscriptionView) (string, error)
+	GetSubscriptionExpiringMessage(view SubscriptionView) (string, error)
+	GetSubscriptionExpiredMessage() (string, error)
 }
 
 func New(token string, storage Storage, log *logger.Logger) (*Bot, error) {
-	api, err := tgbotapi.NewBotAPI(token)
+	api, err := tgbotapi.NewBotAPIWithClient(token, tgbotapi.APIEndpoint, NewHTTPClient(DefaultSendTimeout))
 	if err != nil {
 		return nil, err
 	}
-	
-	bot := &Bot{
-		api:         api,
-		log:         log,
-		bookingURL:  "https://n841217.yclients.com/",
-		storage:     storage,
+	return NewFromBotAPI(api, storage, log), nil
+}
+
+// NewFromBotAPI wraps an already authenticated client, letting callers run
+// the GetMe round trip concurrently with other initialization.
+func NewFromBotAPI(api *tgbotapi.BotAPI, storage Storage, log *logger.Logger) *Bot {
+	bot := NewWithAPI(api, storage, log)
+	bot.username = api.Self.UserName
+	if c, ok := api.Client.(*deadlineClient); ok {
+		bot.transport = c
 	}
-	
+
 	bot.log.InfoWithFields("Telegram bot initialized", logger.Fields{
-		"bot_username": api.Self.UserName,
-		"bot_id":       api.Self.ID,
+		"bot_username":    api.Self.UserName,
+		"bot_id":          api.Self.ID,
+		"library_version": LibraryVersion(),
 	})
-	
-	return bot, nil
+
+	return bot
+}
+
+// NewWithAPI creates a bot on top of an existing Telegram API implementation.
+func NewWithAPI(api TelegramAPI, storage Storage, log *logger.Logger) *Bot {
+	return &Bot{
+		api:             api,
+		log:             log,
+		bookingURL:      DefaultBookingURL,
+		storage:         storage,
+		currentCooldown: DefaultCurrentCooldown,
+		digestTime:      DefaultDigestTime,
+		reminderTime:    DefaultReminderTime,
+		loc:             time.UTC,
+		now:             time.Now,
+	}
 }
 
 func (b *Bot) Run(ctx context.Context) {
@@ -87,53 +186,82 @@ func (b *Bot) Run(ctx context.Context) {
 			if upd.Message != nil {
 				b.handleMessage(upd.Message)
 			}
+			if upd.CallbackQuery != nil {
+				b.handleCallback(upd.CallbackQuery)
+			}
 		}
 	}
 }
 
 func (b *Bot) handleMessage(msg *tgbotapi.Message) {
 	chatID := msg.Chat.ID
-	username := msg.From.UserName
-	firstName := msg.From.FirstName
+	if msg.MigrateToChatID != 0 {
+		b.migrateChat(chatID, msg.MigrateToChatID)
+		return
+	}
+
+	// From is empty for channel posts
+	var identity *storage.UserIdentity
+	var username, firstName string
+	if msg.From != nil {
+		username, firstName = msg.From.UserName, msg.From.FirstName
+		identity = &storage.UserIdentity{Username: username, FirstName: firstName}
+	}
 	text := msg.Text
+	access := b.hasAccess(chatID)
 
-	b.log.InfoWithFields("Received message", logger.Fields{
-		"text":       text,
+	fields := logger.Fields{
 		"chat_id":    chatID,
 		"username":   username,
 		"first_name": firstName,
-	})
+	}
+	// Without access the text is an invite code attempt, which
+	// handleAccessRequest logs by prefix only.
+	if access {
+		fields["text"] = text
+	}
+	b.log.InfoWithFields("Received message", fields)
+
+	if err := b.storage.TouchUser(chatID, identity); err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Warn("Failed to record user activity")
+	}
+
+	if !access {
+		b.handleAccessRequest(msg)
+		return
+	}
 
 	// Handle commands
 	if msg.IsCommand() {
+		if b.handleAdminCommand(msg) {
+			return
+		}
 		command := msg.Command()
 		switch command {
 		case "start":
-			// Record unique user on first interaction
-			if err := b.storage.AddUniqueUser(chatID); err == nil {
-				if b.metrics != nil {
-					b.metrics.RecordUniqueUser()
-				}
+			if b.startSubscribes {
+				b.subscribe(chatID, username)
+			} else {
+				b.sendWelcomeMessage(chatID)
 			}
-			b.addSubscriber(chatID)
-			subsCount := len(b.Subscribers())
-			b.log.InfoWithFields("User subscribed", logger.Fields{
-				"chat_id":           chatID,
-				"username":          username,
-				"total_subscribers": subsCount,
-			})
-			b.sendWelcomeMessage(chatID)
+		case "subscribe":
+			b.subscribe(chatID, username)
 		case "current":
 			b.handleCurrentSlots(chatID)
-		case "stop":
-			b.removeSubscriber(chatID)
-			subsCount := len(b.Subscribers())
-			b.log.InfoWithFields("User unsubscribed", logger.Fields{
-				"chat_id":           chatID,
-				"username":          username,
-				"total_subscribers": subsCount,
-			})
-			b.sendGoodbyeMessage(chatID)
+		case "settings":
+			b.handleSettings(chatID)
+		case "status":
+			b.handleStatus(chatID)
+		case "pin":
+			b.handlePinCommand(chatID)
+		case "history":
+			b.handleHistory(chatID)
+		case "besttime":
+			b.handleBestTime(chatID)
+		case "until":
+			b.handleUntilCommand(chatID, msg.CommandArguments())
+		case "stop", "unsubscribe":
+			b.unsubscribe(chatID, username)
 
 		default:
 			b.sendHelpMessage(chatID)
@@ -147,30 +275,87 @@ func (b *Bot) handleMessage(msg *tgbotapi.Message) {
 		b.handleCurrentSlots(chatID)
 	case "📝 Записаться":
 		b.handleBooking(chatID)
+	case "⚙️ Настройки":
+		b.handleSettings(chatID)
 	case "🔔 Подписаться":
-		b.addSubscriber(chatID)
-		subsCount := len(b.Subscribers())
-		b.log.InfoWithFields("User subscribed via button", logger.Fields{
-			"chat_id":           chatID,
-			"total_subscribers": subsCount,
-		})
-		b.sendWelcomeMessage(chatID)
+		b.subscribe(chatID, username)
 	case "🔕 Отписаться":
-		b.removeSubscriber(chatID)
-		subsCount := len(b.Subscribers())
+		if err := b.removeSubscriber(chatID, storage.UnsubscribeByUser); err != nil {
+			b.reply(chatID, unsubscribeFailedText)
+			return
+		}
+		subsCount := b.subscriberCount()
 		b.log.InfoWithFields("User unsubscribed via button", logger.Fields{
 			"chat_id":           chatID,
 			"total_subscribers": subsCount,
 		})
 		b.sendGoodbyeMessage(chatID)
 	default:
+		if b.handleAdminButton(chatID, text) {
+			return
+		}
 		b.sendHelpMessage(chatID)
 	}
 }
 
+// migrateChat follows a group chat that Telegram upgraded to a supergroup.
+func (b *Bot) migrateChat(oldChatID, newChatID int64) {
+	if err := b.storage.MigrateChat(oldChatID, newChatID); err != nil {
+		b.log.WithError(err).ErrorWithFields("Failed to migrate chat", logger.Fields{
+			"old_chat_id": oldChatID,
+			"new_chat_id": newChatID,
+		})
+		return
+	}
+	b.log.InfoWithFields("Chat migrated", logger.Fields{
+		"old_chat_id": oldChatID,
+		"new_chat_id": newChatID,
+	})
+}
+
+func (b *Bot) subscribe(chatID int64, username string) {
+	ctx, cancel := b.storageContext()
+	wasSubscribed, _ := b.storage.IsSubscribed(ctx, chatID)
+	cancel()
+	var missed *MissedSlots
+	if !wasSubscribed {
+		missed = b.missedSinceUnsubscribe(chatID)
+	}
+	b.addSubscriber(chatID)
+	subsCount := b.subscriberCount()
+	b.log.InfoWithFields("User subscribed", logger.Fields{
+		"chat_id":           chatID,
+		"username":          username,
+		"total_subscribers": subsCount,
+	})
+	b.sendWelcome(chatID, missed)
+	if !wasSubscribed {
+		b.offerUntilDate(chatID)
+	}
+}
+
+// unsubscribeFailedText is the reply when the database refuses an unsubscription.
+const unsubscribeFailedText = "❌ Не удалось отписаться. Попробуйте ещё раз чуть позже."
+
+func (b *Bot) unsubscribe(chatID int64, username string) {
+	if err := b.removeSubscriber(chatID, storage.UnsubscribeByUser); err != nil {
+		b.reply(chatID, unsubscribeFailedText)
+		return
+	}
+	subsCount := b.subscriberCount()
+	b.log.InfoWithFields("User unsubscribed", logger.Fields{
+		"chat_id":           chatID,
+		"username":          username,
+		"total_subscribers": subsCount,
+	})
+	b.sendGoodbyeMessage(chatID)
+}
+
 func (b *Bot) reply(chatID int64, text string) {
 	msg := tgbotapi.NewMessage(chatID, text)
-	if _, err := b.api.Send(msg); err != nil {
+	_, err := b.send(msg)
+	b.recordSend(err)
+	if err != nil {
 		b.log.WithError(err).WithFields(logger.Fields{
 			"chat_id": chatID,
 			"message": text,
@@ -183,45 +368,86 @@ func (b *Bot) reply(chatID int64, text string) {
 	}
 }
 
+// replyLong sends text split into as many messages as Telegram's length
+// limit requires.
+func (b *Bot) replyLong(chatID int64, text string) {
+	for _, part := range SplitMessage(text, MaxMessageLength) {
+		b.reply(chatID, part)
+	}
+}
+
 func (b *Bot) addSubscriber(chatID int64) {
-	if err := b.storage.AddSubscriber(chatID); err != nil {
+	ctx, cancel := b.storageContext()
+	defer cancel()
+	if err := b.storage.AddSubscriber(ctx, chatID); err != nil {
 		b.log.WithError(err).Error("Failed to add subscriber")
 		if b.metrics != nil {
 			b.metrics.RecordError("subscription_failed")
 		}
 	} else {
+		b.addDailyStat(storage.StatSubscriptions)
 		if b.metrics != nil {
 			b.metrics.RecordSubscription()
-			b.metrics.SetActiveSubscribers(float64(len(b.Subscribers())))
+			if count := b.subscriberCount(); count >= 0 {
+				b.metrics.SetActiveSubscribers(float64(count))
+			}
 		}
 	}
 }
 
-func (b *Bot) removeSubscriber(chatID int64) {
-	if err := b.storage.RemoveSubscriber(chatID); err != nil {
-		b.log.WithError(err).Error("Failed to remove subscriber")
+// removeSubscriber unsubscribes chatID. The error is already logged; callers
+// use it to avoid confirming an unsubscription that didn't happen.
+func (b *Bot) removeSubscriber(chatID int64, reason string) error {
+	ctx, cancel := b.storageContext()
+	defer cancel()
+	if err := b.storage.RemoveSubscriber(ctx, chatID, reason); err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to remove subscriber")
 		if b.metrics != nil {
 			b.metrics.RecordError("unsubscription_failed")
 		}
-	} else {
-		if b.metrics != nil {
-			b.metrics.RecordUnsubscription()
-			b.metrics.SetActiveSubscribers(float64(len(b.Subscribers())))
+		return err
+	}
+	b.addDailyStat(storage.StatUnsubscriptions)
+	if b.metrics != nil {
+		b.metrics.RecordUnsubscription(reason)
+		if count := b.subscriberCount(); count >= 0 {
+			b.metrics.SetActiveSubscribers(float64(count))
 		}
 	}
+	return nil
+}
+
+// storageContext returns the context for a storage call made while
+// handling an update.
+func (b *Bot) storageContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), storageTimeout)
+}
+
+// Subscribers returns the subscribed chats. An error must not be taken for
+// an empty list: that would silently skip every subscriber.
+func (b *Bot) Subscribers() ([]int64, error) {
+	ctx, cancel := b.storageContext()
+	defer cancel()
+	return b.storage.GetSubscribers(ctx)
 }
 
-func (b *Bot) Subscribers() []int64 {
-	subscribers, err := b.storage.GetSubscribers()
+// subscriberCount returns the number of subscribers for logs and metrics,
+// or -1 when it can't be read.
+func (b *Bot) subscriberCount() int {
+	subscribers, err := b.Subscribers()
 	if err != nil {
 		b.log.WithError(err).Error("Failed to get subscribers")
-		return []int64{}
+		return -1
 	}
-	return subscribers
+	return len(subscribers)
 }
 
 func (b *Bot) UpdateInterfaceForAll() {
-	subscribers := b.Subscribers()
+	subscribers, err := b.Subscribers()
+	if err != nil {
+		b.log.WithError(err).Error("Failed to get subscribers, skipping interface update")
+		return
+	}
 	
 	for _, chatID := range subscribers {
 		keyboard := b.createMainKeyboard(chatID)
@@ -230,7 +456,7 @@ func (b *Bot) UpdateInterfaceForAll() {
 		msg := tgbotapi.NewMessage(chatID, "⚡")
 		msg.ReplyMarkup = keyboard
 		
-		sentMsg, err := b.api.Send(msg)
+		sentMsg, err := b.send(msg)
 		if err != nil {
 			b.log.WithError(err).WithFields(logger.Fields{
 				"chat_id": chatID,
@@ -240,7 +466,7 @@ func (b *Bot) UpdateInterfaceForAll() {
 		
 		// Immediately delete the message
 		deleteMsg := tgbotapi.NewDeleteMessage(chatID, sentMsg.MessageID)
-		b.api.Request(deleteMsg)
+		b.request(deleteMsg)
 		
 		b.log.InfoWithFields("Interface silently updated", logger.Fields{
 			"chat_id": chatID,
@@ -253,15 +479,22 @@ func (b *Bot) UpdateInterfaceForAll() {
 }
 
 func (b *Bot) Notify(chatID int64, text string) error {
-	msg := tgbotapi.NewMessage(chatID, text)
-	_, err := b.api.Send(msg)
+	return b.deliver(tgbotapi.NewMessage(chatID, text))
+}
+
+// deliver sends a notification, honoring silent hours and recording the outcome.
+func (b *Bot) deliver(msg tgbotapi.MessageConfig) error {
+	chatID, text := msg.ChatID, msg.Text
+	msg.DisableNotification = b.isSilentNow()
+	_, err := b.send(msg)
+	b.recordSend(err)
 	if err != nil {
 		b.log.WithError(err).WithFields(logger.Fields{
 			"chat_id": chatID,
 			"message": text,
 		}).Error("Failed to send notification")
 		if b.metrics != nil {
-			b.metrics.RecordError("notification_failed")
+			b.metrics.RecordError(notificationErrorType(err))
 		}
 	} else {
 		b.log.InfoWithFields("Notification sent", logger.Fields{
@@ -274,7 +507,9 @@ func (b *Bot) Notify(chatID int64, text string) error {
 	return err
 }
 
-func (b *Bot) SetCurrentSlotsHandler(fn func() ([]string, error)) {
+// SetCurrentSlotsHandler sets the source of /current: it returns the
+// bookable slots and when they were fetched.
+func (b *Bot) SetCurrentSlotsHandler(fn func(ctx context.Context) ([]slots.Slot, time.Time, error)) {
 	b.currentSlotsFn = fn
 }
 
@@ -282,42 +517,92 @@ func (b *Bot) SetTemplateRenderer(renderer TemplateRenderer) {
 	b.templateRenderer = renderer
 }
 
+// rendered passes through a TemplateRenderer result; ok is false when
+// rendering failed and the caller should use its built-in text. The
+// renderer has already logged the failure.
+func (b *Bot) rendered(text string, err error) (string, bool) {
+	if err != nil {
+		b.log.WithError(err).Warn("Using built-in message text")
+		return "", false
+	}
+	return text, true
+}
+
 func (b *Bot) SetMetrics(metrics MetricsRecorder) {
 	b.metrics = metrics
 }
 
+// SetStartSubscribes restores the legacy behavior where /start also subscribes.
+func (b *Bot) SetStartSubscribes(enabled bool) {
+	b.startSubscribes = enabled
+}
+
+// RegisterCommands publishes the user-facing command list to Telegram.
+func (b *Bot) RegisterCommands() {
+	cfg := tgbotapi.NewSetMyCommands(
+		tgbotapi.BotCommand{Command: "start", Description: "Показать меню"},
+		tgbotapi.BotCommand{Command: "subscribe", Description: "Подписаться на уведомления"},
+		tgbotapi.BotCommand{Command: "unsubscribe", Description: "Отписаться от уведомлений"},
+		tgbotapi.BotCommand{Command: "current", Description: "Текущие свободные слоты"},
+		tgbotapi.BotCommand{Command: "settings", Description: "Настройки уведомлений"},
+		tgbotapi.BotCommand{Command: "status", Description: "Статус подписки"},
+		tgbotapi.BotCommand{Command: "pin", Description: "Закрепить обновляемый список слотов"},
+		tgbotapi.BotCommand{Command: "history", Description: "Последние уведомления"},
+		tgbotapi.BotCommand{Command: "besttime", Description: "Когда чаще появляются слоты"},
+	)
+	if _, err := b.request(cfg); err != nil {
+		b.log.WithError(err).Warn("Failed to register bot commands")
+	}
+}
+
 func (b *Bot) sendWelcomeMessage(chatID int64) {
-	var text string
+	b.sendWelcome(chatID, nil)
+}
+
+// sendWelcome sends the welcome message, mentioning slots a returning
+// subscriber missed when missed is set.
+func (b *Bot) sendWelcome(chatID int64, missed *MissedSlots) {
+	ctx, cancel := b.storageContext()
+	subscribed, err := b.storage.IsSubscribed(ctx, chatID)
+	cancel()
+	if err != nil {
+		b.log.WithError(err).Error("Failed to check subscription status")
+	}
+
+	text := "🚗 Привет! Я бот автошколы Мото Город.\n\n🔕 Вы не подписаны на уведомления. Отправьте /subscribe, чтобы подписаться."
+	if subscribed {
+		text = "🚗 Привет! Я бот автошколы Мото Город.\n\n✅ Вы подписаны на уведомления."
+	}
 	if b.templateRenderer != nil {
-		text = b.templateRenderer.GetWelcomeMessage()
-	} else {
-		text = "🚗 Привет! Я бот автошколы Мото Город."
+		if rendered, ok := b.rendered(b.templateRenderer.GetWelcomeMessage(WelcomeView{Subscribed: subscribed, Missed: missed})); ok {
+			text = rendered
+		}
 	}
 	keyboard := b.createMainKeyboard(chatID)
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ReplyMarkup = keyboard
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 func (b *Bot) sendGoodbyeMessage(chatID int64) {
-	var text string
+	text := "👋 Подписка отменена."
 	if b.templateRenderer != nil {
-		text = b.templateRenderer.GetGoodbyeMessage()
-	} else {
-		text = "👋 Подписка отменена."
+		if rendered, ok := b.rendered(b.templateRenderer.GetGoodbyeMessage()); ok {
+			text = rendered
+		}
 	}
 	keyboard := b.createMainKeyboard(chatID)
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ReplyMarkup = keyboard
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 func (b *Bot) sendHelpMessage(chatID int64) {
-	text := "ℹ️ Доступные команды:\n\n/start - подписаться на уведомления\n/current - показать текущие слоты\n/stop - отписаться от уведомлений"
+	text := "ℹ️ Доступные команды:\n\n/start - показать меню\n/subscribe - подписаться на уведомления\n/unsubscribe - отписаться от уведомлений\n/current - показать текущие слоты\n/settings - настройки уведомлений\n/status - статус подписки\n/until ДД.ММ.ГГГГ - подписка до даты\n/pin - закрепить обновляемый список слотов\n/history - последние уведомления\n/besttime - когда чаще появляются слоты"
 	keyboard := b.createMainKeyboard(chatID)
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ReplyMarkup = keyboard
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 func (b *Bot) handleCurrentSlots(chatID int64) {
@@ -326,26 +611,41 @@ func (b *Bot) handleCurrentSlots(chatID int64) {
 		return
 	}
 
-	slots, err := b.currentSlotsFn()
+	if text, ok := b.cachedCurrent(chatID); ok {
+		b.log.DebugWithFields("Serving cached current slots", logger.Fields{"chat_id": chatID})
+		b.replyLong(chatID, text+"\n\n⏳ Это результат недавней проверки. Подождите немного, чтобы получить обновлённый список.")
+		return
+	}
+
+	available, fetchedAt, err := b.currentSlotsFn(context.Background())
 	if err != nil {
 		b.log.WithError(err).Error("Failed to get current slots")
 		b.reply(chatID, "❌ Ошибка при получении информации о слотах")
 		return
 	}
 
-	var text string
+	text := "😔 В данный момент свободных слотов нет"
+	if len(available) > 0 {
+		lines := make([]string, 0, len(available))
+		for _, s := range available {
+			lines = append(lines, s.Start.Format("02.01.2006 15:04"))
+		}
+		text = "📅 Доступные слоты:\n\n" + strings.Join(lines, "\n")
+	}
 	if b.templateRenderer != nil {
-		text = b.templateRenderer.GetCurrentSlotsMessage(slots)
-	} else if len(slots) == 0 {
-		text = "😔 В данный момент свободных слотов нет"
-	} else {
-		text = "📅 Доступные слоты:\n\n" + strings.Join(slots, "\n")
+		if rendered, ok := b.rendered(b.templateRenderer.GetCurrentSlotsMessage(available)); ok {
+			text = rendered
+		}
 	}
-	b.reply(chatID, text)
+	text += b.currentAgeNote(fetchedAt)
+	b.rememberCurrent(chatID, text)
+	b.replyLong(chatID, text)
 }
 
 func (b *Bot) createMainKeyboard(chatID int64) tgbotapi.ReplyKeyboardMarkup {
-	isSubscribed, err := b.storage.IsSubscribed(chatID)
+	ctx, cancel := b.storageContext()
+	isSubscribed, err := b.storage.IsSubscribed(ctx, chatID)
+	cancel()
 	if err != nil {
 		b.log.WithError(err).Error("Failed to check subscription status")
 		isSubscribed = false
@@ -358,18 +658,19 @@ func (b *Bot) createMainKeyboard(chatID int64) tgbotapi.ReplyKeyboardMarkup {
 		subscriptionText = "🔔 Подписаться"
 	}
 
-	return tgbotapi.NewReplyKeyboard(
+	rows := [][]tgbotapi.KeyboardButton{
 		tgbotapi.NewKeyboardButtonRow(
 			tgbotapi.NewKeyboardButton("📅 Текущие слоты"),
 			tgbotapi.NewKeyboardButton("📝 Записаться"),
 		),
 		tgbotapi.NewKeyboardButtonRow(
 			tgbotapi.NewKeyboardButton(subscriptionText),
+			tgbotapi.NewKeyboardButton("⚙️ Настройки"),
 		),
-	)
+	}
+	if b.isAdmin(chatID) {
+		rows = append(rows, b.adminKeyboardRow())
+	}
+	return tgbotapi.NewReplyKeyboard(rows...)
 }
 
-func (b *Bot) handleBooking(chatID int64) {
-	text := "📝 Для записи перейдите по ссылке:\n\n" + b.bookingURL
-	b.reply(chatID, text)
-}
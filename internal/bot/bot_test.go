@@ -0,0 +1,338 @@
+package bot_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot/bottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+const (
+	testChatID = 1001
+	// markerChatID asks for help after every update so a test can tell
+	// when the update loop has handled what came before.
+	markerChatID = 9999
+)
+
+type testEnv struct {
+	b     *bot.Bot
+	api   *bottest.FakeAPI
+	store *failingStorage
+	logs  *logBuffer
+}
+
+// logBuffer collects the bot's log output; the update loop writes to it
+// while tests read it.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// failingStorage is the database with GetSubscribers and RemoveSubscriber
+// failing on demand.
+type failingStorage struct {
+	*storage.Storage
+	SubscribersErr error
+
+	mu        sync.Mutex
+	RemoveErr error
+}
+
+func (s *failingStorage) setRemoveErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.RemoveErr = err
+}
+
+func (s *failingStorage) RemoveSubscriber(ctx context.Context, chatID int64, reason string) error {
+	s.mu.Lock()
+	err := s.RemoveErr
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.Storage.RemoveSubscriber(ctx, chatID, reason)
+}
+
+func (s *failingStorage) GetSubscribers(ctx context.Context) ([]int64, error) {
+	if s.SubscribersErr != nil {
+		return nil, s.SubscribersErr
+	}
+	return s.Storage.GetSubscribers(ctx)
+}
+
+// newTestEnv starts a bot on a fake Telegram API and a database in a
+// temporary directory.
+func newTestEnv(t *testing.T, configure ...func(*bot.Bot)) *testEnv {
+	t.Helper()
+	logs := &logBuffer{}
+	log := logger.New().WithLevel(logger.DebugLevel).WithOutput(logs)
+	db, err := storage.New(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	store := &failingStorage{Storage: db}
+	api := bottest.NewFakeAPI()
+	b := bot.NewWithAPI(api, store, log)
+	for _, fn := range configure {
+		fn(b)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		db.Close()
+	})
+	return &testEnv{b: b, api: api, store: store, logs: logs}
+}
+
+// handle delivers upd and waits until the bot has processed it.
+func (e *testEnv) handle(t *testing.T, upd tgbotapi.Update) {
+	t.Helper()
+	before := len(e.api.MessagesTo(markerChatID))
+	e.api.Push(upd)
+	e.api.Push(bottest.NewCommand(markerChatID, "help"))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(e.api.MessagesTo(markerChatID)) == before {
+		if time.Now().After(deadline) {
+			t.Fatal("update was not handled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (e *testEnv) subscribed(t *testing.T, chatID int64) bool {
+	t.Helper()
+	ok, err := e.store.IsSubscribed(context.Background(), chatID)
+	if err != nil {
+		t.Fatalf("IsSubscribed: %v", err)
+	}
+	return ok
+}
+
+// lastMessage returns the last text sent to chatID.
+func (e *testEnv) lastMessage(t *testing.T, chatID int64) string {
+	t.Helper()
+	msgs := e.api.MessagesTo(chatID)
+	if len(msgs) == 0 {
+		t.Fatalf("nothing sent to %d", chatID)
+	}
+	return msgs[len(msgs)-1]
+}
+
+func TestSubscribeCommand(t *testing.T) {
+	e := newTestEnv(t)
+
+	e.handle(t, bottest.NewCommand(testChatID, "subscribe"))
+
+	if !e.subscribed(t, testChatID) {
+		t.Fatal("chat not subscribed")
+	}
+	msgs := e.api.MessagesTo(testChatID)
+	if len(msgs) != 2 {
+		t.Fatalf("sent %d messages, want the welcome and the term offer: %q", len(msgs), msgs)
+	}
+	if !strings.Contains(msgs[0], "Вы подписаны") {
+		t.Errorf("welcome = %q, want it to confirm the subscription", msgs[0])
+	}
+}
+
+func TestSubscribeTwiceOffersTermOnce(t *testing.T) {
+	e := newTestEnv(t)
+
+	e.handle(t, bottest.NewCommand(testChatID, "subscribe"))
+	e.handle(t, bottest.NewCommand(testChatID, "subscribe"))
+
+	if got := len(e.api.MessagesTo(testChatID)); got != 3 {
+		t.Errorf("sent %d messages, want two welcomes and one term offer", got)
+	}
+}
+
+func TestStartDoesNotSubscribe(t *testing.T) {
+	e := newTestEnv(t)
+
+	e.handle(t, bottest.NewCommand(testChatID, "start"))
+
+	if e.subscribed(t, testChatID) {
+		t.Error("/start subscribed the chat")
+	}
+	if msg := e.lastMessage(t, testChatID); !strings.Contains(msg, "/subscribe") {
+		t.Errorf("welcome = %q, want it to point to /subscribe", msg)
+	}
+}
+
+func TestStartSubscribesWhenEnabled(t *testing.T) {
+	e := newTestEnv(t, func(b *bot.Bot) { b.SetStartSubscribes(true) })
+
+	e.handle(t, bottest.NewCommand(testChatID, "start"))
+
+	if !e.subscribed(t, testChatID) {
+		t.Error("/start did not subscribe the chat")
+	}
+}
+
+func TestUnsubscribeCommands(t *testing.T) {
+	for _, command := range []string{"stop", "unsubscribe"} {
+		t.Run(command, func(t *testing.T) {
+			e := newTestEnv(t)
+			e.handle(t, bottest.NewCommand(testChatID, "subscribe"))
+
+			e.handle(t, bottest.NewCommand(testChatID, command))
+
+			if e.subscribed(t, testChatID) {
+				t.Error("chat still subscribed")
+			}
+			if msg := e.lastMessage(t, testChatID); !strings.Contains(msg, "Подписка отменена") {
+				t.Errorf("reply = %q, want the goodbye message", msg)
+			}
+			if _, ok, err := e.store.LastUnsubscribedAt(testChatID); err != nil || !ok {
+				t.Errorf("unsubscription not recorded: ok %v, err %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestSubscriptionButtons(t *testing.T) {
+	e := newTestEnv(t)
+
+	e.handle(t, bottest.NewText(testChatID, "🔔 Подписаться"))
+	if !e.subscribed(t, testChatID) {
+		t.Fatal("subscribe button did not subscribe")
+	}
+
+	e.handle(t, bottest.NewText(testChatID, "🔕 Отписаться"))
+	if e.subscribed(t, testChatID) {
+		t.Error("unsubscribe button did not unsubscribe")
+	}
+	if msg := e.lastMessage(t, testChatID); !strings.Contains(msg, "Подписка отменена") {
+		t.Errorf("reply = %q, want the goodbye message", msg)
+	}
+}
+
+func TestUnknownInputGetsHelp(t *testing.T) {
+	for name, upd := range map[string]tgbotapi.Update{
+		"command": bottest.NewCommand(testChatID, "nonsense"),
+		"text":    bottest.NewText(testChatID, "привет"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			e := newTestEnv(t)
+			e.handle(t, upd)
+			if msg := e.lastMessage(t, testChatID); !strings.Contains(msg, "Доступные команды") {
+				t.Errorf("reply = %q, want the help message", msg)
+			}
+			if e.subscribed(t, testChatID) {
+				t.Error("unknown input subscribed the chat")
+			}
+		})
+	}
+}
+
+func TestCurrentWithoutHandler(t *testing.T) {
+	e := newTestEnv(t)
+
+	e.handle(t, bottest.NewCommand(testChatID, "current"))
+
+	if msg := e.lastMessage(t, testChatID); !strings.Contains(msg, "недоступна") {
+		t.Errorf("reply = %q, want the unavailable notice", msg)
+	}
+}
+
+func TestChatMigration(t *testing.T) {
+	e := newTestEnv(t)
+	e.handle(t, bottest.NewCommand(testChatID, "subscribe"))
+
+	const newChatID = -1001234
+	e.handle(t, tgbotapi.Update{Message: &tgbotapi.Message{
+		Chat:            &tgbotapi.Chat{ID: testChatID},
+		MigrateToChatID: newChatID,
+	}})
+
+	if e.subscribed(t, testChatID) || !e.subscribed(t, newChatID) {
+		t.Error("subscription did not follow the migrated chat")
+	}
+}
+
+func TestNotify(t *testing.T) {
+	e := newTestEnv(t)
+
+	if err := e.b.Notify(testChatID, "🟢 Новый слот"); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if msgs := e.api.MessagesTo(testChatID); len(msgs) != 1 || msgs[0] != "🟢 Новый слот" {
+		t.Errorf("sent %q", msgs)
+	}
+}
+
+func TestNotifyReturnsSendError(t *testing.T) {
+	e := newTestEnv(t)
+	blocked := errors.New("Forbidden: bot was blocked by the user")
+	e.api.FailChats = map[int64]error{testChatID: blocked}
+
+	if err := e.b.Notify(testChatID, "🟢 Новый слот"); !errors.Is(err, blocked) {
+		t.Errorf("Notify to a blocked chat: err = %v, want %v", err, blocked)
+	}
+	if err := e.b.Notify(testChatID+1, "🟢 Новый слот"); err != nil {
+		t.Errorf("Notify to another chat: %v", err)
+	}
+}
+
+func TestSubscribersReturnsStorageError(t *testing.T) {
+	e := newTestEnv(t)
+	e.handle(t, bottest.NewCommand(testChatID, "subscribe"))
+
+	got, err := e.b.Subscribers()
+	if err != nil || len(got) != 1 || got[0] != testChatID {
+		t.Fatalf("Subscribers = %v, %v; want [%d]", got, err, testChatID)
+	}
+
+	e.store.SubscribersErr = errors.New("database is locked")
+	if _, err := e.b.Subscribers(); err == nil {
+		t.Error("Subscribers hid the storage error")
+	}
+}
+
+func TestSettingsPause(t *testing.T) {
+	e := newTestEnv(t)
+
+	e.handle(t, bottest.NewCallback(testChatID, 1, "set:pause:8"))
+	prefs, err := e.store.GetPreferences(testChatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prefs.Muted(time.Now().Add(7*time.Hour)) || prefs.Muted(time.Now().Add(9*time.Hour)) {
+		t.Errorf("MutedUntil = %s, want about 8 hours from now", prefs.MutedUntil)
+	}
+
+	e.handle(t, bottest.NewCallback(testChatID, 1, "set:pause:off"))
+	if prefs, _ := e.store.GetPreferences(testChatID); prefs.Muted(time.Now()) {
+		t.Error("pause was not lifted")
+	}
+}
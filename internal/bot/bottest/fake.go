@@ -0,0 +1,163 @@
+// Package bottest provides an in-memory Telegram API for exercising the bot
+// without a real token.
+package bottest
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+)
+
+// ErrFake is returned by FakeAPI when a failure is injected.
+var ErrFake = errors.New("bottest: injected failure")
+
+// FakeAPI implements bot.TelegramAPI, recording every outgoing call.
+type FakeAPI struct {
+	mu         sync.Mutex
+	sent       []tgbotapi.Chattable
+	requests   []tgbotapi.Chattable
+	nextID     int
+	updates    chan tgbotapi.Update
+	stopped    bool
+	SendErr    error
+	RequestErr error
+	// FailChats makes Send fail for messages addressed to these chats.
+	FailChats map[int64]error
+}
+
+var _ bot.TelegramAPI = (*FakeAPI)(nil)
+
+func NewFakeAPI() *FakeAPI {
+	return &FakeAPI{updates: make(chan tgbotapi.Update, 100)}
+}
+
+func (f *FakeAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	chatID := chatIDOf(c)
+	if err, ok := f.FailChats[chatID]; ok {
+		return tgbotapi.Message{}, err
+	}
+	if f.SendErr != nil {
+		return tgbotapi.Message{}, f.SendErr
+	}
+	f.sent = append(f.sent, c)
+	f.nextID++
+	return tgbotapi.Message{MessageID: f.nextID, Chat: &tgbotapi.Chat{ID: chatID}}, nil
+}
+
+func (f *FakeAPI) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.RequestErr != nil {
+		return nil, f.RequestErr
+	}
+	f.requests = append(f.requests, c)
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (f *FakeAPI) GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
+	return f.updates
+}
+
+func (f *FakeAPI) StopReceivingUpdates() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+}
+
+// Push delivers an update to the bot's update loop.
+func (f *FakeAPI) Push(upd tgbotapi.Update) {
+	f.updates <- upd
+}
+
+// Sent returns a copy of all successfully sent chattables.
+func (f *FakeAPI) Sent() []tgbotapi.Chattable {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]tgbotapi.Chattable(nil), f.sent...)
+}
+
+// Requests returns a copy of all successful Request calls.
+func (f *FakeAPI) Requests() []tgbotapi.Chattable {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]tgbotapi.Chattable(nil), f.requests...)
+}
+
+// MessagesTo returns texts of plain messages sent to chatID.
+func (f *FakeAPI) MessagesTo(chatID int64) []string {
+	var out []string
+	for _, c := range f.Sent() {
+		if m, ok := c.(tgbotapi.MessageConfig); ok && m.ChatID == chatID {
+			out = append(out, m.Text)
+		}
+	}
+	return out
+}
+
+// Stopped reports whether StopReceivingUpdates was called.
+func (f *FakeAPI) Stopped() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stopped
+}
+
+func chatIDOf(c tgbotapi.Chattable) int64 {
+	switch m := c.(type) {
+	case tgbotapi.MessageConfig:
+		return m.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return m.ChatID
+	case tgbotapi.DeleteMessageConfig:
+		return m.ChatID
+	}
+	return 0
+}
+
+// NewCommand builds an update carrying a /command message from chatID,
+// followed by args separated by spaces.
+func NewCommand(chatID int64, command string, args ...string) tgbotapi.Update {
+	text := "/" + command
+	length := len(text)
+	if len(args) > 0 {
+		text += " " + strings.Join(args, " ")
+	}
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		Text: text,
+		Chat: &tgbotapi.Chat{ID: chatID},
+		From: &tgbotapi.User{ID: chatID},
+		Entities: []tgbotapi.MessageEntity{{
+			Type:   "bot_command",
+			Offset: 0,
+			Length: length,
+		}},
+	}}
+}
+
+// NewText builds an update carrying a plain text message from chatID.
+func NewText(chatID int64, text string) tgbotapi.Update {
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		Text: text,
+		Chat: &tgbotapi.Chat{ID: chatID},
+		From: &tgbotapi.User{ID: chatID},
+	}}
+}
+
+// NewCallback builds an update for an inline button with data pressed by
+// chatID on message messageID.
+func NewCallback(chatID int64, messageID int, data string) tgbotapi.Update {
+	return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:   "cb",
+		From: &tgbotapi.User{ID: chatID},
+		Message: &tgbotapi.Message{
+			MessageID: messageID,
+			Chat:      &tgbotapi.Chat{ID: chatID},
+		},
+		Data: data,
+	}}
+}
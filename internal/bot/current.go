@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultCurrentCooldown limits how often one chat can request current slots.
+const DefaultCurrentCooldown = 30 * time.Second
+
+// currentFreshAge is how old /current data may be before the reply says
+// when it was fetched.
+const currentFreshAge = 5 * time.Second
+
+// currentReply is the last /current answer sent to a chat.
+type currentReply struct {
+	at   time.Time
+	text string
+}
+
+// SetCurrentCooldown sets the minimum interval between /current scans per
+// chat; zero disables throttling.
+func (b *Bot) SetCurrentCooldown(d time.Duration) {
+	b.currentMu.Lock()
+	defer b.currentMu.Unlock()
+	b.currentCooldown = d
+}
+
+// cachedCurrent returns the previous reply to chatID if it is still within
+// the cooldown, pruning expired entries along the way.
+func (b *Bot) cachedCurrent(chatID int64) (string, bool) {
+	b.currentMu.Lock()
+	defer b.currentMu.Unlock()
+
+	if b.currentCooldown <= 0 {
+		return "", false
+	}
+	now := b.now()
+	if now.Sub(b.currentPruned) > b.currentCooldown {
+		for id, r := range b.currentReplies {
+			if now.Sub(r.at) >= b.currentCooldown {
+				delete(b.currentReplies, id)
+			}
+		}
+		b.currentPruned = now
+	}
+
+	r, ok := b.currentReplies[chatID]
+	if !ok || now.Sub(r.at) >= b.currentCooldown {
+		return "", false
+	}
+	return r.text, true
+}
+
+// currentAgeNote tells the user when the listed slots were fetched if
+// they came from an earlier scan.
+func (b *Bot) currentAgeNote(fetchedAt time.Time) string {
+	if fetchedAt.IsZero() || b.now().Sub(fetchedAt) < currentFreshAge {
+		return ""
+	}
+	return fmt.Sprintf("\n\n🕒 Данные на %s", fetchedAt.In(b.loc).Format("15:04:05"))
+}
+
+func (b *Bot) rememberCurrent(chatID int64, text string) {
+	b.currentMu.Lock()
+	defer b.currentMu.Unlock()
+
+	if b.currentCooldown <= 0 {
+		return
+	}
+	if b.currentReplies == nil {
+		b.currentReplies = make(map[int64]currentReply)
+	}
+	b.currentReplies[chatID] = currentReply{at: b.now(), text: text}
+}
@@ -0,0 +1,63 @@
+package bot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// DefaultDigestTime is when daily digests are delivered unless configured.
+const DefaultDigestTime = "19:00"
+
+// DefaultReminderTime is when evening reminders about tomorrow's slots are sent.
+const DefaultReminderTime = "20:00"
+
+const digestPrefix = "digest:"
+
+// SetDigestTime sets the "HH:MM" delivery time shown to users.
+func (b *Bot) SetDigestTime(hhmm string) {
+	if hhmm != "" {
+		b.digestTime = hhmm
+	}
+}
+
+// SetReminderTime sets the "HH:MM" evening reminder time shown to users.
+func (b *Bot) SetReminderTime(hhmm string) {
+	if hhmm != "" {
+		b.reminderTime = hhmm
+	}
+}
+
+// NotifyWithDigestOffer sends a notification with a button that switches
+// the chat to digest mode.
+func (b *Bot) NotifyWithDigestOffer(chatID int64, text string) error {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📬 Включить дайджест", digestPrefix+"on"),
+		),
+	)
+	return b.deliver(msg)
+}
+
+func (b *Bot) handleDigestCallback(chatID int64, messageID int, data string) {
+	if data != "on" {
+		return
+	}
+
+	prefs := b.preferences(chatID)
+	if !prefs.Digest {
+		prefs.Digest = true
+		b.savePreferences(chatID, prefs)
+		b.log.InfoWithFields("Digest mode enabled from offer", logger.Fields{"chat_id": chatID})
+	}
+
+	// Drop the button so the offer cannot be pressed again
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{},
+	})
+	if _, err := b.request(edit); err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Debug("Failed to remove digest offer button")
+	}
+	b.reply(chatID, "📬 Дайджест включён: новые слоты будут приходить одним сообщением раз в день в "+
+		b.digestTime+". Вернуть мгновенные уведомления можно в /settings.")
+}
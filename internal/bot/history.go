@@ -0,0 +1,29 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// historyLimit is how many notifications /history lists.
+const historyLimit = 10
+
+func (b *Bot) handleHistory(chatID int64) {
+	records, err := b.storage.RecentNotifications(chatID, historyLimit)
+	if err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to load notification history")
+		b.reply(chatID, "❌ Не удалось получить историю уведомлений")
+		return
+	}
+	if len(records) == 0 {
+		b.reply(chatID, "📭 Уведомлений пока не было")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🕓 Последние уведомления:\n")
+	for _, r := range records {
+		fmt.Fprintf(&sb, "\n%s — %s", r.SentAt.In(b.loc).Format("02.01 15:04"), r.Excerpt)
+	}
+	b.reply(chatID, sb.String())
+}
@@ -0,0 +1,202 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Telegram Bot API limits checked before a request leaves the process.
+// Text lengths are counted in characters of the source text, which is
+// conservative for HTML messages: Telegram counts them after markup is
+// stripped.
+const (
+	MaxMessageLength      = 4096
+	MaxCaptionLength      = 1024
+	MaxCallbackDataBytes  = 64
+	MaxCallbackAnswerText = 200
+	MaxButtonsPerRow      = 8
+	MaxKeyboardButtons    = 100
+)
+
+// Validation failures, wrapped by *ValidationError so callers can match them
+// with errors.Is and decide whether to split, truncate or drop the content.
+var (
+	ErrTextTooLong        = errors.New("telegram: text too long")
+	ErrCaptionTooLong     = errors.New("telegram: caption too long")
+	ErrCallbackDataLength = errors.New("telegram: invalid callback data length")
+	ErrKeyboardTooLarge   = errors.New("telegram: keyboard too large")
+)
+
+// ValidationError describes which Telegram limit a request would exceed.
+type ValidationError struct {
+	Field string
+	Limit int
+	Got   int
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %s is %d, limit %d", e.Err, e.Field, e.Got, e.Limit)
+}
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// LibraryVersion reports the telegram-bot-api module version compiled into
+// the binary, or "unknown" when build info is unavailable.
+func LibraryVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/go-telegram-bot-api/telegram-bot-api/v5" {
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+// Validate checks c against Telegram limits. Request types without known
+// limits are accepted as is.
+func Validate(c tgbotapi.Chattable) error {
+	switch m := c.(type) {
+	case tgbotapi.MessageConfig:
+		if err := checkLength("text", m.Text, MaxMessageLength, ErrTextTooLong); err != nil {
+			return err
+		}
+		return validateMarkup(m.ReplyMarkup)
+	case tgbotapi.EditMessageTextConfig:
+		if err := checkLength("text", m.Text, MaxMessageLength, ErrTextTooLong); err != nil {
+			return err
+		}
+		if m.ReplyMarkup != nil {
+			return validateInlineKeyboard(*m.ReplyMarkup)
+		}
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		if m.ReplyMarkup != nil {
+			return validateInlineKeyboard(*m.ReplyMarkup)
+		}
+	case tgbotapi.DocumentConfig:
+		if err := checkLength("caption", m.Caption, MaxCaptionLength, ErrCaptionTooLong); err != nil {
+			return err
+		}
+		return validateMarkup(m.ReplyMarkup)
+	case tgbotapi.PhotoConfig:
+		if err := checkLength("caption", m.Caption, MaxCaptionLength, ErrCaptionTooLong); err != nil {
+			return err
+		}
+		return validateMarkup(m.ReplyMarkup)
+	case tgbotapi.CallbackConfig:
+		return checkLength("callback answer", m.Text, MaxCallbackAnswerText, ErrTextTooLong)
+	}
+	return nil
+}
+
+func checkLength(field, s string, limit int, err error) error {
+	if n := utf8.RuneCountInString(s); n > limit {
+		return &ValidationError{Field: field, Limit: limit, Got: n, Err: err}
+	}
+	return nil
+}
+
+func validateMarkup(markup interface{}) error {
+	switch k := markup.(type) {
+	case tgbotapi.InlineKeyboardMarkup:
+		return validateInlineKeyboard(k)
+	case *tgbotapi.InlineKeyboardMarkup:
+		if k != nil {
+			return validateInlineKeyboard(*k)
+		}
+	case tgbotapi.ReplyKeyboardMarkup:
+		total := 0
+		for _, row := range k.Keyboard {
+			if err := checkRow(len(row)); err != nil {
+				return err
+			}
+			total += len(row)
+		}
+		return checkTotal(total)
+	}
+	return nil
+}
+
+func validateInlineKeyboard(k tgbotapi.InlineKeyboardMarkup) error {
+	total := 0
+	for _, row := range k.InlineKeyboard {
+		if err := checkRow(len(row)); err != nil {
+			return err
+		}
+		total += len(row)
+		for _, button := range row {
+			if button.CallbackData == nil {
+				continue
+			}
+			if n := len(*button.CallbackData); n == 0 || n > MaxCallbackDataBytes {
+				return &ValidationError{Field: "callback data", Limit: MaxCallbackDataBytes, Got: n, Err: ErrCallbackDataLength}
+			}
+		}
+	}
+	return checkTotal(total)
+}
+
+func checkRow(n int) error {
+	if n > MaxButtonsPerRow {
+		return &ValidationError{Field: "buttons per row", Limit: MaxButtonsPerRow, Got: n, Err: ErrKeyboardTooLarge}
+	}
+	return nil
+}
+
+func checkTotal(n int) error {
+	if n > MaxKeyboardButtons {
+		return &ValidationError{Field: "buttons", Limit: MaxKeyboardButtons, Got: n, Err: ErrKeyboardTooLarge}
+	}
+	return nil
+}
+
+// maxEntityLength bounds an HTML entity such as &amp; or &#128293; so a
+// stray ampersand in plain text doesn't pull the cut far back.
+const maxEntityLength = 10
+
+// SplitMessage breaks text into chunks of at most limit characters. It cuts
+// at the last line break inside the window when there is one, so lists keep
+// whole entries together; a single overlong line is cut mid-line, but never
+// inside an HTML tag or entity.
+func SplitMessage(text string, limit int) []string {
+	if limit <= 0 {
+		limit = MaxMessageLength
+	}
+	var parts []string
+	for utf8.RuneCountInString(text) > limit {
+		window := string([]rune(text)[:limit])
+		cut := len(window)
+		if i := strings.LastIndex(window, "\n"); i > 0 {
+			cut = i
+		}
+		cut = markupSafeCut(window, cut)
+		parts = append(parts, text[:cut])
+		text = strings.TrimPrefix(text[cut:], "\n")
+	}
+	if text != "" || len(parts) == 0 {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+// markupSafeCut moves cut back to the start of a tag or entity that it
+// would otherwise split. A tag too long to move leaves cut unchanged.
+func markupSafeCut(window string, cut int) int {
+	head := window[:cut]
+	if i := strings.LastIndexByte(head, '<'); i > 0 && i > strings.LastIndexByte(head, '>') {
+		head = head[:i]
+	}
+	if i := strings.LastIndexByte(head, '&'); i > 0 && len(head)-i <= maxEntityLength &&
+		!strings.ContainsAny(head[i:], "; \n") {
+		head = head[:i]
+	}
+	return len(head)
+}
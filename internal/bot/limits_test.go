@@ -0,0 +1,248 @@
+package bot_test
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+)
+
+// keyboard returns an inline keyboard of rows × perRow buttons.
+func keyboard(rows, perRow int) tgbotapi.InlineKeyboardMarkup {
+	var k [][]tgbotapi.InlineKeyboardButton
+	for r := 0; r < rows; r++ {
+		var row []tgbotapi.InlineKeyboardButton
+		for i := 0; i < perRow; i++ {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData("x", "set:x"))
+		}
+		k = append(k, row)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(k...)
+}
+
+func TestValidate(t *testing.T) {
+	withMarkup := func(markup interface{}) tgbotapi.MessageConfig {
+		m := tgbotapi.NewMessage(testChatID, "text")
+		m.ReplyMarkup = markup
+		return m
+	}
+	photo := func(caption string) tgbotapi.PhotoConfig {
+		p := tgbotapi.NewPhoto(testChatID, tgbotapi.FileID("file"))
+		p.Caption = caption
+		return p
+	}
+	data := func(n int) tgbotapi.InlineKeyboardMarkup {
+		return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("x", strings.Repeat("a", n))))
+	}
+
+	for _, tt := range []struct {
+		name string
+		c    tgbotapi.Chattable
+		want error
+	}{
+		{"text at the limit", tgbotapi.NewMessage(testChatID, strings.Repeat("я", 4096)), nil},
+		{"text over the limit", tgbotapi.NewMessage(testChatID, strings.Repeat("я", 4097)), bot.ErrTextTooLong},
+		{"edited text over the limit", tgbotapi.NewEditMessageText(testChatID, 1, strings.Repeat("a", 4097)), bot.ErrTextTooLong},
+		{"caption at the limit", photo(strings.Repeat("я", 1024)), nil},
+		{"caption over the limit", photo(strings.Repeat("я", 1025)), bot.ErrCaptionTooLong},
+		{"callback data of 64 bytes", withMarkup(data(64)), nil},
+		{"callback data of 65 bytes", withMarkup(data(65)), bot.ErrCallbackDataLength},
+		{"empty callback data", withMarkup(data(0)), bot.ErrCallbackDataLength},
+		{"8 buttons in a row", withMarkup(keyboard(1, 8)), nil},
+		{"9 buttons in a row", withMarkup(keyboard(1, 9)), bot.ErrKeyboardTooLarge},
+		{"100 buttons", withMarkup(keyboard(20, 5)), nil},
+		{"101 buttons", withMarkup(keyboard(101, 1)), bot.ErrKeyboardTooLarge},
+		{"pointer keyboard", withMarkup(func() *tgbotapi.InlineKeyboardMarkup { k := keyboard(1, 9); return &k }()), bot.ErrKeyboardTooLarge},
+		{"reply keyboard row", withMarkup(tgbotapi.NewReplyKeyboard(make([]tgbotapi.KeyboardButton, 9))), bot.ErrKeyboardTooLarge},
+		{"callback answer", tgbotapi.NewCallback("id", strings.Repeat("a", 201)), bot.ErrTextTooLong},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bot.Validate(tt.c)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Fatalf("Validate = %v, want %v", err, tt.want)
+			}
+			var verr *bot.ValidationError
+			if tt.want != nil && !errors.As(err, &verr) {
+				t.Errorf("error %v is not a *ValidationError", err)
+			}
+		})
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"short", "hello", 10, []string{"hello"}},
+		{"empty", "", 10, []string{""}},
+		{"at a newline", "first line\nsecond line", 15, []string{"first line", "second line"}},
+		{"last newline in the window", "a\nb\ncccccc", 6, []string{"a\nb", "cccccc"}},
+		{"mid-line", "abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"counts characters", "привет мир", 6, []string{"привет", " мир"}},
+		{"before a tag", "aaaa<b>bold</b>", 6, []string{"aaaa", "<b>bol", "d</b>"}},
+		{"tag longer than the limit", `see <a href="https://x.y">here</a>`, 10, []string{"see ", `<a href="h`, `ttps://x.y`, `">here</a>`}},
+		{"before an entity", "aaaa&amp;bbbb", 7, []string{"aaaa", "&amp;bb", "bb"}},
+		{"entity longer than the limit", "ab&#128293;c", 6, []string{"ab", "&#1282", "93;c"}},
+		{"plain ampersand", "fish & chips", 8, []string{"fish & c", "hips"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bot.SplitMessage(tt.text, tt.limit)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("SplitMessage(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+			}
+			for _, part := range got {
+				if n := utf8.RuneCountInString(part); n > tt.limit {
+					t.Errorf("part %q has %d characters, limit %d", part, n, tt.limit)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitMessageKeepsMarkupWhole(t *testing.T) {
+	line := strings.Repeat("<b>Иван</b> &amp; ", 400)
+	for _, part := range bot.SplitMessage(line, bot.MaxMessageLength) {
+		if utf8.RuneCountInString(part) > bot.MaxMessageLength {
+			t.Fatalf("part of %d characters", utf8.RuneCountInString(part))
+		}
+		if strings.Count(part, "<") != strings.Count(part, ">") {
+			t.Errorf("part splits a tag: ...%q", part[len(part)-20:])
+		}
+		if i := strings.LastIndex(part, "&"); i >= 0 && !strings.Contains(part[i:], ";") {
+			t.Errorf("part splits an entity: ...%q", part[i:])
+		}
+	}
+}
+
+// batchOf returns n numbered items, each linking to url plus its number.
+func batchOf(n int, style bot.LinkStyle, url string) bot.Batch {
+	b := bot.Batch{
+		Header:      "Новые слоты",
+		Footer:      "Все слоты: /current",
+		Style:       style,
+		FallbackURL: "https://example.com/widget",
+	}
+	for i := 0; i < n; i++ {
+		b.Items = append(b.Items, bot.BatchItem{
+			Text: fmt.Sprintf("Слот %03d: 18 марта 10:00 & Иван", i),
+			URL:  fmt.Sprintf("%s?slot=%d", url, i),
+		})
+	}
+	return b
+}
+
+// checkBatch validates every message, checks that every item arrives once
+// and in order, and returns the number of per-item links.
+func checkBatch(t *testing.T, msgs []tgbotapi.MessageConfig, batch bot.Batch) (links int) {
+	var items []string
+	t.Helper()
+	if len(msgs) == 0 {
+		t.Fatal("no messages built")
+	}
+	for i, m := range msgs {
+		if err := bot.Validate(m); err != nil {
+			t.Errorf("message %d: %v", i+1, err)
+		}
+		text := m.Text
+		if m.ParseMode == tgbotapi.ModeHTML {
+			inline := strings.Count(text, "<a href=")
+			if inline > bot.MaxMessageEntities {
+				t.Errorf("message %d has %d links, limit %d", i+1, inline, bot.MaxMessageEntities)
+			}
+			links += inline
+			text = html.UnescapeString(regexp.MustCompile(`</?a[^>]*>`).ReplaceAllString(text, ""))
+		}
+		if k, ok := m.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup); ok {
+			for _, row := range k.InlineKeyboard {
+				for _, button := range row {
+					if button.URL != nil && *button.URL != batch.FallbackURL {
+						links++
+					}
+				}
+			}
+		}
+		if strings.Contains(text, batch.Header) != (i == 0) {
+			t.Errorf("message %d: header placement wrong", i+1)
+		}
+		if strings.Contains(text, batch.Footer) != (i == len(msgs)-1) {
+			t.Errorf("message %d: footer placement wrong", i+1)
+		}
+		for _, line := range strings.Split(text, "\n") {
+			if strings.HasPrefix(line, "Слот ") {
+				items = append(items, line)
+			}
+		}
+	}
+	if len(items) != len(batch.Items) {
+		t.Fatalf("%d items delivered, want %d", len(items), len(batch.Items))
+	}
+	for i, item := range batch.Items {
+		if items[i] != item.Text {
+			t.Fatalf("item %d = %q, want %q", i, items[i], item.Text)
+		}
+	}
+	return links
+}
+
+func TestBuildBatchButtons(t *testing.T) {
+	batch := batchOf(150, bot.LinkButtons, "https://example.com/book")
+	msgs := bot.BuildBatch(testChatID, batch)
+	if links := checkBatch(t, msgs, batch); links != 150 {
+		t.Errorf("%d slot buttons, want one per slot", links)
+	}
+	if len(msgs) != 2 {
+		t.Errorf("built %d messages, want 2 for 150 buttons", len(msgs))
+	}
+}
+
+func TestBuildBatchInlineLinks(t *testing.T) {
+	batch := batchOf(40, bot.LinkInline, "https://example.com/book")
+	msgs := bot.BuildBatch(testChatID, batch)
+	if links := checkBatch(t, msgs, batch); links != 40 {
+		t.Errorf("%d inline links, want one per slot", links)
+	}
+	if msgs[0].ParseMode != tgbotapi.ModeHTML {
+		t.Errorf("parse mode = %q, want HTML for inline links", msgs[0].ParseMode)
+	}
+}
+
+func TestBuildBatchFallsBackToWidgetButton(t *testing.T) {
+	short := batchOf(150, bot.LinkButtons, "https://example.com/book")
+	for i := range short.Items {
+		short.Items[i].Text = fmt.Sprintf("Слот %03d", i)
+	}
+	for _, tt := range []struct {
+		name  string
+		batch bot.Batch
+	}{
+		// 150 buttons need two messages where the plain list needs one.
+		{"buttons over the keyboard limit", short},
+		// Inline links make every line longer than the plain one.
+		{"inline links over the length limit", batchOf(150, bot.LinkInline, "https://example.com/book")},
+		{"long inline links", batchOf(150, bot.LinkInline, "https://example.com/book?"+strings.Repeat("x", 1500))},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs := bot.BuildBatch(testChatID, tt.batch)
+			if links := checkBatch(t, msgs, tt.batch); links != 0 {
+				t.Errorf("%d per-slot links kept, want none", links)
+			}
+			for i, m := range msgs {
+				k, ok := m.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+				widget := ok && len(k.InlineKeyboard) == 1 && *k.InlineKeyboard[0][0].URL == tt.batch.FallbackURL
+				if widget != (i == len(msgs)-1) {
+					t.Errorf("message %d has the widget button = %v, want it on the last message only", i+1, widget)
+				}
+			}
+		})
+	}
+}
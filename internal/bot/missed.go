@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"time"
+)
+
+// MissedSlots summarizes slots that appeared while a chat was unsubscribed.
+type MissedSlots struct {
+	Total     int
+	StillFree int
+	// Partial is set when the gap is longer than seen slots are kept, so
+	// Total is a lower bound and StillFree is unknown.
+	Partial bool
+}
+
+// MissedSlotsSource reports slots first seen after a moment in time.
+type MissedSlotsSource interface {
+	MissedSlots(since time.Time) (MissedSlots, error)
+}
+
+// WelcomeView is the data passed to the welcome template.
+type WelcomeView struct {
+	Subscribed bool
+	Missed     *MissedSlots
+}
+
+func (b *Bot) SetMissedSlotsSource(src MissedSlotsSource) {
+	b.missedSlots = src
+}
+
+// missedSinceUnsubscribe summarizes what a returning chat missed, or nil if
+// it never unsubscribed or nothing appeared meanwhile.
+func (b *Bot) missedSinceUnsubscribe(chatID int64) *MissedSlots {
+	if b.missedSlots == nil {
+		return nil
+	}
+	since, ok, err := b.storage.LastUnsubscribedAt(chatID)
+	if err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Warn("Failed to load unsubscribe time")
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	missed, err := b.missedSlots.MissedSlots(since)
+	if err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Warn("Failed to summarize missed slots")
+		return nil
+	}
+	if missed.Total == 0 {
+		return nil
+	}
+	return &missed
+}
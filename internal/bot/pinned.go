@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+const pinnedPlaceholder = "📌 Здесь будет актуальный список свободных слотов. Он обновится после следующей проверки."
+
+// handlePinCommand toggles the continuously updated availability message.
+func (b *Bot) handlePinCommand(chatID int64) {
+	messageID, err := b.storage.GetPinnedMessage(chatID)
+	if err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to load pinned message")
+		b.reply(chatID, "❌ Не удалось выполнить команду, попробуйте позже")
+		return
+	}
+
+	if messageID != 0 {
+		if _, err := b.request(tgbotapi.UnpinChatMessageConfig{ChatID: chatID, MessageID: messageID}); err != nil {
+			b.log.WithError(err).WithField("chat_id", chatID).Warn("Failed to unpin availability message")
+		}
+		if err := b.storage.RemovePinnedMessage(chatID); err != nil {
+			b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to remove pinned message")
+		}
+		b.reply(chatID, "📌 Закреплённое сообщение больше не будет обновляться. Чтобы включить снова, отправьте /pin.")
+		return
+	}
+
+	b.pinnedMu.RLock()
+	text := b.pinnedText
+	b.pinnedMu.RUnlock()
+	if text == "" {
+		text = pinnedPlaceholder
+	}
+
+	sent, err := b.send(tgbotapi.NewMessage(chatID, text))
+	if err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to send availability message")
+		return
+	}
+	pin := tgbotapi.PinChatMessageConfig{ChatID: chatID, MessageID: sent.MessageID, DisableNotification: true}
+	if _, err := b.request(pin); err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Warn("Failed to pin availability message")
+	}
+	if err := b.storage.SetPinnedMessage(chatID, sent.MessageID); err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to save pinned message")
+		return
+	}
+	b.log.InfoWithFields("Availability message pinned", logger.Fields{
+		"chat_id":    chatID,
+		"message_id": sent.MessageID,
+	})
+}
+
+// UpdatePinned replaces the text of every pinned availability message.
+func (b *Bot) UpdatePinned(text string) {
+	b.pinnedMu.Lock()
+	b.pinnedText = text
+	b.pinnedMu.Unlock()
+
+	pinned, err := b.storage.GetPinnedMessages()
+	if err != nil {
+		b.log.WithError(err).Error("Failed to load pinned messages")
+		return
+	}
+
+	for chatID, messageID := range pinned {
+		_, err := b.send(tgbotapi.NewEditMessageText(chatID, messageID, text))
+		switch {
+		case err == nil:
+		case strings.Contains(err.Error(), "message is not modified"):
+		case strings.Contains(err.Error(), "message to edit not found"):
+			// The user deleted the message; stop tracking it.
+			if err := b.storage.RemovePinnedMessage(chatID); err != nil {
+				b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to remove pinned message")
+			}
+		default:
+			b.log.WithError(err).WithField("chat_id", chatID).Warn("Failed to update pinned message")
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package bot
+
+import "github.com/thatguy/moto_gorod-notifier/internal/logger"
+
+// ReportSource renders the weekly admin report.
+type ReportSource interface {
+	WeeklyReportMessage() (string, error)
+}
+
+func (b *Bot) SetReportSource(src ReportSource) {
+	b.report = src
+}
+
+func (b *Bot) handleReportCommand(chatID int64) {
+	if b.report == nil {
+		b.reply(chatID, "⚠️ Отчёт недоступен")
+		return
+	}
+	text, err := b.report.WeeklyReportMessage()
+	if err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to build weekly report")
+		b.reply(chatID, "❌ Не удалось собрать отчёт")
+		return
+	}
+	b.reply(chatID, text)
+}
+
+// addDailyStat feeds the weekly report; failures are only logged.
+func (b *Bot) addDailyStat(name string) {
+	if err := b.storage.AddDailyStat(b.now().In(b.loc), name, 1); err != nil {
+		b.log.WithError(err).WarnWithFields("Failed to record daily statistic", logger.Fields{
+			"stat": name,
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DefaultSendTimeout bounds a single Telegram API call when no timeout is configured.
+const DefaultSendTimeout = 10 * time.Second
+
+// ErrSendTimeout is returned when Telegram does not answer within the
+// per-message deadline. The HTTP request is canceled; use ShouldRetry to
+// tell whether it may have reached Telegram anyway.
+var ErrSendTimeout = errors.New("telegram: send deadline exceeded")
+
+// SendTimeoutError is the ErrSendTimeout of one canceled call. Sent reports
+// whether the whole request had been written: Telegram may then have
+// delivered the message even though no answer came back.
+type SendTimeoutError struct {
+	Sent bool
+}
+
+func (e *SendTimeoutError) Error() string {
+	if e.Sent {
+		return ErrSendTimeout.Error() + " after the request was sent"
+	}
+	return ErrSendTimeout.Error() + " before the request was sent"
+}
+
+func (e *SendTimeoutError) Unwrap() error { return ErrSendTimeout }
+
+// NewHTTPClient returns the HTTP client for tgbotapi.NewBotAPIWithClient.
+// Every call except the getUpdates long poll is canceled once timeout
+// passes, closing its connection instead of leaving it running.
+func NewHTTPClient(timeout time.Duration) tgbotapi.HTTPClient {
+	if timeout <= 0 {
+		timeout = DefaultSendTimeout
+	}
+	return &deadlineClient{client: &http.Client{}, timeout: timeout}
+}
+
+type deadlineClient struct {
+	client  *http.Client
+	timeout time.Duration
+
+	// The library retries a failed getUpdates poll after a pause; each
+	// failure is counted as a reconnect for the status document.
+	reconnects      atomic.Int64
+	lastReconnectAt atomic.Int64 // unix nanoseconds
+}
+
+func (c *deadlineClient) Do(req *http.Request) (*http.Response, error) {
+	// getUpdates is held open by Telegram for the poll timeout on purpose.
+	if strings.HasSuffix(req.URL.Path, "/getUpdates") {
+		resp, err := c.client.Do(req)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			c.reconnects.Add(1)
+			c.lastReconnectAt.Store(time.Now().UnixNano())
+		}
+		return resp, err
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), c.timeout)
+	var sent atomic.Bool
+	trace := &httptrace.ClientTrace{
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				sent.Store(true)
+			}
+		},
+	}
+	resp, err := c.client.Do(req.WithContext(httptrace.WithClientTrace(ctx, trace)))
+	if err != nil {
+		cancel()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, &SendTimeoutError{Sent: sent.Load()}
+		}
+		return nil, err
+	}
+	resp.Body = &deadlineBody{ReadCloser: resp.Body, ctx: ctx, cancel: cancel}
+	return resp, nil
+}
+
+// deadlineBody keeps the call's deadline running while the library reads
+// the answer and releases it on Close.
+type deadlineBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && errors.Is(b.ctx.Err(), context.DeadlineExceeded) {
+		return n, &SendTimeoutError{Sent: true}
+	}
+	return n, err
+}
+
+func (b *deadlineBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// send is the single path for outgoing messages.
+func (b *Bot) send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	if err := b.validate(c); err != nil {
+		return tgbotapi.Message{}, err
+	}
+	msg, err := b.api.Send(c)
+	b.recordTimeout(err)
+	return msg, err
+}
+
+// request is the single path for API calls that don't produce a message.
+func (b *Bot) request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	if err := b.validate(c); err != nil {
+		return nil, err
+	}
+	resp, err := b.api.Request(c)
+	b.recordTimeout(err)
+	return resp, err
+}
+
+func (b *Bot) recordTimeout(err error) {
+	if errors.Is(err, ErrSendTimeout) && b.metrics != nil {
+		b.metrics.RecordError("telegram_send_timeout")
+	}
+}
+
+// validate rejects requests Telegram would refuse, so the caller gets a
+// typed error instead of an opaque API failure.
+func (b *Bot) validate(c tgbotapi.Chattable) error {
+	err := Validate(c)
+	if err != nil && b.metrics != nil {
+		b.metrics.RecordError("telegram_validation")
+	}
+	return err
+}
+
+// notificationErrorType labels a failed notification for the errors metric.
+func notificationErrorType(err error) string {
+	var validation *ValidationError
+	switch {
+	case errors.Is(err, ErrSendTimeout):
+		return "notification_timeout"
+	case errors.As(err, &validation):
+		return "notification_invalid"
+	default:
+		return "notification_failed"
+	}
+}
+
+// IsPermanent reports whether retrying a failed send cannot succeed: the
+// request breaks Telegram limits, or Telegram rejected it outright, e.g.
+// because the user blocked the bot.
+func IsPermanent(err error) bool {
+	var validation *ValidationError
+	if errors.As(err, &validation) {
+		return true
+	}
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && (apiErr.Code == 400 || apiErr.Code == 403)
+}
+
+// ShouldRetry reports whether a failed send may be retried without risking
+// a duplicate: the error is not permanent, and a timed-out request never
+// reached Telegram.
+func ShouldRetry(err error) bool {
+	if IsPermanent(err) {
+		return false
+	}
+	var timeout *SendTimeoutError
+	return !errors.As(err, &timeout) || !timeout.Sent
+}
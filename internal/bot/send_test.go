@@ -0,0 +1,143 @@
+package bot_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot/bottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// stallingTelegram answers getMe and holds every other call until the
+// client gives up, reporting each cancellation on canceled.
+func stallingTelegram(t *testing.T) (*tgbotapi.BotAPI, <-chan struct{}) {
+	t.Helper()
+	canceled := make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot"}}`))
+			return
+		}
+		// The server notices a closed connection only once the body is read.
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+			canceled <- struct{}{}
+		case <-time.After(5 * time.Second):
+			w.Write([]byte(`{"ok":true,"result":{"message_id":1}}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	api, err := tgbotapi.NewBotAPIWithClient("token", srv.URL+"/bot%s/%s", bot.NewHTTPClient(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewBotAPIWithClient: %v", err)
+	}
+	return api, canceled
+}
+
+func TestSendTimeoutCancelsRequest(t *testing.T) {
+	api, canceled := stallingTelegram(t)
+
+	start := time.Now()
+	_, err := api.Send(tgbotapi.NewMessage(testChatID, "🟢 Новый слот"))
+	if !errors.Is(err, bot.ErrSendTimeout) {
+		t.Fatalf("Send to a stalled Telegram: err = %v, want ErrSendTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send returned after %s, want about the 50ms deadline", elapsed)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the stalled request was not canceled")
+	}
+
+	// The request was written, so Telegram may have the message.
+	if bot.ShouldRetry(err) {
+		t.Error("ShouldRetry = true for a timeout after the request was sent")
+	}
+}
+
+func TestNotifyTimesOutOnStalledTelegram(t *testing.T) {
+	api, canceled := stallingTelegram(t)
+	b := bot.NewFromBotAPI(api, nil, logger.New().WithOutput(io.Discard))
+
+	if err := b.Notify(testChatID, "🟢 Новый слот"); !errors.Is(err, bot.ErrSendTimeout) {
+		t.Fatalf("Notify: err = %v, want ErrSendTimeout", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the stalled request was not canceled")
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"network error", errors.New("connection reset by peer"), true},
+		{"timeout before sending", &bot.SendTimeoutError{Sent: false}, true},
+		{"timeout after sending", &bot.SendTimeoutError{Sent: true}, false},
+		{"blocked by the user", &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, false},
+		{"too many requests", &tgbotapi.Error{Code: 429, Message: "Too Many Requests"}, true},
+		{"fake failure", bottest.ErrFake, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bot.ShouldRetry(tt.err); got != tt.want {
+				t.Errorf("ShouldRetry(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusReportCountsReconnects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot"}}`))
+			return
+		}
+		http.Error(w, `{"ok":false,"error_code":502,"description":"Bad Gateway"}`, http.StatusBadGateway)
+	}))
+	t.Cleanup(srv.Close)
+	api, err := tgbotapi.NewBotAPIWithClient("token", srv.URL+"/bot%s/%s", bot.NewHTTPClient(time.Second))
+	if err != nil {
+		t.Fatalf("NewBotAPIWithClient: %v", err)
+	}
+	b := bot.NewFromBotAPI(api, nil, logger.New().WithOutput(io.Discard))
+
+	report := b.StatusReport(context.Background()).(map[string]interface{})
+	if got := report["reconnects"]; got != int64(0) {
+		t.Fatalf("reconnects before polling = %v, want 0", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		report = b.StatusReport(context.Background()).(map[string]interface{})
+		if n, _ := report["reconnects"].(int64); n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("reconnects = %v after a failed poll, want at least 1", report["reconnects"])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := report["last_reconnect_at"]; !ok {
+		t.Error("report has no last_reconnect_at")
+	}
+}
@@ -0,0 +1,511 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+// ServiceOption is a service users can pick in the settings menu.
+type ServiceOption struct {
+	ID   int
+	Name string
+}
+
+// SettingsView is the data passed to the settings template.
+type SettingsView struct {
+	Services   string
+	Weekdays   string
+	QuietHours string
+	Digest     string
+	Reminder   string
+	Horizon    string
+	Pause      string
+	Language   string
+}
+
+const settingsPrefix = "set:"
+
+var weekdayOrder = []int{1, 2, 3, 4, 5, 6, 0}
+
+var weekdayShortNames = map[int]string{
+	0: "Вс", 1: "Пн", 2: "Вт", 3: "Ср", 4: "Чт", 5: "Пт", 6: "Сб",
+}
+
+// horizonPresets are the notification horizons, in days, offered in the
+// settings menu.
+var horizonPresets = []int{3, 7, 14}
+
+// pausePresets are the notification pauses, in hours, offered in the
+// settings menu.
+var pausePresets = []int{1, 8, 24}
+
+// languageOptions are the slot message languages offered in the settings
+// menu. The empty code is the default Russian.
+var languageOptions = []struct {
+	Code  string
+	Label string
+}{
+	{"", "🇷🇺 Русский"},
+	{"en", "🇬🇧 English"},
+}
+
+// Notices shown above the settings when a toggle would leave nothing
+// selected; an empty filter means "all", which is the opposite.
+const (
+	lastServiceNotice = "⚠️ Нужно оставить хотя бы одну услугу."
+	lastWeekdayNotice = "⚠️ Нужно оставить хотя бы один день недели."
+)
+
+// quietHourPresets are the quiet hours windows offered in the settings menu.
+var quietHourPresets = [][2]string{
+	{"22:00", "08:00"},
+	{"23:00", "08:00"},
+	{"00:00", "07:00"},
+}
+
+func (b *Bot) SetServiceOptions(services []ServiceOption) {
+	b.services = services
+}
+
+func (b *Bot) handleSettings(chatID int64) {
+	prefs := b.preferences(chatID)
+	msg := tgbotapi.NewMessage(chatID, b.settingsText(prefs))
+	msg.ReplyMarkup = b.settingsKeyboard()
+	if _, err := b.send(msg); err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to send settings")
+	}
+}
+
+func (b *Bot) handleCallback(cb *tgbotapi.CallbackQuery) {
+	if cb.Message == nil {
+		return
+	}
+	chatID := cb.Message.Chat.ID
+	if !b.hasAccess(chatID) {
+		return
+	}
+
+	b.log.DebugWithFields("Received callback", logger.Fields{
+		"chat_id": chatID,
+		"data":    cb.Data,
+	})
+
+	if _, err := b.request(tgbotapi.NewCallback(cb.ID, "")); err != nil {
+		b.log.WithError(err).Debug("Failed to answer callback")
+	}
+
+	switch {
+	case strings.HasPrefix(cb.Data, settingsPrefix):
+		b.handleSettingsCallback(chatID, cb.Message.MessageID, strings.TrimPrefix(cb.Data, settingsPrefix))
+	case strings.HasPrefix(cb.Data, subscriptionPrefix):
+		b.handleSubscriptionCallback(chatID, cb.Message.MessageID, strings.TrimPrefix(cb.Data, subscriptionPrefix))
+	case strings.HasPrefix(cb.Data, digestPrefix):
+		b.handleDigestCallback(chatID, cb.Message.MessageID, strings.TrimPrefix(cb.Data, digestPrefix))
+	}
+}
+
+func (b *Bot) handleSettingsCallback(chatID int64, messageID int, data string) {
+	prefs := b.preferences(chatID)
+	parts := strings.Split(data, ":")
+
+	var keyboard tgbotapi.InlineKeyboardMarkup
+	var notice string
+	switch parts[0] {
+	case "menu":
+		keyboard = b.settingsKeyboard()
+	case "svc":
+		if len(parts) > 1 {
+			// Callback data comes from the client, so only catalog
+			// services may enter the filter.
+			if id, err := strconv.Atoi(parts[1]); err == nil && containsInt(b.serviceIDs(), id) {
+				selected := prefs.ServiceIDs
+				if len(selected) == 0 {
+					selected = b.serviceIDs()
+				}
+				// Services removed from the config can't be toggled back, so
+				// drop them instead of letting them linger in the filter.
+				selected = toggleInt(intersectInts(selected, b.serviceIDs()), id)
+				switch {
+				case len(selected) == 0:
+					notice = lastServiceNotice
+				case len(selected) == len(b.services):
+					prefs.ServiceIDs = nil
+					b.savePreferences(chatID, prefs)
+				default:
+					prefs.ServiceIDs = selected
+					b.savePreferences(chatID, prefs)
+				}
+			}
+		}
+		keyboard = b.servicesKeyboard(prefs)
+	case "wd":
+		if len(parts) > 1 {
+			if wd, err := strconv.Atoi(parts[1]); err == nil && wd >= 0 && wd <= 6 {
+				selected := prefs.Weekdays
+				if len(selected) == 0 {
+					selected = append([]int(nil), weekdayOrder...)
+				}
+				selected = toggleInt(selected, wd)
+				switch {
+				case len(selected) == 0:
+					notice = lastWeekdayNotice
+				case len(selected) == len(weekdayOrder):
+					prefs.Weekdays = nil
+					b.savePreferences(chatID, prefs)
+				default:
+					prefs.Weekdays = selected
+					b.savePreferences(chatID, prefs)
+				}
+			}
+		}
+		keyboard = b.weekdaysKeyboard(prefs)
+	case "lang":
+		if len(parts) > 1 {
+			if i, err := strconv.Atoi(parts[1]); err == nil && i >= 0 && i < len(languageOptions) {
+				prefs.Language = languageOptions[i].Code
+				b.savePreferences(chatID, prefs)
+			}
+		}
+		keyboard = b.languageKeyboard(prefs)
+	case "quiet":
+		if len(parts) > 1 {
+			if i, err := strconv.Atoi(parts[1]); err == nil && i >= 0 && i < len(quietHourPresets) {
+				prefs.QuietFrom, prefs.QuietTo = quietHourPresets[i][0], quietHourPresets[i][1]
+			} else {
+				prefs.QuietFrom, prefs.QuietTo = "", ""
+			}
+			b.savePreferences(chatID, prefs)
+		}
+		keyboard = b.quietHoursKeyboard(prefs)
+	case "horizon":
+		if len(parts) > 1 {
+			if days, err := strconv.Atoi(parts[1]); err == nil && containsInt(horizonPresets, days) {
+				prefs.HorizonDays = days
+			} else {
+				prefs.HorizonDays = 0
+			}
+			b.savePreferences(chatID, prefs)
+		}
+		keyboard = b.horizonKeyboard(prefs)
+	case "pause":
+		if len(parts) > 1 {
+			if hours, err := strconv.Atoi(parts[1]); err == nil && containsInt(pausePresets, hours) {
+				prefs.MutedUntil = b.now().Add(time.Duration(hours) * time.Hour)
+			} else {
+				prefs.MutedUntil = time.Time{}
+			}
+			b.savePreferences(chatID, prefs)
+		}
+		keyboard = b.pauseKeyboard(prefs)
+	case "digest":
+		prefs.Digest = !prefs.Digest
+		b.savePreferences(chatID, prefs)
+		keyboard = b.settingsKeyboard()
+	case "reminder":
+		prefs.EveningReminder = !prefs.EveningReminder
+		b.savePreferences(chatID, prefs)
+		keyboard = b.settingsKeyboard()
+	case "reset":
+		if err := b.storage.ResetPreferences(chatID); err != nil {
+			b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to reset preferences")
+		} else {
+			b.log.InfoWithFields("Preferences reset to defaults", logger.Fields{"chat_id": chatID})
+		}
+		prefs = storage.DefaultPreferences()
+		keyboard = b.settingsKeyboard()
+	default:
+		return
+	}
+
+	text := b.settingsText(prefs)
+	if notice != "" {
+		text = notice + "\n\n" + text
+	}
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, keyboard)
+	if _, err := b.send(edit); err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to update settings message")
+	}
+}
+
+func (b *Bot) preferences(chatID int64) storage.Preferences {
+	prefs, err := b.storage.GetPreferences(chatID)
+	if err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to load preferences")
+	}
+	return prefs
+}
+
+func (b *Bot) savePreferences(chatID int64, prefs storage.Preferences) {
+	if err := b.storage.SetPreferences(chatID, prefs); err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to save preferences")
+		if b.metrics != nil {
+			b.metrics.RecordError("preferences_save_failed")
+		}
+	}
+}
+
+func (b *Bot) settingsText(prefs storage.Preferences) string {
+	view := b.settingsView(prefs)
+	if b.templateRenderer != nil {
+		if text, ok := b.rendered(b.templateRenderer.GetSettingsMessage(view)); ok {
+			return text
+		}
+	}
+	return fmt.Sprintf("⚙️ Настройки уведомлений\n\nУслуги: %s\nДни недели: %s\nНа сколько дней вперёд: %s\nТихие часы: %s\nПауза: %s\nДайджест: %s\nНапоминание на завтра: %s\nЯзык уведомлений: %s",
+		view.Services, view.Weekdays, view.Horizon, view.QuietHours, view.Pause, view.Digest, view.Reminder, view.Language)
+}
+
+func (b *Bot) settingsView(prefs storage.Preferences) SettingsView {
+	view := SettingsView{Services: "все", Weekdays: "все", QuietHours: "выключены", Digest: "выключен", Reminder: "выключено", Horizon: "без ограничений", Pause: "нет", Language: languageLabel(prefs.Language)}
+
+	if len(prefs.ServiceIDs) > 0 {
+		names := make([]string, 0, len(prefs.ServiceIDs))
+		for _, id := range prefs.ServiceIDs {
+			names = append(names, b.serviceName(id))
+		}
+		view.Services = strings.Join(names, ", ")
+	}
+	if len(prefs.Weekdays) > 0 {
+		var days []string
+		for _, wd := range weekdayOrder {
+			if containsInt(prefs.Weekdays, wd) {
+				days = append(days, weekdayShortNames[wd])
+			}
+		}
+		view.Weekdays = strings.Join(days, ", ")
+	}
+	if prefs.HorizonDays > 0 {
+		view.Horizon = horizonLabel(prefs.HorizonDays)
+	}
+	if prefs.HasQuietHours() {
+		view.QuietHours = prefs.QuietFrom + "–" + prefs.QuietTo
+	}
+	if prefs.Muted(b.now()) {
+		view.Pause = "до " + prefs.MutedUntil.In(b.loc).Format("02.01 15:04")
+	}
+	if prefs.Digest {
+		view.Digest = "раз в день в " + b.digestTime
+	}
+	if prefs.EveningReminder {
+		view.Reminder = "в " + b.reminderTime
+	}
+	return view
+}
+
+func (b *Bot) serviceIDs() []int {
+	ids := make([]int, 0, len(b.services))
+	for _, s := range b.services {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// NotifyRemovedServices tells a chat that services in its filter are no
+// longer tracked and offers to edit the filter.
+func (b *Bot) NotifyRemovedServices(chatID int64, serviceIDs []int) error {
+	names := make([]string, 0, len(serviceIDs))
+	for _, id := range serviceIDs {
+		names = append(names, "#"+strconv.Itoa(id))
+	}
+	text := "⚠️ Бот больше не отслеживает услуги из вашего фильтра: " + strings.Join(names, ", ") + "."
+	if len(intersectInts(b.preferences(chatID).ServiceIDs, b.serviceIDs())) == 0 {
+		text += "\n\nСейчас фильтр не совпадает ни с одной услугой, и уведомления не приходят."
+	}
+	text += "\n\nВыберите услуги заново в настройках."
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🚗 Изменить услуги", settingsPrefix+"svc"),
+	))
+	return b.deliver(msg)
+}
+
+func (b *Bot) serviceName(id int) string {
+	for _, s := range b.services {
+		if s.ID == id {
+			return s.Name
+		}
+	}
+	return "#" + strconv.Itoa(id)
+}
+
+func (b *Bot) settingsKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🚗 Услуги", settingsPrefix+"svc"),
+			tgbotapi.NewInlineKeyboardButtonData("📆 Дни недели", settingsPrefix+"wd"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⏳ На сколько дней вперёд", settingsPrefix+"horizon"),
+			tgbotapi.NewInlineKeyboardButtonData("🌐 Язык", settingsPrefix+"lang"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌙 Тихие часы", settingsPrefix+"quiet"),
+			tgbotapi.NewInlineKeyboardButtonData("🔕 Пауза", settingsPrefix+"pause"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📅 Подписка до даты", subscriptionPrefix+"menu"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📬 Дайджест", settingsPrefix+"digest"),
+			tgbotapi.NewInlineKeyboardButtonData("🌆 Напоминание на завтра", settingsPrefix+"reminder"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("♻️ Сбросить настройки", settingsPrefix+"reset"),
+		),
+	)
+}
+
+func (b *Bot) servicesKeyboard(prefs storage.Preferences) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, s := range b.services {
+		label := checkbox(len(prefs.ServiceIDs) == 0 || containsInt(prefs.ServiceIDs, s.ID)) + " " + s.Name
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, settingsPrefix+"svc:"+strconv.Itoa(s.ID)),
+		))
+	}
+	rows = append(rows, backRow())
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func (b *Bot) weekdaysKeyboard(prefs storage.Preferences) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, wd := range weekdayOrder {
+		label := checkbox(len(prefs.Weekdays) == 0 || containsInt(prefs.Weekdays, wd)) + weekdayShortNames[wd]
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, settingsPrefix+"wd:"+strconv.Itoa(wd)))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row[:4], row[4:], backRow())
+}
+
+func (b *Bot) quietHoursKeyboard(prefs storage.Preferences) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, p := range quietHourPresets {
+		selected := prefs.QuietFrom == p[0] && prefs.QuietTo == p[1]
+		label := checkbox(selected) + " " + p[0] + "–" + p[1]
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, settingsPrefix+"quiet:"+strconv.Itoa(i)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(checkbox(!prefs.HasQuietHours())+" Выключить", settingsPrefix+"quiet:off"),
+	))
+	rows = append(rows, backRow())
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func (b *Bot) horizonKeyboard(prefs storage.Preferences) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, days := range horizonPresets {
+		label := checkbox(prefs.HorizonDays == days) + " " + horizonLabel(days)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, settingsPrefix+"horizon:"+strconv.Itoa(days)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(checkbox(prefs.HorizonDays == 0)+" Без ограничений", settingsPrefix+"horizon:off"),
+	))
+	rows = append(rows, backRow())
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func (b *Bot) pauseKeyboard(prefs storage.Preferences) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, hours := range pausePresets {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			"на "+strconv.Itoa(hours)+" ч", settingsPrefix+"pause:"+strconv.Itoa(hours)))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		row,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(checkbox(!prefs.Muted(b.now()))+" Без паузы", settingsPrefix+"pause:off"),
+		),
+		backRow(),
+	)
+}
+
+func (b *Bot) languageKeyboard(prefs storage.Preferences) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	for i, l := range languageOptions {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			checkbox(prefs.Language == l.Code)+" "+l.Label, settingsPrefix+"lang:"+strconv.Itoa(i)))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row, backRow())
+}
+
+// languageLabel names a language code from languageOptions; unknown codes
+// are shown as is.
+func languageLabel(code string) string {
+	for _, l := range languageOptions {
+		if l.Code == code {
+			return l.Label
+		}
+	}
+	return code
+}
+
+// horizonLabel describes a horizon preset, e.g. "7 дней".
+func horizonLabel(days int) string {
+	switch {
+	case days%10 == 1 && days%100 != 11:
+		return strconv.Itoa(days) + " день"
+	case days%10 >= 2 && days%10 <= 4 && (days%100 < 12 || days%100 > 14):
+		return strconv.Itoa(days) + " дня"
+	default:
+		return strconv.Itoa(days) + " дней"
+	}
+}
+
+func backRow() []tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", settingsPrefix+"menu"),
+	)
+}
+
+func checkbox(on bool) string {
+	if on {
+		return "✅"
+	}
+	return "⬜"
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// intersectInts returns the values that are also in allowed, keeping order.
+func intersectInts(values, allowed []int) []int {
+	var out []int
+	for _, v := range values {
+		if containsInt(allowed, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// toggleInt adds v to values or removes it if already present.
+func toggleInt(values []int, v int) []int {
+	if containsInt(values, v) {
+		out := make([]int, 0, len(values))
+		for _, x := range values {
+			if x != v {
+				out = append(out, x)
+			}
+		}
+		return out
+	}
+	out := append(append([]int(nil), values...), v)
+	sort.Ints(out)
+	return out
+}
@@ -0,0 +1,135 @@
+package bot_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot/bottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+func newSettingsEnv(t *testing.T) *testEnv {
+	t.Helper()
+	return newTestEnv(t, func(b *bot.Bot) {
+		b.SetServiceOptions([]bot.ServiceOption{{ID: 10, Name: "Площадка"}, {ID: 20, Name: "Город"}})
+	})
+}
+
+func (e *testEnv) prefs(t *testing.T, chatID int64) storage.Preferences {
+	t.Helper()
+	prefs, err := e.store.GetPreferences(chatID)
+	if err != nil {
+		t.Fatalf("GetPreferences: %v", err)
+	}
+	return prefs
+}
+
+// lastEdit returns the last settings message edit sent to chatID.
+func (e *testEnv) lastEdit(t *testing.T, chatID int64) tgbotapi.EditMessageTextConfig {
+	t.Helper()
+	sent := e.api.Sent()
+	for i := len(sent) - 1; i >= 0; i-- {
+		if m, ok := sent[i].(tgbotapi.EditMessageTextConfig); ok && m.ChatID == chatID {
+			return m
+		}
+	}
+	t.Fatalf("no message edited in %d", chatID)
+	return tgbotapi.EditMessageTextConfig{}
+}
+
+func TestSettingsServiceToggle(t *testing.T) {
+	e := newSettingsEnv(t)
+
+	e.handle(t, bottest.NewCallback(testChatID, 1, "set:svc:10"))
+	if got := e.prefs(t, testChatID).ServiceIDs; !reflect.DeepEqual(got, []int{20}) {
+		t.Fatalf("ServiceIDs = %v, want [20]", got)
+	}
+
+	// Turning off the last service is refused instead of meaning "all".
+	e.handle(t, bottest.NewCallback(testChatID, 1, "set:svc:20"))
+	if got := e.prefs(t, testChatID).ServiceIDs; !reflect.DeepEqual(got, []int{20}) {
+		t.Errorf("ServiceIDs = %v after removing the last one, want [20]", got)
+	}
+	if text := e.lastEdit(t, testChatID).Text; !strings.HasPrefix(text, "⚠️ Нужно оставить хотя бы одну услугу") {
+		t.Errorf("settings text = %q, want the last service notice", text)
+	}
+
+	// Selecting every service again stores the "all" filter.
+	e.handle(t, bottest.NewCallback(testChatID, 1, "set:svc:10"))
+	if got := e.prefs(t, testChatID).ServiceIDs; got != nil {
+		t.Errorf("ServiceIDs = %v with every service selected, want nil", got)
+	}
+}
+
+func TestSettingsRejectsUnknownService(t *testing.T) {
+	e := newSettingsEnv(t)
+
+	for _, data := range []string{"set:svc:999", "set:svc:-1", "set:svc:abc"} {
+		e.handle(t, bottest.NewCallback(testChatID, 1, data))
+		if got := e.prefs(t, testChatID).ServiceIDs; got != nil {
+			t.Errorf("%s: ServiceIDs = %v, want the filter unchanged", data, got)
+		}
+	}
+}
+
+func TestSettingsWeekdayToggle(t *testing.T) {
+	e := newSettingsEnv(t)
+	if err := e.store.SetPreferences(testChatID, storage.Preferences{Weekdays: []int{6}}); err != nil {
+		t.Fatal(err)
+	}
+
+	e.handle(t, bottest.NewCallback(testChatID, 1, "set:wd:6"))
+	if got := e.prefs(t, testChatID).Weekdays; !reflect.DeepEqual(got, []int{6}) {
+		t.Errorf("Weekdays = %v after removing the last one, want [6]", got)
+	}
+	if text := e.lastEdit(t, testChatID).Text; !strings.HasPrefix(text, "⚠️ Нужно оставить хотя бы один день недели") {
+		t.Errorf("settings text = %q, want the last weekday notice", text)
+	}
+
+	e.handle(t, bottest.NewCallback(testChatID, 1, "set:wd:0"))
+	if got := e.prefs(t, testChatID).Weekdays; !reflect.DeepEqual(got, []int{0, 6}) {
+		t.Errorf("Weekdays = %v, want [0 6]", got)
+	}
+	for _, data := range []string{"set:wd:7", "set:wd:-1"} {
+		e.handle(t, bottest.NewCallback(testChatID, 1, data))
+		if got := e.prefs(t, testChatID).Weekdays; !reflect.DeepEqual(got, []int{0, 6}) {
+			t.Errorf("%s: Weekdays = %v, want [0 6]", data, got)
+		}
+	}
+}
+
+func TestSettingsLanguage(t *testing.T) {
+	e := newSettingsEnv(t)
+
+	e.handle(t, bottest.NewCallback(testChatID, 1, "set:lang"))
+	edit := e.lastEdit(t, testChatID)
+	if edit.ReplyMarkup == nil || len(edit.ReplyMarkup.InlineKeyboard[0]) != 2 {
+		t.Fatalf("language menu = %+v, want two options", edit.ReplyMarkup)
+	}
+	if label := edit.ReplyMarkup.InlineKeyboard[0][0].Text; !strings.HasPrefix(label, "✅") {
+		t.Errorf("default language button = %q, want it checked", label)
+	}
+	if !strings.Contains(edit.Text, "Язык уведомлений: 🇷🇺 Русский") {
+		t.Errorf("settings text = %q, want Russian as the language", edit.Text)
+	}
+
+	e.handle(t, bottest.NewCallback(testChatID, 1, "set:lang:1"))
+	if got := e.prefs(t, testChatID).Language; got != "en" {
+		t.Fatalf("Language = %q, want en", got)
+	}
+	if text := e.lastEdit(t, testChatID).Text; !strings.Contains(text, "English") {
+		t.Errorf("settings text = %q, want English as the language", text)
+	}
+
+	e.handle(t, bottest.NewCallback(testChatID, 1, "set:lang:5"))
+	if got := e.prefs(t, testChatID).Language; got != "en" {
+		t.Errorf("Language = %q after an unknown option, want en", got)
+	}
+	e.handle(t, bottest.NewCallback(testChatID, 1, "set:lang:0"))
+	if got := e.prefs(t, testChatID).Language; got != "" {
+		t.Errorf("Language = %q, want the default", got)
+	}
+}
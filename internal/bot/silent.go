@@ -0,0 +1,34 @@
+package bot
+
+import "time"
+
+// SetSilentHours configures a daily "HH:MM"-"HH:MM" window in the bot's
+// location during which notifications are delivered without sound.
+// Empty bounds disable the window.
+func (b *Bot) SetSilentHours(from, to string) {
+	b.silentFrom, b.silentTo = from, to
+}
+
+func (b *Bot) isSilentNow() bool {
+	if b.silentFrom == "" || b.silentTo == "" {
+		return false
+	}
+	return InWindow(b.now().In(b.loc), b.silentFrom, b.silentTo)
+}
+
+// InWindow reports whether t falls into the daily "HH:MM"-"HH:MM" window.
+// Windows where from is later than to wrap around midnight.
+func InWindow(t time.Time, from, to string) bool {
+	f, err1 := time.Parse("15:04", from)
+	e, err2 := time.Parse("15:04", to)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	start := f.Hour()*60 + f.Minute()
+	end := e.Hour()*60 + e.Minute()
+	if start <= end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
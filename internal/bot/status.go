@@ -0,0 +1,41 @@
+package bot
+
+import (
+	"context"
+	"time"
+)
+
+func (b *Bot) recordSend(err error) {
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+	if err != nil {
+		b.lastSendErr = err.Error()
+		b.lastSendErrAt = time.Now()
+		return
+	}
+	b.lastSendAt = time.Now()
+}
+
+// StatusReport implements status.Reporter.
+func (b *Bot) StatusReport(ctx context.Context) interface{} {
+	b.stateMu.RLock()
+	defer b.stateMu.RUnlock()
+
+	report := map[string]interface{}{
+		"bot_username": b.username,
+	}
+	if !b.lastSendAt.IsZero() {
+		report["last_successful_send"] = b.lastSendAt.UTC().Format(time.RFC3339)
+	}
+	if b.lastSendErr != "" {
+		report["last_send_error"] = b.lastSendErr
+		report["last_send_error_at"] = b.lastSendErrAt.UTC().Format(time.RFC3339)
+	}
+	if b.transport != nil {
+		report["reconnects"] = b.transport.reconnects.Load()
+		if at := b.transport.lastReconnectAt.Load(); at != 0 {
+			report["last_reconnect_at"] = time.Unix(0, at).UTC().Format(time.RFC3339)
+		}
+	}
+	return report
+}
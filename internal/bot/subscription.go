@@ -0,0 +1,311 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+// SubscriptionView is the data passed to subscription templates.
+type SubscriptionView struct {
+	Subscribed bool
+	UntilDate  bool
+	ExpiresAt  string // last day of the subscription, DD.MM.YYYY
+	DaysLeft   int
+}
+
+const (
+	subscriptionPrefix = "sub:"
+	// expiryWarningLead is how long before expiry users are offered an extension.
+	expiryWarningLead = 48 * time.Hour
+	maintenanceEvery  = time.Hour
+)
+
+var untilPresets = []struct {
+	Days  int
+	Label string
+}{
+	{14, "2 недели"},
+	{30, "1 месяц"},
+	{60, "2 месяца"},
+	{90, "3 месяца"},
+}
+
+// SetLocation sets the timezone used for dates shown to users.
+func (b *Bot) SetLocation(loc *time.Location) {
+	if loc != nil {
+		b.loc = loc
+	}
+}
+
+// SetClock replaces the time source, used by tests.
+func (b *Bot) SetClock(now func() time.Time) {
+	b.now = now
+}
+
+// RunMaintenance periodically warns about and expires date-limited subscriptions.
+func (b *Bot) RunMaintenance(ctx context.Context) {
+	ticker := time.NewTicker(maintenanceEvery)
+	defer ticker.Stop()
+
+	b.sweepSubscriptions()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.sweepSubscriptions()
+		}
+	}
+}
+
+func (b *Bot) sweepSubscriptions() {
+	now := b.now()
+
+	expiring, err := b.storage.SubscriptionsExpiringBefore(now.Add(expiryWarningLead))
+	if err != nil {
+		b.log.WithError(err).Error("Failed to load expiring subscriptions")
+	}
+	for _, sub := range expiring {
+		if !sub.ExpiresAt.After(now) {
+			continue // expired below without a warning
+		}
+		b.sendExpiryWarning(sub)
+		if err := b.storage.MarkExpiryWarned(sub.ChatID); err != nil {
+			b.log.WithError(err).WithField("chat_id", sub.ChatID).Error("Failed to mark expiry warning")
+		}
+	}
+
+	expired, err := b.storage.ExpiredSubscriptions(now)
+	if err != nil {
+		b.log.WithError(err).Error("Failed to load expired subscriptions")
+		return
+	}
+	for _, sub := range expired {
+		if err := b.removeSubscriber(sub.ChatID, storage.UnsubscribeExpired); err != nil {
+			continue // still subscribed; the next sweep retries
+		}
+		b.log.InfoWithFields("Subscription expired", logger.Fields{
+			"chat_id":    sub.ChatID,
+			"expires_at": sub.ExpiresAt,
+		})
+		text := "⌛ Срок подписки закончился, уведомления больше не приходят.\n\nЧтобы подписаться снова, отправьте /subscribe."
+		if b.templateRenderer != nil {
+			if rendered, ok := b.rendered(b.templateRenderer.GetSubscriptionExpiredMessage()); ok {
+				text = rendered
+			}
+		}
+		msg := tgbotapi.NewMessage(sub.ChatID, text)
+		msg.ReplyMarkup = b.createMainKeyboard(sub.ChatID)
+		if _, err := b.send(msg); err != nil {
+			b.log.WithError(err).WithField("chat_id", sub.ChatID).Error("Failed to send expiry message")
+		}
+	}
+}
+
+func (b *Bot) sendExpiryWarning(sub storage.Subscription) {
+	view := b.subscriptionView(&sub)
+	text := fmt.Sprintf("⏳ Подписка закончится %s.\n\nНужно продлить?", view.ExpiresAt)
+	if b.templateRenderer != nil {
+		if rendered, ok := b.rendered(b.templateRenderer.GetSubscriptionExpiringMessage(view)); ok {
+			text = rendered
+		}
+	}
+	msg := tgbotapi.NewMessage(sub.ChatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➕ Продлить на месяц", subscriptionPrefix+"extend:30"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("♾ Без срока", subscriptionPrefix+"forever"),
+		),
+	)
+	if _, err := b.send(msg); err != nil {
+		b.log.WithError(err).WithField("chat_id", sub.ChatID).Error("Failed to send expiry warning")
+	}
+}
+
+// offerUntilDate asks a freshly subscribed user whether the subscription should end on a date.
+func (b *Bot) offerUntilDate(chatID int64) {
+	msg := tgbotapi.NewMessage(chatID, "📅 Нужны уведомления только до определённой даты, например до экзамена?\n\nВыберите срок или отправьте /until ДД.ММ.ГГГГ.")
+	msg.ReplyMarkup = b.untilKeyboard()
+	if _, err := b.send(msg); err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to send subscription term offer")
+	}
+}
+
+func (b *Bot) untilKeyboard() tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, p := range untilPresets {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(p.Label, subscriptionPrefix+"until:"+strconv.Itoa(p.Days)))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		row[:2], row[2:],
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("♾ Без срока", subscriptionPrefix+"forever"),
+		),
+	)
+}
+
+func (b *Bot) handleSubscriptionCallback(chatID int64, messageID int, data string) {
+	parts := strings.Split(data, ":")
+	now := b.now()
+
+	var expiresAt *time.Time
+	switch parts[0] {
+	case "menu":
+		edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID,
+			"📅 До какой даты присылать уведомления?\n\nВыберите срок или отправьте /until ДД.ММ.ГГГГ.", b.untilKeyboard())
+		if _, err := b.send(edit); err != nil {
+			b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to show subscription term menu")
+		}
+		return
+	case "until", "extend":
+		if len(parts) < 2 {
+			return
+		}
+		days, err := strconv.Atoi(parts[1])
+		if err != nil || days <= 0 {
+			return
+		}
+		from := now
+		if parts[0] == "extend" {
+			if sub, err := b.storage.GetSubscription(chatID); err == nil && sub.ExpiresAt != nil && sub.ExpiresAt.After(now) {
+				from = *sub.ExpiresAt
+			}
+		}
+		t := b.endOfDay(from.AddDate(0, 0, days))
+		expiresAt = &t
+	case "forever":
+	default:
+		return
+	}
+
+	text := b.applySubscriptionExpiry(chatID, expiresAt)
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	if _, err := b.send(edit); err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to confirm subscription term")
+	}
+}
+
+func (b *Bot) handleUntilCommand(chatID int64, args string) {
+	day, err := time.ParseInLocation("02.01.2006", strings.TrimSpace(args), b.loc)
+	if err != nil {
+		b.reply(chatID, "⚠️ Укажите дату в формате ДД.ММ.ГГГГ, например: /until 25.12.2026")
+		return
+	}
+	expiresAt := b.endOfDay(day)
+	if !expiresAt.After(b.now()) {
+		b.reply(chatID, "⚠️ Дата должна быть в будущем")
+		return
+	}
+	b.reply(chatID, b.applySubscriptionExpiry(chatID, &expiresAt))
+}
+
+func (b *Bot) applySubscriptionExpiry(chatID int64, expiresAt *time.Time) string {
+	err := b.storage.SetSubscriptionExpiry(chatID, expiresAt)
+	if errors.Is(err, storage.ErrNotSubscribed) {
+		return "🔕 Вы не подписаны на уведомления. Отправьте /subscribe, чтобы подписаться."
+	}
+	if err != nil {
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to set subscription expiry")
+		return "❌ Не удалось сохранить срок подписки"
+	}
+
+	b.log.InfoWithFields("Subscription term updated", logger.Fields{
+		"chat_id":    chatID,
+		"expires_at": expiresAt,
+	})
+	if expiresAt == nil {
+		return "♾ Подписка без срока: уведомления будут приходить, пока вы не отпишетесь."
+	}
+	return fmt.Sprintf("✅ Уведомления будут приходить до %s включительно.", b.lastDay(*expiresAt))
+}
+
+func (b *Bot) handleStatus(chatID int64) {
+	var view SubscriptionView
+	sub, err := b.storage.GetSubscription(chatID)
+	switch {
+	case err == nil:
+		view = b.subscriptionView(&sub)
+	case errors.Is(err, storage.ErrNotSubscribed):
+		view = b.subscriptionView(nil)
+	default:
+		b.log.WithError(err).WithField("chat_id", chatID).Error("Failed to load subscription")
+		b.reply(chatID, "❌ Не удалось получить статус подписки")
+		return
+	}
+
+	var text string
+	switch {
+	case !view.Subscribed:
+		text = "🔕 Вы не подписаны на уведомления."
+	case view.UntilDate:
+		text = fmt.Sprintf("✅ Вы подписаны до %s (осталось дней: %d).", view.ExpiresAt, view.DaysLeft)
+	default:
+		text = "✅ Вы подписаны на уведомления без срока."
+	}
+	if b.templateRenderer != nil {
+		if rendered, ok := b.rendered(b.templateRenderer.GetStatusMessage(view)); ok {
+			text = rendered
+		}
+	}
+	if b.isAdmin(chatID) && b.notifierControl != nil {
+		text += "\n\n" + b.healthLine(b.notifierControl.Health())
+	}
+	b.reply(chatID, text)
+}
+
+// healthLine summarizes checker health for admins.
+func (b *Bot) healthLine(h HealthView) string {
+	last := "ещё не было"
+	if !h.LastSuccess.IsZero() {
+		last = h.LastSuccess.In(b.loc).Format("02.01 15:04:05")
+	}
+	switch {
+	case h.Paused:
+		return "⏸ Проверки приостановлены. Последняя успешная: " + last
+	case h.Healthy:
+		return "🩺 Проверки работают. Последняя успешная: " + last
+	}
+	line := "🚨 Проверки не проходят. Последняя успешная: " + last
+	if h.LastError != "" {
+		line += "\nОшибка: " + h.LastError
+	}
+	return line
+}
+
+func (b *Bot) subscriptionView(sub *storage.Subscription) SubscriptionView {
+	if sub == nil {
+		return SubscriptionView{}
+	}
+	view := SubscriptionView{Subscribed: true}
+	if sub.ExpiresAt != nil {
+		view.UntilDate = true
+		view.ExpiresAt = b.lastDay(*sub.ExpiresAt)
+		left := sub.ExpiresAt.Sub(b.now())
+		view.DaysLeft = int((left + 24*time.Hour - 1) / (24 * time.Hour))
+		if view.DaysLeft < 0 {
+			view.DaysLeft = 0
+		}
+	}
+	return view
+}
+
+// endOfDay returns the moment the given local day ends.
+func (b *Bot) endOfDay(t time.Time) time.Time {
+	t = t.In(b.loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, b.loc).AddDate(0, 0, 1)
+}
+
+// lastDay formats the last full day before an end-of-day expiry.
+func (b *Bot) lastDay(expiresAt time.Time) string {
+	return expiresAt.In(b.loc).Add(-time.Second).Format("02.01.2006")
+}
@@ -0,0 +1,190 @@
+package bot_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot/bottest"
+)
+
+const (
+	expiryWarningText = "⏳ Подписка закончится"
+	expiredText       = "⌛ Срок подписки закончился"
+)
+
+// fakeClock is a settable time source shared with the update loop.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newSubscriptionEnv(t *testing.T) (*testEnv, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)}
+	e := newTestEnv(t, func(b *bot.Bot) { b.SetClock(clock.Now) })
+	return e, clock
+}
+
+// subscribeUntil subscribes chatID with a subscription ending at expiresAt.
+func (e *testEnv) subscribeUntil(t *testing.T, chatID int64, expiresAt time.Time) {
+	t.Helper()
+	if err := e.store.AddSubscriber(context.Background(), chatID); err != nil {
+		t.Fatalf("AddSubscriber: %v", err)
+	}
+	if err := e.store.SetSubscriptionExpiry(chatID, &expiresAt); err != nil {
+		t.Fatalf("SetSubscriptionExpiry: %v", err)
+	}
+}
+
+// sweep runs one pass of the subscription maintenance.
+func (e *testEnv) sweep() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.b.RunMaintenance(ctx)
+}
+
+func countPrefixed(msgs []string, prefix string) int {
+	n := 0
+	for _, m := range msgs {
+		if strings.HasPrefix(m, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestExpiryWarningSentOnceWithin48Hours(t *testing.T) {
+	e, clock := newSubscriptionEnv(t)
+	e.subscribeUntil(t, testChatID, clock.Now().Add(72*time.Hour))
+
+	e.sweep()
+	if got := countPrefixed(e.api.MessagesTo(testChatID), expiryWarningText); got != 0 {
+		t.Fatalf("warned %d times three days before expiry, want 0", got)
+	}
+
+	clock.Advance(25 * time.Hour)
+	e.sweep()
+	e.sweep()
+	if got := countPrefixed(e.api.MessagesTo(testChatID), expiryWarningText); got != 1 {
+		t.Fatalf("warned %d times within 48 hours of expiry, want 1", got)
+	}
+
+	var offer tgbotapi.MessageConfig
+	for _, c := range e.api.Sent() {
+		if m, ok := c.(tgbotapi.MessageConfig); ok && strings.HasPrefix(m.Text, expiryWarningText) {
+			offer = m
+		}
+	}
+	k, ok := offer.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	if !ok || len(k.InlineKeyboard) != 2 || *k.InlineKeyboard[0][0].CallbackData != "sub:extend:30" {
+		t.Errorf("warning keyboard = %+v, want the extend and forever buttons", offer.ReplyMarkup)
+	}
+	if !e.subscribed(t, testChatID) {
+		t.Error("chat unsubscribed before expiry")
+	}
+}
+
+func TestExpiredSubscriptionRemoved(t *testing.T) {
+	e, clock := newSubscriptionEnv(t)
+	e.subscribeUntil(t, testChatID, clock.Now().Add(time.Hour))
+	e.subscribeUntil(t, testChatID+1, clock.Now().Add(240*time.Hour))
+
+	clock.Advance(2 * time.Hour)
+	e.sweep()
+
+	if e.subscribed(t, testChatID) {
+		t.Error("expired subscription still active")
+	}
+	if got := e.lastMessage(t, testChatID); !strings.HasPrefix(got, expiredText) {
+		t.Errorf("last message = %q, want the expiry notice", got)
+	}
+	if got := countPrefixed(e.api.MessagesTo(testChatID), expiryWarningText); got != 0 {
+		t.Errorf("expired chat got %d warnings, want none after expiry", got)
+	}
+	if !e.subscribed(t, testChatID+1) {
+		t.Error("subscription with days left was removed")
+	}
+}
+
+func TestExtendSubscriptionFromWarning(t *testing.T) {
+	e, clock := newSubscriptionEnv(t)
+	expiresAt := clock.Now().Add(24 * time.Hour)
+	e.subscribeUntil(t, testChatID, expiresAt)
+	e.sweep()
+
+	e.handle(t, bottest.NewCallback(testChatID, 1, "sub:extend:30"))
+
+	sub, err := e.store.GetSubscription(testChatID)
+	if err != nil {
+		t.Fatalf("GetSubscription: %v", err)
+	}
+	// Extended from the old end date, to the end of that day.
+	want := time.Date(2026, 4, 11, 0, 0, 0, 0, time.UTC)
+	if sub.ExpiresAt == nil || !sub.ExpiresAt.Equal(want) {
+		t.Fatalf("expires at %v, want %s", sub.ExpiresAt, want)
+	}
+
+	clock.Advance(48 * time.Hour)
+	e.sweep()
+	if !e.subscribed(t, testChatID) {
+		t.Error("extended subscription expired at the old date")
+	}
+	if got := countPrefixed(e.api.MessagesTo(testChatID), expiredText); got != 0 {
+		t.Errorf("sent %d expiry notices after the extension", got)
+	}
+}
+
+func TestExpiryNoticeWaitsForRemoval(t *testing.T) {
+	e, clock := newSubscriptionEnv(t)
+	e.subscribeUntil(t, testChatID, clock.Now().Add(time.Hour))
+	clock.Advance(2 * time.Hour)
+
+	e.store.setRemoveErr(bottest.ErrFake)
+	e.sweep()
+	if !e.subscribed(t, testChatID) {
+		t.Fatal("subscription removed despite the storage error")
+	}
+	if got := countPrefixed(e.api.MessagesTo(testChatID), expiredText); got != 0 {
+		t.Fatalf("sent %d expiry notices although removal failed", got)
+	}
+
+	e.store.setRemoveErr(nil)
+	e.sweep()
+	if e.subscribed(t, testChatID) {
+		t.Error("retry did not remove the expired subscription")
+	}
+	if got := countPrefixed(e.api.MessagesTo(testChatID), expiredText); got != 1 {
+		t.Errorf("sent %d expiry notices after the retry, want 1", got)
+	}
+}
+
+func TestUnsubscribeReportsStorageError(t *testing.T) {
+	e := newTestEnv(t)
+	e.handle(t, bottest.NewCommand(testChatID, "subscribe"))
+
+	e.store.setRemoveErr(bottest.ErrFake)
+	e.handle(t, bottest.NewCommand(testChatID, "unsubscribe"))
+	if !e.subscribed(t, testChatID) {
+		t.Fatal("chat unsubscribed despite the storage error")
+	}
+	if got := e.lastMessage(t, testChatID); !strings.HasPrefix(got, "❌") {
+		t.Errorf("reply = %q, want an error instead of the goodbye", got)
+	}
+}
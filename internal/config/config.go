@@ -1,8 +1,12 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -13,32 +17,154 @@ import (
 
 // Config holds application configuration loaded from environment variables.
 // Required: TELEGRAM_TOKEN, YCLIENTS_LOGIN, YCLIENTS_PASSWORD, YCLIENTS_PARTNER_TOKEN, YCLIENTS_FORM_ID
-// Optional: YCLIENTS_COMPANY_ID (default 780413), TIMEZONE (default Europe/Moscow), CHECK_INTERVAL_SECONDS (default 60s)
+// Optional: YCLIENTS_COMPANY_ID (default 780413), TIMEZONE (default Europe/Moscow), CHECK_INTERVAL_SECONDS (default 60s),
+// TELEGRAM_SEND_TIMEOUT_SECONDS (default 10s), SILENT_HOURS (e.g. 23:00-08:00, disabled by default),
+// ADMIN_CHAT_IDS (comma-separated), TEMPLATES_DIR (external template overrides),
+// NOTIFY_SEATS_DECREASE (default false), NOTIFY_SLOT_TAKEN (default false), START_SUBSCRIBES (default false; /start also subscribes),
+// ALLOWED_CHAT_IDS (comma-separated), INVITE_CODE (either one restricts access; open by default),
+// CURRENT_COOLDOWN_SECONDS (default 30s per chat, 0 disables), BOOKING_URL (fallback booking link),
+// BOOKING_URLS (JSON object mapping service ID to booking link),
+// STAFF_NAMES (JSON object mapping staff ID to display name; overrides names fetched from YCLIENTS),
+// STORAGE_ENCRYPTION_KEY (encrypts personal data in the database; rotate with "notifier db rotate-key"),
+// ADMIN_API_TOKEN (bearer token for PUT /api/loglevel; without it only localhost may change the level),
+// BULK_ANNOUNCE_MIN_SLOTS (default 15, 0 disables), BULK_ANNOUNCE_MIN_DATES (default 4),
+// MAX_SLOT_MESSAGES_PER_CHECK (slot messages per subscriber per check, default 10, 0 disables),
+// NOTIFICATION_LOG_RETENTION_DAYS (default 30), DIGEST_TIME (default 19:00),
+// DIGEST_SUGGEST_AFTER (daily notifications before digest mode is offered, default 5, 0 disables),
+// YCLIENTS_CONSISTENCY_CHECK (strict, warn or off; default warn), LOOKAHEAD_DAYS (default 30),
+// YCLIENTS_RETRY_ATTEMPTS (attempts per availability request on network errors, 429 and 5xx, default 3, 1 disables retries),
+// YCLIENTS_RATE_LIMIT (requests per second to YCLIENTS, default 5, 0 disables), YCLIENTS_RATE_BURST (default 5),
+// YCLIENTS_HTTP_TIMEOUT (seconds per HTTP request to YCLIENTS, default 10),
+// YCLIENTS_REQUEST_TIMEOUT (seconds per availability request attempt, default 0: only YCLIENTS_HTTP_TIMEOUT applies),
+// YCLIENTS_TOKEN_TTL (seconds a user token is reused before authenticating again, default 3600; a 401 refreshes it earlier),
+// YCLIENTS_DEBUG_DUMP_DIR (directory for raw availability requests and responses, default empty: off),
+// REMINDER_TIME (evening reminder about tomorrow's slots, default 20:00),
+// WEEKLY_REPORT_DAY (weekday of the admin report, e.g. mon, default mon, "off" disables), WEEKLY_REPORT_TIME (default 09:00),
+// DB_MAINTENANCE_TIME (daily integrity check, WAL checkpoint and VACUUM when needed, default 04:00, "off" disables),
+// SCAN_CONCURRENCY (parallel YCLIENTS requests per check, default 4),
+// CHECK_JITTER_SECONDS (random ± spread of the poll interval, default 0),
+// CHECK_TIMEOUT_SECONDS (upper bound on one check, default 90% of the poll interval, -1 disables),
+// STARTUP_CHECK_DELAY_SECONDS (delay before the check run at startup, default 5s, -1 disables),
+// STAFF_CACHE_TTL_SECONDS (how long bookable staff IDs are reused between checks, default 3600, 0 disables),
+// CURRENT_CACHE_TTL_SECONDS (how long a complete scan answers /current, default 45, 0 disables),
+// PURGE_REMOVED_SERVICE_SLOTS (forget seen slots of services dropped from YCLIENTS_SERVICE_IDS, default false),
+// DRY_RUN (check and log but never message subscribers, default false),
+// DRY_RUN_ADMIN_PREVIEW (in dry run, show would-be messages to admins, default false)
+
+// Modes of the startup check that YCLIENTS_FORM_ID belongs to YCLIENTS_COMPANY_ID.
+const (
+	ConsistencyStrict = "strict" // refuse to start on mismatch
+	ConsistencyWarn   = "warn"   // log mismatches and continue
+	ConsistencyOff    = "off"    // skip the check, e.g. for offline development
+)
 
 type Config struct {
-	TelegramToken        string
-	YClientsLogin        string
-	YClientsPassword     string
-	YClientsPartnerToken string
-	YClientsCompanyID    string
-	YClientsFormID       string
-	Timezone             string
-	ServiceIDs           []int
-	PollInterval         time.Duration
+	TelegramToken            string
+	YClientsLogin            string
+	YClientsPassword         string
+	YClientsPartnerToken     string
+	YClientsCompanyID        string
+	YClientsFormID           string
+	Timezone                 string
+	ServiceIDs               []int
+	PollInterval             time.Duration
+	TelegramSendTimeout      time.Duration
+	SilentHoursFrom          string
+	SilentHoursTo            string
+	AdminChatIDs             []int64
+	TemplatesDir             string
+	NotifySeatsDecrease      bool
+	NotifySlotTaken          bool
+	StartSubscribes          bool
+	AllowedChatIDs           []int64
+	InviteCode               string
+	CurrentCooldown          time.Duration
+	BookingURL               string
+	BookingURLs              map[int]string
+	StaffNames               map[int]string
+	StorageEncryptionKey     string
+	AdminAPIToken            string
+	BulkMinSlots             int
+	BulkMinDates             int
+	MaxSlotMessages          int
+	NotificationRetention    time.Duration
+	DigestTime               string
+	DigestSuggestAfter       int
+	YClientsConsistencyCheck string
+	YClientsRetryAttempts    int
+	YClientsRateLimit        float64
+	YClientsRateBurst        int
+	YClientsHTTPTimeout      time.Duration
+	YClientsRequestTimeout   time.Duration
+	YClientsDebugDumpDir     string
+	YClientsTokenTTL         time.Duration
+	LookaheadDays            int
+	ReminderTime             string
+	WeeklyReport             bool
+	WeeklyReportDay          time.Weekday
+	WeeklyReportTime         string
+	DBMaintenance            bool
+	DBMaintenanceTime        string
+	ScanConcurrency          int
+	StartupCheckDelay        time.Duration
+	PollJitter               time.Duration
+	CheckTimeout             time.Duration
+	StaffCacheTTL            time.Duration
+	CurrentCacheTTL          time.Duration
+	PurgeRemovedServiceSlots bool
+	DryRun                   bool
+	DryRunAdminPreview       bool
 }
 
 func Load() (Config, error) {
 	_ = godotenv.Load() // ignore error if .env doesn't exist
 
 	cfg := Config{
-		TelegramToken:        os.Getenv("TELEGRAM_TOKEN"),
-		YClientsLogin:        os.Getenv("YCLIENTS_LOGIN"),
-		YClientsPassword:     os.Getenv("YCLIENTS_PASSWORD"),
-		YClientsPartnerToken: os.Getenv("YCLIENTS_PARTNER_TOKEN"),
-		YClientsCompanyID:    firstNonEmpty(os.Getenv("YCLIENTS_COMPANY_ID"), "780413"),
-		YClientsFormID:       os.Getenv("YCLIENTS_FORM_ID"),
-		Timezone:             firstNonEmpty(os.Getenv("TIMEZONE"), "Europe/Moscow"),
-		PollInterval:         60 * time.Second,
+		TelegramToken:            os.Getenv("TELEGRAM_TOKEN"),
+		YClientsLogin:            os.Getenv("YCLIENTS_LOGIN"),
+		YClientsPassword:         os.Getenv("YCLIENTS_PASSWORD"),
+		YClientsPartnerToken:     os.Getenv("YCLIENTS_PARTNER_TOKEN"),
+		YClientsCompanyID:        firstNonEmpty(os.Getenv("YCLIENTS_COMPANY_ID"), "780413"),
+		YClientsFormID:           os.Getenv("YCLIENTS_FORM_ID"),
+		YClientsDebugDumpDir:     strings.TrimSpace(os.Getenv("YCLIENTS_DEBUG_DUMP_DIR")),
+		Timezone:                 firstNonEmpty(os.Getenv("TIMEZONE"), "Europe/Moscow"),
+		PollInterval:             60 * time.Second,
+		TelegramSendTimeout:      10 * time.Second,
+		CurrentCooldown:          30 * time.Second,
+		BulkMinSlots:             15,
+		BulkMinDates:             4,
+		MaxSlotMessages:          10,
+		NotificationRetention:    30 * 24 * time.Hour,
+		DigestTime:               "19:00",
+		DigestSuggestAfter:       5,
+		YClientsConsistencyCheck: ConsistencyWarn,
+		YClientsRetryAttempts:    3,
+		YClientsRateLimit:        5,
+		YClientsRateBurst:        5,
+		YClientsHTTPTimeout:      10 * time.Second,
+		YClientsTokenTTL:         time.Hour,
+		LookaheadDays:            30,
+		ReminderTime:             "20:00",
+		WeeklyReport:             true,
+		WeeklyReportDay:          time.Monday,
+		WeeklyReportTime:         "09:00",
+		DBMaintenance:            true,
+		DBMaintenanceTime:        "04:00",
+		ScanConcurrency:          4,
+		StartupCheckDelay:        5 * time.Second,
+		StaffCacheTTL:            time.Hour,
+		CurrentCacheTTL:          45 * time.Second,
+		TemplatesDir:             strings.TrimSpace(os.Getenv("TEMPLATES_DIR")),
+		NotifySeatsDecrease:      parseBool(os.Getenv("NOTIFY_SEATS_DECREASE")),
+		NotifySlotTaken:          parseBool(os.Getenv("NOTIFY_SLOT_TAKEN")),
+		PurgeRemovedServiceSlots: parseBool(os.Getenv("PURGE_REMOVED_SERVICE_SLOTS")),
+		DryRun:                   parseBool(os.Getenv("DRY_RUN")),
+		DryRunAdminPreview:       parseBool(os.Getenv("DRY_RUN_ADMIN_PREVIEW")),
+		StartSubscribes:          parseBool(os.Getenv("START_SUBSCRIBES")),
+		InviteCode:               strings.TrimSpace(os.Getenv("INVITE_CODE")),
+		BookingURL:               strings.TrimSpace(os.Getenv("BOOKING_URL")),
+		StorageEncryptionKey:     os.Getenv("STORAGE_ENCRYPTION_KEY"),
+		AdminAPIToken:            strings.TrimSpace(os.Getenv("ADMIN_API_TOKEN")),
 	}
 
 	if s := strings.TrimSpace(os.Getenv("YCLIENTS_SERVICE_IDS")); s != "" {
@@ -57,18 +183,317 @@ func Load() (Config, error) {
 		}
 	}
 
+	cfg.AdminChatIDs = parseChatIDs("ADMIN_CHAT_IDS")
+	cfg.AllowedChatIDs = parseChatIDs("ALLOWED_CHAT_IDS")
+
 	if s := strings.TrimSpace(os.Getenv("CHECK_INTERVAL_SECONDS")); s != "" {
 		if n, err := strconv.Atoi(s); err == nil && n > 0 {
 			cfg.PollInterval = time.Duration(n) * time.Second
 		}
 	}
 
+	if s := strings.TrimSpace(os.Getenv("TELEGRAM_SEND_TIMEOUT_SECONDS")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			cfg.TelegramSendTimeout = time.Duration(n) * time.Second
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("CHECK_JITTER_SECONDS")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			cfg.PollJitter = time.Duration(n) * time.Second
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("CHECK_TIMEOUT_SECONDS")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil {
+			cfg.CheckTimeout = time.Duration(n) * time.Second
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("STARTUP_CHECK_DELAY_SECONDS")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil {
+			cfg.StartupCheckDelay = time.Duration(n) * time.Second
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("STAFF_CACHE_TTL_SECONDS")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			cfg.StaffCacheTTL = time.Duration(n) * time.Second
+			if n == 0 {
+				cfg.StaffCacheTTL = -1 // the notifier treats zero as "use the default"
+			}
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("CURRENT_CACHE_TTL_SECONDS")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			cfg.CurrentCacheTTL = time.Duration(n) * time.Second
+			if n == 0 {
+				cfg.CurrentCacheTTL = -1
+			}
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("CURRENT_COOLDOWN_SECONDS")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			cfg.CurrentCooldown = time.Duration(n) * time.Second
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("BULK_ANNOUNCE_MIN_SLOTS")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			cfg.BulkMinSlots = n
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("BULK_ANNOUNCE_MIN_DATES")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			cfg.BulkMinDates = n
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("MAX_SLOT_MESSAGES_PER_CHECK")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			cfg.MaxSlotMessages = n
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("NOTIFICATION_LOG_RETENTION_DAYS")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			cfg.NotificationRetention = time.Duration(n) * 24 * time.Hour
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("LOOKAHEAD_DAYS")); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return Config{}, fmt.Errorf("invalid LOOKAHEAD_DAYS %q: expected a positive number of days", s)
+		}
+		cfg.LookaheadDays = n
+	}
+
+	if s := strings.TrimSpace(os.Getenv("SCAN_CONCURRENCY")); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return Config{}, fmt.Errorf("invalid SCAN_CONCURRENCY %q: expected a positive number", s)
+		}
+		cfg.ScanConcurrency = n
+	}
+
+	if s := strings.TrimSpace(os.Getenv("DIGEST_TIME")); s != "" {
+		if _, err := time.Parse("15:04", s); err != nil {
+			return Config{}, fmt.Errorf("invalid DIGEST_TIME: expected HH:MM, got %q", s)
+		}
+		cfg.DigestTime = s
+	}
+
+	if s := strings.TrimSpace(os.Getenv("REMINDER_TIME")); s != "" {
+		if _, err := time.Parse("15:04", s); err != nil {
+			return Config{}, fmt.Errorf("invalid REMINDER_TIME: expected HH:MM, got %q", s)
+		}
+		cfg.ReminderTime = s
+	}
+
+	if s := strings.ToLower(strings.TrimSpace(os.Getenv("WEEKLY_REPORT_DAY"))); s != "" {
+		if s == "off" {
+			cfg.WeeklyReport = false
+		} else {
+			wd, ok := parseWeekday(s)
+			if !ok {
+				return Config{}, fmt.Errorf("invalid WEEKLY_REPORT_DAY %q: expected mon..sun or off", s)
+			}
+			cfg.WeeklyReportDay = wd
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("WEEKLY_REPORT_TIME")); s != "" {
+		if _, err := time.Parse("15:04", s); err != nil {
+			return Config{}, fmt.Errorf("invalid WEEKLY_REPORT_TIME: expected HH:MM, got %q", s)
+		}
+		cfg.WeeklyReportTime = s
+	}
+
+	if s := strings.ToLower(strings.TrimSpace(os.Getenv("DB_MAINTENANCE_TIME"))); s != "" {
+		if s == "off" {
+			cfg.DBMaintenance = false
+		} else {
+			if _, err := time.Parse("15:04", s); err != nil {
+				return Config{}, fmt.Errorf("invalid DB_MAINTENANCE_TIME: expected HH:MM or off, got %q", s)
+			}
+			cfg.DBMaintenanceTime = s
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("DIGEST_SUGGEST_AFTER")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			cfg.DigestSuggestAfter = n
+		}
+	}
+
+	if s := strings.ToLower(strings.TrimSpace(os.Getenv("YCLIENTS_CONSISTENCY_CHECK"))); s != "" {
+		switch s {
+		case ConsistencyStrict, ConsistencyWarn, ConsistencyOff:
+			cfg.YClientsConsistencyCheck = s
+		default:
+			return Config{}, fmt.Errorf("invalid YCLIENTS_CONSISTENCY_CHECK %q: expected strict, warn or off", s)
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("YCLIENTS_RETRY_ATTEMPTS")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			cfg.YClientsRetryAttempts = n
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("YCLIENTS_RATE_LIMIT")); s != "" {
+		if f, err := strconv.ParseFloat(s, 64); err == nil && f >= 0 {
+			cfg.YClientsRateLimit = f
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("YCLIENTS_RATE_BURST")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			cfg.YClientsRateBurst = n
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("YCLIENTS_HTTP_TIMEOUT")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			cfg.YClientsHTTPTimeout = time.Duration(n) * time.Second
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("YCLIENTS_REQUEST_TIMEOUT")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			cfg.YClientsRequestTimeout = time.Duration(n) * time.Second
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("YCLIENTS_TOKEN_TTL")); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			cfg.YClientsTokenTTL = time.Duration(n) * time.Second
+		}
+	}
+
+	if s := strings.TrimSpace(os.Getenv("BOOKING_URLS")); s != "" {
+		urls, err := parseBookingURLs(s)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid BOOKING_URLS: %w", err)
+		}
+		cfg.BookingURLs = urls
+	}
+
+	if s := strings.TrimSpace(os.Getenv("STAFF_NAMES")); s != "" {
+		names, err := parseStaffNames(s)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid STAFF_NAMES: %w", err)
+		}
+		cfg.StaffNames = names
+	}
+
+	if s := strings.TrimSpace(os.Getenv("SILENT_HOURS")); s != "" {
+		from, to, err := parseWindow(s)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid SILENT_HOURS: %w", err)
+		}
+		cfg.SilentHoursFrom, cfg.SilentHoursTo = from, to
+	}
+
 	if cfg.TelegramToken == "" || cfg.YClientsLogin == "" || cfg.YClientsPassword == "" || cfg.YClientsPartnerToken == "" || cfg.YClientsFormID == "" {
 		return Config{}, errors.New("missing required env vars: TELEGRAM_TOKEN, YCLIENTS_LOGIN, YCLIENTS_PASSWORD, YCLIENTS_PARTNER_TOKEN, YCLIENTS_FORM_ID")
 	}
 	return cfg, nil
 }
 
+// parseChatIDs reads a comma-separated list of chat IDs from the named variable.
+func parseChatIDs(name string) []int64 {
+	var ids []int64
+	for _, p := range strings.Split(os.Getenv(name), ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if n, err := strconv.ParseInt(p, 10, 64); err == nil {
+			ids = append(ids, n)
+		} else {
+			fmt.Printf("Warning: invalid chat ID '%s' in %s ignored\n", p, name)
+		}
+	}
+	return ids
+}
+
+// parseBookingURLs parses a JSON object like {"15728488": "https://n841217.yclients.com/"}.
+func parseBookingURLs(s string) (map[int]string, error) {
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, err
+	}
+	urls := make(map[int]string, len(raw))
+	for k, v := range raw {
+		id, err := strconv.Atoi(strings.TrimSpace(k))
+		if err != nil {
+			return nil, fmt.Errorf("invalid service ID %q", k)
+		}
+		u, err := url.Parse(strings.TrimSpace(v))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid URL %q for service %d", v, id)
+		}
+		urls[id] = u.String()
+	}
+	return urls, nil
+}
+
+// parseStaffNames parses a JSON object like {"3021877": "Иван"}.
+func parseStaffNames(s string) (map[int]string, error) {
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, err
+	}
+	names := make(map[int]string, len(raw))
+	for k, v := range raw {
+		id, err := strconv.Atoi(strings.TrimSpace(k))
+		if err != nil {
+			return nil, fmt.Errorf("invalid staff ID %q", k)
+		}
+		if v = strings.TrimSpace(v); v != "" {
+			names[id] = v
+		}
+	}
+	return names, nil
+}
+
+// parseWeekday accepts English weekday names, full or abbreviated to
+// three letters.
+func parseWeekday(s string) (time.Weekday, bool) {
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		name := strings.ToLower(wd.String())
+		if s == name || s == name[:3] {
+			return wd, true
+		}
+	}
+	return 0, false
+}
+
+// parseWindow parses "HH:MM-HH:MM" into its two bounds.
+func parseWindow(s string) (string, string, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return "", "", fmt.Errorf("expected HH:MM-HH:MM, got %q", s)
+	}
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	for _, v := range []string{from, to} {
+		if _, err := time.Parse("15:04", v); err != nil {
+			return "", "", fmt.Errorf("invalid time %q", v)
+		}
+	}
+	return from, to, nil
+}
+
+func parseBool(s string) bool {
+	b, err := strconv.ParseBool(strings.TrimSpace(s))
+	return err == nil && b
+}
+
 func firstNonEmpty(values ...string) string {
 	for _, v := range values {
 		if v != "" {
@@ -90,3 +515,31 @@ func (c Config) String() string {
 		mask(c.TelegramToken), mask(c.YClientsLogin), mask(c.YClientsPartnerToken), c.YClientsCompanyID, c.YClientsFormID, c.Timezone, c.PollInterval, c.ServiceIDs,
 	)
 }
+
+// Features reports which optional behaviours are switched on, for the
+// status document.
+func (c Config) Features() map[string]bool {
+	return map[string]bool{
+		"dry_run":                     c.DryRun,
+		"dry_run_admin_preview":       c.DryRunAdminPreview,
+		"notify_seats_decrease":       c.NotifySeatsDecrease,
+		"notify_slot_taken":           c.NotifySlotTaken,
+		"start_subscribes":            c.StartSubscribes,
+		"restricted_access":           len(c.AllowedChatIDs) > 0 || c.InviteCode != "",
+		"storage_encryption":          c.StorageEncryptionKey != "",
+		"silent_hours":                c.SilentHoursFrom != "",
+		"bulk_announce":               c.BulkMinSlots > 0,
+		"digest_suggestion":           c.DigestSuggestAfter > 0,
+		"weekly_report":               c.WeeklyReport,
+		"db_maintenance":              c.DBMaintenance,
+		"purge_removed_service_slots": c.PurgeRemovedServiceSlots,
+		"yclients_debug_dump":         c.YClientsDebugDumpDir != "",
+	}
+}
+
+// Hash returns a short fingerprint of the effective configuration so that
+// deployments can be compared without exposing secrets.
+func (c Config) Hash() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%#v", c)))
+	return hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,57 @@
+package config
+
+import "testing"
+
+// loadWith sets the required variables plus env and loads the config.
+func loadWith(t *testing.T, env map[string]string) Config {
+	t.Helper()
+	for k, v := range map[string]string{
+		"TELEGRAM_TOKEN":         "token",
+		"YCLIENTS_LOGIN":         "login",
+		"YCLIENTS_PASSWORD":      "password",
+		"YCLIENTS_PARTNER_TOKEN": "partner",
+		"YCLIENTS_FORM_ID":       "1",
+	} {
+		t.Setenv(k, v)
+	}
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return cfg
+}
+
+func TestBulkAnnounceThresholds(t *testing.T) {
+	for _, tt := range []struct {
+		name                 string
+		env                  map[string]string
+		wantSlots, wantDates int
+	}{
+		{"defaults", nil, 15, 4},
+		{"configured", map[string]string{"BULK_ANNOUNCE_MIN_SLOTS": "30", "BULK_ANNOUNCE_MIN_DATES": "7"}, 30, 7},
+		{"disabled", map[string]string{"BULK_ANNOUNCE_MIN_SLOTS": "0"}, 0, 4},
+		{"invalid values ignored", map[string]string{"BULK_ANNOUNCE_MIN_SLOTS": "-1", "BULK_ANNOUNCE_MIN_DATES": "0"}, 15, 4},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadWith(t, tt.env)
+			if cfg.BulkMinSlots != tt.wantSlots || cfg.BulkMinDates != tt.wantDates {
+				t.Errorf("thresholds = %d slots, %d dates; want %d, %d", cfg.BulkMinSlots, cfg.BulkMinDates, tt.wantSlots, tt.wantDates)
+			}
+			if got := cfg.Features()["bulk_announce"]; got != (tt.wantSlots > 0) {
+				t.Errorf("bulk_announce feature = %v", got)
+			}
+		})
+	}
+}
+
+func TestPurgeRemovedServiceSlots(t *testing.T) {
+	if loadWith(t, nil).PurgeRemovedServiceSlots {
+		t.Error("seen slots of removed services are purged by default")
+	}
+	if !loadWith(t, map[string]string{"PURGE_REMOVED_SERVICE_SLOTS": "true"}).PurgeRemovedServiceSlots {
+		t.Error("PURGE_REMOVED_SERVICE_SLOTS=true did not enable purging")
+	}
+}
@@ -0,0 +1,27 @@
+// Package format holds user-facing formatting helpers shared across
+// packages.
+package format
+
+import "time"
+
+var (
+	russianWeekdays       = [...]string{"воскресенье", "понедельник", "вторник", "среда", "четверг", "пятница", "суббота"}
+	russianWeekdayAbbrevs = [...]string{"вс", "пн", "вт", "ср", "чт", "пт", "сб"}
+)
+
+// WeekdayRU returns the Russian name of wd, e.g. "понедельник", or an
+// empty string for values outside time.Sunday..time.Saturday.
+func WeekdayRU(wd time.Weekday) string {
+	if wd < time.Sunday || wd > time.Saturday {
+		return ""
+	}
+	return russianWeekdays[wd]
+}
+
+// WeekdayShortRU returns the two-letter Russian abbreviation of wd, e.g. "пн".
+func WeekdayShortRU(wd time.Weekday) string {
+	if wd < time.Sunday || wd > time.Saturday {
+		return ""
+	}
+	return russianWeekdayAbbrevs[wd]
+}
@@ -0,0 +1,63 @@
+package format
+
+import (
+	"testing"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+func TestWeekdayRU(t *testing.T) {
+	tests := []struct {
+		wd    time.Weekday
+		long  string
+		short string
+	}{
+		{time.Monday, "понедельник", "пн"},
+		{time.Tuesday, "вторник", "вт"},
+		{time.Wednesday, "среда", "ср"},
+		{time.Thursday, "четверг", "чт"},
+		{time.Friday, "пятница", "пт"},
+		{time.Saturday, "суббота", "сб"},
+		{time.Sunday, "воскресенье", "вс"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wd.String(), func(t *testing.T) {
+			if got := WeekdayRU(tt.wd); got != tt.long {
+				t.Errorf("WeekdayRU = %q, want %q", got, tt.long)
+			}
+			if got := WeekdayShortRU(tt.wd); got != tt.short {
+				t.Errorf("WeekdayShortRU = %q, want %q", got, tt.short)
+			}
+			for _, s := range []string{WeekdayRU(tt.wd), WeekdayShortRU(tt.wd)} {
+				if !utf8.ValidString(s) {
+					t.Fatalf("%q is not valid UTF-8", s)
+				}
+				for _, r := range s {
+					if !unicode.Is(unicode.Cyrillic, r) {
+						t.Errorf("%q has non-Cyrillic rune %q", s, r)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWeekdayRUOutOfRange(t *testing.T) {
+	for _, wd := range []time.Weekday{-1, 7} {
+		if got := WeekdayRU(wd); got != "" {
+			t.Errorf("WeekdayRU(%d) = %q, want empty", wd, got)
+		}
+		if got := WeekdayShortRU(wd); got != "" {
+			t.Errorf("WeekdayShortRU(%d) = %q, want empty", wd, got)
+		}
+	}
+}
+
+func TestWeekdayRUOfDate(t *testing.T) {
+	// 18 March 2025 was a Tuesday
+	date := time.Date(2025, 3, 18, 10, 0, 0, 0, time.UTC)
+	if got := WeekdayRU(date.Weekday()); got != "вторник" {
+		t.Errorf("WeekdayRU(2025-03-18) = %q, want вторник", got)
+	}
+}
@@ -0,0 +1,188 @@
+package logger
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// levelState holds the effective level shared by a logger and every child
+// derived from it, so a change is visible to all of them at once.
+type levelState struct {
+	current atomic.Value // LogLevel
+
+	mu       sync.Mutex
+	base     LogLevel // level restored when a temporary change expires
+	revertAt time.Time
+	timer    *time.Timer
+}
+
+func newLevelState(level LogLevel) *levelState {
+	s := &levelState{}
+	s.current.Store(level)
+	return s
+}
+
+func (s *levelState) load() LogLevel {
+	return s.current.Load().(LogLevel)
+}
+
+// LevelStatus describes the effective level and a pending auto-revert.
+type LevelStatus struct {
+	Level    LogLevel   `json:"level"`
+	RevertTo LogLevel   `json:"revert_to,omitempty"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// ParseLevel validates a level name case-insensitively.
+func ParseLevel(s string) (LogLevel, error) {
+	level := LogLevel(strings.ToUpper(strings.TrimSpace(s)))
+	switch level {
+	case DebugLevel, InfoLevel, WarnLevel, ErrorLevel:
+		return level, nil
+	}
+	return "", fmt.Errorf("unknown log level %q", s)
+}
+
+// Level returns the effective log level.
+func (l *Logger) Level() LogLevel {
+	return l.level.load()
+}
+
+// ChangeLevel sets the effective level for this logger and all loggers
+// sharing its level. A positive revertAfter restores the previous level
+// once it elapses; zero makes the change permanent.
+func (l *Logger) ChangeLevel(level LogLevel, revertAfter time.Duration) (LevelStatus, error) {
+	level, err := ParseLevel(string(level))
+	if err != nil {
+		return LevelStatus{}, err
+	}
+
+	s := l.level
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	} else {
+		s.base = s.load()
+	}
+	s.current.Store(level)
+	s.revertAt = time.Time{}
+
+	if revertAfter > 0 {
+		s.revertAt = time.Now().Add(revertAfter)
+		var timer *time.Timer
+		timer = time.AfterFunc(revertAfter, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.timer != timer {
+				return // superseded by a later change
+			}
+			s.current.Store(s.base)
+			s.timer = nil
+			s.revertAt = time.Time{}
+		})
+		s.timer = timer
+	}
+	return s.status(), nil
+}
+
+// LevelStatus reports the effective level and any pending auto-revert.
+func (l *Logger) LevelStatus() LevelStatus {
+	l.level.mu.Lock()
+	defer l.level.mu.Unlock()
+	return l.level.status()
+}
+
+func (s *levelState) status() LevelStatus {
+	st := LevelStatus{Level: s.load()}
+	if s.timer != nil {
+		at := s.revertAt.UTC()
+		st.RevertTo = s.base
+		st.RevertAt = &at
+	}
+	return st
+}
+
+// StatusReport implements the status reporter used by /api/status.
+func (l *Logger) StatusReport(ctx context.Context) interface{} {
+	return l.LevelStatus()
+}
+
+// LevelHandler serves the effective level on GET and changes it on PUT with
+// a JSON body like {"level": "DEBUG", "duration": "30m"}. A PUT must carry
+// "Authorization: Bearer <token>"; with an empty token only loopback
+// clients may change the level.
+func (l *Logger) LevelHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			if !authorized(r, token) {
+				l.WarnWithFields("Rejected log level change via API", Fields{"remote_addr": r.RemoteAddr})
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			var req struct {
+				Level    string `json:"level"`
+				Duration string `json:"duration"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid JSON body", http.StatusBadRequest)
+				return
+			}
+			var revertAfter time.Duration
+			if req.Duration != "" {
+				d, err := time.ParseDuration(req.Duration)
+				if err != nil || d < 0 {
+					http.Error(w, "invalid duration", http.StatusBadRequest)
+					return
+				}
+				revertAfter = d
+			}
+			st, err := l.ChangeLevel(LogLevel(req.Level), revertAfter)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			l.WarnWithFields("Log level changed via API", Fields{
+				"level":       st.Level,
+				"revert_at":   st.RevertAt,
+				"remote_addr": r.RemoteAddr,
+			})
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(l.LevelStatus()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// authorized reports whether r may change the level: it must present token
+// as a bearer credential or, when no token is configured, come from loopback.
+func authorized(r *http.Request, token string) bool {
+	if token == "" {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return false
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
@@ -0,0 +1,155 @@
+package logger
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLogger() *Logger {
+	return New().WithOutput(io.Discard)
+}
+
+// waitLevel polls until l reaches want or the deadline passes.
+func waitLevel(t *testing.T, l *Logger, want LogLevel) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for l.Level() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Level = %s, want %s", l.Level(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestChangeLevelRevertsAfterDuration(t *testing.T) {
+	l := testLogger()
+	child := l.WithField("component", "bot")
+
+	st, err := l.ChangeLevel(DebugLevel, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("ChangeLevel: %v", err)
+	}
+	if st.Level != DebugLevel || st.RevertTo != InfoLevel || st.RevertAt == nil {
+		t.Fatalf("status = %+v, want DEBUG reverting to INFO", st)
+	}
+	if child.Level() != DebugLevel {
+		t.Errorf("child Level = %s, want DEBUG shared with the parent", child.Level())
+	}
+
+	waitLevel(t, l, InfoLevel)
+	if st := l.LevelStatus(); st.RevertAt != nil {
+		t.Errorf("status after revert = %+v, want no pending revert", st)
+	}
+}
+
+func TestChangeLevelSupersedesPendingRevert(t *testing.T) {
+	l := testLogger()
+
+	if _, err := l.ChangeLevel(DebugLevel, 30*time.Millisecond); err != nil {
+		t.Fatalf("ChangeLevel: %v", err)
+	}
+	// A second temporary change keeps the original base level.
+	if _, err := l.ChangeLevel(WarnLevel, time.Hour); err != nil {
+		t.Fatalf("ChangeLevel: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if l.Level() != WarnLevel {
+		t.Fatalf("Level = %s, want WARN: the first timer must not fire", l.Level())
+	}
+	if st := l.LevelStatus(); st.RevertTo != InfoLevel {
+		t.Errorf("RevertTo = %s, want INFO", st.RevertTo)
+	}
+
+	// A permanent change cancels the pending revert.
+	if _, err := l.ChangeLevel(ErrorLevel, 0); err != nil {
+		t.Fatalf("ChangeLevel: %v", err)
+	}
+	if st := l.LevelStatus(); st.Level != ErrorLevel || st.RevertAt != nil {
+		t.Errorf("status = %+v, want permanent ERROR", st)
+	}
+}
+
+func TestChangeLevelRejectsUnknownLevel(t *testing.T) {
+	l := testLogger()
+	if _, err := l.ChangeLevel("TRACE", 0); err == nil {
+		t.Fatal("ChangeLevel(TRACE) succeeded, want an error")
+	}
+	if l.Level() != InfoLevel {
+		t.Errorf("Level = %s, want INFO unchanged", l.Level())
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	const token = "secret"
+	for _, tt := range []struct {
+		name       string
+		token      string
+		method     string
+		remoteAddr string
+		auth       string
+		body       string
+		wantCode   int
+		wantLevel  LogLevel
+	}{
+		{name: "get", token: token, method: http.MethodGet, remoteAddr: "203.0.113.7:4000", wantCode: http.StatusOK, wantLevel: InfoLevel},
+		{name: "put with token", token: token, method: http.MethodPut, remoteAddr: "203.0.113.7:4000", auth: "Bearer secret", body: `{"level":"debug"}`, wantCode: http.StatusOK, wantLevel: DebugLevel},
+		{name: "put without token", token: token, method: http.MethodPut, remoteAddr: "127.0.0.1:4000", body: `{"level":"DEBUG"}`, wantCode: http.StatusUnauthorized, wantLevel: InfoLevel},
+		{name: "put with wrong token", token: token, method: http.MethodPut, remoteAddr: "127.0.0.1:4000", auth: "Bearer guess", body: `{"level":"DEBUG"}`, wantCode: http.StatusUnauthorized, wantLevel: InfoLevel},
+		{name: "loopback without configured token", method: http.MethodPut, remoteAddr: "[::1]:4000", body: `{"level":"WARN"}`, wantCode: http.StatusOK, wantLevel: WarnLevel},
+		{name: "remote without configured token", method: http.MethodPut, remoteAddr: "203.0.113.7:4000", body: `{"level":"WARN"}`, wantCode: http.StatusUnauthorized, wantLevel: InfoLevel},
+		{name: "unknown level", token: token, method: http.MethodPut, remoteAddr: "127.0.0.1:4000", auth: "Bearer secret", body: `{"level":"TRACE"}`, wantCode: http.StatusBadRequest, wantLevel: InfoLevel},
+		{name: "bad duration", token: token, method: http.MethodPut, remoteAddr: "127.0.0.1:4000", auth: "Bearer secret", body: `{"level":"DEBUG","duration":"soon"}`, wantCode: http.StatusBadRequest, wantLevel: InfoLevel},
+		{name: "bad JSON", token: token, method: http.MethodPut, remoteAddr: "127.0.0.1:4000", auth: "Bearer secret", body: `level=DEBUG`, wantCode: http.StatusBadRequest, wantLevel: InfoLevel},
+		{name: "post", token: token, method: http.MethodPost, remoteAddr: "127.0.0.1:4000", auth: "Bearer secret", wantCode: http.StatusMethodNotAllowed, wantLevel: InfoLevel},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			l := testLogger()
+			req := httptest.NewRequest(tt.method, "/api/loglevel", strings.NewReader(tt.body))
+			req.RemoteAddr = tt.remoteAddr
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			l.LevelHandler(tt.token).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (body %q)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if l.Level() != tt.wantLevel {
+				t.Errorf("Level = %s, want %s", l.Level(), tt.wantLevel)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var st LevelStatus
+			if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if st.Level != tt.wantLevel {
+				t.Errorf("response level = %s, want %s", st.Level, tt.wantLevel)
+			}
+		})
+	}
+}
+
+func TestLevelHandlerTemporaryChange(t *testing.T) {
+	l := testLogger()
+	req := httptest.NewRequest(http.MethodPut, "/api/loglevel", strings.NewReader(`{"level":"DEBUG","duration":"50ms"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	l.LevelHandler("secret").ServeHTTP(rec, req)
+
+	var st LevelStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if st.Level != DebugLevel || st.RevertTo != InfoLevel || st.RevertAt == nil {
+		t.Fatalf("response = %+v, want DEBUG reverting to INFO", st)
+	}
+	waitLevel(t, l, InfoLevel)
+}
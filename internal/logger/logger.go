@@ -3,6 +3,7 @@ package logger
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -28,7 +29,7 @@ type Fields map[string]interface{}
 type Logger struct {
 	logger *log.Logger
 	fields Fields
-	level  LogLevel
+	level  *levelState
 }
 
 // New creates a new Logger instance
@@ -36,16 +37,25 @@ func New() *Logger {
 	return &Logger{
 		logger: log.New(os.Stdout, "", 0), // No prefix, we'll format everything ourselves
 		fields: make(Fields),
-		level:  InfoLevel, // Default level
+		level:  newLevelState(InfoLevel), // Default level
 	}
 }
 
-// WithLevel sets the log level for the logger
+// WithLevel sets the log level for the logger and every logger derived from it
 func (l *Logger) WithLevel(level LogLevel) *Logger {
-	l.level = level
+	l.level.current.Store(level)
 	return l
 }
 
+// WithOutput returns a logger writing to w that shares l's fields and level
+func (l *Logger) WithOutput(w io.Writer) *Logger {
+	return &Logger{
+		logger: log.New(w, "", 0),
+		fields: l.fields,
+		level:  l.level,
+	}
+}
+
 // WithField adds a single field to the logger
 func (l *Logger) WithField(key string, value interface{}) *Logger {
 	return l.WithFields(Fields{key: value})
@@ -91,7 +101,7 @@ func (l *Logger) log(level LogLevel, msg string, fields Fields) {
 
 // shouldSkip returns true if the log level is below the configured level
 func (l *Logger) shouldSkip(level LogLevel) bool {
-	return levelToInt(level) < levelToInt(l.level)
+	return levelToInt(level) < levelToInt(l.level.load())
 }
 
 // prepareEntry creates a log entry with all necessary fields
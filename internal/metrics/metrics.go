@@ -1,40 +1,67 @@
 package metrics
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/thatguy/moto_gorod-notifier/internal/version"
 )
 
 type Metrics struct {
 	// Counters
 	SubscriptionsTotal   prometheus.Counter
-	UnsubscriptionsTotal prometheus.Counter
+	UnsubscriptionsTotal *prometheus.CounterVec
 	UniqueUsersTotal     prometheus.Gauge
 	NewSlotsTotal        prometheus.Counter
+	SlotsTakenTotal      prometheus.Counter
+	SkippedTicksTotal    prometheus.Counter
 	NotificationsSent    prometheus.Counter
 	ErrorsTotal          *prometheus.CounterVec
+	GaugeCorrections     *prometheus.CounterVec
+	StaffCacheLookups    *prometheus.CounterVec
+	DeliveryRetries      *prometheus.CounterVec
+	YClientsRetries      *prometheus.CounterVec
 
 	// Gauges
 	ActiveSubscribers prometheus.Gauge
 	SeenSlotsTotal    prometheus.Gauge
+	BuildInfo         prometheus.Gauge
 
 	// Histograms
-	SlotCheckDuration prometheus.Histogram
-	NotificationDelay prometheus.Histogram
+	SlotCheckDuration       prometheus.Histogram
+	NotificationDelay       prometheus.Histogram
+	YClientsRateLimitWait   prometheus.Histogram
+	YClientsRequestDuration *prometheus.HistogramVec
+	YClientsRequestErrors   *prometheus.CounterVec
+	YClientsThrottled       *prometheus.CounterVec
+	YClientsAuth            *prometheus.CounterVec
+
+	// Storage
+	StorageQueryDuration *prometheus.HistogramVec
+	StorageErrors        *prometheus.CounterVec
 }
 
+// New creates the metrics and registers them with the default Prometheus
+// registry served by Handler.
 func New() *Metrics {
+	return NewWithRegistry(prometheus.DefaultRegisterer)
+}
+
+// NewWithRegistry creates the metrics and registers them with reg, so tests
+// can use a fresh registry each.
+func NewWithRegistry(reg prometheus.Registerer) *Metrics {
 	m := &Metrics{
 		SubscriptionsTotal: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "moto_gorod_subscriptions_total",
 			Help: "Total number of user subscriptions",
 		}),
-		UnsubscriptionsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+		UnsubscriptionsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "moto_gorod_unsubscriptions_total",
-			Help: "Total number of user unsubscriptions",
-		}),
+			Help: "Total number of user unsubscriptions by reason",
+		}, []string{"reason"}),
 		UniqueUsersTotal: prometheus.NewGauge(prometheus.GaugeOpts{
 			Name: "moto_gorod_unique_users_total",
 			Help: "Total number of unique users who interacted with bot",
@@ -43,6 +70,30 @@ func New() *Metrics {
 			Name: "moto_gorod_new_slots_total",
 			Help: "Total number of new slots found",
 		}),
+		SlotsTakenTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "moto_gorod_slots_taken_total",
+			Help: "Total number of slots that disappeared before their start time",
+		}),
+		GaugeCorrections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "moto_gorod_gauge_corrections_total",
+			Help: "Total number of times a gauge had drifted from storage and was corrected",
+		}, []string{"gauge"}),
+		StaffCacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "moto_gorod_staff_cache_lookups_total",
+			Help: "Total number of bookable staff lookups by cache result (hit or miss)",
+		}, []string{"result"}),
+		DeliveryRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "moto_gorod_delivery_retries_total",
+			Help: "Total number of failed notification deliveries by retry outcome",
+		}, []string{"outcome"}),
+		YClientsRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "moto_gorod_yclients_retries_total",
+			Help: "Total number of retried YCLIENTS availability requests by endpoint",
+		}, []string{"endpoint"}),
+		SkippedTicksTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "moto_gorod_check_ticks_skipped_total",
+			Help: "Total number of scheduled checks skipped because the previous check overran the interval",
+		}),
 		NotificationsSent: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "moto_gorod_notifications_sent_total",
 			Help: "Total number of notifications sent to users",
@@ -59,11 +110,51 @@ func New() *Metrics {
 			Name: "moto_gorod_seen_slots_total",
 			Help: "Total number of seen slots in database",
 		}),
+		BuildInfo: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "moto_gorod_build_info",
+			Help: "Build information of the running binary, always 1",
+			ConstLabels: prometheus.Labels{
+				"version":    version.Version,
+				"commit":     version.ShortCommit(),
+				"build_date": version.BuildDate,
+			},
+		}),
 		SlotCheckDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
 			Name:    "moto_gorod_slot_check_duration_seconds",
 			Help:    "Duration of slot availability checks",
 			Buckets: prometheus.DefBuckets,
 		}),
+		YClientsRateLimitWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "moto_gorod_yclients_rate_limit_wait_seconds",
+			Help:    "Time YCLIENTS requests spent waiting for the client-side rate limiter",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0},
+		}),
+		StorageQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "moto_gorod_storage_query_duration_seconds",
+			Help:    "Duration of storage operations by operation",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 5.0},
+		}, []string{"operation"}),
+		StorageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "moto_gorod_storage_errors_total",
+			Help: "Total number of failed storage operations by operation",
+		}, []string{"operation"}),
+		YClientsRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "moto_gorod_yclients_request_duration_seconds",
+			Help:    "Duration of HTTP requests to YCLIENTS by endpoint and status class",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0},
+		}, []string{"endpoint", "status"}),
+		YClientsRequestErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "moto_gorod_yclients_request_errors_total",
+			Help: "Total number of failed YCLIENTS requests by endpoint and error type (network, 4xx, 5xx, parse)",
+		}, []string{"endpoint", "type"}),
+		YClientsThrottled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "moto_gorod_yclients_throttled_total",
+			Help: "Total number of YCLIENTS requests answered with 429 Too Many Requests by endpoint",
+		}, []string{"endpoint"}),
+		YClientsAuth: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "moto_gorod_yclients_auth_total",
+			Help: "Total number of YCLIENTS authentication requests by result (success or failure)",
+		}, []string{"result"}),
 		NotificationDelay: prometheus.NewHistogram(prometheus.HistogramOpts{
 			Name:    "moto_gorod_notification_delay_seconds",
 			Help:    "Delay between slot discovery and notification",
@@ -72,18 +163,33 @@ func New() *Metrics {
 	}
 
 	// Register all metrics
-	prometheus.MustRegister(
+	reg.MustRegister(
 		m.SubscriptionsTotal,
 		m.UnsubscriptionsTotal,
 		m.UniqueUsersTotal,
 		m.NewSlotsTotal,
+		m.SlotsTakenTotal,
+		m.SkippedTicksTotal,
 		m.NotificationsSent,
 		m.ErrorsTotal,
+		m.GaugeCorrections,
+		m.StaffCacheLookups,
+		m.DeliveryRetries,
+		m.YClientsRetries,
 		m.ActiveSubscribers,
 		m.SeenSlotsTotal,
+		m.BuildInfo,
 		m.SlotCheckDuration,
 		m.NotificationDelay,
+		m.YClientsRateLimitWait,
+		m.YClientsRequestDuration,
+		m.YClientsRequestErrors,
+		m.YClientsThrottled,
+		m.YClientsAuth,
+		m.StorageQueryDuration,
+		m.StorageErrors,
 	)
+	m.BuildInfo.Set(1)
 
 	return m
 }
@@ -96,8 +202,8 @@ func (m *Metrics) RecordSubscription() {
 	m.SubscriptionsTotal.Inc()
 }
 
-func (m *Metrics) RecordUnsubscription() {
-	m.UnsubscriptionsTotal.Inc()
+func (m *Metrics) RecordUnsubscription(reason string) {
+	m.UnsubscriptionsTotal.WithLabelValues(reason).Inc()
 }
 
 func (m *Metrics) RecordUniqueUser() {
@@ -112,6 +218,66 @@ func (m *Metrics) RecordNewSlot() {
 	m.NewSlotsTotal.Inc()
 }
 
+func (m *Metrics) RecordSlotTaken() {
+	m.SlotsTakenTotal.Inc()
+}
+
+func (m *Metrics) RecordSkippedTick() {
+	m.SkippedTicksTotal.Inc()
+}
+
+// RecordStaffCacheLookup counts a bookable staff lookup served from the
+// cache (hit) or from YCLIENTS (miss).
+func (m *Metrics) RecordStaffCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.StaffCacheLookups.WithLabelValues(result).Inc()
+}
+
+// RecordDeliveryRetry counts a retry queue event: queued, delivered,
+// expired, unsubscribed or abandoned.
+func (m *Metrics) RecordDeliveryRetry(outcome string) {
+	m.DeliveryRetries.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) RecordYClientsRetry(endpoint string) {
+	m.YClientsRetries.WithLabelValues(endpoint).Inc()
+}
+
+func (m *Metrics) ObserveYClientsRateLimitWait(seconds float64) {
+	m.YClientsRateLimitWait.Observe(seconds)
+}
+
+func (m *Metrics) ObserveStorageQuery(operation string, seconds float64) {
+	m.StorageQueryDuration.WithLabelValues(operation).Observe(seconds)
+}
+
+func (m *Metrics) RecordStorageError(operation string) {
+	m.StorageErrors.WithLabelValues(operation).Inc()
+}
+
+func (m *Metrics) ObserveYClientsRequest(endpoint, status string, seconds float64) {
+	m.YClientsRequestDuration.WithLabelValues(endpoint, status).Observe(seconds)
+}
+
+func (m *Metrics) RecordYClientsError(endpoint, errType string) {
+	m.YClientsRequestErrors.WithLabelValues(endpoint, errType).Inc()
+}
+
+func (m *Metrics) RecordYClientsThrottled(endpoint string) {
+	m.YClientsThrottled.WithLabelValues(endpoint).Inc()
+}
+
+func (m *Metrics) RecordYClientsAuth(success bool) {
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	m.YClientsAuth.WithLabelValues(result).Inc()
+}
+
 func (m *Metrics) RecordNotificationSent() {
 	m.NotificationsSent.Inc()
 }
@@ -134,4 +300,38 @@ func (m *Metrics) ObserveSlotCheckDuration(duration float64) {
 
 func (m *Metrics) ObserveNotificationDelay(delay float64) {
 	m.NotificationDelay.Observe(delay)
-}
\ No newline at end of file
+}
+
+// Gauge names accepted by Reconcile.
+const (
+	GaugeActiveSubscribers = "active_subscribers"
+	GaugeSeenSlots         = "seen_slots"
+	GaugeUniqueUsers       = "unique_users"
+)
+
+// Reconcile sets the named gauge to the value recomputed from storage and
+// returns how far it had drifted. Nonzero drift is counted per gauge.
+func (m *Metrics) Reconcile(gauge string, truth float64) (float64, error) {
+	var g prometheus.Gauge
+	switch gauge {
+	case GaugeActiveSubscribers:
+		g = m.ActiveSubscribers
+	case GaugeSeenSlots:
+		g = m.SeenSlotsTotal
+	case GaugeUniqueUsers:
+		g = m.UniqueUsersTotal
+	default:
+		return 0, fmt.Errorf("unknown gauge %q", gauge)
+	}
+
+	var current dto.Metric
+	if err := g.Write(&current); err != nil {
+		return 0, err
+	}
+	delta := current.GetGauge().GetValue() - truth
+	if delta != 0 {
+		m.GaugeCorrections.WithLabelValues(gauge).Inc()
+	}
+	g.Set(truth)
+	return delta, nil
+}
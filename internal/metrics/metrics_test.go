@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func gaugeValue(t *testing.T, g prometheus.Gauge) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := g.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func correctionCount(t *testing.T, m *Metrics, gauge string) float64 {
+	t.Helper()
+	var metric dto.Metric
+	if err := m.GaugeCorrections.WithLabelValues(gauge).Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func TestReconcile(t *testing.T) {
+	m := NewWithRegistry(prometheus.NewRegistry())
+	m.SetActiveSubscribers(7)
+	m.SetSeenSlotsTotal(40)
+
+	for _, tt := range []struct {
+		gauge string
+		g     prometheus.Gauge
+		truth float64
+		delta float64
+	}{
+		{GaugeActiveSubscribers, m.ActiveSubscribers, 5, 2},
+		{GaugeSeenSlots, m.SeenSlotsTotal, 40, 0},
+		{GaugeUniqueUsers, m.UniqueUsersTotal, 12, -12},
+	} {
+		delta, err := m.Reconcile(tt.gauge, tt.truth)
+		if err != nil {
+			t.Fatalf("Reconcile(%s): %v", tt.gauge, err)
+		}
+		if delta != tt.delta {
+			t.Errorf("%s drift = %v, want %v", tt.gauge, delta, tt.delta)
+		}
+		if got := gaugeValue(t, tt.g); got != tt.truth {
+			t.Errorf("%s = %v after reconciling, want %v", tt.gauge, got, tt.truth)
+		}
+		want := 1.0
+		if tt.delta == 0 {
+			want = 0
+		}
+		if got := correctionCount(t, m, tt.gauge); got != want {
+			t.Errorf("%s corrections = %v, want %v", tt.gauge, got, want)
+		}
+	}
+
+	if _, err := m.Reconcile("pending_outbox", 1); err == nil {
+		t.Error("Reconcile accepted an unknown gauge")
+	}
+}
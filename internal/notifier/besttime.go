@@ -0,0 +1,149 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/format"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+const (
+	bestTimeCacheKey    = "best_time"
+	bestTimeRefresh     = 7 * 24 * time.Hour
+	bestTimeMinHistory  = 28 * 24 * time.Hour
+	bestTimeWindowHours = 2
+	bestTimeTop         = 3
+)
+
+// timeWindow is a run of bestTimeWindowHours hours on one weekday.
+type timeWindow struct {
+	Weekday time.Weekday `json:"weekday"`
+	Hour    int          `json:"hour"`
+	Count   int          `json:"count"`
+}
+
+type bestTimeCache struct {
+	ComputedAt time.Time    `json:"computed_at"`
+	Windows    []timeWindow `json:"windows"`
+}
+
+type bestTimeMessageData struct {
+	NotEnoughData bool
+	Windows       []string
+	Zone          string
+}
+
+// mondayFirst orders weekdays the way they are shown to users, for tie-breaking.
+func mondayFirst(wd time.Weekday) int {
+	return (int(wd) + 6) % 7
+}
+
+// rankWindows returns up to top non-overlapping windows of width hours
+// with the most appearances. Ties go to the earlier weekday (Monday first)
+// and then the earlier hour. Windows stay within one day.
+func rankWindows(counts []storage.AppearanceCount, width, top int) []timeWindow {
+	var grid [7][24]int
+	for _, c := range counts {
+		if c.Weekday >= 0 && c.Weekday < 7 && c.Hour >= 0 && c.Hour < 24 {
+			grid[c.Weekday][c.Hour] += c.Count
+		}
+	}
+
+	var candidates []timeWindow
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		for h := 0; h+width <= 24; h++ {
+			sum := 0
+			for i := 0; i < width; i++ {
+				sum += grid[wd][h+i]
+			}
+			if sum > 0 {
+				candidates = append(candidates, timeWindow{Weekday: wd, Hour: h, Count: sum})
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Weekday != b.Weekday {
+			return mondayFirst(a.Weekday) < mondayFirst(b.Weekday)
+		}
+		return a.Hour < b.Hour
+	})
+
+	var picked []timeWindow
+	for _, c := range candidates {
+		if len(picked) == top {
+			break
+		}
+		overlaps := false
+		for _, p := range picked {
+			if p.Weekday == c.Weekday && c.Hour < p.Hour+width && p.Hour < c.Hour+width {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			picked = append(picked, c)
+		}
+	}
+	return picked
+}
+
+// bestTimeWindows returns the cached ranking, recomputing it once a week.
+// ok is false while there is less than bestTimeMinHistory of history.
+func (n *Notifier) bestTimeWindows(now time.Time) (windows []timeWindow, ok bool, err error) {
+	if raw, found, err := n.storage.GetSetting(bestTimeCacheKey); err != nil {
+		return nil, false, err
+	} else if found {
+		var cached bestTimeCache
+		if err := json.Unmarshal([]byte(raw), &cached); err == nil && now.Sub(cached.ComputedAt) < bestTimeRefresh {
+			return cached.Windows, true, nil
+		}
+	}
+
+	first, found, err := n.storage.FirstAppearance()
+	if err != nil {
+		return nil, false, err
+	}
+	if !found || now.Sub(first) < bestTimeMinHistory {
+		return nil, false, nil
+	}
+
+	_, offset := now.In(n.loc).Zone()
+	counts, err := n.storage.AppearanceCounts(now.Add(-bestTimeRefresh*52), time.Duration(offset)*time.Second)
+	if err != nil {
+		return nil, false, err
+	}
+	windows = rankWindows(counts, bestTimeWindowHours, bestTimeTop)
+
+	if raw, err := json.Marshal(bestTimeCache{ComputedAt: now, Windows: windows}); err == nil {
+		if err := n.storage.SetSetting(bestTimeCacheKey, string(raw)); err != nil {
+			n.log.WithError(err).Warn("Failed to cache best time windows")
+		}
+	}
+	return windows, true, nil
+}
+
+// BestTimeMessage renders the /besttime reply.
+func (n *Notifier) BestTimeMessage() (string, error) {
+	windows, ok, err := n.bestTimeWindows(time.Now())
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().In(n.loc)
+	data := bestTimeMessageData{NotEnoughData: !ok || len(windows) == 0, Zone: now.Format("MST")}
+	for _, w := range windows {
+		data.Windows = append(data.Windows, fmt.Sprintf("%s %02d:00–%02d:00",
+			format.WeekdayShortRU(w.Weekday), w.Hour, (w.Hour+bestTimeWindowHours)%24))
+	}
+	n.log.DebugWithFields("Rendering best time message", logger.Fields{
+		"windows": len(data.Windows),
+	})
+	return n.RenderTemplate("templates/besttime.tmpl", data)
+}
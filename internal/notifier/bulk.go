@@ -0,0 +1,246 @@
+package notifier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+type bulkMessageData struct {
+	Period string
+	Slots  int
+	Dates  int
+}
+
+// overflowMessageData fills the summary sent in place of the slot messages
+// above the per-check cap.
+type overflowMessageData struct {
+	More int
+}
+
+var russianMonthsGenitive = [...]string{
+	"января", "февраля", "марта", "апреля", "мая", "июня",
+	"июля", "августа", "сентября", "октября", "ноября", "декабря",
+}
+
+// isBulkPublication reports whether slots look like a whole schedule being
+// published at once rather than individual openings.
+func (n *Notifier) isBulkPublication(found []slots.Slot) bool {
+	if n.opts.BulkMinSlots <= 0 || len(found) < n.opts.BulkMinSlots {
+		return false
+	}
+	return len(distinctDates(found)) >= n.opts.BulkMinDates
+}
+
+// notifyNewSlots delivers the slots found in one check to chats, replacing
+// per-slot messages with one announcement per subscriber for bulk
+// publications.
+// Each successful send is timed from discoveredAt. It returns how many
+// subscribers the fan-out rotation deprioritized.
+func (n *Notifier) notifyNewSlots(found []slots.Slot, chats []int64, discoveredAt time.Time) int {
+	if len(found) == 0 {
+		return 0
+	}
+	bulk := n.isBulkPublication(found)
+	subscribers, deprioritized := n.fanoutOrder(chats)
+	recipients := 0
+	n.dryRunPreviewed = nil
+	now := time.Now().In(n.loc)
+
+	if bulk {
+		n.log.InfoWithFields("Bulk schedule publication detected", logger.Fields{
+			"new_slots": len(found),
+			"dates":     len(distinctDates(found)),
+		})
+	}
+
+	for _, chatID := range subscribers {
+		prefs := n.preferences(chatID)
+		var wanted []slots.Slot
+		for _, s := range found {
+			if matchesPreferences(prefs, s.ServiceID, s.Start, now) {
+				wanted = append(wanted, s)
+			}
+		}
+		if len(wanted) == 0 {
+			continue
+		}
+
+		if prefs.Digest {
+			n.queueDigest(chatID, wanted)
+			continue
+		}
+		if prefs.Muted(now) || n.inQuietHours(prefs) {
+			continue
+		}
+		recipients++
+		if bulk {
+			msg := n.formatBulkMessage(wanted)
+			if n.send(chatID, "bulk", firstLine(msg), msg, false) {
+				n.observeDelay(discoveredAt)
+			}
+			continue
+		}
+		overflow := 0
+		if limit := n.opts.MaxSlotMessages; limit > 0 && len(wanted) > limit {
+			wanted = append([]slots.Slot(nil), wanted...)
+			sort.SliceStable(wanted, func(i, j int) bool { return wanted[i].Start.Before(wanted[j].Start) })
+			overflow = len(wanted) - limit
+			wanted = wanted[:limit]
+		}
+		count := n.notificationsToday(chatID, now)
+		for _, s := range wanted {
+			count++
+			data := n.slotData(s)
+			data.Lang = prefs.Language
+			data.DailyCount = count
+			data.SuggestDigest = n.shouldOfferDigest(chatID, count)
+			if n.send(chatID, s.Key(), n.slotExcerpt(s), n.renderSlotMessage(data), data.SuggestDigest) {
+				n.observeDelay(discoveredAt)
+			}
+		}
+		if overflow > 0 {
+			msg, err := n.RenderTemplate("templates/overflow_summary.tmpl", overflowMessageData{More: overflow})
+			if err != nil {
+				msg = fmt.Sprintf("➕ И ещё %d — посмотрите /current", overflow)
+			}
+			n.send(chatID, "overflow", firstLine(msg), msg, false)
+		}
+	}
+
+	summary := "Notified subscribers about new slots"
+	if n.opts.DryRun {
+		summary = "Dry run: would notify subscribers about new slots"
+	}
+	n.log.InfoWithFields(summary, logger.Fields{
+		"subscribers_count": len(subscribers),
+		"recipients":        recipients,
+		"new_slots":         len(found),
+		"bulk":              bulk,
+		"deprioritized":     deprioritized,
+	})
+	return deprioritized
+}
+
+// notify sends msg to chatID and records the delivery in the notification log.
+func (n *Notifier) notify(chatID int64, slotKey, excerpt, msg string) {
+	n.send(chatID, slotKey, excerpt, msg, false)
+}
+
+// send is notify with an optional button offering digest mode. It reports
+// whether the message was delivered.
+func (n *Notifier) send(chatID int64, slotKey, excerpt, msg string, digestOffer bool) bool {
+	if n.opts.DryRun {
+		n.dryRunSend(chatID, msg)
+		return false
+	}
+	if err := n.deliver(chatID, msg, digestOffer); err != nil {
+		n.log.WithError(err).ErrorWithFields("Failed to notify subscriber", logger.Fields{
+			"chat_id": chatID,
+		})
+		n.addDailyStat(storage.StatDeliveryFailures, 1)
+		n.logFailedNotification(chatID, slotKey, excerpt, err)
+		n.queueRetry(chatID, slotKey, excerpt, msg, digestOffer, err)
+		return false
+	}
+	n.logNotification(chatID, slotKey, excerpt)
+	return true
+}
+
+// deliver sends msg, optionally with the digest mode offer button.
+func (n *Notifier) deliver(chatID int64, msg string, digestOffer bool) error {
+	if digestOffer {
+		return n.bot.NotifyWithDigestOffer(chatID, msg)
+	}
+	return n.bot.Notify(chatID, msg)
+}
+
+// logNotification records a delivered notification in the log and the
+// daily statistics.
+func (n *Notifier) logNotification(chatID int64, slotKey, excerpt string) {
+	n.addDailyStat(storage.StatNotificationsSent, 1)
+	if err := n.storage.LogNotification(chatID, slotKey, excerpt); err != nil {
+		n.log.WithError(err).WarnWithFields("Failed to log notification", logger.Fields{
+			"chat_id": chatID,
+		})
+	}
+}
+
+// logFailedNotification records a failed delivery attempt in the log.
+func (n *Notifier) logFailedNotification(chatID int64, slotKey, excerpt string, sendErr error) {
+	if err := n.storage.LogNotificationFailure(chatID, slotKey, excerpt, sendErr); err != nil {
+		n.log.WithError(err).WarnWithFields("Failed to log failed notification", logger.Fields{
+			"chat_id": chatID,
+		})
+	}
+}
+
+// observeDelay records how long a notification took since its slot was found.
+func (n *Notifier) observeDelay(discoveredAt time.Time) {
+	if n.metrics != nil {
+		n.metrics.ObserveNotificationDelay(time.Since(discoveredAt).Seconds())
+	}
+}
+
+// slotExcerpt summarizes a slot for the notification log.
+func (n *Notifier) slotExcerpt(s slots.Slot) string {
+	data := n.slotData(s)
+	if data.Date == "" {
+		return data.Time + " — " + data.ServiceName
+	}
+	return fmt.Sprintf("%s (%s) %s — %s", data.Date, data.Weekday, data.Time, data.ServiceName)
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func (n *Notifier) formatBulkMessage(found []slots.Slot) string {
+	first, last := found[0].Start, found[0].Start
+	for _, s := range found[1:] {
+		if s.Start.Before(first) {
+			first = s.Start
+		}
+		if s.Start.After(last) {
+			last = s.Start
+		}
+	}
+	data := bulkMessageData{
+		Period: formatDateRange(first, last),
+		Slots:  len(found),
+		Dates:  len(distinctDates(found)),
+	}
+	msg, err := n.RenderTemplate("templates/bulk_publication.tmpl", data)
+	if err != nil {
+		msg = fmt.Sprintf("📢 Опубликовано расписание на %s\n\nНовых слотов: %d\n\nПосмотреть свободное время: /current", data.Period, data.Slots)
+	}
+	return msg
+}
+
+func distinctDates(found []slots.Slot) map[string]bool {
+	dates := make(map[string]bool)
+	for _, s := range found {
+		dates[s.Start.Format("2006-01-02")] = true
+	}
+	return dates
+}
+
+// formatDateRange renders "18–24 марта" or "28 февраля – 6 марта".
+func formatDateRange(from, to time.Time) string {
+	if from.Year() == to.Year() && from.YearDay() == to.YearDay() {
+		return fmt.Sprintf("%d %s", from.Day(), russianMonthsGenitive[from.Month()-1])
+	}
+	if from.Year() == to.Year() && from.Month() == to.Month() {
+		return fmt.Sprintf("%d–%d %s", from.Day(), to.Day(), russianMonthsGenitive[to.Month()-1])
+	}
+	return fmt.Sprintf("%d %s – %d %s",
+		from.Day(), russianMonthsGenitive[from.Month()-1], to.Day(), russianMonthsGenitive[to.Month()-1])
+}
@@ -0,0 +1,258 @@
+package notifier
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/thatguy/moto_gorod-notifier/internal/notifier/slottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+)
+
+const digestOfferText = "Уведомлений много?"
+
+// countingStorage records the start of the day each count was asked for.
+type countingStorage struct {
+	Storage
+	since []time.Time
+}
+
+func (s *countingStorage) CountNotificationsSince(chatID int64, since time.Time) (int, error) {
+	s.since = append(s.since, since)
+	return s.Storage.CountNotificationsSince(chatID, since)
+}
+
+// digestOffers counts messages to chatID carrying the digest mode button.
+func (e *testEnv) digestOffers(chatID int64) int {
+	offers := 0
+	for _, c := range e.api.Sent() {
+		m, ok := c.(tgbotapi.MessageConfig)
+		if !ok || m.ChatID != chatID {
+			continue
+		}
+		if _, ok := m.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup); ok {
+			offers++
+		}
+	}
+	return offers
+}
+
+func TestNotificationsTodayStartsAtLocalMidnight(t *testing.T) {
+	for _, tt := range []struct {
+		timezone string
+		now      string
+		want     string // midnight the count starts from, RFC 3339
+	}{
+		{"Europe/Moscow", "2026-03-10T15:30:00Z", "2026-03-10T00:00:00+03:00"},
+		// Already the 11th in Vladivostok while still the 10th in UTC.
+		{"Asia/Vladivostok", "2026-03-10T15:30:00Z", "2026-03-11T00:00:00+10:00"},
+		// Still the 10th in Los Angeles while already the 11th in UTC.
+		{"America/Los_Angeles", "2026-03-11T05:00:00Z", "2026-03-10T00:00:00-07:00"},
+	} {
+		t.Run(tt.timezone, func(t *testing.T) {
+			e := newTestEnv(t, Options{Timezone: tt.timezone})
+			store := &countingStorage{Storage: e.store}
+			e.n.storage = store
+
+			now, _ := time.Parse(time.RFC3339, tt.now)
+			want, _ := time.Parse(time.RFC3339, tt.want)
+			e.n.notificationsToday(testChatID, now)
+			if len(store.since) != 1 || !store.since[0].Equal(want) {
+				t.Errorf("counted since %v, want %s", store.since, want)
+			}
+		})
+	}
+}
+
+func TestNotificationsTodayResetsAtMidnight(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	for i := 0; i < 3; i++ {
+		if err := e.store.LogNotification(testChatID, "slot", "🟢"); err != nil {
+			t.Fatalf("LogNotification: %v", err)
+		}
+	}
+
+	now := time.Now().In(e.n.loc)
+	if got := e.n.notificationsToday(testChatID, now); got != 3 {
+		t.Errorf("today's count = %d, want 3", got)
+	}
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 1, 0, e.n.loc)
+	if got := e.n.notificationsToday(testChatID, tomorrow); got != 0 {
+		t.Errorf("count just after midnight = %d, want 0", got)
+	}
+}
+
+func TestSlotMessagesCountNotificationsOfTheDay(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	e.subscribe(t, testChatID)
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)), slottest.At(e.day(2)))
+	e.n.checkAndNotify(context.Background())
+
+	msgs := e.api.MessagesTo(testChatID)
+	if len(msgs) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(msgs))
+	}
+	if strings.Contains(msgs[0], "уведомление за сегодня") {
+		t.Errorf("first message %q shows a counter", msgs[0])
+	}
+	if !strings.Contains(msgs[1], "(2-е уведомление за сегодня)") {
+		t.Errorf("second message %q lacks the counter", msgs[1])
+	}
+}
+
+func TestDigestSuggestedOnlyOnce(t *testing.T) {
+	e := newTestEnv(t, Options{DigestSuggestAfter: 2})
+	e.subscribe(t, testChatID)
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)), slottest.At(e.day(2)), slottest.At(e.day(3)))
+	e.n.checkAndNotify(context.Background())
+
+	msgs := e.api.MessagesTo(testChatID)
+	if len(msgs) != 3 {
+		t.Fatalf("sent %d messages, want 3", len(msgs))
+	}
+	for i, msg := range msgs {
+		if offered := strings.Contains(msg, digestOfferText); offered != (i == 1) {
+			t.Errorf("message %d offers digest = %v, want only the second", i+1, offered)
+		}
+	}
+	if got := e.digestOffers(testChatID); got != 1 {
+		t.Errorf("%d messages carry the digest button, want 1", got)
+	}
+
+	// More notifications later, even on another day, don't repeat it.
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(4)), slottest.At(e.day(5)))
+	e.n.checkAndNotify(context.Background())
+	msgs = e.api.MessagesTo(testChatID)
+	if len(msgs) != 5 {
+		t.Fatalf("sent %d messages, want 5", len(msgs))
+	}
+	for _, msg := range msgs[3:] {
+		if strings.Contains(msg, digestOfferText) {
+			t.Errorf("digest offered again: %q", msg)
+		}
+	}
+	if got := e.digestOffers(testChatID); got != 1 {
+		t.Errorf("%d messages carry the digest button, want 1", got)
+	}
+}
+
+func TestDigestSuggestionDisabled(t *testing.T) {
+	e := newTestEnv(t, Options{DigestSuggestAfter: -1})
+	e.subscribe(t, testChatID)
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)), slottest.At(e.day(2)), slottest.At(e.day(3)))
+	e.n.checkAndNotify(context.Background())
+
+	if got := e.digestOffers(testChatID); got != 0 {
+		t.Errorf("%d digest offers with suggestions disabled", got)
+	}
+}
+
+func TestIsBulkPublication(t *testing.T) {
+	e := newTestEnv(t, Options{BulkMinSlots: 5, BulkMinDates: 3})
+	fixture := func(perDay ...int) []slots.Slot {
+		var found []slots.Slot
+		for day, n := range perDay {
+			for i := 0; i < n; i++ {
+				start := e.day(day + 1).Add(time.Duration(i) * time.Hour)
+				found = append(found, slots.FromTimeslot(testServiceID, testStaffID, slottest.At(start), e.n.loc))
+			}
+		}
+		return found
+	}
+
+	for _, tt := range []struct {
+		name  string
+		found []slots.Slot
+		want  bool
+	}{
+		{"a whole week", fixture(2, 2, 2, 2, 2, 2, 2), true},
+		{"at both thresholds", fixture(2, 2, 1), true},
+		{"too few slots", fixture(1, 1, 1, 1), false},
+		{"too few dates", fixture(4, 4), false},
+		{"nothing new", nil, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.n.isBulkPublication(tt.found); got != tt.want {
+				t.Errorf("isBulkPublication = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	e.n.opts.BulkMinSlots = 0
+	if e.n.isBulkPublication(fixture(5, 5, 5)) {
+		t.Error("bulk detected with announcements disabled")
+	}
+}
+
+func TestFormatDateRange(t *testing.T) {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2026, month, day, 10, 0, 0, 0, time.UTC)
+	}
+	for _, tt := range []struct {
+		from, to time.Time
+		want     string
+	}{
+		{date(3, 18), date(3, 18), "18 марта"},
+		{date(3, 18), date(3, 24), "18–24 марта"},
+		{date(2, 28), date(3, 6), "28 февраля – 6 марта"},
+	} {
+		if got := formatDateRange(tt.from, tt.to); got != tt.want {
+			t.Errorf("formatDateRange(%s, %s) = %q, want %q", tt.from.Format("01-02"), tt.to.Format("01-02"), got, tt.want)
+		}
+	}
+}
+
+func TestBulkPublicationAnnouncedOnce(t *testing.T) {
+	e := newTestEnv(t, Options{BulkMinSlots: 5, BulkMinDates: 3})
+	e.subscribe(t, testChatID)
+	for day := 1; day <= 3; day++ {
+		e.src.Add(testServiceID, testStaffID, slottest.At(e.day(day)), slottest.At(e.day(day).Add(2*time.Hour)))
+	}
+
+	res := e.n.checkAndNotify(context.Background())
+	if res.NewSlots != 6 {
+		t.Fatalf("first check found %d new slots, want 6", res.NewSlots)
+	}
+	msgs := e.api.MessagesTo(testChatID)
+	if len(msgs) != 1 {
+		t.Fatalf("sent %d messages, want a single announcement", len(msgs))
+	}
+	if !strings.HasPrefix(msgs[0], "📢 Опубликовано расписание на") || !strings.Contains(msgs[0], "Новых слотов: 6 (дней: 3)") ||
+		!strings.Contains(msgs[0], "/current") {
+		t.Errorf("announcement = %q", msgs[0])
+	}
+
+	// Every slot is still marked seen on its own.
+	seen, err := e.store.SeenSlotsSince(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("SeenSlotsSince: %v", err)
+	}
+	if len(seen) != 6 {
+		t.Errorf("%d slots marked seen, want 6", len(seen))
+	}
+	if res := e.n.checkAndNotify(context.Background()); res.NewSlots != 0 {
+		t.Errorf("second check found %d new slots, want 0", res.NewSlots)
+	}
+	if got := len(e.api.MessagesTo(testChatID)); got != 1 {
+		t.Errorf("sent %d messages after the second check, want 1", got)
+	}
+}
+
+func TestSmallBatchNotifiedPerSlot(t *testing.T) {
+	e := newTestEnv(t, Options{BulkMinSlots: 5, BulkMinDates: 3})
+	e.subscribe(t, testChatID)
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)), slottest.At(e.day(2)))
+	e.n.checkAndNotify(context.Background())
+
+	msgs := e.api.MessagesTo(testChatID)
+	if len(msgs) != 2 {
+		t.Fatalf("sent %d messages, want one per slot", len(msgs))
+	}
+	for _, msg := range msgs {
+		if strings.Contains(msg, "Опубликовано расписание") {
+			t.Errorf("small batch announced as a publication: %q", msg)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package notifier
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// serviceNamesRefresh bounds how often the service catalog is fetched.
+const serviceNamesRefresh = time.Hour
+
+// refreshServiceNames reloads service names from the YCLIENTS catalog
+// unless they were fetched within serviceNamesRefresh. Like staff names, a
+// failed fetch keeps the previous names, which fall back to the static map
+// and then to the bare ID.
+func (n *Notifier) refreshServiceNames(ctx context.Context) {
+	if n.yc == nil || n.opts.LocationID == 0 {
+		return
+	}
+	n.catalogMu.Lock()
+	defer n.catalogMu.Unlock()
+	if !n.serviceNamesAt.IsZero() && time.Since(n.serviceNamesAt) < serviceNamesRefresh {
+		return
+	}
+	n.serviceNamesAt = time.Now()
+
+	services, err := n.yc.GetServices(ctx, strconv.Itoa(n.opts.LocationID))
+	if err != nil {
+		n.logFor(ctx).WithError(err).WarnWithFields("Failed to fetch service names", logger.Fields{
+			"location_id": n.opts.LocationID,
+		})
+		return
+	}
+	names := make(map[string]string, len(services))
+	for _, s := range services {
+		if s.Title != "" {
+			names[strconv.Itoa(s.ID)] = s.Title
+		}
+	}
+	SeedServiceNames(names)
+	n.logFor(ctx).DebugWithFields("Service names refreshed", logger.Fields{"services": len(names)})
+}
@@ -0,0 +1,65 @@
+package notifier
+
+import (
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// SetPaused suspends or resumes scheduled checks. Manual checks still run.
+func (n *Notifier) SetPaused(paused bool) {
+	n.paused.Store(paused)
+	n.log.WarnWithFields("Notifier pause state changed", logger.Fields{"paused": paused})
+}
+
+// Paused reports whether scheduled checks are suspended.
+func (n *Notifier) Paused() bool {
+	return n.paused.Load()
+}
+
+// TriggerCheck asks the polling loop to run a check as soon as it is free;
+// a scheduled check in flight finishes first. Triggers arriving before the
+// check starts share it: queued is false when one was already pending. The
+// returned channel receives the check's result and is closed if the
+// notifier stops first.
+func (n *Notifier) TriggerCheck() (result <-chan bot.CheckReport, queued bool) {
+	ch := make(chan bot.CheckReport, 1)
+	n.checkMu.Lock()
+	defer n.checkMu.Unlock()
+	n.checkWaiters = append(n.checkWaiters, ch)
+	select {
+	case n.checkNow <- struct{}{}:
+		queued = true
+	default:
+	}
+	return ch, queued
+}
+
+// takeCheckWaiters hands over the waiters of the check about to start.
+// Later triggers queue a new check, so they never get a result that
+// predates them.
+func (n *Notifier) takeCheckWaiters() []chan bot.CheckReport {
+	n.checkMu.Lock()
+	defer n.checkMu.Unlock()
+	waiters := n.checkWaiters
+	n.checkWaiters = nil
+	return waiters
+}
+
+func (n *Notifier) reportCheck(waiters []chan bot.CheckReport, res checkResult) {
+	report := bot.CheckReport{
+		Skipped:  res.At.IsZero(),
+		Duration: res.Duration,
+		NewSlots: res.NewSlots,
+		Errors:   res.Errors,
+	}
+	for _, ch := range waiters {
+		ch <- report
+		close(ch)
+	}
+}
+
+func (n *Notifier) cancelCheckWaiters() {
+	for _, ch := range n.takeCheckWaiters() {
+		close(ch)
+	}
+}
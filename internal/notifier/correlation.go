@@ -0,0 +1,26 @@
+package notifier
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
+)
+
+// withCheckID tags ctx with a fresh correlation ID, so the YCLIENTS
+// requests of one check and the logs about them can be matched up.
+func withCheckID(ctx context.Context, prefix string) context.Context {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	return yclients.WithRequestID(ctx, prefix+"-"+hex.EncodeToString(b[:]))
+}
+
+// logFor returns the notifier logger tagged with the correlation ID of ctx.
+func (n *Notifier) logFor(ctx context.Context) *logger.Logger {
+	if id := yclients.RequestID(ctx); id != "" {
+		return n.log.WithRequestID(id)
+	}
+	return n.log
+}
@@ -0,0 +1,134 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+)
+
+// DefaultCurrentCacheTTL is how long a complete scan answers /current
+// without scanning again.
+const DefaultCurrentCacheTTL = 45 * time.Second
+
+// scanCache holds the latest complete scan, from a check or /current.
+type scanCache struct {
+	slots []slots.Slot
+	at    time.Time
+}
+
+// cacheScan replaces the cached scan; a completed check thereby
+// invalidates older /current results.
+func (n *Notifier) cacheScan(available []slots.Slot, at time.Time) {
+	n.stateMu.Lock()
+	defer n.stateMu.Unlock()
+	if at.After(n.lastScan.at) {
+		n.lastScan = scanCache{slots: available, at: at}
+	}
+}
+
+func (n *Notifier) cachedScan(now time.Time) ([]slots.Slot, time.Time, bool) {
+	if n.opts.CurrentCacheTTL < 0 {
+		return nil, time.Time{}, false
+	}
+	n.stateMu.RLock()
+	defer n.stateMu.RUnlock()
+	if n.lastScan.at.IsZero() || now.Sub(n.lastScan.at) >= n.opts.CurrentCacheTTL {
+		return nil, time.Time{}, false
+	}
+	return n.lastScan.slots, n.lastScan.at, true
+}
+
+// CurrentSlots returns every slot bookable right now, using the same
+// services, lookahead window and timezone as the periodic check, and when
+// the list was fetched. A complete scan younger than CurrentCacheTTL is
+// reused; otherwise YCLIENTS is scanned, one request at a time so users
+// pressing /current together share the result. Slots are sorted by start
+// time. Failed requests are skipped; an error is returned only when
+// nothing could be fetched at all.
+func (n *Notifier) CurrentSlots(ctx context.Context) ([]slots.Slot, time.Time, error) {
+	if len(n.opts.ServiceIDs) == 0 || n.opts.LocationID == 0 {
+		return nil, time.Time{}, errors.New("notifier: location or services not configured")
+	}
+
+	n.currentMu.Lock()
+	defer n.currentMu.Unlock()
+	if cached, at, ok := n.cachedScan(time.Now()); ok {
+		return cached, at, nil
+	}
+
+	ctx = withCheckID(ctx, "current")
+	n.refreshStaffNames(ctx)
+	n.refreshServiceNames(ctx)
+	scannedAt := time.Now()
+	from, to := DateRange(scannedAt.In(n.loc), n.opts.LookaheadDays)
+	available, errorsCount := n.scanAvailability(ctx, from, to)
+	if len(available) == 0 && errorsCount > 0 {
+		return nil, time.Time{}, fmt.Errorf("notifier: no slots fetched, %d availability requests failed", errorsCount)
+	}
+	sortByStart(available)
+	if errorsCount == 0 {
+		n.cacheScan(available, scannedAt)
+	}
+	return available, scannedAt, nil
+}
+
+func sortByStart(available []slots.Slot) {
+	sort.SliceStable(available, func(i, j int) bool { return available[i].Start.Before(available[j].Start) })
+}
+
+// groupCurrentSlots prepares the /current listing: slots are sorted by
+// start time, staff members free at the same moment are merged into one
+// entry, and entries are grouped by date in loc. Slots without a parsed
+// start are left out.
+func groupCurrentSlots(available []slots.Slot, loc *time.Location) currentSlotsData {
+	sorted := make([]slots.Slot, 0, len(available))
+	for _, s := range available {
+		if !s.Start.IsZero() {
+			sorted = append(sorted, s)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].Start.Equal(sorted[j].Start) {
+			return sorted[i].Start.Before(sorted[j].Start)
+		}
+		return sorted[i].StaffID < sorted[j].StaffID
+	})
+
+	var data currentSlotsData
+	staff := make(map[int]bool)
+	for _, s := range sorted {
+		start := s.Start.In(loc)
+		last := len(data.Slots) - 1
+		if last >= 0 && data.Slots[last].Start.Equal(start) {
+			entry := &data.Slots[last]
+			if !staff[s.StaffID] {
+				staff[s.StaffID] = true
+				entry.StaffCount++
+			}
+			if s.HasSeats {
+				entry.HasSeats = true
+				entry.SeatsLeft += s.SeatsLeft
+			}
+			continue
+		}
+		staff = map[int]bool{s.StaffID: true}
+		data.Slots = append(data.Slots, currentSlot{
+			Start: start, StaffID: s.StaffID, StaffName: staffLabel(s.StaffID), StaffCount: 1, HasSeats: s.HasSeats, SeatsLeft: s.SeatsLeft,
+		})
+	}
+
+	for _, entry := range data.Slots {
+		y, m, d := entry.Start.Date()
+		date := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		if n := len(data.Days); n == 0 || !data.Days[n-1].Date.Equal(date) {
+			data.Days = append(data.Days, currentDay{Date: date})
+		}
+		day := &data.Days[len(data.Days)-1]
+		day.Slots = append(day.Slots, entry)
+	}
+	return data
+}
@@ -0,0 +1,159 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/notifier/slottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+)
+
+func TestCurrentSlotsSortedByStart(t *testing.T) {
+	e := newTestEnv(t, Options{ServiceIDs: []int{1, 2}})
+	e.src.Add(1, 10, slottest.At(e.day(3)))
+	e.src.Add(2, 20, slottest.At(e.day(1)), slottest.At(e.day(2)))
+
+	got, at, err := e.n.CurrentSlots(context.Background())
+	if err != nil {
+		t.Fatalf("CurrentSlots: %v", err)
+	}
+	if at.IsZero() {
+		t.Error("fetch time not set")
+	}
+	if len(got) != 3 {
+		t.Fatalf("%d slots, want 3", len(got))
+	}
+	for i, day := range []int{1, 2, 3} {
+		if !got[i].Start.Equal(e.day(day)) {
+			t.Errorf("slot %d starts %s, want day +%d", i, got[i].Start, day)
+		}
+	}
+}
+
+func TestCurrentSlotsUsesLookahead(t *testing.T) {
+	e := newTestEnv(t, Options{LookaheadDays: 3})
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)), slottest.At(e.day(10)))
+
+	got, _, err := e.n.CurrentSlots(context.Background())
+	if err != nil {
+		t.Fatalf("CurrentSlots: %v", err)
+	}
+	if len(got) != 1 || !got[0].Start.Equal(e.day(1)) {
+		t.Errorf("slots = %v, want only the one within 3 days", starts(got))
+	}
+}
+
+func TestCurrentSlotsReusesRecentScan(t *testing.T) {
+	e := newTestEnv(t, Options{CurrentCacheTTL: time.Minute})
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+
+	first, at, _ := e.n.CurrentSlots(context.Background())
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(2)))
+	second, at2, _ := e.n.CurrentSlots(context.Background())
+
+	if calls := e.src.Calls(slottest.BookableDatesAnyStaff); calls != 1 {
+		t.Errorf("source scanned %d times, want 1", calls)
+	}
+	if len(second) != len(first) || !at2.Equal(at) {
+		t.Errorf("second call returned %d slots from %s, want the cached %d from %s", len(second), at2, len(first), at)
+	}
+}
+
+func TestCurrentSlotsReusesCheckScan(t *testing.T) {
+	e := newTestEnv(t, Options{CurrentCacheTTL: time.Minute})
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+	e.n.checkAndNotify(context.Background())
+
+	got, _, err := e.n.CurrentSlots(context.Background())
+	if err != nil {
+		t.Fatalf("CurrentSlots: %v", err)
+	}
+	if len(got) != 1 {
+		t.Errorf("%d slots, want 1", len(got))
+	}
+	if calls := e.src.Calls(slottest.BookableDatesAnyStaff); calls != 1 {
+		t.Errorf("source scanned %d times, want only by the check", calls)
+	}
+}
+
+func TestCurrentSlotsCacheDisabled(t *testing.T) {
+	e := newTestEnv(t, Options{CurrentCacheTTL: -1})
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+
+	e.n.CurrentSlots(context.Background())
+	e.n.CurrentSlots(context.Background())
+	if calls := e.src.Calls(slottest.BookableDatesAnyStaff); calls != 2 {
+		t.Errorf("source scanned %d times, want 2", calls)
+	}
+}
+
+func TestCurrentSlotsFailure(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+	e.src.Fail(slottest.BookableDatesAnyStaff, errors.New("unavailable"))
+
+	if _, _, err := e.n.CurrentSlots(context.Background()); err == nil {
+		t.Error("CurrentSlots succeeded with nothing fetched")
+	}
+
+	// A failed scan is not cached
+	e.src.Fail(slottest.BookableDatesAnyStaff, nil)
+	if got, _, err := e.n.CurrentSlots(context.Background()); err != nil || len(got) != 1 {
+		t.Errorf("after recovery: %d slots, err %v; want 1 slot", len(got), err)
+	}
+}
+
+func TestCurrentSlotsPartialResults(t *testing.T) {
+	e := newTestEnv(t, Options{CurrentCacheTTL: time.Minute})
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)), slottest.At(e.day(2)))
+	e.src.FailDate(e.day(2).Format("2006-01-02"), errors.New("timeslots unavailable"))
+
+	got, _, err := e.n.CurrentSlots(context.Background())
+	if err != nil || len(got) != 1 {
+		t.Fatalf("%d slots, err %v; want the 1 on the working date", len(got), err)
+	}
+	e.n.CurrentSlots(context.Background())
+	if calls := e.src.Calls(slottest.BookableDatesAnyStaff); calls != 2 {
+		t.Errorf("source scanned %d times, want an incomplete scan not cached", calls)
+	}
+}
+
+func TestCurrentSlotsUnconfigured(t *testing.T) {
+	e := newTestEnv(t, Options{ServiceIDs: []int{}})
+	if _, _, err := e.n.CurrentSlots(context.Background()); err == nil {
+		t.Error("CurrentSlots succeeded without services")
+	}
+}
+
+func TestGroupCurrentSlotsMergesStaff(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	at := e.day(1)
+	data := groupCurrentSlots([]slots.Slot{
+		{StaffID: 2, Start: at.Add(2 * time.Hour)},
+		{StaffID: 1, Start: at},
+		{StaffID: 2, Start: at, HasSeats: true, SeatsLeft: 3},
+		{StaffID: 3, Start: e.day(2)},
+		{StaffID: 4},
+	}, e.n.loc)
+
+	if len(data.Slots) != 3 {
+		t.Fatalf("%d entries, want 3", len(data.Slots))
+	}
+	first := data.Slots[0]
+	if first.StaffID != 1 || first.StaffCount != 2 || !first.HasSeats || first.SeatsLeft != 3 {
+		t.Errorf("merged entry = %+v", first)
+	}
+	if len(data.Days) != 2 || len(data.Days[0].Slots) != 2 || len(data.Days[1].Slots) != 1 {
+		t.Errorf("days = %+v, want 2 entries then 1", data.Days)
+	}
+}
+
+func starts(list []slots.Slot) []time.Time {
+	out := make([]time.Time, len(list))
+	for i, s := range list {
+		out[i] = s.Start
+	}
+	return out
+}
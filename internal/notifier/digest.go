@@ -0,0 +1,134 @@
+package notifier
+
+import (
+	"strings"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+)
+
+// digestMaxSlots keeps digests well below Telegram's message length limit.
+const digestMaxSlots = 30
+
+type digestMessageData struct {
+	Total int
+	Slots []string
+	More  int
+}
+
+// notificationsToday counts notifications chatID received since the last
+// midnight before now in the display timezone.
+func (n *Notifier) notificationsToday(chatID int64, now time.Time) int {
+	now = now.In(n.loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, n.loc)
+	count, err := n.storage.CountNotificationsSince(chatID, midnight)
+	if err != nil {
+		n.log.WithError(err).WarnWithFields("Failed to count today's notifications", logger.Fields{
+			"chat_id": chatID,
+		})
+	}
+	return count
+}
+
+// shouldOfferDigest reports whether the notification numbered count should
+// carry the digest offer. Each chat is offered digest mode at most once.
+func (n *Notifier) shouldOfferDigest(chatID int64, count int) bool {
+	if n.opts.DigestSuggestAfter <= 0 || count < n.opts.DigestSuggestAfter {
+		return false
+	}
+	first, err := n.storage.MarkDigestSuggested(chatID)
+	if err != nil {
+		n.log.WithError(err).WarnWithFields("Failed to record digest offer", logger.Fields{
+			"chat_id": chatID,
+		})
+		return false
+	}
+	return first
+}
+
+func (n *Notifier) queueDigest(chatID int64, found []slots.Slot) {
+	for _, s := range found {
+		if err := n.storage.QueueDigest(chatID, s.Key(), n.slotExcerpt(s)); err != nil {
+			n.log.WithError(err).ErrorWithFields("Failed to queue slot for digest", logger.Fields{
+				"chat_id": chatID,
+			})
+		}
+	}
+}
+
+// nextDailyAt returns the first occurrence of the local "HH:MM" time after
+// now, using fallback when hhmm is malformed. It drives digests and reminders.
+func (n *Notifier) nextDailyAt(now time.Time, hhmm, fallback string) time.Time {
+	at, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		at, _ = time.Parse("15:04", fallback)
+	}
+	now = now.In(n.loc)
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, n.loc)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// sendDigests delivers queued slots that are still bookable to digest-mode
+// chats. Chats in quiet hours keep their queue until the next digest.
+func (n *Notifier) sendDigests() {
+	pending, err := n.storage.PendingDigests()
+	if err != nil {
+		n.log.WithError(err).Error("Failed to load pending digests")
+		return
+	}
+
+	n.stateMu.RLock()
+	available := n.availableKeys
+	n.stateMu.RUnlock()
+
+	subscribers, err := n.bot.Subscribers()
+	if err != nil {
+		n.log.WithError(err).Error("Failed to get subscribers, keeping digests queued")
+		return
+	}
+	subscribed := make(map[int64]bool)
+	for _, chatID := range subscribers {
+		subscribed[chatID] = true
+	}
+
+	sent := 0
+	for chatID, items := range pending {
+		prefs := n.preferences(chatID)
+		if subscribed[chatID] && prefs.Digest && n.inQuietHours(prefs) {
+			continue
+		}
+
+		var lines []string
+		for _, item := range items {
+			if available == nil || available[item.SlotKey] {
+				lines = append(lines, item.Excerpt)
+			}
+		}
+		if subscribed[chatID] && prefs.Digest && len(lines) > 0 {
+			data := digestMessageData{Total: len(lines), Slots: lines}
+			if len(lines) > digestMaxSlots {
+				data.Slots, data.More = lines[:digestMaxSlots], len(lines)-digestMaxSlots
+			}
+			msg, err := n.RenderTemplate("templates/digest.tmpl", data)
+			if err != nil {
+				msg = "📬 Дайджест новых слотов:\n\n" + strings.Join(data.Slots, "\n") + "\n\nВсе слоты: /current"
+			}
+			n.notify(chatID, "digest", firstLine(msg), msg)
+			sent++
+		}
+		if err := n.storage.ClearDigest(chatID); err != nil {
+			n.log.WithError(err).ErrorWithFields("Failed to clear digest queue", logger.Fields{
+				"chat_id": chatID,
+			})
+		}
+	}
+
+	n.log.InfoWithFields("Daily digests sent", logger.Fields{
+		"queued_chats": len(pending),
+		"sent":         sent,
+	})
+}
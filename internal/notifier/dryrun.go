@@ -0,0 +1,25 @@
+package notifier
+
+import (
+	"fmt"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// dryRunSend stands in for a delivery in dry-run mode: the message is only
+// logged and, when DryRunPreview is set, shown to admins once per distinct
+// text so a check with many recipients doesn't flood the admin chat.
+func (n *Notifier) dryRunSend(chatID int64, msg string) {
+	n.log.InfoWithFields("Dry run: would notify subscriber", logger.Fields{
+		"chat_id": chatID,
+		"message": msg,
+	})
+	if !n.opts.DryRunPreview || n.dryRunPreviewed[msg] {
+		return
+	}
+	if n.dryRunPreviewed == nil {
+		n.dryRunPreviewed = make(map[string]bool)
+	}
+	n.dryRunPreviewed[msg] = true
+	n.bot.NotifyAdmins(fmt.Sprintf("🧪 Пробный режим, сообщение для чата %d:\n\n%s", chatID, msg))
+}
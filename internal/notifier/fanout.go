@@ -0,0 +1,60 @@
+package notifier
+
+import (
+	"strconv"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+const (
+	fanoutCursorKey = "fanout_cursor"
+	// fanoutRotationCycles is how many notifying cycles it takes for every
+	// subscriber to have been near the front of the fan-out at least once.
+	fanoutRotationCycles = 10
+)
+
+// fanoutOrder rotates subscribers by a persisted cursor so that caps and
+// rate limits don't always cut off the most recent subscribers. It returns
+// the ordered list and how many recipients were moved behind others.
+func (n *Notifier) fanoutOrder(subscribers []int64) ([]int64, int) {
+	if len(subscribers) < 2 {
+		return subscribers, 0
+	}
+
+	cursor := 0
+	if v, ok, err := n.storage.GetSetting(fanoutCursorKey); err != nil {
+		n.log.WithError(err).Warn("Failed to load fan-out cursor")
+	} else if ok {
+		cursor, _ = strconv.Atoi(v)
+	}
+
+	ordered, offset := rotateFrom(subscribers, cursor)
+	next := (offset + fanoutStride(len(subscribers))) % len(subscribers)
+	if err := n.storage.SetSetting(fanoutCursorKey, strconv.Itoa(next)); err != nil {
+		n.log.WithError(err).WarnWithFields("Failed to save fan-out cursor", logger.Fields{"cursor": next})
+	}
+	return ordered, offset
+}
+
+// rotateFrom returns subscribers starting at cursor modulo their count,
+// wrapping around, together with the effective offset.
+func rotateFrom(subscribers []int64, cursor int) ([]int64, int) {
+	offset := cursor % len(subscribers)
+	if offset < 0 {
+		offset += len(subscribers)
+	}
+	ordered := make([]int64, 0, len(subscribers))
+	ordered = append(ordered, subscribers[offset:]...)
+	ordered = append(ordered, subscribers[:offset]...)
+	return ordered, offset
+}
+
+// fanoutStride advances the cursor so a full rotation takes about
+// fanoutRotationCycles cycles.
+func fanoutStride(count int) int {
+	stride := (count + fanoutRotationCycles - 1) / fanoutRotationCycles
+	if stride < 1 {
+		stride = 1
+	}
+	return stride
+}
@@ -0,0 +1,150 @@
+package notifier
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// settingsStorage keeps settings in memory; the fan-out touches nothing else.
+type settingsStorage struct {
+	Storage
+	values map[string]string
+	getErr error
+}
+
+func (s *settingsStorage) GetSetting(key string) (string, bool, error) {
+	if s.getErr != nil {
+		return "", false, s.getErr
+	}
+	v, ok := s.values[key]
+	return v, ok, nil
+}
+
+func (s *settingsStorage) SetSetting(key, value string) error {
+	s.values[key] = value
+	return nil
+}
+
+func newFanoutNotifier() (*Notifier, *settingsStorage) {
+	store := &settingsStorage{values: map[string]string{}}
+	return &Notifier{storage: store, log: logger.New().WithOutput(io.Discard)}, store
+}
+
+func TestRotateFrom(t *testing.T) {
+	subscribers := []int64{1, 2, 3, 4, 5}
+	for _, tt := range []struct {
+		name       string
+		cursor     int
+		want       []int64
+		wantOffset int
+	}{
+		{"zero", 0, []int64{1, 2, 3, 4, 5}, 0},
+		{"middle", 2, []int64{3, 4, 5, 1, 2}, 2},
+		{"last", 4, []int64{5, 1, 2, 3, 4}, 4},
+		{"wraps past the end", 7, []int64{3, 4, 5, 1, 2}, 2},
+		{"exact multiple", 10, []int64{1, 2, 3, 4, 5}, 0},
+		{"negative", -1, []int64{5, 1, 2, 3, 4}, 4},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, offset := rotateFrom(subscribers, tt.cursor)
+			if !reflect.DeepEqual(got, tt.want) || offset != tt.wantOffset {
+				t.Errorf("rotateFrom(%d) = %v, %d; want %v, %d", tt.cursor, got, offset, tt.want, tt.wantOffset)
+			}
+		})
+	}
+	if !reflect.DeepEqual(subscribers, []int64{1, 2, 3, 4, 5}) {
+		t.Errorf("rotateFrom modified its input: %v", subscribers)
+	}
+}
+
+func TestFanoutStride(t *testing.T) {
+	for _, tt := range []struct {
+		count, want int
+	}{
+		{1, 1},
+		{2, 1},
+		{9, 1},
+		{10, 1},
+		{11, 2},
+		{25, 3},
+		{100, 10},
+		{101, 11},
+	} {
+		if got := fanoutStride(tt.count); got != tt.want {
+			t.Errorf("fanoutStride(%d) = %d, want %d", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestFanoutOrderRotatesAcrossCycles(t *testing.T) {
+	n, store := newFanoutNotifier()
+	subscribers := make([]int64, 25)
+	for i := range subscribers {
+		subscribers[i] = int64(100 + i)
+	}
+
+	firsts := map[int64]bool{}
+	var previous []int64
+	for cycle := 0; cycle < 2*fanoutRotationCycles; cycle++ {
+		ordered, offset := n.fanoutOrder(subscribers)
+		if len(ordered) != len(subscribers) {
+			t.Fatalf("cycle %d: %d recipients, want %d", cycle, len(ordered), len(subscribers))
+		}
+		seen := map[int64]bool{}
+		for _, id := range ordered {
+			seen[id] = true
+		}
+		if len(seen) != len(subscribers) {
+			t.Fatalf("cycle %d: order %v drops or repeats recipients", cycle, ordered)
+		}
+		if ordered[0] != subscribers[offset] {
+			t.Errorf("cycle %d: first = %d, want subscriber at offset %d", cycle, ordered[0], offset)
+		}
+		if previous != nil && reflect.DeepEqual(ordered, previous) {
+			t.Errorf("cycle %d: same order as the previous cycle", cycle)
+		}
+		previous = ordered
+		// Everyone within one stride of the front is equally well placed.
+		for _, id := range ordered[:fanoutStride(len(subscribers))] {
+			firsts[id] = true
+		}
+	}
+	if len(firsts) != len(subscribers) {
+		t.Errorf("%d of %d subscribers reached the front within %d cycles", len(firsts), len(subscribers), 2*fanoutRotationCycles)
+	}
+	if _, ok := store.values[fanoutCursorKey]; !ok {
+		t.Error("cursor was not persisted")
+	}
+}
+
+func TestFanoutOrderResumesFromPersistedCursor(t *testing.T) {
+	n, store := newFanoutNotifier()
+	store.values[fanoutCursorKey] = "3"
+
+	ordered, offset := n.fanoutOrder([]int64{1, 2, 3, 4, 5})
+	if want := []int64{4, 5, 1, 2, 3}; !reflect.DeepEqual(ordered, want) || offset != 3 {
+		t.Errorf("order = %v (offset %d), want %v (offset 3)", ordered, offset, want)
+	}
+	if got := store.values[fanoutCursorKey]; got != "4" {
+		t.Errorf("saved cursor = %q, want 4", got)
+	}
+}
+
+func TestFanoutOrderWithoutSettings(t *testing.T) {
+	n, store := newFanoutNotifier()
+	store.getErr = errors.New("database is locked")
+
+	ordered, offset := n.fanoutOrder([]int64{1, 2, 3})
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(ordered, want) || offset != 0 {
+		t.Errorf("order = %v (offset %d), want the stored order", ordered, offset)
+	}
+
+	single, offset := n.fanoutOrder([]int64{7})
+	if !reflect.DeepEqual(single, []int64{7}) || offset != 0 {
+		t.Errorf("single subscriber: %v (offset %d)", single, offset)
+	}
+}
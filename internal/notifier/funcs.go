@@ -0,0 +1,138 @@
+package notifier
+
+import (
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/format"
+)
+
+// templateFuncs returns helpers available to every message template.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"formatMoney":      formatMoney,
+		"formatMoneyRange": formatMoneyRange,
+		"formatDate":       formatDate,
+		"formatTime":       formatTime,
+		"weekday":          weekday,
+		"plural":           plural,
+		"escapeMD":         escapeMD,
+		"escapeHTML":       html.EscapeString,
+	}
+}
+
+// formatDate renders a date as "18.03.2025".
+func formatDate(t time.Time) string {
+	return t.Format("02.01.2006")
+}
+
+// formatTime renders a time of day as "10:00".
+func formatTime(t time.Time) string {
+	return t.Format("15:04")
+}
+
+var englishWeekdays = [...]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+
+// weekday names the day of t in the given language; ru is the default.
+func weekday(lang string, t time.Time) string {
+	if lang == "en" {
+		return englishWeekdays[t.Weekday()]
+	}
+	return format.WeekdayRU(t.Weekday())
+}
+
+// plural picks the Russian form for n: plural 1 "слот" "слота" "слотов"
+// gives "слот", 3 gives "слота" and 5 or 11 give "слотов".
+func plural(n int, one, few, many string) string {
+	if n < 0 {
+		n = -n
+	}
+	switch {
+	case n%100 >= 11 && n%100 <= 14:
+		return many
+	case n%10 == 1:
+		return one
+	case n%10 >= 2 && n%10 <= 4:
+		return few
+	default:
+		return many
+	}
+}
+
+// markdownReplacer escapes characters reserved by Telegram MarkdownV2.
+var markdownReplacer = strings.NewReplacer(
+	"\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(", ")", "\\)",
+	"~", "\\~", "`", "\\`", ">", "\\>", "#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=",
+	"|", "\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
+)
+
+// escapeMD makes s safe to embed in a MarkdownV2 message.
+func escapeMD(s string) string {
+	return markdownReplacer.Replace(s)
+}
+
+// formatMoney renders an amount in rubles for the given language:
+// "2 500 ₽" for ru and "RUB 2,500" for en. Kopecks are shown only when present.
+func formatMoney(lang string, amount float64) string {
+	if lang == "en" {
+		return "RUB " + formatNumber(lang, amount)
+	}
+	return formatNumber(lang, amount) + " ₽"
+}
+
+// formatMoneyRange renders a price range, collapsing it to a single amount
+// when min and max are equal or only one bound is known.
+func formatMoneyRange(lang string, min, max float64) string {
+	if min <= 0 || max <= 0 || sameAmount(min, max) {
+		return formatMoney(lang, math.Max(min, max))
+	}
+	if min > max {
+		min, max = max, min
+	}
+	if lang == "en" {
+		return "RUB " + formatNumber(lang, min) + "–" + formatNumber(lang, max)
+	}
+	return formatNumber(lang, min) + " – " + formatNumber(lang, max) + " ₽"
+}
+
+func sameAmount(a, b float64) bool {
+	return math.Round(a*100) == math.Round(b*100)
+}
+
+// formatNumber groups thousands and appends kopecks if the amount is fractional.
+func formatNumber(lang string, amount float64) string {
+	groupSep, decimalSep := " ", ","
+	if lang == "en" {
+		groupSep, decimalSep = ",", "."
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	cents := int64(math.Round(amount * 100))
+	whole := strconv.FormatInt(cents/100, 10)
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(groupSep)
+		}
+		b.WriteRune(r)
+	}
+	if frac := cents % 100; frac != 0 {
+		b.WriteString(decimalSep)
+		if frac < 10 {
+			b.WriteString("0")
+		}
+		b.WriteString(strconv.FormatInt(frac, 10))
+	}
+	return b.String()
+}
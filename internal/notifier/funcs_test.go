@@ -0,0 +1,85 @@
+package notifier
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		amount float64
+		ru, en string
+	}{
+		{0, "0 ₽", "RUB 0"},
+		{500, "500 ₽", "RUB 500"},
+		{2500, "2 500 ₽", "RUB 2,500"},
+		{1234567, "1 234 567 ₽", "RUB 1,234,567"},
+		{2500.5, "2 500,50 ₽", "RUB 2,500.50"},
+		{99.05, "99,05 ₽", "RUB 99.05"},
+		{2499.999, "2 500 ₽", "RUB 2,500"},
+		{-1500, "-1 500 ₽", "RUB -1,500"},
+	}
+	for _, tt := range tests {
+		if got := formatMoney("ru", tt.amount); got != tt.ru {
+			t.Errorf("formatMoney(ru, %v) = %q, want %q", tt.amount, got, tt.ru)
+		}
+		if got := formatMoney("en", tt.amount); got != tt.en {
+			t.Errorf("formatMoney(en, %v) = %q, want %q", tt.amount, got, tt.en)
+		}
+	}
+}
+
+func TestFormatMoneyRange(t *testing.T) {
+	tests := []struct {
+		min, max float64
+		ru, en   string
+	}{
+		{2500, 3000, "2 500 – 3 000 ₽", "RUB 2,500–3,000"},
+		{3000, 2500, "2 500 – 3 000 ₽", "RUB 2,500–3,000"},
+		{2500, 2500, "2 500 ₽", "RUB 2,500"},
+		{2500, 2500.001, "2 500 ₽", "RUB 2,500"},
+		{0, 3000, "3 000 ₽", "RUB 3,000"},
+		{2500, 0, "2 500 ₽", "RUB 2,500"},
+		{999.5, 1000, "999,50 – 1 000 ₽", "RUB 999.50–1,000"},
+	}
+	for _, tt := range tests {
+		if got := formatMoneyRange("ru", tt.min, tt.max); got != tt.ru {
+			t.Errorf("formatMoneyRange(ru, %v, %v) = %q, want %q", tt.min, tt.max, got, tt.ru)
+		}
+		if got := formatMoneyRange("en", tt.min, tt.max); got != tt.en {
+			t.Errorf("formatMoneyRange(en, %v, %v) = %q, want %q", tt.min, tt.max, got, tt.en)
+		}
+	}
+}
+
+func TestSlotMessagePrice(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	msk := time.FixedZone("MSK", 3*60*60)
+	data := slotMessageData{
+		CompanyName: "Мото Город", ServiceName: "Вождение мотоцикла", ServiceID: testServiceID,
+		StaffID: testStaffID, StaffName: "Иван", Start: time.Date(2025, 3, 18, 10, 0, 0, 0, msk), Zone: "MSK",
+		DailyCount: 1,
+	}
+	const head = "🟢 Доступно окно записи\n\nКомпания: Мото Город\nУслуга: Вождение мотоцикла\nСотрудник: Иван\n" +
+		"Дата: 18.03.2025 (вторник)\nВремя: 10:00 MSK\n"
+
+	tests := []struct {
+		name     string
+		min, max float64
+		want     string
+	}{
+		{"range", 2500, 3000, head + "Стоимость: 2 500 – 3 000 ₽\n"},
+		{"equal bounds", 2500, 2500, head + "Стоимость: 2 500 ₽\n"},
+		{"kopecks", 1999.9, 1999.9, head + "Стоимость: 1 999,90 ₽\n"},
+		{"unknown", 0, 0, head},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := data
+			d.PriceMin, d.PriceMax = tt.min, tt.max
+			if got := e.n.renderSlotMessage(d); got != tt.want {
+				t.Errorf("message =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package notifier
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+)
+
+// Health keys in app_settings, readable by other tools while the notifier
+// runs.
+const (
+	lastSuccessfulCheckKey = "last_successful_check_at"
+	lastCheckErrorKey      = "last_error"
+)
+
+// healthMaxIntervals is how many poll intervals may pass without a
+// successful check before the notifier reports itself unhealthy.
+const healthMaxIntervals = 3
+
+// saveHealth persists the outcome of a check that ran.
+func (n *Notifier) saveHealth(res checkResult) {
+	if res.At.IsZero() {
+		return
+	}
+	if res.Errors == 0 {
+		if err := n.storage.SetSetting(lastSuccessfulCheckKey, res.At.UTC().Format(time.RFC3339)); err != nil {
+			n.log.WithError(err).Warn("Failed to save last successful check time")
+		}
+		if err := n.storage.SetSetting(lastCheckErrorKey, ""); err != nil {
+			n.log.WithError(err).Warn("Failed to clear last check error")
+		}
+		return
+	}
+	msg := fmt.Sprintf("%s: %d requests failed", res.At.UTC().Format(time.RFC3339), res.Errors)
+	if err := n.storage.SetSetting(lastCheckErrorKey, msg); err != nil {
+		n.log.WithError(err).Warn("Failed to save last check error")
+	}
+}
+
+// Healthy reports whether a check succeeded within maxAge. A notifier that
+// started less than maxAge ago or is paused by an admin counts as healthy.
+func (n *Notifier) Healthy(maxAge time.Duration) (bot.HealthView, bool) {
+	var view bot.HealthView
+	if raw, ok, err := n.storage.GetSetting(lastSuccessfulCheckKey); err != nil {
+		view.LastError = "storage: " + err.Error()
+	} else if ok {
+		view.LastSuccess, _ = time.Parse(time.RFC3339, raw)
+	}
+	if view.LastError == "" {
+		if raw, _, err := n.storage.GetSetting(lastCheckErrorKey); err == nil {
+			view.LastError = raw
+		}
+	}
+
+	n.stateMu.RLock()
+	last := n.lastCheck
+	n.stateMu.RUnlock()
+	if last.Errors == 0 && last.At.After(view.LastSuccess) {
+		view.LastSuccess = last.At
+	}
+
+	view.Paused = n.Paused()
+	since := view.LastSuccess
+	if n.startedAt.After(since) {
+		since = n.startedAt
+	}
+	view.Healthy = view.Paused || time.Since(since) <= maxAge
+	return view, view.Healthy
+}
+
+// Health reports health with the default threshold of healthMaxIntervals
+// poll intervals.
+func (n *Notifier) Health() bot.HealthView {
+	view, _ := n.Healthy(healthMaxIntervals * n.opts.Interval)
+	return view
+}
@@ -0,0 +1,37 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+// DefaultMaintenanceTime is when the nightly database maintenance runs.
+const DefaultMaintenanceTime = "04:00"
+
+// Maintain runs database maintenance and logs the outcome.
+func (n *Notifier) Maintain(ctx context.Context) (storage.MaintenanceResult, error) {
+	res, err := n.storage.Maintain(ctx)
+	fields := logger.Fields{
+		"duration":    res.Duration.String(),
+		"integrity":   res.Integrity,
+		"free_ratio":  res.FreeRatio,
+		"vacuumed":    res.Vacuumed,
+		"size_before": res.SizeBefore,
+		"size_after":  res.SizeAfter,
+	}
+	if err != nil {
+		n.log.WithError(err).ErrorWithFields("Database maintenance failed", fields)
+		return res, err
+	}
+	n.log.InfoWithFields("Database maintenance completed", fields)
+	return res, nil
+}
+
+// scheduledMaintenance is the nightly run; admins hear about failures.
+func (n *Notifier) scheduledMaintenance(ctx context.Context) {
+	if _, err := n.Maintain(ctx); err != nil {
+		n.bot.NotifyAdmins("🧹 Ночное обслуживание базы данных завершилось ошибкой:\n\n" + err.Error())
+	}
+}
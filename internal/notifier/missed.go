@@ -0,0 +1,51 @@
+package notifier
+
+import (
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+)
+
+// seenSlotRetention is how long seen slots are remembered after their start
+// (undated ones after being seen); older gaps can only be summarized
+// partially.
+const seenSlotRetention = 7 * 24 * time.Hour
+
+// recordAvailable remembers which slots were bookable in the last check.
+func (n *Notifier) recordAvailable(available []slots.Slot) {
+	keys := make(map[string]bool, len(available))
+	for _, s := range available {
+		keys[s.Key()] = true
+	}
+	n.stateMu.Lock()
+	n.available = available
+	n.availableKeys = keys
+	n.stateMu.Unlock()
+}
+
+// MissedSlots counts slots first seen after since and how many of them are
+// still bookable. Gaps longer than the seen-slot retention window, or a
+// missing complete check, yield only a partial count.
+func (n *Notifier) MissedSlots(since time.Time) (bot.MissedSlots, error) {
+	keys, err := n.storage.SeenSlotsSince(since)
+	if err != nil {
+		return bot.MissedSlots{}, err
+	}
+	missed := bot.MissedSlots{Total: len(keys)}
+
+	n.stateMu.RLock()
+	available := n.availableKeys
+	n.stateMu.RUnlock()
+
+	if time.Since(since) > seenSlotRetention || available == nil {
+		missed.Partial = true
+		return missed, nil
+	}
+	for _, key := range keys {
+		if available[key] {
+			missed.StillFree++
+		}
+	}
+	return missed, nil
+}
@@ -1,5 +1,7 @@
 package notifier
 
+import "sync"
+
 // Static mapping for human-friendly names.
 // Extend here if you add more companies/services/forms.
 var (
@@ -12,19 +14,94 @@ var (
 	formNames = map[string]string{
 		"n841217": "Город с инструктором",
 	}
+	// staffNames comes from STAFF_NAMES and wins over fetchedStaffNames,
+	// which is replaced on every refresh from the API.
+	staffNames        = map[int]string{}
+	fetchedStaffNames = map[int]string{}
+	// fetchedServiceNames comes from the YCLIENTS catalog and wins over
+	// serviceNames, so renamed services show their current title.
+	fetchedServiceNames = map[string]string{}
+
+	// namesMu guards the maps above against seeding at startup.
+	namesMu sync.RWMutex
 )
 
 func CompanyName(id string) (string, bool) {
+	namesMu.RLock()
+	defer namesMu.RUnlock()
 	name, ok := companyNames[id]
 	return name, ok
 }
 
 func ServiceName(id string) (string, bool) {
+	namesMu.RLock()
+	defer namesMu.RUnlock()
+	if name, ok := fetchedServiceNames[id]; ok {
+		return name, true
+	}
 	name, ok := serviceNames[id]
 	return name, ok
 }
 
 func FormName(id string) (string, bool) {
+	namesMu.RLock()
+	defer namesMu.RUnlock()
 	name, ok := formNames[id]
 	return name, ok
 }
+
+func StaffName(id int) (string, bool) {
+	namesMu.RLock()
+	defer namesMu.RUnlock()
+	if name, ok := staffNames[id]; ok {
+		return name, true
+	}
+	name, ok := fetchedStaffNames[id]
+	return name, ok
+}
+
+// SetStaffNames installs the configured staff names.
+func SetStaffNames(names map[int]string) {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	staffNames = make(map[int]string, len(names))
+	for id, name := range names {
+		staffNames[id] = name
+	}
+}
+
+// SeedStaffNames replaces the names fetched from the API.
+func SeedStaffNames(names map[int]string) {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	fetchedStaffNames = names
+}
+
+// SeedServiceNames replaces the service names fetched from the API.
+func SeedServiceNames(names map[string]string) {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	fetchedServiceNames = names
+}
+
+// SeedCompanyName records a name fetched from the API unless one is
+// already configured above.
+func SeedCompanyName(id, name string) {
+	seedName(companyNames, id, name)
+}
+
+// SeedFormName is SeedCompanyName for booking forms.
+func SeedFormName(id, name string) {
+	seedName(formNames, id, name)
+}
+
+func seedName(names map[string]string, id, name string) {
+	if name == "" {
+		return
+	}
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	if _, ok := names[id]; !ok {
+		names[id] = name
+	}
+}
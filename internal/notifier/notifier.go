@@ -1,298 +1,590 @@
 package notifier
 
 import (
-	"context"
 	"bytes"
+	"context"
 	"fmt"
-	"time"
+	"sync"
+	"sync/atomic"
 	"text/template"
+	"time"
 
 	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/format"
 	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
 	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
 )
 
 type Options struct {
-	Interval time.Duration
-	Timezone string
+	Interval   time.Duration
+	Timezone   string
 	LocationID int
 	ServiceIDs []int
+	// LookaheadDays limits scans to dates from today up to this many days ahead.
+	LookaheadDays int
+	// ScanConcurrency bounds parallel YCLIENTS requests during a check.
+	ScanConcurrency int
+	// StaffCacheTTL is how long bookable staff IDs of a service are reused
+	// between checks; negative disables the cache.
+	StaffCacheTTL time.Duration
+	// CurrentCacheTTL is how long a complete scan is reused for /current;
+	// zero means DefaultCurrentCacheTTL and negative disables the cache.
+	CurrentCacheTTL time.Duration
+	// CheckTimeout bounds one check so hung requests can't stall the loop;
+	// zero means 90% of Interval and negative disables it.
+	CheckTimeout time.Duration
+	// IntervalJitter randomizes each wait by up to ± this much so several
+	// instances don't poll YCLIENTS in lockstep.
+	IntervalJitter time.Duration
+	TemplatesDir   string
+	// NotifySeatsDecrease announces group slots whose remaining seats dropped.
+	NotifySeatsDecrease bool
+	// NotifySlotTaken tells chats that were sent a slot when it gets booked.
+	NotifySlotTaken bool
+	// BulkMinSlots and BulkMinDates define a bulk schedule publication: at
+	// least that many new slots across that many dates in one check are
+	// announced with a single message. Zero BulkMinSlots disables it.
+	BulkMinSlots int
+	BulkMinDates int
+	// MaxSlotMessages caps the individual slot messages a subscriber gets
+	// from one check; the rest are summed up in one message pointing to
+	// /current. Zero disables the cap.
+	MaxSlotMessages int
+	// DigestTime is the local "HH:MM" when digest-mode chats get their
+	// daily summary. DigestSuggestAfter is the daily notification count at
+	// which instant users are offered digest mode once; zero disables it.
+	DigestTime         string
+	DigestSuggestAfter int
+	// ReminderTime is the local "HH:MM" when opted-in chats are reminded
+	// about slots still free tomorrow.
+	ReminderTime string
+	// WeeklyReport sends admins a summary of the past week every
+	// WeeklyReportDay at the local "HH:MM" WeeklyReportTime.
+	WeeklyReport     bool
+	WeeklyReportDay  time.Weekday
+	WeeklyReportTime string
+	// Maintenance runs database maintenance daily at the local "HH:MM"
+	// MaintenanceTime.
+	Maintenance     bool
+	MaintenanceTime string
+	// DryRun runs checks and marks slots seen but never messages
+	// subscribers; DryRunPreview forwards the would-be messages to admins.
+	DryRun        bool
+	DryRunPreview bool
+	// StartupCheckDelay postpones the check run at startup; negative
+	// disables it and the first check waits for the ticker.
+	StartupCheckDelay time.Duration
 }
 
 type Notifier struct {
-	bot       *bot.Bot
-	yc        *yclients.Client
-	opts      Options
-	templates map[string]*template.Template
-	log       *logger.Logger
-	storage   Storage
-	metrics   MetricsRecorder
+	bot         *bot.Bot
+	yc          SlotSource
+	opts        Options
+	loc         *time.Location
+	templates   map[string]*template.Template
+	templatesMu sync.RWMutex
+	log         *logger.Logger
+	storage     Storage
+	metrics     MetricsRecorder
+	now         func() time.Time // scheduler clock, replaced in tests
+
+	// dryRunPreviewed holds message texts already shown to admins in the
+	// current check; only touched from the Run goroutine.
+	dryRunPreviewed map[string]bool
+
+	stateMu   sync.RWMutex
+	lastCheck checkResult
+	startedAt time.Time
+	lastScan  scanCache
+	// backoffUntil postpones scheduled checks after YCLIENTS rate limiting;
+	// authAlertedAt throttles alerts about rejected credentials;
+	// badRequestsLogged holds invalid requests already logged.
+	backoffUntil      time.Time
+	authAlertedAt     time.Time
+	badRequestsLogged map[string]bool
+	// currentMu lets one /current scan run at a time.
+	currentMu sync.Mutex
+	// available and availableKeys hold the slots bookable as of the last
+	// complete check.
+	available     []slots.Slot
+	availableKeys map[string]bool
+
+	// seats holds remaining seats per slot key observed on the previous check.
+	seats map[string]int
+
+	// staffNamesAt is when staff names were last fetched; staffMu
+	// serializes refreshes between checks and /current.
+	staffMu      sync.Mutex
+	staffNamesAt time.Time
+	// serviceNamesAt and catalogMu do the same for the service catalog.
+	catalogMu      sync.Mutex
+	serviceNamesAt time.Time
+
+	staffCache staffCache
+	// serviceBatching records whether the source attributes the results
+	// of a search for several services; see scanStaffServices.
+	serviceBatching atomic.Int32
+
+	paused   atomic.Bool
+	checkNow chan struct{}
+	// checkWaiters receive the result of the next requested check.
+	checkMu      sync.Mutex
+	checkWaiters []chan bot.CheckReport
+}
+
+// SlotSource is the booking system the notifier polls for availability.
+// *yclients.Client implements it; another provider only has to report
+// bookable staff, dates and timeslots the same way.
+type SlotSource interface {
+	GetBookableStaff(ctx context.Context, locationID, serviceID int) ([]yclients.StaffAvailability, error)
+	GetBookableDates(ctx context.Context, locationID, serviceID int, dateFrom, dateTo string, staffID *int) ([]string, error)
+	GetBookableDatesAnyStaff(ctx context.Context, locationID, serviceID int, dateFrom, dateTo string) ([]string, error)
+	GetBookableDatesServices(ctx context.Context, locationID int, serviceIDs []int, dateFrom, dateTo string) ([]string, error)
+	GetBookableDatesByService(ctx context.Context, locationID int, serviceIDs []int, dateFrom, dateTo string, staffID int) (map[int][]string, error)
+	GetBookableTimeslots(ctx context.Context, locationID, serviceID int, date string, staffID int) ([]yclients.Timeslot, error)
+	GetBookableTimeslotsByService(ctx context.Context, locationID int, serviceIDs []int, date string, staffID int) (map[int][]yclients.Timeslot, error)
+	GetStaff(ctx context.Context, companyID string) ([]yclients.Staff, error)
+	GetServices(ctx context.Context, companyID string) ([]yclients.Service, error)
 }
 
 type MetricsRecorder interface {
 	RecordNewSlot()
+	RecordSlotTaken()
+	RecordSkippedTick()
+	RecordStaffCacheLookup(hit bool)
+	RecordDeliveryRetry(outcome string)
 	ObserveSlotCheckDuration(duration float64)
+	ObserveNotificationDelay(delay float64)
 	SetSeenSlotsTotal(count float64)
 	RecordError(errorType string)
 }
 
 type Storage interface {
-	IsSlotSeen(slotKey string) (bool, error)
-	MarkSlotSeen(slotKey string) error
-	CleanOldSlots(olderThan time.Duration) error
+	MarkSlotSeen(ctx context.Context, o storage.ObservedSlot) error
+	CleanOldSlots(ctx context.Context, olderThan time.Duration) error
+	Maintain(ctx context.Context) (storage.MaintenanceResult, error)
+	GetPreferences(chatID int64) (storage.Preferences, error)
+	LogNotification(chatID int64, slotKey, excerpt string) error
+	LogNotificationFailure(chatID int64, slotKey, excerpt string, sendErr error) error
+	SeenSlotsSince(t time.Time) ([]string, error)
+	CountNotificationsSince(chatID int64, since time.Time) (int, error)
+	QueueDigest(chatID int64, slotKey, excerpt string) error
+	PendingDigests() (map[int64][]storage.DigestItem, error)
+	ClearDigest(chatID int64) error
+	MarkDigestSuggested(chatID int64) (bool, error)
+	ChatsNotifiedAbout(slotKey string) ([]int64, error)
+	LatestSnapshot() (storage.Snapshot, error)
+	SaveSnapshot(at time.Time, complete bool, observed []storage.ObservedSlot) error
+	NotifiedSlotKeysSince(chatID int64, since time.Time) (map[string]bool, error)
+	GetSetting(key string) (string, bool, error)
+	SetSetting(key, value string) error
+	RecordSlotAppearance(slotKey string, at time.Time) error
+	FirstAppearance() (time.Time, bool, error)
+	AppearanceCounts(since time.Time, utcOffset time.Duration) ([]storage.AppearanceCount, error)
+	QueueRetry(r storage.DeliveryRetry) error
+	DueRetries(now time.Time, limit int) ([]storage.DeliveryRetry, error)
+	RescheduleRetry(id int64, attempts int, next time.Time) error
+	DeleteRetry(id int64) error
+	AddDailyStat(t time.Time, name string, value float64) error
+	DailyStatsSince(from time.Time) (map[string]storage.DailyStat, error)
 }
 
-func New(b *bot.Bot, yc *yclients.Client, opts Options, storage Storage, log *logger.Logger) *Notifier {
+func New(b *bot.Bot, yc SlotSource, opts Options, storage Storage, log *logger.Logger) *Notifier {
 	if opts.Interval <= 0 {
 		opts.Interval = 30 * time.Second
 	}
+	if opts.CheckTimeout == 0 {
+		opts.CheckTimeout = defaultCheckTimeout(opts.Interval)
+	}
+	if opts.ScanConcurrency <= 0 {
+		opts.ScanConcurrency = DefaultScanConcurrency
+	}
+	if opts.StaffCacheTTL == 0 {
+		opts.StaffCacheTTL = DefaultStaffCacheTTL
+	}
+	if opts.CurrentCacheTTL == 0 {
+		opts.CurrentCacheTTL = DefaultCurrentCacheTTL
+	}
+	if opts.LookaheadDays <= 0 {
+		opts.LookaheadDays = DefaultLookaheadDays
+	}
+	if opts.DigestTime == "" {
+		opts.DigestTime = bot.DefaultDigestTime
+	}
+	if opts.ReminderTime == "" {
+		opts.ReminderTime = bot.DefaultReminderTime
+	}
+	if opts.WeeklyReportTime == "" {
+		opts.WeeklyReportTime = DefaultWeeklyReportTime
+	}
+	if opts.MaintenanceTime == "" {
+		opts.MaintenanceTime = DefaultMaintenanceTime
+	}
 	n := &Notifier{
 		bot:       b,
 		yc:        yc,
 		opts:      opts,
-		templates: make(map[string]*template.Template),
 		log:       log,
 		storage:   storage,
+		checkNow:  make(chan struct{}, 1),
+		startedAt: time.Now(),
+		now:       time.Now,
 	}
-	
-	// Parse all templates
-	templateFiles := []string{
-		"templates/slot_message.tmpl",
-		"templates/welcome_message.tmpl",
-		"templates/current_slots.tmpl",
-		"templates/no_slots.tmpl",
-		"templates/goodbye_message.tmpl",
-	}
-	
-	for _, file := range templateFiles {
-		t, err := template.ParseFS(templateFS, file)
-		if err != nil {
-			n.log.WithError(err).ErrorWithFields("Failed to parse template", logger.Fields{"file": file})
-		} else {
-			n.templates[file] = t
-		}
+
+	loc, err := time.LoadLocation(opts.Timezone)
+	if err != nil {
+		log.WithError(err).WarnWithFields("Failed to load timezone, using fallback", logger.Fields{
+			"timezone": opts.Timezone,
+			"fallback": "UTC+3",
+		})
+		loc = time.FixedZone("UTC+3", 3*3600)
+	}
+	n.loc = loc
+
+	n.loadTemplates()
+	for _, d := range n.TemplateDivergences() {
+		n.log.WarnWithFields("External template differs from embedded version", logger.Fields{
+			"template":      d.Name,
+			"embedded_hash": d.EmbeddedHash,
+			"external_hash": d.ExternalHash,
+			"lines_added":   d.LinesAdded,
+			"lines_removed": d.LinesRemoved,
+		})
+	}
+
+	for _, err := range n.VerifyTemplates() {
+		n.log.WithError(err).Warn("Template verification failed")
 	}
-	
-	n.log.InfoWithFields("Templates loaded", logger.Fields{"count": len(n.templates)})
-	
+
+	n.log.InfoWithFields("Templates loaded", logger.Fields{"count": n.templateCount()})
+
 	n.log.InfoWithFields("Notifier initialized", logger.Fields{
-		"interval":    opts.Interval.String(),
-		"timezone":    opts.Timezone,
-		"location_id": opts.LocationID,
-		"service_ids": opts.ServiceIDs,
+		"interval":         opts.Interval.String(),
+		"timezone":         opts.Timezone,
+		"location_id":      opts.LocationID,
+		"service_ids":      opts.ServiceIDs,
+		"lookahead_days":   opts.LookaheadDays,
+		"scan_concurrency": opts.ScanConcurrency,
+		"templates_dir":    opts.TemplatesDir,
+		"dry_run":          opts.DryRun,
 	})
-	
+	if opts.DryRun {
+		n.log.WarnWithFields("DRY RUN mode is active: subscribers will not be notified", logger.Fields{
+			"admin_preview": opts.DryRunPreview,
+		})
+	}
+
 	return n
 }
 
 func (n *Notifier) Run(ctx context.Context) {
 	n.log.InfoWithFields("Starting notifier polling loop", logger.Fields{
 		"interval": n.opts.Interval.String(),
+		"jitter":   n.opts.IntervalJitter.String(),
 	})
-	
-	ticker := time.NewTicker(n.opts.Interval)
-	defer ticker.Stop()
-	
+
+	if n.opts.StartupCheckDelay >= 0 {
+		n.initialCheck(ctx)
+	}
+
+	timer := time.NewTimer(n.nextCheckDelay())
+	defer timer.Stop()
+	digestDue := time.After(time.Until(n.nextDailyAt(time.Now(), n.opts.DigestTime, bot.DefaultDigestTime)))
+	reminderDue := time.After(time.Until(n.nextDailyAt(time.Now(), n.opts.ReminderTime, bot.DefaultReminderTime)))
+	var reportDue <-chan time.Time
+	if n.opts.WeeklyReport {
+		reportDue = time.After(time.Until(n.nextWeeklyAt(time.Now(), n.opts.WeeklyReportDay, n.opts.WeeklyReportTime)))
+	}
+	var maintenanceDue <-chan time.Time
+	if n.opts.Maintenance {
+		maintenanceDue = time.After(time.Until(n.nextDailyAt(time.Now(), n.opts.MaintenanceTime, DefaultMaintenanceTime)))
+	}
+	retryTicker := time.NewTicker(retryPollInterval)
+	defer retryTicker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			n.log.Info("Context canceled, stopping notifier")
+			n.cancelCheckWaiters()
 			return
-		case <-ticker.C:
-			n.checkAndNotify(ctx)
+		case <-digestDue:
+			n.sendDigests()
+			digestDue = time.After(time.Until(n.nextDailyAt(time.Now(), n.opts.DigestTime, bot.DefaultDigestTime)))
+		case <-reminderDue:
+			n.sendEveningReminders(time.Now())
+			reminderDue = time.After(time.Until(n.nextDailyAt(time.Now(), n.opts.ReminderTime, bot.DefaultReminderTime)))
+		case <-reportDue:
+			n.sendWeeklyReport()
+			reportDue = time.After(time.Until(n.nextWeeklyAt(time.Now(), n.opts.WeeklyReportDay, n.opts.WeeklyReportTime)))
+		case <-maintenanceDue:
+			n.scheduledMaintenance(ctx)
+			maintenanceDue = time.After(time.Until(n.nextDailyAt(time.Now(), n.opts.MaintenanceTime, DefaultMaintenanceTime)))
+		case now := <-retryTicker.C:
+			n.retryDeliveries(now)
+		case <-timer.C:
+			if n.Paused() {
+				n.log.Debug("Notifier paused, skipping scheduled check")
+			} else if n.backingOff(n.now()) {
+				n.log.Debug("Backing off after YCLIENTS rate limiting, skipping scheduled check")
+			} else {
+				n.timedCheck(ctx)
+			}
+			timer.Reset(n.nextCheckDelay())
+		case <-n.checkNow:
+			n.log.Info("Running requested check")
+			waiters := n.takeCheckWaiters()
+			res := n.timedCheck(ctx)
+			n.reportCheck(waiters, res)
+			resetTimer(timer, n.nextCheckDelay())
 		}
 	}
 }
 
-func (n *Notifier) checkAndNotify(ctx context.Context) {
+// checkAndNotify runs one availability check and returns its summary; the
+// result is zero when the check was skipped.
+func (n *Notifier) checkAndNotify(ctx context.Context) checkResult {
 	start := time.Now()
-	n.log.Debug("Starting slot availability check")
-	
+	ctx = withCheckID(ctx, "check")
+	log := n.logFor(ctx)
+	log.Debug("Starting slot availability check")
+
 	if len(n.opts.ServiceIDs) == 0 || n.opts.LocationID == 0 {
-		n.log.WarnWithFields("Configuration incomplete, skipping check", logger.Fields{
+		log.WarnWithFields("Configuration incomplete, skipping check", logger.Fields{
 			"location_id": n.opts.LocationID,
 			"service_ids": n.opts.ServiceIDs,
 		})
-		return
+		return checkResult{}
 	}
 
-	loc, err := time.LoadLocation(n.opts.Timezone)
+	phase := &checkPhase{}
+	phase.set("staff_names")
+	runCtx := ctx
+	ctx, cancel := n.withCheckTimeout(ctx, phase)
+	defer cancel()
+
+	n.refreshStaffNames(ctx)
+	n.refreshServiceNames(ctx)
+	loc := n.loc
+	today, dateTo := DateRange(time.Now().In(loc), n.opts.LookaheadDays)
+
+	newSlotsFound := 0
+	seats := make(map[string]int)
+	var found []slots.Slot
+
+	phase.set("scan")
+	available, errorsCount := n.scanAvailability(ctx, today, dateTo)
+	discoveredAt := time.Now()
+	phase.set("process")
+	totalChecks := len(available)
+	complete := errorsCount == 0
+	subscribers, err := n.bot.Subscribers()
 	if err != nil {
-		n.log.WithError(err).WarnWithFields("Failed to load timezone, using fallback", logger.Fields{
-			"timezone": n.opts.Timezone,
-			"fallback": "UTC+3",
-		})
-		loc = time.FixedZone("UTC+3", 3*3600)
+		// Slots recorded now would never be announced; leave them for the
+		// next check
+		log.WithError(err).Error("Failed to get subscribers, skipping notifications")
+		n.recordError("subscribers_load")
+		available, errorsCount, complete = nil, errorsCount+1, false
 	}
-	
-	today := time.Now().In(loc).Format("2006-01-02")
-	const farFuture = "9999-01-01"
-	
-	newSlotsFound := 0
-	totalChecks := 0
-	
-	for _, serviceID := range n.opts.ServiceIDs {
-		n.log.DebugWithFields("Checking service", logger.Fields{
-			"service_id": serviceID,
-		})
-		
-		staffIDs, err := n.yc.GetBookableStaffIDs(ctx, n.opts.LocationID, serviceID)
-		if err != nil {
-			n.log.WithError(err).ErrorWithFields("Failed to get staff IDs", logger.Fields{
-				"service_id": serviceID,
-			})
-			continue
+	previous, err := n.storage.LatestSnapshot()
+	if err != nil {
+		// Without the previous snapshot every slot would look new
+		log.WithError(err).Error("Failed to load availability snapshot, skipping notifications")
+		if n.metrics != nil {
+			n.metrics.RecordError("snapshot_load")
 		}
-		
-		if len(staffIDs) == 0 {
-			n.log.DebugWithFields("No bookable staff found", logger.Fields{
-				"service_id": serviceID,
-			})
-			continue
+		available, errorsCount, complete = nil, errorsCount+1, false
+	}
+	diff := diffSnapshot(previous, available, complete)
+	if err == nil {
+		if err := n.storage.SaveSnapshot(discoveredAt, complete, diff.next); err != nil {
+			log.WithError(err).Error("Failed to save availability snapshot")
+		}
+	}
+	for _, slot := range available {
+		if slot.HasSeats {
+			seats[slot.Key()] = slot.SeatsLeft
 		}
-		
-		n.log.DebugWithFields("Found bookable staff", logger.Fields{
-			"service_id": serviceID,
-			"staff_ids":  staffIDs,
+	}
+	for _, slot := range diff.kept {
+		if prev, ok := n.seats[slot.Key()]; ok && slot.HasSeats && slot.SeatsLeft < prev {
+			n.handleSeatsDecrease(slot, prev)
+		}
+	}
+	storeCtx, storeCancel := storageContext(runCtx)
+	defer storeCancel()
+	for _, slot := range diff.added {
+		key := slot.Key()
+		if err := n.storage.MarkSlotSeen(storeCtx, observedSlot(slot)); err != nil {
+			log.WithError(err).Error("Failed to mark slot as seen")
+		}
+		if err := n.storage.RecordSlotAppearance(key, time.Now()); err != nil {
+			log.WithError(err).Warn("Failed to record slot appearance")
+		}
+		newSlotsFound++
+		if n.metrics != nil {
+			n.metrics.RecordNewSlot()
+		}
+
+		log.InfoWithFields("New slot found", logger.Fields{
+			"service_id": slot.ServiceID,
+			"staff_id":   slot.StaffID,
+			"time":       slot.Raw,
 		})
-		
-		for _, staffID := range staffIDs {
-			sid := staffID
-			dates, err := n.yc.GetBookableDates(ctx, n.opts.LocationID, serviceID, today, farFuture, &sid)
-			if err != nil {
-				n.log.WithError(err).ErrorWithFields("Failed to get bookable dates", logger.Fields{
-					"service_id": serviceID,
-					"staff_id":   staffID,
-				})
-				continue
-			}
-			
-			for _, date := range dates {
-				times, err := n.yc.GetBookableTimeslots(ctx, n.opts.LocationID, serviceID, date, staffID)
-				if err != nil {
-					n.log.WithError(err).ErrorWithFields("Failed to get timeslots", logger.Fields{
-						"service_id": serviceID,
-						"staff_id":   staffID,
-						"date":       date,
-					})
-					continue
-				}
-				
-				for _, t := range times {
-					totalChecks++
-					key := n.buildKey(serviceID, staffID, t)
-					seen, err := n.storage.IsSlotSeen(key)
-					if err != nil {
-						n.log.WithError(err).Error("Failed to check if slot seen")
-						continue
-					}
-					if seen {
-						continue
-					}
-					
-					if err := n.storage.MarkSlotSeen(key); err != nil {
-						n.log.WithError(err).Error("Failed to mark slot as seen")
-					}
-					newSlotsFound++
-					if n.metrics != nil {
-						n.metrics.RecordNewSlot()
-					}
-					
-					n.log.InfoWithFields("New slot found", logger.Fields{
-						"service_id": serviceID,
-						"staff_id":   staffID,
-						"date":       date,
-						"time":       t,
-					})
-					
-					// Notify subscribers
-					msg := n.formatSlotMessage(serviceID, staffID, t)
-					subscribers := n.bot.Subscribers()
-					
-					for _, chatID := range subscribers {
-						if err := n.bot.Notify(chatID, msg); err != nil {
-							n.log.WithError(err).ErrorWithFields("Failed to notify subscriber", logger.Fields{
-								"chat_id": chatID,
-							})
-						}
-					}
-					
-					n.log.InfoWithFields("Notified subscribers about new slot", logger.Fields{
-						"subscribers_count": len(subscribers),
-						"service_id":        serviceID,
-						"staff_id":          staffID,
-					})
-				}
+
+		found = append(found, slot)
+	}
+
+	phase.set("notify")
+	deprioritized := n.notifyNewSlots(found, subscribers, discoveredAt)
+	if complete {
+		// Partial results would make the pinned list look emptier than it
+		// is
+		if !n.opts.DryRun {
+			if text, err := n.formatPinnedMessage(available, time.Now().In(loc)); err == nil {
+				n.bot.UpdatePinned(text)
 			}
 		}
+		n.recordAvailable(available)
+		sorted := append([]slots.Slot(nil), available...)
+		sortByStart(sorted)
+		n.cacheScan(sorted, discoveredAt)
+		n.reportTakenSlots(diff.removed, time.Now())
 	}
-	
+	n.seats = seats
+
 	duration := time.Since(start)
 	if n.metrics != nil {
 		n.metrics.ObserveSlotCheckDuration(duration.Seconds())
 	}
-	
-	// Clean old slots (older than 7 days)
-	if err := n.storage.CleanOldSlots(7 * 24 * time.Hour); err != nil {
-		n.log.WithError(err).Warn("Failed to clean old slots")
+	res := checkResult{
+		At: start, Duration: duration, NewSlots: newSlotsFound, Errors: errorsCount, Deprioritized: deprioritized,
 	}
-	
-	n.log.InfoWithFields("Slot availability check completed", logger.Fields{
+	n.recordCheck(res)
+	n.saveHealth(res)
+	n.addDailyStat(storage.StatCheckSeconds, duration.Seconds())
+	if newSlotsFound > 0 {
+		n.addDailyStat(storage.StatSlotsDiscovered, float64(newSlotsFound))
+	}
+
+	// Clean old slots
+	phase.set("cleanup")
+	cleanCtx, cleanCancel := storageContext(runCtx)
+	defer cleanCancel()
+	if err := n.storage.CleanOldSlots(cleanCtx, seenSlotRetention); err != nil {
+		log.WithError(err).Warn("Failed to clean old slots")
+	}
+
+	log.InfoWithFields("Slot availability check completed", logger.Fields{
 		"duration":        duration.String(),
 		"new_slots_found": newSlotsFound,
 		"total_checks":    totalChecks,
 		"seen_slots":      totalChecks - newSlotsFound,
 	})
+	return res
 }
 
-func (n *Notifier) buildKey(serviceID, staffID int, datetime string) string {
-	return fmt.Sprintf("svc=%d|staff=%d|dt=%s", serviceID, staffID, datetime)
+// wantsSlot applies the subscriber's preferences to an instant notification
+// about a slot. Digest-mode and muted chats never get instant notifications.
+func (n *Notifier) wantsSlot(chatID int64, serviceID int, slotTime time.Time) bool {
+	prefs := n.preferences(chatID)
+	now := time.Now().In(n.loc)
+	return !prefs.Digest && !prefs.Muted(now) && !n.inQuietHours(prefs) && matchesPreferences(prefs, serviceID, slotTime, now)
 }
 
-func getRussianWeekday(wd time.Weekday) string {
-	switch wd {
-	case time.Monday:
-		return "понедельник"
-	case time.Tuesday:
-		return "вторник"
-	case time.Wednesday:
-		return "среда"
-	case time.Thursday:
-		return "четверг"
-	case time.Friday:
-		return "пятница"
-	case time.Saturday:
-		return "суббота"
-	case time.Sunday:
-		return "воскресенье"
-	default:
-		return ""
+// preferences loads the subscriber's settings. Preferences that cannot be
+// loaded never block a notification.
+func (n *Notifier) preferences(chatID int64) storage.Preferences {
+	prefs, err := n.storage.GetPreferences(chatID)
+	if err != nil {
+		n.log.WithError(err).WarnWithFields("Failed to load preferences, notifying anyway", logger.Fields{
+			"chat_id": chatID,
+		})
+		return storage.DefaultPreferences()
 	}
+	return prefs
 }
 
-func (n *Notifier) formatSlotMessage(serviceID, staffID int, datetime string) string {
-	// Try to parse RFC3339 datetime and present it nicely in configured timezone
-	loc, err := time.LoadLocation(n.opts.Timezone)
-	if err != nil {
-		n.log.WithError(err).WarnWithFields("Failed to load timezone for message formatting", logger.Fields{
-			"timezone": n.opts.Timezone,
-		})
-		loc = time.FixedZone("UTC+3", 3*3600)
+func (n *Notifier) inQuietHours(prefs storage.Preferences) bool {
+	return prefs.HasQuietHours() && bot.InWindow(time.Now().In(n.loc), prefs.QuietFrom, prefs.QuietTo)
+}
+
+// matchesPreferences reports whether the slot's service, weekday and date
+// are wanted; now is the current time in the configured timezone.
+func matchesPreferences(prefs storage.Preferences, serviceID int, slotTime, now time.Time) bool {
+	if len(prefs.ServiceIDs) > 0 && !containsInt(prefs.ServiceIDs, serviceID) {
+		return false
 	}
-	
-	t, err := time.Parse(time.RFC3339, datetime)
-	var date, clock, zone, weekday string
-	if err == nil {
-		tt := t.In(loc)
-		date = tt.Format("02.01.2006")
-		clock = tt.Format("15:04")
-		zone = tt.Format("MST")
-		weekday = getRussianWeekday(tt.Weekday())
+	if !prefs.WithinHorizon(slotTime, now) {
+		return false
+	}
+	if len(prefs.Weekdays) > 0 && !slotTime.IsZero() && !containsInt(prefs.Weekdays, int(slotTime.Weekday())) {
+		return false
+	}
+	return true
+}
+
+// DefaultLookaheadDays is how far ahead slots are scanned unless configured.
+const DefaultLookaheadDays = 30
+
+// DateRange returns the "YYYY-MM-DD" bounds of a scan starting on now's date
+// and covering the following days.
+func DateRange(now time.Time, days int) (string, string) {
+	return now.Format("2006-01-02"), now.AddDate(0, 0, days).Format("2006-01-02")
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// slotMessageData is the data passed to slot templates. Start is zero when
+// the API returned a bare time; Date, Time and Weekday are preformatted
+// copies kept for templates written before Start was available.
+type slotMessageData struct {
+	CompanyName string
+	ServiceName string
+	ServiceID   int
+	StaffID     int
+	StaffName   string // resolved name, or "#id" when unknown
+	Start       time.Time
+	Date        string
+	Time        string
+	Zone        string
+	Weekday     string
+	PriceMin    float64
+	PriceMax    float64
+	HasSeats    bool
+	SeatsLeft   int
+	BookingURL  string
+	// Lang is the chat's language from its preferences; empty means the
+	// default, Russian.
+	Lang string
+	// DailyCount is the position of this notification among those the chat
+	// received today; SuggestDigest appends the one-time digest offer.
+	DailyCount    int
+	SuggestDigest bool
+}
+
+func (n *Notifier) slotData(slot slots.Slot) slotMessageData {
+	serviceID := slot.ServiceID
+	data := slotMessageData{ServiceID: serviceID, StaffID: slot.StaffID, StaffName: staffLabel(slot.StaffID), Start: slot.Start, HasSeats: slot.HasSeats, SeatsLeft: slot.SeatsLeft, PriceMin: slot.PriceMin, PriceMax: slot.PriceMax}
+	if n.bot != nil {
+		data.BookingURL = n.bot.BookingURL(serviceID)
+	}
+	if !slot.Start.IsZero() {
+		data.Date = slot.Start.Format("02.01.2006")
+		data.Time = slot.Start.Format("15:04")
+		data.Zone = slot.Start.Format("MST")
+		data.Weekday = format.WeekdayRU(slot.Start.Weekday())
 	} else {
-		n.log.WithError(err).WarnWithFields("Failed to parse datetime, using raw value", logger.Fields{
-			"datetime": datetime,
-		})
-		clock = datetime
+		data.Time = slot.Raw
 	}
 
 	// Resolve human-friendly names
@@ -312,20 +604,35 @@ func (n *Notifier) formatSlotMessage(serviceID, staffID int, datetime string) st
 			"service_id": svc,
 		})
 	}
+	data.CompanyName = companyName
+	data.ServiceName = serviceName
+	return data
+}
+
+func (n *Notifier) formatSlotMessage(slot slots.Slot) string {
+	return n.renderSlotMessage(n.slotData(slot))
+}
 
-	// Render via template if available
-	if tmpl, ok := n.templates["templates/slot_message.tmpl"]; ok {
+func (n *Notifier) renderSlotMessage(data slotMessageData) string {
+	// Render via template if available, preferring the service's own
+	file := serviceTemplateFile(data.ServiceID)
+	tmpl, ok := n.template(file)
+	if !ok && data.Lang != "" {
+		file = languageTemplateFile(data.Lang)
+		tmpl, ok = n.template(file)
+	}
+	if !ok {
+		file = "templates/slot_message.tmpl"
+		tmpl, ok = n.template(file)
+	}
+	if ok {
+		n.log.DebugWithFields("Rendering slot message", logger.Fields{
+			"template":   file,
+			"service_id": data.ServiceID,
+		})
 		var buf bytes.Buffer
-		err := tmpl.Execute(&buf, struct {
-			CompanyName string
-			ServiceName string
-			StaffID     int
-			Date        string
-			Time        string
-			Zone        string
-			Weekday     string
-		}{CompanyName: companyName, ServiceName: serviceName, StaffID: staffID, Date: date, Time: clock, Zone: zone, Weekday: weekday})
-		
+		err := tmpl.Execute(&buf, data)
+
 		if err != nil {
 			n.log.WithError(err).Error("Failed to execute message template, using fallback")
 		} else {
@@ -334,42 +641,69 @@ func (n *Notifier) formatSlotMessage(serviceID, staffID int, datetime string) st
 	}
 
 	// Fallback template
-	if date != "" {
-		return fmt.Sprintf("🟢 Доступно окно записи\n\nКомпания: %s\nУслуга: %s\nСотрудник: #%d\nДата: %s (%s)\nВремя: %s %s\n", companyName, serviceName, staffID, date, weekday, clock, zone)
+	if data.Date != "" {
+		return fmt.Sprintf("🟢 Доступно окно записи\n\nКомпания: %s\nУслуга: %s\nСотрудник: %s\nДата: %s (%s)\nВремя: %s %s\n", data.CompanyName, data.ServiceName, data.StaffName, data.Date, data.Weekday, data.Time, data.Zone)
 	}
-	return fmt.Sprintf("🟢 Доступно окно записи\n\nКомпания: %s\nУслуга: %s\nСотрудник: #%d\nВремя: %s\n", companyName, serviceName, staffID, clock)
+	return fmt.Sprintf("🟢 Доступно окно записи\n\nКомпания: %s\nУслуга: %s\nСотрудник: %s\nВремя: %s\n", data.CompanyName, data.ServiceName, data.StaffName, data.Time)
 }
 
-func (n *Notifier) RenderTemplate(templateName string, data interface{}) string {
-	tmpl, ok := n.templates[templateName]
+// RenderTemplate executes a loaded template. Failures are logged and
+// counted as template_render errors; callers pick their own fallback text.
+func (n *Notifier) RenderTemplate(templateName string, data interface{}) (string, error) {
+	tmpl, ok := n.template(templateName)
 	if !ok {
 		n.log.WarnWithFields("Template not found", logger.Fields{"template": templateName})
-		return "Template not found"
+		n.recordTemplateError()
+		return "", fmt.Errorf("template %s not found", templateName)
 	}
-	
+
 	var buf bytes.Buffer
 	err := tmpl.Execute(&buf, data)
 	if err != nil {
 		n.log.WithError(err).ErrorWithFields("Failed to execute template", logger.Fields{"template": templateName})
-		return "Template error"
+		n.recordTemplateError()
+		return "", fmt.Errorf("execute template %s: %w", templateName, err)
+	}
+
+	return buf.String(), nil
+}
+
+func (n *Notifier) recordTemplateError() {
+	if n.metrics != nil {
+		n.metrics.RecordError("template_render")
 	}
-	
-	return buf.String()
 }
 
-func (n *Notifier) GetWelcomeMessage() string {
-	return n.RenderTemplate("templates/welcome_message.tmpl", nil)
+func (n *Notifier) GetWelcomeMessage(view bot.WelcomeView) (string, error) {
+	return n.RenderTemplate("templates/welcome_message.tmpl", view)
 }
 
-func (n *Notifier) GetGoodbyeMessage() string {
+func (n *Notifier) GetGoodbyeMessage() (string, error) {
 	return n.RenderTemplate("templates/goodbye_message.tmpl", nil)
 }
 
-func (n *Notifier) GetCurrentSlotsMessage(slots []string) string {
-	if len(slots) == 0 {
+func (n *Notifier) GetCurrentSlotsMessage(available []slots.Slot) (string, error) {
+	data := groupCurrentSlots(available, n.loc)
+	if len(data.Slots) == 0 {
 		return n.RenderTemplate("templates/no_slots.tmpl", nil)
 	}
-	return n.RenderTemplate("templates/current_slots.tmpl", struct{ Slots []string }{Slots: slots})
+	return n.RenderTemplate("templates/current_slots.tmpl", data)
+}
+
+func (n *Notifier) GetSettingsMessage(view bot.SettingsView) (string, error) {
+	return n.RenderTemplate("templates/settings.tmpl", view)
+}
+
+func (n *Notifier) GetStatusMessage(view bot.SubscriptionView) (string, error) {
+	return n.RenderTemplate("templates/status.tmpl", view)
+}
+
+func (n *Notifier) GetSubscriptionExpiringMessage(view bot.SubscriptionView) (string, error) {
+	return n.RenderTemplate("templates/subscription_expiring.tmpl", view)
+}
+
+func (n *Notifier) GetSubscriptionExpiredMessage() (string, error) {
+	return n.RenderTemplate("templates/subscription_expired.tmpl", nil)
 }
 
 func (n *Notifier) SetMetrics(metrics MetricsRecorder) {
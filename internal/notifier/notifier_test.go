@@ -0,0 +1,371 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/bot/bottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/metrics"
+	"github.com/thatguy/moto_gorod-notifier/internal/notifier/slottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+var _ SlotSource = (*slottest.Source)(nil)
+
+const (
+	testLocationID = 780413
+	testServiceID  = 15728488
+	testStaffID    = 2850522
+	testChatID     = 1001
+)
+
+// testEnv is a notifier wired to a fake slot source, a fake Telegram API
+// and a real database in a temporary directory.
+type testEnv struct {
+	n        *Notifier
+	src      *slottest.Source
+	api      *bottest.FakeAPI
+	store    *storage.Storage
+	botStore *botStorage
+}
+
+// botStorage is the bot's view of the database; SubscribersErr makes
+// GetSubscribers fail.
+type botStorage struct {
+	*storage.Storage
+	SubscribersErr error
+}
+
+func (s *botStorage) GetSubscribers(ctx context.Context) ([]int64, error) {
+	if s.SubscribersErr != nil {
+		return nil, s.SubscribersErr
+	}
+	return s.Storage.GetSubscribers(ctx)
+}
+
+func newTestEnv(t *testing.T, opts Options) *testEnv {
+	t.Helper()
+	log := logger.New().WithOutput(io.Discard)
+	store, err := storage.New(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	if opts.LocationID == 0 {
+		opts.LocationID = testLocationID
+	}
+	if opts.ServiceIDs == nil {
+		opts.ServiceIDs = []int{testServiceID}
+	}
+	if opts.Timezone == "" {
+		opts.Timezone = "Europe/Moscow"
+	}
+	api := bottest.NewFakeAPI()
+	src := slottest.NewSource()
+	bs := &botStorage{Storage: store}
+	b := bot.NewWithAPI(api, bs, log)
+	return &testEnv{n: New(b, src, opts, store, log), src: src, api: api, store: store, botStore: bs}
+}
+
+// subscribe adds chatID as a subscriber.
+func (e *testEnv) subscribe(t *testing.T, chatID int64) {
+	t.Helper()
+	if err := e.store.AddSubscriber(context.Background(), chatID); err != nil {
+		t.Fatalf("AddSubscriber: %v", err)
+	}
+}
+
+// day returns 10:00 on the date days from today in the notifier's timezone.
+func (e *testEnv) day(days int) time.Time {
+	now := time.Now().In(e.n.loc)
+	return time.Date(now.Year(), now.Month(), now.Day()+days, 10, 0, 0, 0, e.n.loc)
+}
+
+func TestCheckAndNotifyAnnouncesNewSlotOnce(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	e.subscribe(t, testChatID)
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+
+	res := e.n.checkAndNotify(context.Background())
+	if res.NewSlots != 1 || res.Errors != 0 {
+		t.Fatalf("first check = %+v, want 1 new slot and no errors", res)
+	}
+	if got := len(e.api.MessagesTo(testChatID)); got != 1 {
+		t.Fatalf("subscriber got %d messages, want 1", got)
+	}
+
+	res = e.n.checkAndNotify(context.Background())
+	if res.NewSlots != 0 {
+		t.Errorf("second check found %d new slots, want 0", res.NewSlots)
+	}
+	if got := len(e.api.MessagesTo(testChatID)); got != 1 {
+		t.Errorf("subscriber got %d messages after the second check, want 1", got)
+	}
+}
+
+func TestCheckAndNotifyAnnouncesReturningSlot(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	e.subscribe(t, testChatID)
+	slot := slottest.At(e.day(2))
+	e.src.Add(testServiceID, testStaffID, slot)
+	e.n.checkAndNotify(context.Background())
+
+	e.src.Clear()
+	e.n.checkAndNotify(context.Background())
+	e.src.Add(testServiceID, testStaffID, slot)
+	res := e.n.checkAndNotify(context.Background())
+
+	if res.NewSlots != 1 {
+		t.Errorf("check after the slot returned found %d new slots, want 1", res.NewSlots)
+	}
+}
+
+func TestCheckAndNotifySkipsMutedChats(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	e.subscribe(t, testChatID)
+	e.subscribe(t, testChatID+1)
+	if err := e.store.SetPreferences(testChatID, storage.Preferences{MutedUntil: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.store.SetPreferences(testChatID+1, storage.Preferences{MutedUntil: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+
+	e.n.checkAndNotify(context.Background())
+
+	if got := len(e.api.MessagesTo(testChatID)); got != 0 {
+		t.Errorf("muted chat got %d messages, want 0", got)
+	}
+	if got := len(e.api.MessagesTo(testChatID + 1)); got != 1 {
+		t.Errorf("chat whose pause ended got %d messages, want 1", got)
+	}
+}
+
+func TestCheckAndNotifyUsesChatLanguage(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	e.subscribe(t, testChatID)
+	e.subscribe(t, testChatID+1)
+	if err := e.store.SetPreferences(testChatID, storage.Preferences{Language: "en"}); err != nil {
+		t.Fatal(err)
+	}
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+
+	e.n.checkAndNotify(context.Background())
+
+	if msgs := e.api.MessagesTo(testChatID); len(msgs) != 1 || !strings.HasPrefix(msgs[0], "🟢 New booking slot") {
+		t.Errorf("English chat got %q, want the English template", msgs)
+	}
+	if msgs := e.api.MessagesTo(testChatID + 1); len(msgs) != 1 || !strings.HasPrefix(msgs[0], "🟢 Доступно окно записи") {
+		t.Errorf("default chat got %q, want the Russian template", msgs)
+	}
+}
+
+func TestCheckAndNotifySourceFailure(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	e.subscribe(t, testChatID)
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+	e.src.Fail(slottest.BookableDatesAnyStaff, errors.New("unavailable"))
+
+	res := e.n.checkAndNotify(context.Background())
+	if res.Errors == 0 {
+		t.Errorf("check reported no errors")
+	}
+	if res.NewSlots != 0 {
+		t.Errorf("check found %d new slots, want 0", res.NewSlots)
+	}
+	if got := e.api.MessagesTo(testChatID); len(got) != 0 {
+		t.Errorf("subscriber got %d messages, want none", len(got))
+	}
+
+	e.src.Fail(slottest.BookableDatesAnyStaff, nil)
+	if res := e.n.checkAndNotify(context.Background()); res.NewSlots != 1 {
+		t.Errorf("check after recovery found %d new slots, want 1", res.NewSlots)
+	}
+}
+
+func TestCheckAndNotifySkipsUnconfigured(t *testing.T) {
+	e := newTestEnv(t, Options{ServiceIDs: []int{}})
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+
+	if res := e.n.checkAndNotify(context.Background()); res != (checkResult{}) {
+		t.Errorf("check = %+v, want skipped", res)
+	}
+	if calls := e.src.Calls(slottest.BookableDatesAnyStaff); calls != 0 {
+		t.Errorf("source called %d times, want 0", calls)
+	}
+}
+
+func TestCheckAndNotifyDryRun(t *testing.T) {
+	e := newTestEnv(t, Options{DryRun: true})
+	e.subscribe(t, testChatID)
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+
+	if res := e.n.checkAndNotify(context.Background()); res.NewSlots != 1 {
+		t.Fatalf("check found %d new slots, want 1", res.NewSlots)
+	}
+	if got := e.api.MessagesTo(testChatID); len(got) != 0 {
+		t.Errorf("dry run messaged the subscriber %d times", len(got))
+	}
+}
+
+func TestCheckAndNotifyRecordsPartialResultsAfterTimeout(t *testing.T) {
+	e := newTestEnv(t, Options{CheckTimeout: 200 * time.Millisecond})
+	e.subscribe(t, testChatID)
+	fast, slow := e.day(1), e.day(2)
+	e.src.Add(testServiceID, testStaffID, slottest.At(fast), slottest.At(slow))
+	e.src.DelayDate(slow.Format("2006-01-02"), time.Minute)
+
+	res := e.n.checkAndNotify(context.Background())
+	if res.Errors == 0 {
+		t.Fatalf("check did not time out")
+	}
+	if res.NewSlots != 1 {
+		t.Fatalf("check found %d new slots, want 1", res.NewSlots)
+	}
+	seen, err := e.store.SeenSlotsSince(time.Time{})
+	if err != nil {
+		t.Fatalf("SeenSlotsSince: %v", err)
+	}
+	if len(seen) != 1 {
+		t.Errorf("%d slots marked seen after the timeout, want 1", len(seen))
+	}
+
+	e.src.DelayDate(slow.Format("2006-01-02"), 0)
+	res = e.n.checkAndNotify(context.Background())
+	if res.NewSlots != 1 {
+		t.Errorf("next check found %d new slots, want only the slow one", res.NewSlots)
+	}
+	if got := len(e.api.MessagesTo(testChatID)); got != 2 {
+		t.Errorf("subscriber got %d messages, want 2", got)
+	}
+}
+
+func TestCheckAndNotifyKeepsSlotsWhenSubscribersFail(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	e.subscribe(t, testChatID)
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+	e.botStore.SubscribersErr = errors.New("database is locked")
+
+	res := e.n.checkAndNotify(context.Background())
+	if res.Errors == 0 {
+		t.Error("check reported no errors")
+	}
+	if res.NewSlots != 0 {
+		t.Errorf("check recorded %d new slots nobody was told about", res.NewSlots)
+	}
+
+	e.botStore.SubscribersErr = nil
+	if res := e.n.checkAndNotify(context.Background()); res.NewSlots != 1 {
+		t.Errorf("check after recovery found %d new slots, want 1", res.NewSlots)
+	}
+	if got := len(e.api.MessagesTo(testChatID)); got != 1 {
+		t.Errorf("subscriber got %d messages, want 1", got)
+	}
+}
+
+func TestRetryDeliveriesKeepsRetriesWhenSubscribersFail(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	e.subscribe(t, testChatID)
+	now := time.Now()
+	retry := storage.DeliveryRetry{ChatID: testChatID, SlotKey: "k", Message: "m", NextRetryAt: now.Add(-time.Minute)}
+	if err := e.store.QueueRetry(retry); err != nil {
+		t.Fatalf("QueueRetry: %v", err)
+	}
+	e.botStore.SubscribersErr = errors.New("database is locked")
+
+	e.n.retryDeliveries(now)
+
+	due, err := e.store.DueRetries(now, 10)
+	if err != nil {
+		t.Fatalf("DueRetries: %v", err)
+	}
+	if len(due) != 1 {
+		t.Errorf("%d retries left, want the queued one kept", len(due))
+	}
+}
+
+// metricValue returns the counter or gauge value, or the histogram sample
+// count, of the series of name in reg whose labels include labels.
+func metricValue(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	var total float64
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+	series:
+		for _, m := range f.GetMetric() {
+			for k, v := range labels {
+				found := false
+				for _, l := range m.GetLabel() {
+					found = found || (l.GetName() == k && l.GetValue() == v)
+				}
+				if !found {
+					continue series
+				}
+			}
+			switch {
+			case m.Counter != nil:
+				total += m.GetCounter().GetValue()
+			case m.Gauge != nil:
+				total += m.GetGauge().GetValue()
+			case m.Histogram != nil:
+				total += float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return total
+}
+
+func TestCheckAndNotifyRecordsMetrics(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	reg := prometheus.NewRegistry()
+	m := metrics.NewWithRegistry(reg)
+	e.n.SetMetrics(m)
+	e.n.bot.SetMetrics(m)
+	e.subscribe(t, testChatID)
+	e.subscribe(t, testChatID+1)
+	e.api.FailChats = map[int64]error{testChatID + 1: bottest.ErrFake}
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)), slottest.At(e.day(2)))
+
+	e.n.checkAndNotify(context.Background())
+
+	for _, tt := range []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{"moto_gorod_slot_check_duration_seconds", nil, 1},
+		{"moto_gorod_new_slots_total", nil, 2},
+		{"moto_gorod_notifications_sent_total", nil, 2},
+		{"moto_gorod_notification_delay_seconds", nil, 2},
+		{"moto_gorod_errors_total", map[string]string{"type": "notification_failed"}, 2},
+	} {
+		if got := metricValue(t, reg, tt.name, tt.labels); got != tt.want {
+			t.Errorf("%s%v = %v, want %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+
+	// Nothing new: only the check itself is observed.
+	e.n.checkAndNotify(context.Background())
+	if got := metricValue(t, reg, "moto_gorod_slot_check_duration_seconds", nil); got != 2 {
+		t.Errorf("%v checks observed, want 2", got)
+	}
+	if got := metricValue(t, reg, "moto_gorod_new_slots_total", nil); got != 2 {
+		t.Errorf("new slots counter = %v after a check without news, want 2", got)
+	}
+}
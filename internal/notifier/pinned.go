@@ -0,0 +1,64 @@
+package notifier
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/format"
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+)
+
+// pinnedMaxDays limits the pinned message to stay well below Telegram's
+// message length limit.
+const pinnedMaxDays = 14
+
+type pinnedDay struct {
+	Date    string
+	Weekday string
+	Times   string
+}
+
+type pinnedMessageData struct {
+	Days      []pinnedDay
+	MoreDays  int
+	UpdatedAt string
+}
+
+// formatPinnedMessage renders all currently available slots grouped by date.
+func (n *Notifier) formatPinnedMessage(available []slots.Slot, now time.Time) (string, error) {
+	sorted := append([]slots.Slot(nil), available...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var (
+		days  []pinnedDay
+		times []string
+		day   time.Time
+	)
+	flush := func() {
+		if len(times) > 0 {
+			days = append(days, pinnedDay{
+				Date:    day.Format("02.01"),
+				Weekday: format.WeekdayRU(day.Weekday()),
+				Times:   strings.Join(times, ", "),
+			})
+		}
+	}
+	for _, s := range sorted {
+		if s.Start.Format("2006-01-02") != day.Format("2006-01-02") {
+			flush()
+			day, times = s.Start, nil
+		}
+		t := s.Start.Format("15:04")
+		if len(times) == 0 || times[len(times)-1] != t {
+			times = append(times, t)
+		}
+	}
+	flush()
+
+	data := pinnedMessageData{Days: days, UpdatedAt: now.Format("15:04")}
+	if len(days) > pinnedMaxDays {
+		data.Days, data.MoreDays = days[:pinnedMaxDays], len(days)-pinnedMaxDays
+	}
+	return n.RenderTemplate("templates/pinned_availability.tmpl", data)
+}
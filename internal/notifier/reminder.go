@@ -0,0 +1,124 @@
+package notifier
+
+import (
+	"sort"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/format"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+)
+
+type reminderMessageData struct {
+	Date       string
+	Weekday    string
+	Slots      []string
+	BookingURL string
+}
+
+// slotsOn returns the slots starting on day's calendar date in the
+// configured timezone, earliest first.
+func (n *Notifier) slotsOn(available []slots.Slot, day time.Time) []slots.Slot {
+	date := day.In(n.loc).Format("2006-01-02")
+	var out []slots.Slot
+	for _, s := range available {
+		if !s.Start.IsZero() && s.Start.In(n.loc).Format("2006-01-02") == date {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out
+}
+
+// sendEveningReminders lists tomorrow's remaining slots to opted-in chats.
+// A chat is skipped when tomorrow is empty for it, during its quiet hours,
+// or when it was already notified today about every one of those slots.
+func (n *Notifier) sendEveningReminders(now time.Time) {
+	n.stateMu.RLock()
+	available, complete := n.available, n.availableKeys != nil
+	n.stateMu.RUnlock()
+	if !complete {
+		n.log.Warn("No complete check yet, skipping evening reminders")
+		return
+	}
+
+	now = now.In(n.loc)
+	tomorrow := n.slotsOn(available, now.AddDate(0, 0, 1))
+	if len(tomorrow) == 0 {
+		n.log.Debug("No slots left for tomorrow, skipping evening reminders")
+		return
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, n.loc)
+
+	subscribers, err := n.bot.Subscribers()
+	if err != nil {
+		n.log.WithError(err).Error("Failed to get subscribers, skipping evening reminders")
+		return
+	}
+	sent := 0
+	for _, chatID := range subscribers {
+		prefs := n.preferences(chatID)
+		if !prefs.EveningReminder || prefs.Muted(now) || n.inQuietHours(prefs) {
+			continue
+		}
+		var wanted []slots.Slot
+		for _, s := range tomorrow {
+			if matchesPreferences(prefs, s.ServiceID, s.Start, now) {
+				wanted = append(wanted, s)
+			}
+		}
+		if len(wanted) == 0 {
+			continue
+		}
+
+		notified, err := n.storage.NotifiedSlotKeysSince(chatID, midnight)
+		if err != nil {
+			n.log.WithError(err).WarnWithFields("Failed to load today's notifications", logger.Fields{
+				"chat_id": chatID,
+			})
+		}
+		if allNotified(wanted, notified) {
+			continue
+		}
+
+		msg, err := n.RenderTemplate("templates/evening_reminder.tmpl", n.reminderData(wanted))
+		if err != nil {
+			// The template is shared, so every remaining chat would fail too.
+			n.log.WithError(err).ErrorWithFields("Failed to render evening reminder, skipping the rest", logger.Fields{
+				"chat_id": chatID,
+				"sent":    sent,
+			})
+			return
+		}
+		n.notify(chatID, "reminder", firstLine(msg), msg)
+		sent++
+	}
+
+	n.log.InfoWithFields("Evening reminders sent", logger.Fields{
+		"tomorrow_slots": len(tomorrow),
+		"sent":           sent,
+	})
+}
+
+// allNotified reports whether every slot's key is in notified.
+func allNotified(list []slots.Slot, notified map[string]bool) bool {
+	for _, s := range list {
+		if !notified[s.Key()] {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *Notifier) reminderData(list []slots.Slot) reminderMessageData {
+	day := list[0].Start
+	data := reminderMessageData{Date: day.Format("02.01"), Weekday: format.WeekdayRU(day.Weekday())}
+	for _, s := range list {
+		sd := n.slotData(s)
+		data.Slots = append(data.Slots, sd.Time+" — "+sd.ServiceName)
+		if data.BookingURL == "" {
+			data.BookingURL = sd.BookingURL
+		}
+	}
+	return data
+}
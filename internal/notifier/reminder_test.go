@@ -0,0 +1,202 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/notifier/slottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+// optIn subscribes chatID with evening reminders on.
+func (e *testEnv) optIn(t *testing.T, chatID int64) {
+	t.Helper()
+	e.subscribe(t, chatID)
+	if err := e.store.SetPreferences(chatID, storage.Preferences{EveningReminder: true}); err != nil {
+		t.Fatalf("SetPreferences: %v", err)
+	}
+}
+
+// evening returns 20:00 today in the notifier's timezone.
+func (e *testEnv) evening() time.Time {
+	now := time.Now().In(e.n.loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 20, 0, 0, 0, e.n.loc)
+}
+
+func TestAllNotified(t *testing.T) {
+	a := slots.Slot{ServiceID: 1, StaffID: 2, Start: time.Date(2026, 3, 10, 10, 0, 0, 0, time.UTC)}
+	b := slots.Slot{ServiceID: 1, StaffID: 2, Start: time.Date(2026, 3, 10, 11, 0, 0, 0, time.UTC)}
+	for _, tt := range []struct {
+		name     string
+		list     []slots.Slot
+		notified map[string]bool
+		want     bool
+	}{
+		{"nothing notified", []slots.Slot{a, b}, nil, false},
+		{"one of two", []slots.Slot{a, b}, map[string]bool{a.Key(): true}, false},
+		{"both", []slots.Slot{a, b}, map[string]bool{a.Key(): true, b.Key(): true}, true},
+		{"other slots only", []slots.Slot{a}, map[string]bool{b.Key(): true}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allNotified(tt.list, tt.notified); got != tt.want {
+				t.Errorf("allNotified = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEveningReminderSkipsSlotsNotifiedToday(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	const notifiedChat, lateChat = testChatID, testChatID + 1
+	e.optIn(t, notifiedChat)
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+	e.n.checkAndNotify(context.Background())
+	if got := len(e.api.MessagesTo(notifiedChat)); got != 1 {
+		t.Fatalf("subscriber got %d slot messages, want 1", got)
+	}
+	// Subscribed after the slot was announced, so never told about it.
+	e.optIn(t, lateChat)
+
+	e.n.sendEveningReminders(e.evening())
+
+	if got := len(e.api.MessagesTo(notifiedChat)); got != 1 {
+		t.Errorf("already notified chat got %d messages, want no reminder", got)
+	}
+	msgs := e.api.MessagesTo(lateChat)
+	if len(msgs) != 1 || !strings.Contains(msgs[0], "На завтра") {
+		t.Fatalf("late subscriber got %q, want one reminder", msgs)
+	}
+}
+
+func TestEveningReminderListsAllSlotsWhenOneIsNew(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)), slottest.At(e.day(1).Add(2*time.Hour)))
+	e.n.checkAndNotify(context.Background())
+	e.optIn(t, testChatID)
+
+	tomorrow := e.n.slotsOn(e.n.available, e.day(1))
+	if len(tomorrow) != 2 {
+		t.Fatalf("tomorrow has %d slots, want 2", len(tomorrow))
+	}
+	// Only the first slot was announced to the chat today.
+	if err := e.store.LogNotification(testChatID, tomorrow[0].Key(), "🟢"); err != nil {
+		t.Fatalf("LogNotification: %v", err)
+	}
+
+	e.n.sendEveningReminders(e.evening())
+	msgs := e.api.MessagesTo(testChatID)
+	if len(msgs) != 1 {
+		t.Fatalf("chat got %d messages, want 1 reminder", len(msgs))
+	}
+	for _, s := range tomorrow {
+		if hhmm := s.Start.In(e.n.loc).Format("15:04"); !strings.Contains(msgs[0], hhmm) {
+			t.Errorf("reminder %q does not list %s", msgs[0], hhmm)
+		}
+	}
+
+	// Once both are logged for today the reminder has nothing new to say.
+	if err := e.store.LogNotification(testChatID, tomorrow[1].Key(), "🟢"); err != nil {
+		t.Fatalf("LogNotification: %v", err)
+	}
+	e.n.sendEveningReminders(e.evening())
+	if got := len(e.api.MessagesTo(testChatID)); got != 1 {
+		t.Errorf("chat got %d messages after every slot was notified, want 1", got)
+	}
+}
+
+func TestEveningReminderNeedsOptIn(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+	e.n.checkAndNotify(context.Background())
+	e.subscribe(t, testChatID)
+
+	e.n.sendEveningReminders(e.evening())
+	if got := len(e.api.MessagesTo(testChatID)); got != 0 {
+		t.Errorf("chat without the reminder option got %d messages", got)
+	}
+}
+
+func TestEveningReminderUsesConfiguredTimezone(t *testing.T) {
+	// UTC+10: 01:00 tomorrow there is still today in UTC.
+	e := newTestEnv(t, Options{Timezone: "Asia/Vladivostok"})
+	early := e.day(1).Add(-9 * time.Hour)
+	e.src.Add(testServiceID, testStaffID, slottest.At(early), slottest.At(e.day(2)))
+	e.n.checkAndNotify(context.Background())
+	e.optIn(t, testChatID)
+
+	e.n.sendEveningReminders(e.evening())
+	msgs := e.api.MessagesTo(testChatID)
+	if len(msgs) != 1 {
+		t.Fatalf("chat got %d messages, want 1 reminder", len(msgs))
+	}
+	if want := early.Format("02.01"); !strings.Contains(msgs[0], want) {
+		t.Errorf("reminder %q is not for %s", msgs[0], want)
+	}
+	if !strings.Contains(msgs[0], "01:00") {
+		t.Errorf("reminder %q does not list the 01:00 slot", msgs[0])
+	}
+	if strings.Contains(msgs[0], "10:00") {
+		t.Errorf("reminder %q lists the slot the day after tomorrow", msgs[0])
+	}
+}
+
+func TestEveningReminderStopsOnRenderFailure(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+	e.n.checkAndNotify(context.Background())
+	e.optIn(t, testChatID)
+	e.optIn(t, testChatID+1)
+
+	e.n.templatesMu.Lock()
+	delete(e.n.templates, "templates/evening_reminder.tmpl")
+	e.n.templatesMu.Unlock()
+	var logs bytes.Buffer
+	e.n.log = logger.New().WithOutput(&logs)
+
+	e.n.sendEveningReminders(e.evening())
+	if got := len(e.api.MessagesTo(testChatID)) + len(e.api.MessagesTo(testChatID+1)); got != 0 {
+		t.Errorf("chats got %d messages, want none", got)
+	}
+	if !strings.Contains(logs.String(), "Failed to render evening reminder") {
+		t.Errorf("render failure was not logged:\n%s", logs.String())
+	}
+	if strings.Contains(logs.String(), "Evening reminders sent") {
+		t.Errorf("reminders reported as sent after a render failure:\n%s", logs.String())
+	}
+}
+
+func TestNextDailyAtInTimezone(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		timezone string
+		now      string // RFC 3339
+		hhmm     string
+		want     string // RFC 3339 in the timezone
+	}{
+		{"later today", "Europe/Moscow", "2026-03-10T12:00:00Z", "20:00", "2026-03-10T20:00:00+03:00"},
+		{"already passed", "Europe/Moscow", "2026-03-10T17:30:00Z", "20:00", "2026-03-11T20:00:00+03:00"},
+		{"exactly now", "Europe/Moscow", "2026-03-10T17:00:00Z", "20:00", "2026-03-11T20:00:00+03:00"},
+		{"east of UTC", "Asia/Vladivostok", "2026-03-10T09:00:00Z", "20:00", "2026-03-10T20:00:00+10:00"},
+		{"next local day is today in UTC", "Asia/Vladivostok", "2026-03-10T11:00:00Z", "20:00", "2026-03-11T20:00:00+10:00"},
+		{"across a DST change", "Europe/Berlin", "2026-03-28T20:00:00Z", "20:00", "2026-03-29T20:00:00+02:00"},
+		{"malformed uses fallback", "Europe/Moscow", "2026-03-10T12:00:00Z", "8pm", "2026-03-10T20:00:00+03:00"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t, Options{Timezone: tt.timezone})
+			now, _ := time.Parse(time.RFC3339, tt.now)
+			want, _ := time.Parse(time.RFC3339, tt.want)
+			got := e.n.nextDailyAt(now, tt.hhmm, "20:00")
+			if !got.Equal(want) {
+				t.Errorf("nextDailyAt(%s, %s) = %s, want %s", tt.now, tt.hhmm, got, want)
+			}
+			if got.Location() != e.n.loc {
+				t.Errorf("location = %s, want %s", got.Location(), e.n.loc)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package notifier
+
+import (
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+// DefaultWeeklyReportTime is when the weekly report goes out on its day.
+const DefaultWeeklyReportTime = "09:00"
+
+// reportDays is the period covered by the weekly report, today included.
+const reportDays = 7
+
+// weeklyReportData is the data passed to weekly_report.tmpl.
+type weeklyReportData struct {
+	From, To          time.Time
+	SlotsDiscovered   int
+	NotificationsSent int
+	DeliveryFailures  int
+	Subscribers       int
+	Subscriptions     int
+	Unsubscriptions   int
+	Checks            int
+	AvgCheckDuration  time.Duration
+}
+
+// addDailyStat adds value to today's counter; the report is best effort,
+// so failures are only logged.
+func (n *Notifier) addDailyStat(name string, value float64) {
+	if err := n.storage.AddDailyStat(time.Now().In(n.loc), name, value); err != nil {
+		n.log.WithError(err).WarnWithFields("Failed to record daily statistic", logger.Fields{
+			"stat": name,
+		})
+	}
+}
+
+// nextWeeklyAt returns the next occurrence of weekday at the "HH:MM" time
+// hhmm in the configured timezone.
+func (n *Notifier) nextWeeklyAt(now time.Time, weekday time.Weekday, hhmm string) time.Time {
+	next := n.nextDailyAt(now, hhmm, DefaultWeeklyReportTime)
+	for next.Weekday() != weekday {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// WeeklyReportMessage summarizes the last seven days for admins.
+func (n *Notifier) WeeklyReportMessage() (string, error) {
+	to := time.Now().In(n.loc)
+	from := to.AddDate(0, 0, -(reportDays - 1))
+	stats, err := n.storage.DailyStatsSince(from)
+	if err != nil {
+		return "", err
+	}
+
+	checks := stats[storage.StatCheckSeconds]
+	data := weeklyReportData{
+		From:              from,
+		To:                to,
+		SlotsDiscovered:   int(stats[storage.StatSlotsDiscovered].Total),
+		NotificationsSent: int(stats[storage.StatNotificationsSent].Total),
+		DeliveryFailures:  int(stats[storage.StatDeliveryFailures].Total),
+		Subscriptions:     int(stats[storage.StatSubscriptions].Total),
+		Unsubscriptions:   int(stats[storage.StatUnsubscriptions].Total),
+		Checks:            checks.Samples,
+	}
+	if checks.Samples > 0 {
+		avg := time.Duration(checks.Total / float64(checks.Samples) * float64(time.Second))
+		data.AvgCheckDuration = avg.Round(100 * time.Millisecond)
+	}
+	if n.bot != nil {
+		subscribers, err := n.bot.Subscribers()
+		if err != nil {
+			return "", err
+		}
+		data.Subscribers = len(subscribers)
+	}
+	return n.RenderTemplate("templates/weekly_report.tmpl", data)
+}
+
+// sendWeeklyReport pushes the weekly report to admin chats.
+func (n *Notifier) sendWeeklyReport() {
+	msg, err := n.WeeklyReportMessage()
+	if err != nil {
+		n.log.WithError(err).Error("Failed to build weekly report")
+		return
+	}
+	n.log.Info("Sending weekly report to admins")
+	n.bot.NotifyAdmins(msg)
+}
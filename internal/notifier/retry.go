@@ -0,0 +1,129 @@
+package notifier
+
+import (
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+// Failed deliveries are retried with exponential backoff starting at
+// retryBaseDelay, capped at retryMaxDelay, and dropped after
+// maxDeliveryAttempts attempts in total.
+const (
+	retryPollInterval   = 30 * time.Second
+	retryBaseDelay      = 30 * time.Second
+	retryMaxDelay       = 30 * time.Minute
+	maxDeliveryAttempts = 6
+	retryBatchSize      = 50
+)
+
+// retryDelay is the wait after the given number of failed attempts.
+func retryDelay(attempts int) time.Duration {
+	d := retryBaseDelay
+	for i := 1; i < attempts && d < retryMaxDelay; i++ {
+		d *= 2
+	}
+	if d > retryMaxDelay {
+		return retryMaxDelay
+	}
+	return d
+}
+
+// queueRetry stores a failed delivery so the retry worker sends it later,
+// even after a restart. Errors that a retry cannot fix are not queued, nor
+// are timeouts after which Telegram may already have the message.
+func (n *Notifier) queueRetry(chatID int64, slotKey, excerpt, msg string, digestOffer bool, sendErr error) {
+	if !bot.ShouldRetry(sendErr) {
+		return
+	}
+	err := n.storage.QueueRetry(storage.DeliveryRetry{
+		ChatID:      chatID,
+		SlotKey:     slotKey,
+		Excerpt:     excerpt,
+		Message:     msg,
+		DigestOffer: digestOffer,
+		Attempts:    1,
+		NextRetryAt: time.Now().Add(retryDelay(1)),
+	})
+	if err != nil {
+		n.log.WithError(err).WarnWithFields("Failed to queue notification retry", logger.Fields{
+			"chat_id":  chatID,
+			"slot_key": slotKey,
+		})
+		return
+	}
+	n.recordRetry("queued")
+}
+
+// retryDeliveries sends queued notifications that are due. Deliveries for
+// slots that already started, or to chats that unsubscribed meanwhile, are
+// dropped.
+func (n *Notifier) retryDeliveries(now time.Time) {
+	due, err := n.storage.DueRetries(now, retryBatchSize)
+	if err != nil {
+		n.log.WithError(err).Error("Failed to load notification retries")
+		return
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	// Without the subscriber list every retry would be dropped as
+	// unsubscribed; leave them for the next round instead.
+	subscribers, err := n.bot.Subscribers()
+	if err != nil {
+		n.log.WithError(err).Error("Failed to get subscribers, postponing notification retries")
+		return
+	}
+	subscribed := make(map[int64]bool)
+	for _, chatID := range subscribers {
+		subscribed[chatID] = true
+	}
+	for _, r := range due {
+		fields := logger.Fields{"chat_id": r.ChatID, "slot_key": r.SlotKey, "attempts": r.Attempts}
+		switch {
+		case !r.SlotStart.IsZero() && !r.SlotStart.After(now):
+			n.finishRetry(r, "expired")
+			continue
+		case !subscribed[r.ChatID]:
+			n.finishRetry(r, "unsubscribed")
+			continue
+		}
+
+		err := n.deliver(r.ChatID, r.Message, r.DigestOffer)
+		if err == nil {
+			n.logNotification(r.ChatID, r.SlotKey, r.Excerpt)
+			n.log.InfoWithFields("Notification delivered on retry", fields)
+			n.finishRetry(r, "delivered")
+			continue
+		}
+		n.logFailedNotification(r.ChatID, r.SlotKey, r.Excerpt, err)
+		attempts := r.Attempts + 1
+		if attempts >= maxDeliveryAttempts || !bot.ShouldRetry(err) {
+			n.log.WithError(err).WarnWithFields("Giving up on notification delivery", fields)
+			n.finishRetry(r, "abandoned")
+			continue
+		}
+		if err := n.storage.RescheduleRetry(r.ID, attempts, now.Add(retryDelay(attempts))); err != nil {
+			n.log.WithError(err).WarnWithFields("Failed to reschedule notification retry", fields)
+		}
+	}
+}
+
+func (n *Notifier) finishRetry(r storage.DeliveryRetry, outcome string) {
+	if err := n.storage.DeleteRetry(r.ID); err != nil {
+		n.log.WithError(err).WarnWithFields("Failed to remove notification retry", logger.Fields{
+			"chat_id":  r.ChatID,
+			"slot_key": r.SlotKey,
+		})
+	}
+	n.recordRetry(outcome)
+}
+
+func (n *Notifier) recordRetry(outcome string) {
+	if n.metrics != nil {
+		n.metrics.RecordDeliveryRetry(outcome)
+	}
+}
@@ -0,0 +1,391 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
+)
+
+// DefaultScanConcurrency limits parallel YCLIENTS requests during a check.
+const DefaultScanConcurrency = 4
+
+// Values of Notifier.serviceBatching.
+const (
+	batchingUnknown int32 = iota
+	batchingSupported
+	batchingUnsupported
+)
+
+// staffScan holds the slots found for one staff member of one service.
+type staffScan struct {
+	slots  []slots.Slot
+	errors int
+}
+
+// staffService is one bookable staff member of one configured service;
+// i and j index the service and the member in the scan results.
+type staffService struct {
+	i, j      int
+	serviceID int
+	member    yclients.StaffAvailability
+}
+
+// scanAvailability fetches every bookable slot between from and to. Requests
+// run concurrently, at most ScanConcurrency at a time, and results are
+// assembled in configuration order (service, staff, date) regardless of
+// completion order. It returns the slots and the number of failed requests.
+func (n *Notifier) scanAvailability(ctx context.Context, from, to string) ([]slots.Slot, int) {
+	sem := make(chan struct{}, n.opts.ScanConcurrency)
+	acquire := func() bool {
+		select {
+		case sem <- struct{}{}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	release := func() { <-sem }
+
+	staff := make([][]yclients.StaffAvailability, len(n.opts.ServiceIDs))
+	serviceErrors := make([]int, len(n.opts.ServiceIDs))
+	allBookable := n.allServicesBookable(ctx, acquire, release, from, to)
+
+	var wg sync.WaitGroup
+	for i, serviceID := range n.opts.ServiceIDs {
+		wg.Add(1)
+		go func(i, serviceID int) {
+			defer wg.Done()
+			staff[i], serviceErrors[i] = n.serviceStaff(ctx, acquire, release, serviceID, allBookable, from, to)
+		}(i, serviceID)
+	}
+	wg.Wait()
+
+	results := make([][]staffScan, len(n.opts.ServiceIDs))
+	for i := range staff {
+		results[i] = make([]staffScan, len(staff[i]))
+	}
+	groups := groupByStaff(n.opts.ServiceIDs, staff)
+	// Until a batched search has shown whether the source can split its
+	// answer per service, try it for one staff member only, so the others
+	// don't all pay for finding out.
+	for k, g := range groups {
+		if len(g) > 1 && n.serviceBatching.Load() == batchingUnknown {
+			n.scanStaffGroup(ctx, acquire, release, g, from, to, results)
+			groups = append(groups[:k:k], groups[k+1:]...)
+			break
+		}
+	}
+
+	var staffWG sync.WaitGroup
+	for _, g := range groups {
+		staffWG.Add(1)
+		go func(g []staffService) {
+			defer staffWG.Done()
+			n.scanStaffGroup(ctx, acquire, release, g, from, to, results)
+		}(g)
+	}
+	staffWG.Wait()
+
+	var (
+		all    []slots.Slot
+		errors int
+	)
+	for i := range results {
+		errors += serviceErrors[i]
+		for _, r := range results[i] {
+			all = append(all, r.slots...)
+			errors += r.errors
+		}
+	}
+	return all, errors
+}
+
+// serviceStaff returns the bookable staff of serviceID and the number of
+// failed requests. Unless allBookable, one request first tells whether
+// anyone can be booked at all; a fully booked service then costs no
+// per-staff requests.
+func (n *Notifier) serviceStaff(ctx context.Context, acquire func() bool, release func(), serviceID int, allBookable bool, from, to string) ([]yclients.StaffAvailability, int) {
+	n.logFor(ctx).DebugWithFields("Checking service", logger.Fields{
+		"service_id": serviceID,
+	})
+
+	if !allBookable {
+		if !acquire() {
+			return nil, 1
+		}
+		anyDates, err := n.yc.GetBookableDatesAnyStaff(ctx, n.opts.LocationID, serviceID, from, to)
+		release()
+		if err != nil {
+			n.logSourceError(ctx, err, "Failed to get bookable dates", logger.Fields{
+				"service_id": serviceID,
+			})
+			n.handleSourceError(err, "yclients_dates_failed")
+			return nil, 1
+		}
+		if len(anyDates) == 0 {
+			n.logFor(ctx).DebugWithFields("No bookable dates for service", logger.Fields{
+				"service_id": serviceID,
+			})
+			return nil, 0
+		}
+	}
+
+	if !acquire() {
+		return nil, 1
+	}
+	staff, err := n.bookableStaff(ctx, serviceID)
+	release()
+	if err != nil {
+		n.logSourceError(ctx, err, "Failed to get staff IDs", logger.Fields{
+			"service_id": serviceID,
+		})
+		n.handleSourceError(err, "yclients_staff_failed")
+		return nil, 1
+	}
+	if len(staff) == 0 {
+		n.logFor(ctx).DebugWithFields("No bookable staff found", logger.Fields{
+			"service_id": serviceID,
+		})
+		return nil, 0
+	}
+	n.logFor(ctx).DebugWithFields("Found bookable staff", logger.Fields{
+		"service_id": serviceID,
+		"staff":      len(staff),
+	})
+	return staff, 0
+}
+
+// groupByStaff groups the staff lists of the services by staff member, in
+// order of first appearance.
+func groupByStaff(serviceIDs []int, staff [][]yclients.StaffAvailability) [][]staffService {
+	var groups [][]staffService
+	index := make(map[int]int)
+	for i, list := range staff {
+		for j, member := range list {
+			k, ok := index[member.ID]
+			if !ok {
+				k = len(groups)
+				index[member.ID] = k
+				groups = append(groups, nil)
+			}
+			groups[k] = append(groups[k], staffService{i: i, j: j, serviceID: serviceIDs[i], member: member})
+		}
+	}
+	return groups
+}
+
+// scanStaffGroup fetches the slots of one staff member for each of their
+// services into results, in one search per request type when the source
+// can split the answer per service and one per service otherwise.
+func (n *Notifier) scanStaffGroup(ctx context.Context, acquire func() bool, release func(), group []staffService, from, to string, results [][]staffScan) {
+	if len(group) > 1 && n.scanStaffServices(ctx, acquire, release, group, from, to, results) {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, s := range group {
+		wg.Add(1)
+		go func(s staffService) {
+			defer wg.Done()
+			results[s.i][s.j] = n.scanStaff(ctx, acquire, release, s.serviceID, s.member, from, to)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// scanStaffServices asks for the dates of every service of one staff
+// member in one request, then for the timeslots of every service bookable
+// on each date in one request per date. YCLIENTS searches a combined
+// visit unless it names the service of each result, so an unattributed
+// answer makes this and later scans fall back to per-service requests;
+// so does an empty answer before any answer was attributed, since it
+// only means the combined visit doesn't fit. It reports whether the
+// group was scanned.
+func (n *Notifier) scanStaffServices(ctx context.Context, acquire func() bool, release func(), group []staffService, from, to string, results [][]staffScan) bool {
+	if n.serviceBatching.Load() == batchingUnsupported {
+		return false
+	}
+	staffID := group[0].member.ID
+	serviceIDs := make([]int, len(group))
+	for k, s := range group {
+		serviceIDs[k] = s.serviceID
+	}
+
+	if !acquire() {
+		return false
+	}
+	dates, err := n.yc.GetBookableDatesByService(ctx, n.opts.LocationID, serviceIDs, from, to, staffID)
+	release()
+	if !n.batchAnswer(ctx, err, len(dates) > 0, staffID) {
+		return false
+	}
+
+	var days []string
+	byDate := make(map[string][]staffService)
+	for _, s := range group {
+		for _, date := range dates[s.serviceID] {
+			if byDate[date] == nil {
+				days = append(days, date)
+			}
+			byDate[date] = append(byDate[date], s)
+		}
+	}
+	sort.Strings(days)
+
+	for _, date := range days {
+		services := byDate[date]
+		if len(services) > 1 && n.serviceBatching.Load() == batchingSupported {
+			ids := make([]int, len(services))
+			for k, s := range services {
+				ids[k] = s.serviceID
+			}
+			if !acquire() {
+				for _, s := range services {
+					results[s.i][s.j].errors++
+				}
+				continue
+			}
+			times, err := n.yc.GetBookableTimeslotsByService(ctx, n.opts.LocationID, ids, date, staffID)
+			release()
+			if n.batchAnswer(ctx, err, len(times) > 0, staffID) {
+				for _, s := range services {
+					results[s.i][s.j].slots = append(results[s.i][s.j].slots, n.timeslotSlots(s.serviceID, s.member, times[s.serviceID])...)
+				}
+				continue
+			}
+		}
+		for _, s := range services {
+			found, ok := n.staffTimeslots(ctx, acquire, release, s.serviceID, s.member, date)
+			r := &results[s.i][s.j]
+			r.slots = append(r.slots, found...)
+			if !ok {
+				r.errors++
+			}
+		}
+	}
+	return true
+}
+
+// batchAnswer records what a batched search showed about the source and
+// reports whether its answer can be used. A failed batched search isn't
+// counted as an error: the per-service requests that replace it are.
+func (n *Notifier) batchAnswer(ctx context.Context, err error, found bool, staffID int) bool {
+	switch {
+	case errors.Is(err, yclients.ErrUnattributed):
+		if n.serviceBatching.Swap(batchingUnsupported) != batchingUnsupported {
+			n.logFor(ctx).Info("Availability source doesn't split searches per service, checking services separately")
+		}
+		return false
+	case err != nil:
+		n.logFor(ctx).WithError(err).DebugWithFields("Batched availability search failed, checking services separately", logger.Fields{
+			"staff_id": staffID,
+		})
+		return false
+	case found:
+		n.serviceBatching.CompareAndSwap(batchingUnknown, batchingSupported)
+		return true
+	default:
+		return n.serviceBatching.Load() == batchingSupported
+	}
+}
+
+// allServicesBookable asks in one request whether every configured service
+// can be booked between from and to, which spares the per-service check.
+// The combined search can't tell which service is fully booked, so on no
+// dates, a failure or a single service the caller checks each service
+// itself. A failure is not counted as an error, since the per-service
+// checks that replace it are.
+func (n *Notifier) allServicesBookable(ctx context.Context, acquire func() bool, release func(), from, to string) bool {
+	if len(n.opts.ServiceIDs) < 2 {
+		return false
+	}
+	if !acquire() {
+		return false
+	}
+	dates, err := n.yc.GetBookableDatesServices(ctx, n.opts.LocationID, n.opts.ServiceIDs, from, to)
+	release()
+	if err != nil {
+		n.logFor(ctx).WithError(err).WarnWithFields("Combined availability search failed, checking services separately", logger.Fields{
+			"service_ids": n.opts.ServiceIDs,
+		})
+		return false
+	}
+	return len(dates) > 0
+}
+
+// scanStaff fetches the bookable dates of one staff member and the
+// timeslots of each date.
+func (n *Notifier) scanStaff(ctx context.Context, acquire func() bool, release func(), serviceID int, member yclients.StaffAvailability, from, to string) staffScan {
+	var res staffScan
+	staffID := member.ID
+	if !acquire() {
+		res.errors++
+		return res
+	}
+	sid := staffID
+	dates, err := n.yc.GetBookableDates(ctx, n.opts.LocationID, serviceID, from, to, &sid)
+	release()
+	if err != nil {
+		n.logSourceError(ctx, err, "Failed to get bookable dates", logger.Fields{
+			"service_id": serviceID,
+			"staff_id":   staffID,
+		})
+		n.handleSourceError(err, "yclients_dates_failed")
+		res.errors++
+		return res
+	}
+
+	for _, date := range dates {
+		found, ok := n.staffTimeslots(ctx, acquire, release, serviceID, member, date)
+		res.slots = append(res.slots, found...)
+		if !ok {
+			res.errors++
+			if ctx.Err() != nil {
+				return res
+			}
+		}
+	}
+	return res
+}
+
+// staffTimeslots fetches the slots of one staff member for one service on
+// date. It reports false if the request failed or could not be made.
+func (n *Notifier) staffTimeslots(ctx context.Context, acquire func() bool, release func(), serviceID int, member yclients.StaffAvailability, date string) ([]slots.Slot, bool) {
+	if !acquire() {
+		return nil, false
+	}
+	times, err := n.yc.GetBookableTimeslots(ctx, n.opts.LocationID, serviceID, date, member.ID)
+	release()
+	if err != nil {
+		n.logSourceError(ctx, err, "Failed to get timeslots", logger.Fields{
+			"service_id": serviceID,
+			"staff_id":   member.ID,
+			"date":       date,
+		})
+		n.handleSourceError(err, "yclients_timeslots_failed")
+		return nil, false
+	}
+	return n.timeslotSlots(serviceID, member, times), true
+}
+
+// timeslotSlots turns the timeslots of one staff member for one service
+// into slots priced for that member.
+func (n *Notifier) timeslotSlots(serviceID int, member yclients.StaffAvailability, times []yclients.Timeslot) []slots.Slot {
+	out := make([]slots.Slot, 0, len(times))
+	for _, ts := range times {
+		slot := slots.FromTimeslot(serviceID, member.ID, ts, n.loc)
+		slot.PriceMin, slot.PriceMax = member.PriceMin, member.PriceMax
+		out = append(out, slot)
+	}
+	return out
+}
+
+func (n *Notifier) recordError(errorType string) {
+	if n.metrics != nil {
+		n.metrics.RecordError(errorType)
+	}
+}
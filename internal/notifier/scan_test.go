@@ -0,0 +1,281 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/notifier/slottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients/yclientstest"
+)
+
+func TestScanAvailabilityRespectsConcurrencyLimit(t *testing.T) {
+	e := newTestEnv(t, Options{ScanConcurrency: 2, ServiceIDs: []int{1, 2}})
+	for service := 1; service <= 2; service++ {
+		for staff := 1; staff <= 3; staff++ {
+			for day := 1; day <= 3; day++ {
+				e.src.Add(service, staff, slottest.At(e.day(day)))
+			}
+		}
+	}
+	e.src.Delay = 5 * time.Millisecond
+
+	from, to := DateRange(time.Now().In(e.n.loc), 7)
+	found, errs := e.n.scanAvailability(context.Background(), from, to)
+	if errs != 0 {
+		t.Fatalf("scan reported %d errors", errs)
+	}
+	if len(found) != 18 {
+		t.Fatalf("scan found %d slots, want 18", len(found))
+	}
+	if got := e.src.MaxInFlight(); got > 2 {
+		t.Errorf("%d requests ran at once, limit is 2", got)
+	}
+	if got := e.src.MaxInFlight(); got < 2 {
+		t.Errorf("requests never ran concurrently")
+	}
+}
+
+func TestScanAvailabilityKeepsConfigurationOrder(t *testing.T) {
+	e := newTestEnv(t, Options{ScanConcurrency: 4, ServiceIDs: []int{2, 1}})
+	e.src.Add(1, 10, slottest.At(e.day(1)))
+	e.src.Add(2, 20, slottest.At(e.day(2)), slottest.At(e.day(1)))
+	e.src.Add(2, 21, slottest.At(e.day(1)))
+
+	from, to := DateRange(time.Now().In(e.n.loc), 7)
+	found, _ := e.n.scanAvailability(context.Background(), from, to)
+
+	type key struct{ service, staff, day int }
+	want := []key{{2, 20, 1}, {2, 20, 2}, {2, 21, 1}, {1, 10, 1}}
+	if len(found) != len(want) {
+		t.Fatalf("scan found %d slots, want %d", len(found), len(want))
+	}
+	for i, w := range want {
+		s := found[i]
+		if s.ServiceID != w.service || s.StaffID != w.staff || !s.Start.Equal(e.day(w.day)) {
+			t.Errorf("slot %d = service %d staff %d at %s, want service %d staff %d day +%d",
+				i, s.ServiceID, s.StaffID, s.Start, w.service, w.staff, w.day)
+		}
+	}
+}
+
+func TestScanAvailabilityReturnsPartialResults(t *testing.T) {
+	e := newTestEnv(t, Options{ServiceIDs: []int{1, 2}})
+	e.src.Add(1, 10, slottest.At(e.day(1)), slottest.At(e.day(2)))
+	e.src.Add(2, 20, slottest.At(e.day(3)))
+	e.src.FailDate(e.day(2).Format("2006-01-02"), errors.New("timeslots unavailable"))
+
+	from, to := DateRange(time.Now().In(e.n.loc), 7)
+	found, errs := e.n.scanAvailability(context.Background(), from, to)
+	if errs != 1 {
+		t.Errorf("scan reported %d errors, want 1", errs)
+	}
+	if len(found) != 2 {
+		t.Fatalf("scan found %d slots, want the 2 on working dates", len(found))
+	}
+	if !found[0].Start.Equal(e.day(1)) || !found[1].Start.Equal(e.day(3)) {
+		t.Errorf("scan found %s and %s, want days +1 and +3", found[0].Start, found[1].Start)
+	}
+}
+
+func TestScanAvailabilityStopsWhenCanceled(t *testing.T) {
+	e := newTestEnv(t, Options{ScanConcurrency: 1})
+	for day := 1; day <= 5; day++ {
+		e.src.Add(testServiceID, testStaffID, slottest.At(e.day(day)))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	from, to := DateRange(time.Now().In(e.n.loc), 7)
+	found, errs := e.n.scanAvailability(ctx, from, to)
+	if len(found) != 0 {
+		t.Errorf("canceled scan found %d slots", len(found))
+	}
+	if errs == 0 {
+		t.Error("canceled scan reported no errors")
+	}
+	if calls := e.src.Calls(slottest.BookableTimeslots); calls != 0 {
+		t.Errorf("canceled scan made %d timeslot requests", calls)
+	}
+}
+
+// twoStaff is a search-staff answer with two bookable instructors.
+const twoStaff = `{"data":[
+	{"type":"booking_search_result_staff","id":"2850522","attributes":{"is_bookable":true}},
+	{"type":"booking_search_result_staff","id":"2850523","attributes":{"is_bookable":true}}
+]}`
+
+// scanRequests scans once through the yclients client against srv and
+// returns the requests made per path.
+func scanRequests(t *testing.T, srv *yclientstest.Server, opts Options) map[string]int {
+	t.Helper()
+	e := newTestEnv(t, opts)
+	e.n.yc = srv.Client()
+	from, to := DateRange(time.Now().In(e.n.loc), 7)
+	if _, errs := e.n.scanAvailability(context.Background(), from, to); errs != 0 {
+		t.Fatalf("scan reported %d errors", errs)
+	}
+	counts := make(map[string]int)
+	for _, r := range srv.Requests() {
+		if r.Path != yclientstest.AuthPath {
+			counts[r.Path]++
+		}
+	}
+	return counts
+}
+
+func TestScanFullyBookedServiceCostsOneRequest(t *testing.T) {
+	srv := yclientstest.NewServer()
+	defer srv.Close()
+	srv.Set(yclientstest.SearchStaffPath, yclientstest.Response{Status: http.StatusOK, Body: twoStaff})
+	srv.Set(yclientstest.SearchDatesPath, yclientstest.Response{Status: http.StatusOK, Body: `{"data":[]}`})
+
+	got := scanRequests(t, srv, Options{})
+	// Asking each of the two instructors would cost a staff search and
+	// one dates search per instructor
+	if total := got[yclientstest.SearchDatesPath] + got[yclientstest.SearchStaffPath] + got[yclientstest.SearchTimeslotsPath]; total != 1 {
+		t.Errorf("fully booked service cost %d requests (%v), want 1 instead of 3 per instructor", total, got)
+	}
+}
+
+func TestScanBookableServiceRequests(t *testing.T) {
+	srv := yclientstest.NewServer()
+	defer srv.Close()
+	srv.Set(yclientstest.SearchStaffPath, yclientstest.Response{Status: http.StatusOK, Body: twoStaff})
+
+	got := scanRequests(t, srv, Options{})
+	want := map[string]int{
+		yclientstest.SearchDatesPath:     3, // the union, then each instructor
+		yclientstest.SearchStaffPath:     1,
+		yclientstest.SearchTimeslotsPath: 2, // the one bookable date per instructor
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
+
+func TestScanBatchesServiceCheck(t *testing.T) {
+	e := newTestEnv(t, Options{ServiceIDs: []int{1, 2, 3}})
+	for service := 1; service <= 3; service++ {
+		e.src.Add(service, 10, slottest.At(e.day(1)))
+	}
+
+	from, to := DateRange(time.Now().In(e.n.loc), 7)
+	found, errs := e.n.scanAvailability(context.Background(), from, to)
+	if errs != 0 || len(found) != 3 {
+		t.Fatalf("scan found %d slots with %d errors, want 3", len(found), errs)
+	}
+	if calls := e.src.Calls(slottest.BookableDatesServices); calls != 1 {
+		t.Errorf("%d combined searches, want 1", calls)
+	}
+	if calls := e.src.Calls(slottest.BookableDatesAnyStaff); calls != 0 {
+		t.Errorf("%d per-service searches, want none once all services are bookable", calls)
+	}
+}
+
+func TestScanChecksEachServiceWhenOneIsFullyBooked(t *testing.T) {
+	e := newTestEnv(t, Options{ServiceIDs: []int{1, 2}})
+	e.src.Add(1, 10, slottest.At(e.day(1)))
+
+	from, to := DateRange(time.Now().In(e.n.loc), 7)
+	found, errs := e.n.scanAvailability(context.Background(), from, to)
+	if errs != 0 || len(found) != 1 || found[0].ServiceID != 1 {
+		t.Fatalf("scan found %v with %d errors, want the slot of service 1", found, errs)
+	}
+	if calls := e.src.Calls(slottest.BookableDatesAnyStaff); calls != 2 {
+		t.Errorf("%d per-service searches, want one per service", calls)
+	}
+	if calls := e.src.Calls(slottest.BookableStaff); calls != 1 {
+		t.Errorf("%d staff searches, want none for the fully booked service", calls)
+	}
+}
+
+func TestScanFallsBackWhenCombinedSearchFails(t *testing.T) {
+	e := newTestEnv(t, Options{ServiceIDs: []int{1, 2}})
+	e.src.Add(1, 10, slottest.At(e.day(1)))
+	e.src.Add(2, 20, slottest.At(e.day(2)))
+	e.src.Fail(slottest.BookableDatesServices, errors.New("unavailable"))
+
+	from, to := DateRange(time.Now().In(e.n.loc), 7)
+	found, errs := e.n.scanAvailability(context.Background(), from, to)
+	if len(found) != 2 {
+		t.Errorf("scan found %d slots, want both", len(found))
+	}
+	if errs != 0 {
+		t.Errorf("scan reported %d errors, want none: the per-service checks replaced the combined search", errs)
+	}
+}
+
+func TestScanBatchesServicesOfOneStaffMember(t *testing.T) {
+	e := newTestEnv(t, Options{ServiceIDs: []int{1, 2}})
+	e.src.AttributeServices = true
+	e.src.Add(1, 10, slottest.At(e.day(1)), slottest.At(e.day(2)))
+	e.src.Add(2, 10, slottest.At(e.day(1)))
+
+	from, to := DateRange(time.Now().In(e.n.loc), 7)
+	found, errs := e.n.scanAvailability(context.Background(), from, to)
+	if errs != 0 || len(found) != 3 {
+		t.Fatalf("scan found %d slots with %d errors, want 3", len(found), errs)
+	}
+	type key struct{ service, day int }
+	want := []key{{1, 1}, {1, 2}, {2, 1}}
+	for i, w := range want {
+		if found[i].ServiceID != w.service || !found[i].Start.Equal(e.day(w.day)) {
+			t.Errorf("slot %d = service %d at %s, want service %d day +%d", i, found[i].ServiceID, found[i].Start, w.service, w.day)
+		}
+	}
+	for method, want := range map[string]int{
+		slottest.BookableDatesByService:     1,
+		slottest.BookableDates:              0,
+		slottest.BookableTimeslotsByService: 1, // day +1, when both services are bookable
+		slottest.BookableTimeslots:          1, // day +2, service 1 only
+	} {
+		if got := e.src.Calls(method); got != want {
+			t.Errorf("%d calls of %s, want %d", got, method, want)
+		}
+	}
+}
+
+func TestScanRemembersUnattributedServiceSearches(t *testing.T) {
+	e := newTestEnv(t, Options{ServiceIDs: []int{1, 2}})
+	for staff := 10; staff <= 12; staff++ {
+		e.src.Add(1, staff, slottest.At(e.day(1)))
+		e.src.Add(2, staff, slottest.At(e.day(2)))
+	}
+
+	from, to := DateRange(time.Now().In(e.n.loc), 7)
+	for scan := 1; scan <= 2; scan++ {
+		found, errs := e.n.scanAvailability(context.Background(), from, to)
+		if errs != 0 || len(found) != 6 {
+			t.Fatalf("scan %d found %d slots with %d errors, want 6", scan, len(found), errs)
+		}
+	}
+	if got := e.src.Calls(slottest.BookableDatesByService); got != 1 {
+		t.Errorf("%d batched searches, want only the first, which showed the source can't split them", got)
+	}
+	if got := e.src.Calls(slottest.BookableDates); got != 12 {
+		t.Errorf("%d per-service dates searches, want one per service and instructor in each scan", got)
+	}
+}
+
+func TestScanServicesBookableTogetherRequests(t *testing.T) {
+	srv := yclientstest.NewServer()
+	defer srv.Close()
+	srv.Set(yclientstest.SearchStaffPath, yclientstest.Response{Status: http.StatusOK, Body: twoStaff})
+
+	got := scanRequests(t, srv, Options{ServiceIDs: []int{1, 2}})
+	want := map[string]int{
+		// Both services together, one batched search for the first
+		// instructor that the fixture can't split per service, then each
+		// instructor of each service.
+		yclientstest.SearchDatesPath:     6,
+		yclientstest.SearchStaffPath:     2,
+		yclientstest.SearchTimeslotsPath: 4,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+package notifier
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// nextCheckDelay is the wait before the next scheduled check, counted from
+// the end of the previous one.
+func (n *Notifier) nextCheckDelay() time.Duration {
+	return jitteredDelay(n.opts.Interval, n.opts.IntervalJitter, rand.Int63n)
+}
+
+// jitteredDelay returns interval shifted by a random offset in
+// [-jitter, +jitter], never less than half the interval. randInt63n is
+// injected so the spread can be checked deterministically.
+func jitteredDelay(interval, jitter time.Duration, randInt63n func(int64) int64) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+	d := interval - jitter + time.Duration(randInt63n(int64(2*jitter)+1))
+	if min := interval / 2; d < min {
+		return min
+	}
+	return d
+}
+
+// timedCheck runs one check and reports when it outlasted the poll
+// interval. Scheduling starts over after the check, so the tick that would
+// have fired in the meantime is skipped rather than run back to back.
+func (n *Notifier) timedCheck(ctx context.Context) checkResult {
+	start := n.now()
+	res := n.checkAndNotify(ctx)
+
+	if elapsed := n.now().Sub(start); elapsed > n.opts.Interval {
+		n.log.WarnWithFields("Check took longer than the poll interval, skipping tick", logger.Fields{
+			"duration": elapsed.String(),
+			"interval": n.opts.Interval.String(),
+		})
+		if n.metrics != nil {
+			n.metrics.RecordSkippedTick()
+		}
+	}
+	return res
+}
+
+// resetTimer reschedules t, discarding a fire that was not received yet.
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
+}
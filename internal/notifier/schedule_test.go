@@ -0,0 +1,145 @@
+package notifier
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/notifier/slottest"
+)
+
+// fakeClock is a time source that moves forward by step on every reading,
+// as if each reading happened step after the previous one.
+type fakeClock struct {
+	mu   sync.Mutex
+	t    time.Time
+	step time.Duration
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.t
+	c.t = c.t.Add(c.step)
+	return t
+}
+
+// countingMetrics records skipped ticks and ignores everything else.
+type countingMetrics struct {
+	mu           sync.Mutex
+	skippedTicks int
+}
+
+func (m *countingMetrics) RecordSkippedTick() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.skippedTicks++
+}
+
+func (m *countingMetrics) skipped() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.skippedTicks
+}
+
+func (m *countingMetrics) RecordNewSlot()                   {}
+func (m *countingMetrics) RecordSlotTaken()                 {}
+func (m *countingMetrics) RecordStaffCacheLookup(bool)      {}
+func (m *countingMetrics) RecordDeliveryRetry(string)       {}
+func (m *countingMetrics) ObserveSlotCheckDuration(float64) {}
+func (m *countingMetrics) ObserveNotificationDelay(float64) {}
+func (m *countingMetrics) SetSeenSlotsTotal(float64)        {}
+func (m *countingMetrics) RecordError(string)               {}
+
+func TestJitteredDelay(t *testing.T) {
+	const interval, jitter = time.Minute, 10 * time.Second
+	lowest := func(int64) int64 { return 0 }
+	highest := func(n int64) int64 { return n - 1 }
+
+	if got := jitteredDelay(interval, 0, highest); got != interval {
+		t.Errorf("no jitter: delay = %s, want %s", got, interval)
+	}
+	if got := jitteredDelay(interval, jitter, lowest); got != interval-jitter {
+		t.Errorf("lowest draw: delay = %s, want %s", got, interval-jitter)
+	}
+	if got := jitteredDelay(interval, jitter, highest); got != interval+jitter {
+		t.Errorf("highest draw: delay = %s, want %s", got, interval+jitter)
+	}
+	if got := jitteredDelay(interval, 2*interval, lowest); got != interval/2 {
+		t.Errorf("jitter above the interval: delay = %s, want the floor %s", got, interval/2)
+	}
+}
+
+func TestNextCheckDelayStaysInRange(t *testing.T) {
+	e := newTestEnv(t, Options{Interval: time.Minute, IntervalJitter: 10 * time.Second})
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 200; i++ {
+		d := e.n.nextCheckDelay()
+		if d < 50*time.Second || d > 70*time.Second {
+			t.Fatalf("delay %s outside 1m ± 10s", d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Error("delay never varied")
+	}
+}
+
+func TestTimedCheckSkipsTickWhenCheckOverruns(t *testing.T) {
+	e := newTestEnv(t, Options{Interval: time.Minute})
+	m := &countingMetrics{}
+	e.n.SetMetrics(m)
+	clock := &fakeClock{t: time.Date(2025, 3, 18, 10, 0, 0, 0, time.UTC), step: 90 * time.Second}
+	e.n.now = clock.now
+
+	e.n.timedCheck(context.Background())
+	if got := m.skipped(); got != 1 {
+		t.Errorf("skipped ticks = %d after a 90s check, want 1", got)
+	}
+
+	clock.step = 30 * time.Second
+	e.n.timedCheck(context.Background())
+	if got := m.skipped(); got != 1 {
+		t.Errorf("skipped ticks = %d after a 30s check, want still 1", got)
+	}
+}
+
+func TestRunDoesNotOverlapChecks(t *testing.T) {
+	// Without a check timeout, since 90% of the interval would cut every
+	// check short of overrunning it.
+	e := newTestEnv(t, Options{Interval: 10 * time.Millisecond, StartupCheckDelay: -1, CheckTimeout: -1})
+	m := &countingMetrics{}
+	e.n.SetMetrics(m)
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+	e.src.Delay = 30 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	e.n.Run(ctx)
+
+	if got := e.src.MaxInFlight(); got != 1 {
+		t.Errorf("%d requests ran at once, want checks one after another", got)
+	}
+	if e.src.Calls(slottest.BookableDatesAnyStaff) == 0 {
+		t.Fatal("no check ran")
+	}
+	if m.skipped() == 0 {
+		t.Error("no skipped tick recorded for checks longer than the interval")
+	}
+}
+
+func TestRunSkipsChecksWhileBackingOff(t *testing.T) {
+	e := newTestEnv(t, Options{Interval: 10 * time.Millisecond, StartupCheckDelay: -1})
+	clock := &fakeClock{t: time.Now()}
+	e.n.now = clock.now
+	e.n.backOff(clock.t.Add(time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	e.n.Run(ctx)
+
+	if calls := e.src.Calls(slottest.BookableDatesAnyStaff); calls != 0 {
+		t.Errorf("%d checks ran during the back-off, want none", calls)
+	}
+}
@@ -0,0 +1,53 @@
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+)
+
+// handleSeatsDecrease reacts to a group slot losing seats since the previous
+// check. It is distinct from new and taken slots: the slot is still bookable.
+func (n *Notifier) handleSeatsDecrease(slot slots.Slot, prev int) {
+	n.log.InfoWithFields("Slot seats decreased", logger.Fields{
+		"service_id": slot.ServiceID,
+		"staff_id":   slot.StaffID,
+		"datetime":   slot.Raw,
+		"seats_was":  prev,
+		"seats_left": slot.SeatsLeft,
+	})
+
+	if !n.opts.NotifySeatsDecrease {
+		return
+	}
+
+	msg := n.formatSeatsMessage(slot)
+	excerpt := fmt.Sprintf("%s (мест: %d)", n.slotExcerpt(slot), slot.SeatsLeft)
+	subscribers, err := n.bot.Subscribers()
+	if err != nil {
+		n.log.WithError(err).Error("Failed to get subscribers, skipping seats notifications")
+		return
+	}
+	for _, chatID := range subscribers {
+		if !n.wantsSlot(chatID, slot.ServiceID, slot.Start) {
+			continue
+		}
+		n.notify(chatID, slot.Key(), excerpt, msg)
+	}
+}
+
+func (n *Notifier) formatSeatsMessage(slot slots.Slot) string {
+	data := n.slotData(slot)
+	if tmpl, ok := n.template("templates/seats_decrease.tmpl"); ok {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			n.log.WithError(err).Error("Failed to execute seats template, using fallback")
+		} else {
+			return buf.String()
+		}
+	}
+	return fmt.Sprintf("⏳ Места заканчиваются\n\nУслуга: %s\nДата: %s (%s)\nВремя: %s %s\nОсталось мест: %d\n",
+		data.ServiceName, data.Date, data.Weekday, data.Time, data.Zone, data.SeatsLeft)
+}
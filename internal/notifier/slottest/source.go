@@ -0,0 +1,374 @@
+// Package slottest provides an in-memory slot source for exercising the
+// notifier without a booking system.
+package slottest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
+)
+
+// Method names accepted by Fail and Calls.
+const (
+	BookableStaff              = "GetBookableStaff"
+	BookableDates              = "GetBookableDates"
+	BookableDatesAnyStaff      = "GetBookableDatesAnyStaff"
+	BookableDatesServices      = "GetBookableDatesServices"
+	BookableDatesByService     = "GetBookableDatesByService"
+	BookableTimeslots          = "GetBookableTimeslots"
+	BookableTimeslotsByService = "GetBookableTimeslotsByService"
+	Staff                      = "GetStaff"
+	Services                   = "GetServices"
+)
+
+// Source implements notifier.SlotSource from timeslots added with Add.
+// Dates are taken from the timeslots and filtered by the requested range
+// like the real API does. Every call is counted.
+type Source struct {
+	// Delay makes every availability call wait this long, or until its
+	// context is done.
+	Delay time.Duration
+	// AttributeServices makes the ByService searches name the service of
+	// each result. Without it they answer like YCLIENTS does for a
+	// combined visit and fail with yclients.ErrUnattributed whenever they
+	// find anything.
+	AttributeServices bool
+
+	mu          sync.Mutex
+	slots       map[int]map[int][]yclients.Timeslot
+	staff       []yclients.Staff
+	services    []yclients.Service
+	failures    map[string]error
+	dateErrors  map[string]error
+	dateDelays  map[string]time.Duration
+	calls       map[string]int
+	inFlight    int
+	maxInFlight int
+}
+
+// NewSource returns a source with no bookable slots.
+func NewSource() *Source {
+	return &Source{
+		slots:      make(map[int]map[int][]yclients.Timeslot),
+		failures:   make(map[string]error),
+		dateErrors: make(map[string]error),
+		dateDelays: make(map[string]time.Duration),
+		calls:      make(map[string]int),
+	}
+}
+
+// At returns a timeslot starting at t, formatted like search-timeslots.
+func At(t time.Time) yclients.Timeslot {
+	return yclients.Timeslot{Datetime: t.Format(time.RFC3339), Start: t}
+}
+
+// Add makes the timeslots bookable with staffID for serviceID.
+func (s *Source) Add(serviceID, staffID int, ts ...yclients.Timeslot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.slots[serviceID] == nil {
+		s.slots[serviceID] = make(map[int][]yclients.Timeslot)
+	}
+	s.slots[serviceID][staffID] = append(s.slots[serviceID][staffID], ts...)
+}
+
+// Clear removes every bookable slot.
+func (s *Source) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slots = make(map[int]map[int][]yclients.Timeslot)
+}
+
+// SetStaff sets the staff list returned by GetStaff.
+func (s *Source) SetStaff(staff ...yclients.Staff) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.staff = staff
+}
+
+// SetServices sets the catalog returned by GetServices.
+func (s *Source) SetServices(services ...yclients.Service) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.services = services
+}
+
+// Fail makes every call of method return err; a nil err clears it.
+func (s *Source) Fail(method string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		delete(s.failures, method)
+		return
+	}
+	s.failures[method] = err
+}
+
+// FailDate makes GetBookableTimeslots return err for date only.
+func (s *Source) FailDate(date string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dateErrors[date] = err
+}
+
+// DelayDate makes GetBookableTimeslots for date wait d, or until its
+// context is done, on top of Delay.
+func (s *Source) DelayDate(date string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dateDelays[date] = d
+}
+
+// Calls counts the calls of method.
+func (s *Source) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// MaxInFlight returns the most availability calls that ran at once.
+func (s *Source) MaxInFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxInFlight
+}
+
+// begin counts a call and waits out Delay; the returned function ends it.
+func (s *Source) begin(ctx context.Context, method string) (func(), error) {
+	s.mu.Lock()
+	s.calls[method]++
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	err := s.failures[method]
+	s.mu.Unlock()
+
+	end := func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}
+	if werr := wait(ctx, s.Delay); werr != nil {
+		end()
+		return nil, werr
+	}
+	if err != nil {
+		end()
+		return nil, err
+	}
+	return end, nil
+}
+
+func (s *Source) GetBookableStaff(ctx context.Context, locationID, serviceID int) ([]yclients.StaffAvailability, error) {
+	end, err := s.begin(ctx, BookableStaff)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []yclients.StaffAvailability
+	for id, ts := range s.slots[serviceID] {
+		if len(ts) > 0 {
+			out = append(out, yclients.StaffAvailability{ID: id})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (s *Source) GetBookableDates(ctx context.Context, locationID, serviceID int, dateFrom, dateTo string, staffID *int) ([]string, error) {
+	end, err := s.begin(ctx, BookableDates)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	return s.dates(serviceID, dateFrom, dateTo, staffID), nil
+}
+
+func (s *Source) GetBookableDatesAnyStaff(ctx context.Context, locationID, serviceID int, dateFrom, dateTo string) ([]string, error) {
+	end, err := s.begin(ctx, BookableDatesAnyStaff)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	return s.dates(serviceID, dateFrom, dateTo, nil), nil
+}
+
+// GetBookableDatesServices returns the dates on which every one of the
+// services has a slot, the way a combined visit is searched.
+func (s *Source) GetBookableDatesServices(ctx context.Context, locationID int, serviceIDs []int, dateFrom, dateTo string) ([]string, error) {
+	end, err := s.begin(ctx, BookableDatesServices)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	count := make(map[string]int)
+	for _, id := range serviceIDs {
+		for _, d := range s.dates(id, dateFrom, dateTo, nil) {
+			count[d]++
+		}
+	}
+	var out []string
+	for d, n := range count {
+		if n == len(serviceIDs) {
+			out = append(out, d)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func (s *Source) GetBookableDatesByService(ctx context.Context, locationID int, serviceIDs []int, dateFrom, dateTo string, staffID int) (map[int][]string, error) {
+	end, err := s.begin(ctx, BookableDatesByService)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	out := make(map[int][]string)
+	for _, id := range serviceIDs {
+		if dates := s.dates(id, dateFrom, dateTo, &staffID); len(dates) > 0 {
+			out[id] = dates
+		}
+	}
+	return attributed(s, out)
+}
+
+func (s *Source) GetBookableTimeslotsByService(ctx context.Context, locationID int, serviceIDs []int, date string, staffID int) (map[int][]yclients.Timeslot, error) {
+	end, err := s.begin(ctx, BookableTimeslotsByService)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	out := make(map[int][]yclients.Timeslot)
+	for _, id := range serviceIDs {
+		if ts := s.timeslots(id, date, staffID); len(ts) > 0 {
+			out[id] = ts
+		}
+	}
+	return attributed(s, out)
+}
+
+// attributed returns found, or yclients.ErrUnattributed when services
+// are not attributed and something was found.
+func attributed[T any](s *Source, found map[int][]T) (map[int][]T, error) {
+	if !s.AttributeServices && len(found) > 0 {
+		return nil, yclients.ErrUnattributed
+	}
+	return found, nil
+}
+
+func (s *Source) GetBookableTimeslots(ctx context.Context, locationID, serviceID int, date string, staffID int) ([]yclients.Timeslot, error) {
+	end, err := s.begin(ctx, BookableTimeslots)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	s.mu.Lock()
+	delay := s.dateDelays[date]
+	s.mu.Unlock()
+	if err := wait(ctx, delay); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	err = s.dateErrors[date]
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return s.timeslots(serviceID, date, staffID), nil
+}
+
+// timeslots returns the timeslots of serviceID with staffID on date.
+func (s *Source) timeslots(serviceID int, date string, staffID int) []yclients.Timeslot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []yclients.Timeslot
+	for _, ts := range s.slots[serviceID][staffID] {
+		if dateOf(ts) == date {
+			out = append(out, ts)
+		}
+	}
+	return out
+}
+
+func (s *Source) GetStaff(ctx context.Context, companyID string) ([]yclients.Staff, error) {
+	end, err := s.begin(ctx, Staff)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]yclients.Staff(nil), s.staff...), nil
+}
+
+func (s *Source) GetServices(ctx context.Context, companyID string) ([]yclients.Service, error) {
+	end, err := s.begin(ctx, Services)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]yclients.Service(nil), s.services...), nil
+}
+
+// wait sleeps for d or until ctx is done.
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// dates returns the sorted dates between from and to with a slot for
+// serviceID, limited to staffID when set.
+func (s *Source) dates(serviceID int, from, to string, staffID *int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]bool)
+	for id, list := range s.slots[serviceID] {
+		if staffID != nil && id != *staffID {
+			continue
+		}
+		for _, ts := range list {
+			if d := dateOf(ts); d >= from && d <= to {
+				seen[d] = true
+			}
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for d := range seen {
+		out = append(out, d)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// dateOf returns the date of a timeslot as YYYY-MM-DD.
+func dateOf(ts yclients.Timeslot) string {
+	if len(ts.Datetime) >= len("2006-01-02") && ts.Datetime[4] == '-' {
+		return ts.Datetime[:len("2006-01-02")]
+	}
+	return ts.Start.Format("2006-01-02")
+}
@@ -0,0 +1,62 @@
+package notifier
+
+import (
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+// availabilityDiff is what changed between the previous snapshot and the
+// slots found by a check.
+type availabilityDiff struct {
+	added []slots.Slot
+	// kept are slots bookable in both, in check order.
+	kept []slots.Slot
+	// removed is only filled when both sides can be trusted to list every
+	// bookable slot: the check completed and the previous snapshot was not
+	// seeded from seen_slots.
+	removed []storage.ObservedSlot
+	// next is the snapshot to save for this check. After a partial check
+	// it keeps the previous slots that were not seen, so they are neither
+	// re-announced nor lost for slot-taken detection.
+	next []storage.ObservedSlot
+}
+
+func diffSnapshot(prev storage.Snapshot, available []slots.Slot, complete bool) availabilityDiff {
+	known := make(map[string]bool, len(prev.Slots))
+	for _, o := range prev.Slots {
+		known[o.Key] = true
+	}
+
+	var diff availabilityDiff
+	current := make(map[string]bool, len(available))
+	for _, s := range available {
+		key := s.Key()
+		if current[key] {
+			continue
+		}
+		current[key] = true
+		if known[key] {
+			diff.kept = append(diff.kept, s)
+		} else {
+			diff.added = append(diff.added, s)
+		}
+		diff.next = append(diff.next, observedSlot(s))
+	}
+
+	for _, o := range prev.Slots {
+		if current[o.Key] {
+			continue
+		}
+		if !complete {
+			diff.next = append(diff.next, o)
+		} else if prev.ID != 0 && !prev.Seeded {
+			diff.removed = append(diff.removed, o)
+		}
+	}
+	return diff
+}
+
+// observedSlot converts s for storage.
+func observedSlot(s slots.Slot) storage.ObservedSlot {
+	return storage.ObservedSlot{Key: s.Key(), ServiceID: s.ServiceID, StaffID: s.StaffID, Raw: s.Raw, Start: s.Start}
+}
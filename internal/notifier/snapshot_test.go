@@ -0,0 +1,24 @@
+package notifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+func TestDiffSnapshotBareTimeOnTwoDates(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*3600)
+	found := []slots.Slot{
+		{ServiceID: 1, StaffID: 2, Raw: "10:00", Start: time.Date(2025, 3, 18, 10, 0, 0, 0, loc)},
+		{ServiceID: 1, StaffID: 2, Raw: "10:00", Start: time.Date(2025, 3, 19, 10, 0, 0, 0, loc)},
+	}
+	diff := diffSnapshot(storage.Snapshot{}, found, true)
+	if len(diff.added) != 2 {
+		t.Fatalf("added %d slots, want 2", len(diff.added))
+	}
+	if len(diff.next) != 2 {
+		t.Errorf("next snapshot has %d slots, want 2", len(diff.next))
+	}
+}
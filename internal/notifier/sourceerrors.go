@@ -0,0 +1,93 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
+)
+
+// authAlertInterval limits how often admins hear about rejected
+// credentials while the problem persists.
+const authAlertInterval = time.Hour
+
+// handleSourceError records a failed YCLIENTS request under errorType and
+// reacts to failures a plain retry can't fix: rate limiting pauses
+// scheduled checks, rejected credentials alert admins.
+func (n *Notifier) handleSourceError(err error, errorType string) {
+	n.recordError(errorType)
+	switch {
+	case errors.Is(err, yclients.ErrRateLimited):
+		n.recordError("yclients_rate_limited")
+		wait, ok := yclients.RetryAfter(err)
+		if !ok {
+			wait = n.opts.Interval
+		}
+		n.backOff(time.Now().Add(wait))
+	case errors.Is(err, yclients.ErrAuth):
+		n.recordError("yclients_auth_failed")
+		n.alertAuthFailure(err)
+	case errors.Is(err, yclients.ErrBadResponse):
+		n.recordError("yclients_bad_response")
+	case errors.Is(err, yclients.ErrBadRequest):
+		n.recordError("yclients_bad_request")
+	}
+}
+
+// logSourceError logs a failed YCLIENTS request. Invalid requests, which
+// fail the same way on every check until the configuration changes, are
+// logged once per distinct error.
+func (n *Notifier) logSourceError(ctx context.Context, err error, msg string, fields logger.Fields) {
+	if errors.Is(err, yclients.ErrBadRequest) {
+		n.stateMu.Lock()
+		logged := n.badRequestsLogged[err.Error()]
+		if !logged {
+			if n.badRequestsLogged == nil {
+				n.badRequestsLogged = make(map[string]bool)
+			}
+			n.badRequestsLogged[err.Error()] = true
+		}
+		n.stateMu.Unlock()
+		if !logged {
+			n.logFor(ctx).WithError(err).ErrorWithFields(msg+"; check the configuration, further identical errors are not logged", fields)
+		}
+		return
+	}
+	n.logFor(ctx).WithError(err).ErrorWithFields(msg, fields)
+}
+
+// backOff postpones scheduled checks until until.
+func (n *Notifier) backOff(until time.Time) {
+	n.stateMu.Lock()
+	defer n.stateMu.Unlock()
+	if until.After(n.backoffUntil) {
+		n.backoffUntil = until
+		n.log.WarnWithFields("YCLIENTS rate limit hit, pausing scheduled checks", logger.Fields{
+			"until": until.Format(time.RFC3339),
+		})
+	}
+}
+
+// backingOff reports whether scheduled checks should wait for a rate
+// limit to pass.
+func (n *Notifier) backingOff(now time.Time) bool {
+	n.stateMu.RLock()
+	defer n.stateMu.RUnlock()
+	return now.Before(n.backoffUntil)
+}
+
+func (n *Notifier) alertAuthFailure(err error) {
+	n.stateMu.Lock()
+	due := time.Since(n.authAlertedAt) >= authAlertInterval
+	if due {
+		n.authAlertedAt = time.Now()
+	}
+	n.stateMu.Unlock()
+	if !due || n.bot == nil {
+		return
+	}
+	n.bot.NotifyAdmins("🔐 YCLIENTS отклоняет авторизацию, слоты не проверяются.\n\n" + err.Error() +
+		"\n\nПроверьте YCLIENTS_LOGIN, YCLIENTS_PASSWORD и YCLIENTS_PARTNER_TOKEN.")
+}
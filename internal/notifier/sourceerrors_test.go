@@ -0,0 +1,98 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/notifier/slottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
+)
+
+const testAdminID = 42
+
+func TestRateLimitBacksOff(t *testing.T) {
+	e := newTestEnv(t, Options{Interval: time.Minute})
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+	e.src.Fail(slottest.BookableDatesAnyStaff, fmt.Errorf("search: %w", &yclients.APIError{
+		StatusCode: http.StatusTooManyRequests,
+		RetryAfter: time.Hour,
+	}))
+
+	e.n.checkAndNotify(context.Background())
+	now := time.Now()
+	if !e.n.backingOff(now.Add(50 * time.Minute)) {
+		t.Error("not backing off within the requested Retry-After")
+	}
+	if e.n.backingOff(now.Add(2 * time.Hour)) {
+		t.Error("still backing off after the requested Retry-After")
+	}
+}
+
+func TestRateLimitWithoutRetryAfterWaitsOneInterval(t *testing.T) {
+	e := newTestEnv(t, Options{Interval: time.Minute})
+	e.n.handleSourceError(&yclients.APIError{StatusCode: http.StatusTooManyRequests}, "test")
+
+	now := time.Now()
+	if !e.n.backingOff(now) || e.n.backingOff(now.Add(2*time.Minute)) {
+		t.Error("back-off is not one check interval")
+	}
+}
+
+func TestAuthFailureAlertsAdminsOnce(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	e.n.bot.SetAdmins([]int64{testAdminID})
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+	e.src.Fail(slottest.BookableDatesAnyStaff, fmt.Errorf("get auth token: %w", yclients.ErrAuth))
+
+	e.n.checkAndNotify(context.Background())
+	e.n.checkAndNotify(context.Background())
+
+	if got := len(e.api.MessagesTo(testAdminID)); got != 1 {
+		t.Errorf("admin got %d alerts, want 1", got)
+	}
+	if e.n.backingOff(time.Now()) {
+		t.Error("auth failure started a rate-limit back-off")
+	}
+}
+
+func TestOtherSourceErrorsDoNotAlert(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	e.n.bot.SetAdmins([]int64{testAdminID})
+	for _, err := range []error{
+		yclients.ErrBadResponse,
+		yclients.ErrBadRequest,
+		&yclients.APIError{StatusCode: http.StatusInternalServerError},
+		errors.New("connection reset"),
+	} {
+		e.n.handleSourceError(err, "test")
+	}
+
+	if got := len(e.api.MessagesTo(testAdminID)); got != 0 {
+		t.Errorf("admin got %d alerts, want none", got)
+	}
+	if e.n.backingOff(time.Now()) {
+		t.Error("non-rate-limit errors started a back-off")
+	}
+}
+
+func TestBadRequestLoggedOnce(t *testing.T) {
+	e := newTestEnv(t, Options{})
+	var out bytes.Buffer
+	e.n.log = logger.New().WithOutput(&out)
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+	e.src.Fail(slottest.BookableDatesAnyStaff, fmt.Errorf("%w: service ID must be positive, got 0", yclients.ErrBadRequest))
+
+	for i := 0; i < 3; i++ {
+		e.n.checkAndNotify(context.Background())
+	}
+	if got := strings.Count(out.String(), "service ID must be positive"); got != 1 {
+		t.Errorf("invalid request logged %d times over 3 checks, want once", got)
+	}
+}
@@ -0,0 +1,58 @@
+package notifier
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// staffNamesRefresh bounds how often the staff list is fetched; the roster
+// rarely changes and names are cosmetic.
+const staffNamesRefresh = time.Hour
+
+// refreshStaffNames reloads staff names from YCLIENTS unless they were
+// fetched within staffNamesRefresh. A failed fetch keeps the previous names
+// and is not retried before the next refresh is due.
+func (n *Notifier) refreshStaffNames(ctx context.Context) {
+	if n.yc == nil || n.opts.LocationID == 0 {
+		return
+	}
+	n.staffMu.Lock()
+	defer n.staffMu.Unlock()
+	if !n.staffNamesAt.IsZero() && time.Since(n.staffNamesAt) < staffNamesRefresh {
+		return
+	}
+	n.staffNamesAt = time.Now()
+
+	staff, err := n.yc.GetStaff(ctx, strconv.Itoa(n.opts.LocationID))
+	if err != nil {
+		n.logFor(ctx).WithError(err).WarnWithFields("Failed to fetch staff names", logger.Fields{
+			"location_id": n.opts.LocationID,
+		})
+		return
+	}
+	// Staff who take no bookings right now keep their names: their slots
+	// may still be listed or referenced by earlier notifications
+	names := make(map[int]string, len(staff))
+	bookable := 0
+	for _, s := range staff {
+		if s.Name != "" {
+			names[s.ID] = s.Name
+		}
+		if s.Bookable {
+			bookable++
+		}
+	}
+	SeedStaffNames(names)
+	n.logFor(ctx).DebugWithFields("Staff names refreshed", logger.Fields{"staff": len(names), "bookable": bookable})
+}
+
+// staffLabel returns the staff member's name, or "#id" when it is unknown.
+func staffLabel(id int) string {
+	if name, ok := StaffName(id); ok {
+		return name
+	}
+	return "#" + strconv.Itoa(id)
+}
@@ -0,0 +1,78 @@
+package notifier
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/bot/bottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/notifier/slottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
+)
+
+// resetStaffNames clears the package-wide staff names after the test.
+func resetStaffNames(t *testing.T) {
+	t.Cleanup(func() {
+		SeedStaffNames(nil)
+		SetStaffNames(nil)
+	})
+}
+
+func TestRefreshStaffNames(t *testing.T) {
+	resetStaffNames(t)
+	e := newTestEnv(t, Options{})
+	e.src.SetStaff(
+		yclients.Staff{ID: testStaffID, Name: "Иван", Bookable: true},
+		yclients.Staff{ID: testStaffID + 1, Name: "Пётр"},
+		yclients.Staff{ID: testStaffID + 2},
+	)
+
+	e.n.refreshStaffNames(context.Background())
+	for id, want := range map[int]string{
+		testStaffID: "Иван",
+		// Staff who take no bookings keep their names.
+		testStaffID + 1: "Пётр",
+		testStaffID + 2: "#2850524",
+		testStaffID + 3: "#2850525",
+	} {
+		if got := staffLabel(id); got != want {
+			t.Errorf("staffLabel(%d) = %q, want %q", id, got, want)
+		}
+	}
+
+	// The roster is fetched at most once per refresh interval, and a
+	// failure keeps the names already known.
+	e.src.Fail(slottest.Staff, bottest.ErrFake)
+	e.n.refreshStaffNames(context.Background())
+	if got := e.src.Calls(slottest.Staff); got != 1 {
+		t.Errorf("GetStaff called %d times, want 1", got)
+	}
+	e.n.staffNamesAt = e.n.staffNamesAt.Add(-staffNamesRefresh)
+	e.n.refreshStaffNames(context.Background())
+	if got := e.src.Calls(slottest.Staff); got != 2 {
+		t.Errorf("GetStaff called %d times after the interval, want 2", got)
+	}
+	if got := staffLabel(testStaffID); got != "Иван" {
+		t.Errorf("staffLabel after a failed refresh = %q, want Иван", got)
+	}
+}
+
+func TestSlotMessageNamesInstructor(t *testing.T) {
+	resetStaffNames(t)
+	e := newTestEnv(t, Options{})
+	e.subscribe(t, testChatID)
+	e.src.SetStaff(yclients.Staff{ID: testStaffID, Name: "Иван", Bookable: true})
+	e.src.Add(testServiceID, testStaffID, slottest.At(e.day(1)))
+
+	e.n.checkAndNotify(context.Background())
+	msgs := e.api.MessagesTo(testChatID)
+	if len(msgs) != 1 || !strings.Contains(msgs[0], "Сотрудник: Иван") {
+		t.Fatalf("messages = %q, want one naming the instructor", msgs)
+	}
+
+	// Names from STAFF_NAMES win over the fetched ones.
+	SetStaffNames(map[int]string{testStaffID: "Иван Петрович"})
+	if got := staffLabel(testStaffID); got != "Иван Петрович" {
+		t.Errorf("staffLabel = %q, want the configured name", got)
+	}
+}
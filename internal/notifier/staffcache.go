@@ -0,0 +1,86 @@
+package notifier
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
+)
+
+// DefaultStaffCacheTTL is how long bookable staff are reused; the
+// instructor roster changes far less often than checks run.
+const DefaultStaffCacheTTL = time.Hour
+
+type staffCacheKey struct {
+	locationID int
+	serviceID  int
+}
+
+type staffCacheEntry struct {
+	staff     []yclients.StaffAvailability
+	fetchedAt time.Time
+}
+
+// staffCache holds bookable staff per location and service.
+type staffCache struct {
+	mu      sync.Mutex
+	entries map[staffCacheKey]staffCacheEntry
+}
+
+func (c *staffCache) get(key staffCacheKey, ttl time.Duration, now time.Time) ([]yclients.StaffAvailability, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || now.Sub(e.fetchedAt) >= ttl {
+		return nil, false
+	}
+	return e.staff, true
+}
+
+func (c *staffCache) put(key staffCacheKey, staff []yclients.StaffAvailability, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[staffCacheKey]staffCacheEntry)
+	}
+	c.entries[key] = staffCacheEntry{staff: staff, fetchedAt: now}
+}
+
+func (c *staffCache) invalidate(key staffCacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// bookableStaff returns the bookable staff of a service, reusing the
+// result of an earlier call for StaffCacheTTL. Errors drop the cached entry,
+// and an empty roster is never cached so a transient empty response doesn't
+// hide a service for the whole TTL.
+func (n *Notifier) bookableStaff(ctx context.Context, serviceID int) ([]yclients.StaffAvailability, error) {
+	key := staffCacheKey{locationID: n.opts.LocationID, serviceID: serviceID}
+	ttl := n.opts.StaffCacheTTL
+	if ttl > 0 {
+		if staff, ok := n.staffCache.get(key, ttl, time.Now()); ok {
+			n.recordStaffCacheLookup(true)
+			return staff, nil
+		}
+		n.recordStaffCacheLookup(false)
+	}
+
+	staff, err := n.yc.GetBookableStaff(ctx, n.opts.LocationID, serviceID)
+	if err != nil || len(staff) == 0 {
+		n.staffCache.invalidate(key)
+		return staff, err
+	}
+	if ttl > 0 {
+		n.staffCache.put(key, staff, time.Now())
+	}
+	return staff, nil
+}
+
+func (n *Notifier) recordStaffCacheLookup(hit bool) {
+	if n.metrics != nil {
+		n.metrics.RecordStaffCacheLookup(hit)
+	}
+}
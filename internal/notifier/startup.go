@@ -0,0 +1,36 @@
+package notifier
+
+import (
+	"context"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// initialCheck runs one check right after start instead of waiting a full
+// poll interval. The delay keeps it from competing with the interface
+// refresh main sends to every subscriber at startup. It is skipped when ctx ends during the delay, the notifier
+// is paused, or storage does not answer yet; the regular ticker then takes
+// over.
+func (n *Notifier) initialCheck(ctx context.Context) {
+	if n.opts.StartupCheckDelay > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(n.opts.StartupCheckDelay):
+		}
+	}
+	if n.Paused() {
+		n.log.Debug("Notifier paused, skipping initial check")
+		return
+	}
+	if _, _, err := n.storage.GetSetting(fanoutCursorKey); err != nil {
+		n.log.WithError(err).Warn("Storage not ready, skipping initial check")
+		return
+	}
+
+	n.log.InfoWithFields("Running initial check", logger.Fields{
+		"delay": n.opts.StartupCheckDelay.String(),
+	})
+	n.checkAndNotify(ctx)
+}
@@ -0,0 +1,58 @@
+package notifier
+
+import (
+	"context"
+	"time"
+)
+
+// checkResult summarizes the outcome of one availability check.
+type checkResult struct {
+	At       time.Time
+	Duration time.Duration
+	NewSlots int
+	Errors   int
+	// Deprioritized counts subscribers the fan-out rotation moved to the back.
+	Deprioritized int
+}
+
+func (n *Notifier) recordCheck(res checkResult) {
+	n.stateMu.Lock()
+	defer n.stateMu.Unlock()
+	n.lastCheck = res
+}
+
+func (n *Notifier) templateCount() int {
+	n.templatesMu.RLock()
+	defer n.templatesMu.RUnlock()
+	return len(n.templates)
+}
+
+// StatusReport implements status.Reporter.
+func (n *Notifier) StatusReport(ctx context.Context) interface{} {
+	n.stateMu.RLock()
+	last := n.lastCheck
+	n.stateMu.RUnlock()
+
+	report := map[string]interface{}{
+		"interval":    n.opts.Interval.String(),
+		"timezone":    n.opts.Timezone,
+		"service_ids": n.opts.ServiceIDs,
+		"templates":   n.templateCount(),
+		"paused":      n.Paused(),
+	}
+	if !last.At.IsZero() {
+		outcome := "ok"
+		if last.Errors > 0 {
+			outcome = "partial"
+		}
+		report["last_check"] = map[string]interface{}{
+			"at":            last.At.UTC().Format(time.RFC3339),
+			"duration":      last.Duration.String(),
+			"new_slots":     last.NewSlots,
+			"errors":        last.Errors,
+			"outcome":       outcome,
+			"deprioritized": last.Deprioritized,
+		}
+	}
+	return report
+}
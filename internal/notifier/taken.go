@@ -0,0 +1,74 @@
+package notifier
+
+import (
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+	"github.com/thatguy/moto_gorod-notifier/internal/storage"
+)
+
+// reportTakenSlots handles slots that vanished from the availability
+// snapshot before their start time.
+func (n *Notifier) reportTakenSlots(removed []storage.ObservedSlot, now time.Time) {
+	_, dateTo := DateRange(now.In(n.loc), n.opts.LookaheadDays)
+	for _, o := range removed {
+		if !containsInt(n.opts.ServiceIDs, o.ServiceID) {
+			continue
+		}
+		// A shorter lookahead window stops scanning slots, it doesn't book them
+		if o.Start.In(n.loc).Format("2006-01-02") > dateTo {
+			continue
+		}
+		// Slots that moved into the past simply expired
+		if o.Start.IsZero() || !o.Start.After(now) {
+			continue
+		}
+		slot := slots.Slot{ServiceID: o.ServiceID, StaffID: o.StaffID, Raw: o.Raw, Start: o.Start.In(n.loc)}
+		n.handleSlotTaken(slot)
+	}
+}
+
+func (n *Notifier) handleSlotTaken(slot slots.Slot) {
+	n.log.InfoWithFields("Slot taken", logger.Fields{
+		"service_id": slot.ServiceID,
+		"staff_id":   slot.StaffID,
+		"datetime":   slot.Raw,
+	})
+	if n.metrics != nil {
+		n.metrics.RecordSlotTaken()
+	}
+
+	if !n.opts.NotifySlotTaken {
+		return
+	}
+	chats, err := n.storage.ChatsNotifiedAbout(slot.Key())
+	if err != nil {
+		n.log.WithError(err).Error("Failed to find chats notified about slot")
+		return
+	}
+	if len(chats) == 0 {
+		return
+	}
+
+	subscribers, err := n.bot.Subscribers()
+	if err != nil {
+		n.log.WithError(err).Error("Failed to get subscribers, skipping slot-taken notifications")
+		return
+	}
+	subscribed := make(map[int64]bool)
+	for _, chatID := range subscribers {
+		subscribed[chatID] = true
+	}
+	msg, err := n.RenderTemplate("templates/slot_taken.tmpl", n.slotData(slot))
+	if err != nil {
+		return
+	}
+	excerpt := "Занят: " + n.slotExcerpt(slot)
+	for _, chatID := range chats {
+		if !subscribed[chatID] || !n.wantsSlot(chatID, slot.ServiceID, slot.Start) {
+			continue
+		}
+		n.notify(chatID, slot.Key(), excerpt, msg)
+	}
+}
@@ -1,8 +1,269 @@
 package notifier
 
 import (
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
 )
 
 //go:embed templates/*.tmpl
 var templateFS embed.FS
+
+var templateFiles = []string{
+	"templates/slot_message.tmpl",
+	"templates/slot_message.en.tmpl",
+	"templates/welcome_message.tmpl",
+	"templates/current_slots.tmpl",
+	"templates/no_slots.tmpl",
+	"templates/goodbye_message.tmpl",
+	"templates/settings.tmpl",
+	"templates/seats_decrease.tmpl",
+	"templates/status.tmpl",
+	"templates/subscription_expiring.tmpl",
+	"templates/subscription_expired.tmpl",
+	"templates/bulk_publication.tmpl",
+	"templates/pinned_availability.tmpl",
+	"templates/digest.tmpl",
+	"templates/slot_taken.tmpl",
+	"templates/evening_reminder.tmpl",
+	"templates/besttime.tmpl",
+	"templates/weekly_report.tmpl",
+	"templates/overflow_summary.tmpl",
+}
+
+// TemplateDivergence describes an external template that differs from the
+// version shipped in the binary.
+type TemplateDivergence struct {
+	Name         string
+	EmbeddedHash string
+	ExternalHash string
+	LinesAdded   int
+	LinesRemoved int
+}
+
+// loadTemplates parses every known template, preferring files from
+// TemplatesDir over the embedded copies. Templates that fail to load are
+// logged and skipped, so rendering falls back to the built-in format.
+func (n *Notifier) loadTemplates() {
+	loaded, errs := n.parseTemplates()
+	for _, err := range errs {
+		n.log.WithError(err).Error("Failed to load template")
+	}
+
+	n.templatesMu.Lock()
+	n.templates = loaded
+	n.templatesMu.Unlock()
+}
+
+// ReloadTemplates re-reads templates at runtime. Unlike loadTemplates it
+// is all or nothing: any read or parse error keeps the current set.
+func (n *Notifier) ReloadTemplates() error {
+	loaded, errs := n.parseTemplates()
+	if len(errs) > 0 {
+		n.log.WithError(errs[0]).WarnWithFields("Template reload failed, keeping previous templates", logger.Fields{
+			"errors": len(errs),
+		})
+		return errors.Join(errs...)
+	}
+
+	n.templatesMu.Lock()
+	n.templates = loaded
+	n.templatesMu.Unlock()
+	n.log.InfoWithFields("Templates reloaded", logger.Fields{"templates": len(loaded)})
+	return nil
+}
+
+func (n *Notifier) parseTemplates() (map[string]*template.Template, []error) {
+	loaded := make(map[string]*template.Template, len(templateFiles))
+	var errs []error
+	for _, file := range templateFiles {
+		src, external, err := n.readTemplate(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("read %s: %w", path.Base(file), err))
+			continue
+		}
+		t, err := template.New(path.Base(file)).Funcs(templateFuncs()).Parse(string(src))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parse %s: %w", path.Base(file), err))
+			continue
+		}
+		if external {
+			n.log.InfoWithFields("Using external template", logger.Fields{"file": file})
+		}
+		loaded[file] = t
+	}
+
+	for _, serviceID := range n.opts.ServiceIDs {
+		file := serviceTemplateFile(serviceID)
+		src, external, err := n.readTemplate(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("read %s: %w", path.Base(file), err))
+			continue
+		}
+		t, err := template.New(path.Base(file)).Funcs(templateFuncs()).Parse(string(src))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parse %s: %w", path.Base(file), err))
+			continue
+		}
+		n.log.InfoWithFields("Using per-service slot template", logger.Fields{
+			"file":       file,
+			"service_id": serviceID,
+			"external":   external,
+		})
+		loaded[file] = t
+	}
+	return loaded, errs
+}
+
+// languageTemplateFile names the slot template for chats that chose lang
+// over the default Russian. Per-service templates still take precedence.
+func languageTemplateFile(lang string) string {
+	return "templates/slot_message." + lang + ".tmpl"
+}
+
+// serviceTemplateFile names the optional slot template that replaces
+// slot_message.tmpl for one service.
+func serviceTemplateFile(serviceID int) string {
+	return fmt.Sprintf("templates/slot_message_%d.tmpl", serviceID)
+}
+
+// isServiceTemplate reports whether file is a per-service slot template.
+func isServiceTemplate(file string) bool {
+	id, ok := strings.CutPrefix(path.Base(file), "slot_message_")
+	if !ok {
+		return false
+	}
+	id, ok = strings.CutSuffix(id, ".tmpl")
+	if !ok || id == "" {
+		return false
+	}
+	_, err := strconv.Atoi(id)
+	return err == nil
+}
+
+func (n *Notifier) template(file string) (*template.Template, bool) {
+	n.templatesMu.RLock()
+	defer n.templatesMu.RUnlock()
+	t, ok := n.templates[file]
+	return t, ok
+}
+
+// readTemplate returns the template source and whether it came from TemplatesDir.
+func (n *Notifier) readTemplate(file string) ([]byte, bool, error) {
+	if n.opts.TemplatesDir != "" {
+		data, err := os.ReadFile(n.externalTemplatePath(file))
+		if err == nil {
+			return data, true, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, false, err
+		}
+	}
+	data, err := templateFS.ReadFile(file)
+	return data, false, err
+}
+
+func (n *Notifier) externalTemplatePath(file string) string {
+	return filepath.Join(n.opts.TemplatesDir, path.Base(file))
+}
+
+// TemplateDivergences compares external overrides with the embedded templates.
+func (n *Notifier) TemplateDivergences() []TemplateDivergence {
+	if n.opts.TemplatesDir == "" {
+		return nil
+	}
+	var out []TemplateDivergence
+	for _, file := range templateFiles {
+		external, err := os.ReadFile(n.externalTemplatePath(file))
+		if err != nil {
+			continue
+		}
+		embedded, err := templateFS.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		eh, xh := contentHash(embedded), contentHash(external)
+		if eh == xh {
+			continue
+		}
+		added, removed := lineDiff(string(embedded), string(external))
+		out = append(out, TemplateDivergence{
+			Name:         path.Base(file),
+			EmbeddedHash: eh,
+			ExternalHash: xh,
+			LinesAdded:   added,
+			LinesRemoved: removed,
+		})
+	}
+	return out
+}
+
+// DivergedTemplates lists names of external templates that differ from the shipped ones.
+func (n *Notifier) DivergedTemplates() []string {
+	divs := n.TemplateDivergences()
+	names := make([]string, 0, len(divs))
+	for _, d := range divs {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+// AdoptTemplate overwrites the external copy of name with the embedded version
+// and reloads templates.
+func (n *Notifier) AdoptTemplate(name string) error {
+	if n.opts.TemplatesDir == "" {
+		return errors.New("templates directory is not configured")
+	}
+	file := "templates/" + path.Base(name)
+	if !strings.HasSuffix(file, ".tmpl") {
+		file += ".tmpl"
+	}
+	embedded, err := templateFS.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("unknown template %q", name)
+	}
+	if err := os.WriteFile(n.externalTemplatePath(file), embedded, 0o644); err != nil {
+		return fmt.Errorf("write template: %w", err)
+	}
+	n.log.InfoWithFields("Adopted embedded template", logger.Fields{"file": file})
+	n.loadTemplates()
+	return nil
+}
+
+func contentHash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// lineDiff counts lines present only in b (added) and only in a (removed).
+func lineDiff(a, b string) (added, removed int) {
+	counts := make(map[string]int)
+	for _, l := range strings.Split(a, "\n") {
+		counts[l]++
+	}
+	for _, l := range strings.Split(b, "\n") {
+		if counts[l] > 0 {
+			counts[l]--
+		} else {
+			added++
+		}
+	}
+	for _, c := range counts {
+		removed += c
+	}
+	return added, removed
+}
@@ -0,0 +1,145 @@
+package notifier
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+	"github.com/thatguy/moto_gorod-notifier/internal/notifier/slottest"
+	"github.com/thatguy/moto_gorod-notifier/internal/slots"
+)
+
+// templateDir returns a temporary templates directory holding files.
+func templateDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func embeddedTemplate(t *testing.T, name string) string {
+	t.Helper()
+	data, err := templateFS.ReadFile("templates/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestTemplateDivergences(t *testing.T) {
+	dir := templateDir(t, map[string]string{
+		// An unchanged copy is not reported.
+		"welcome_message.tmpl": embeddedTemplate(t, "welcome_message.tmpl"),
+		"no_slots.tmpl":        "Старая строка\n" + embeddedTemplate(t, "no_slots.tmpl"),
+	})
+	e := newTestEnv(t, Options{TemplatesDir: dir})
+
+	divs := e.n.TemplateDivergences()
+	if len(divs) != 1 {
+		t.Fatalf("divergences = %+v, want only no_slots.tmpl", divs)
+	}
+	d := divs[0]
+	if d.Name != "no_slots.tmpl" || d.EmbeddedHash == d.ExternalHash || d.LinesAdded != 1 || d.LinesRemoved != 0 {
+		t.Errorf("divergence = %+v, want no_slots.tmpl with one added line", d)
+	}
+	if got := e.n.DivergedTemplates(); len(got) != 1 || got[0] != "no_slots.tmpl" {
+		t.Errorf("DivergedTemplates = %v, want [no_slots.tmpl]", got)
+	}
+
+	// Without a templates directory nothing can diverge.
+	if got := newTestEnv(t, Options{}).n.TemplateDivergences(); got != nil {
+		t.Errorf("divergences without a directory = %+v, want none", got)
+	}
+}
+
+func TestAdoptTemplate(t *testing.T) {
+	dir := templateDir(t, map[string]string{"no_slots.tmpl": "Старая версия\n"})
+	e := newTestEnv(t, Options{TemplatesDir: dir})
+
+	if err := e.n.AdoptTemplate("no_slots"); err != nil {
+		t.Fatalf("AdoptTemplate: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "no_slots.tmpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != embeddedTemplate(t, "no_slots.tmpl") {
+		t.Errorf("external file = %q, want the embedded version", data)
+	}
+	if got := e.n.DivergedTemplates(); len(got) != 0 {
+		t.Errorf("DivergedTemplates after adopting = %v, want none", got)
+	}
+	got, err := e.n.RenderTemplate("templates/no_slots.tmpl", nil)
+	if err != nil || strings.Contains(got, "Старая версия") {
+		t.Errorf("rendered %q, %v after adopting, want the reloaded embedded template", got, err)
+	}
+
+	for _, name := range []string{"missing.tmpl", "../../etc/passwd"} {
+		if err := e.n.AdoptTemplate(name); err == nil {
+			t.Errorf("AdoptTemplate(%q) succeeded, want an unknown template error", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "passwd.tmpl")); err == nil {
+		t.Error("adopting a path wrote a file")
+	}
+	if err := newTestEnv(t, Options{}).n.AdoptTemplate("no_slots.tmpl"); err == nil {
+		t.Error("AdoptTemplate without a templates directory succeeded")
+	}
+}
+
+func TestSlotTemplateSelection(t *testing.T) {
+	const otherServiceID = testServiceID + 1
+	dir := templateDir(t, map[string]string{
+		"slot_message_15728488.tmpl": "Город с инструктором: {{.Time}}",
+		"slot_message_notanid.tmpl":  "ignored",
+	})
+	e := newTestEnv(t, Options{TemplatesDir: dir, ServiceIDs: []int{testServiceID, otherServiceID}})
+	var logs bytes.Buffer
+	e.n.log = logger.New().WithLevel(logger.DebugLevel).WithOutput(&logs)
+
+	slot := func(serviceID int) slots.Slot {
+		return slots.FromTimeslot(serviceID, testStaffID, slottest.At(e.day(1)), e.n.loc)
+	}
+
+	for _, tt := range []struct {
+		name string
+		data slotMessageData
+		want string
+		file string
+	}{
+		{"service template", e.n.slotData(slot(testServiceID)), "Город с инструктором: 10:00", "slot_message_15728488.tmpl"},
+		{"service template over language", func() slotMessageData {
+			d := e.n.slotData(slot(testServiceID))
+			d.Lang = "en"
+			return d
+		}(), "Город с инструктором: 10:00", "slot_message_15728488.tmpl"},
+		{"language template", func() slotMessageData {
+			d := e.n.slotData(slot(otherServiceID))
+			d.Lang = "en"
+			return d
+		}(), "", "slot_message.en.tmpl"},
+		{"generic template", e.n.slotData(slot(otherServiceID)), "🟢 Доступно окно записи", "slot_message.tmpl"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			got := e.n.renderSlotMessage(tt.data)
+			if !strings.HasPrefix(got, tt.want) {
+				t.Errorf("rendered %q, want it to start with %q", got, tt.want)
+			}
+			if !strings.Contains(logs.String(), "templates/"+tt.file) {
+				t.Errorf("debug log %q does not name %s", logs.String(), tt.file)
+			}
+		})
+	}
+
+	if _, ok := e.n.template("templates/slot_message_notanid.tmpl"); ok {
+		t.Error("loaded a per-service template for a non-numeric ID")
+	}
+}
@@ -0,0 +1,62 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// defaultCheckTimeout leaves a tenth of the poll interval as a margin so a
+// slow check ends before the next one is due.
+func defaultCheckTimeout(interval time.Duration) time.Duration {
+	return interval - interval/10
+}
+
+// storageTimeout bounds the storage writes of a check.
+const storageTimeout = 10 * time.Second
+
+// storageContext returns the context for storage writes of a check. It is
+// derived from the run context rather than the check's, which may already
+// have expired during the scan: partial results must still be recorded.
+func storageContext(runCtx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(runCtx, storageTimeout)
+}
+
+// checkPhase names the step a check is in, so a timeout can be attributed.
+type checkPhase struct {
+	name atomic.Value
+}
+
+func (p *checkPhase) set(name string) { p.name.Store(name) }
+
+func (p *checkPhase) get() string {
+	name, _ := p.name.Load().(string)
+	return name
+}
+
+// withCheckTimeout bounds one check by CheckTimeout. When the deadline
+// passes it logs the phase the check was in and records a check_timeout
+// error; pending requests fail and the check goes on with what it gathered.
+func (n *Notifier) withCheckTimeout(ctx context.Context, phase *checkPhase) (context.Context, context.CancelFunc) {
+	if n.opts.CheckTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, n.opts.CheckTimeout)
+	stop := context.AfterFunc(ctx, func() {
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return
+		}
+		n.log.WarnWithFields("Check timed out, processing partial results", logger.Fields{
+			"phase":   phase.get(),
+			"timeout": n.opts.CheckTimeout.String(),
+		})
+		n.recordError("check_timeout")
+	})
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
@@ -0,0 +1,306 @@
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/bot"
+	"github.com/thatguy/moto_gorod-notifier/internal/format"
+)
+
+type currentSlotsData struct {
+	Slots []currentSlot // every distinct start time, earliest first
+	Days  []currentDay
+}
+
+// currentDay groups the /current list by calendar date.
+type currentDay struct {
+	Date  time.Time
+	Slots []currentSlot
+}
+
+// currentSlot is one entry of the /current list. Staff members free at the
+// same time are merged into one entry with StaffCount above one.
+type currentSlot struct {
+	Start      time.Time
+	StaffID    int
+	StaffName  string
+	StaffCount int
+	HasSeats   bool
+	SeatsLeft  int
+}
+
+// String renders the entry the way current_slots.tmpl did when it received
+// preformatted lines, so older external templates using {{.}} keep working.
+func (c currentSlot) String() string {
+	line := fmt.Sprintf("📅 %s (%s) в %s - Сотрудник %s",
+		formatDate(c.Start), format.WeekdayRU(c.Start.Weekday()), formatTime(c.Start), c.StaffName)
+	if c.HasSeats {
+		line += fmt.Sprintf(" (мест: %d)", c.SeatsLeft)
+	}
+	return line
+}
+
+// templateSamples documents the data every template is rendered with.
+// Each template in templateFiles must have an entry; nil means the template
+// takes no data.
+var templateSamples = map[string]interface{}{
+	"templates/slot_message.tmpl": slotMessageData{
+		CompanyName: "Неваляшка", ServiceName: "Город с инструктором", ServiceID: 7, StaffID: 42, StaffName: "Иван",
+		Start: time.Date(2025, 3, 18, 10, 0, 0, 0, time.UTC), Date: "18.03.2025", Time: "10:00", Zone: "MSK", Weekday: "Вт",
+		PriceMin: 2500, PriceMax: 3000, HasSeats: true, SeatsLeft: 3,
+		BookingURL: "https://n841217.yclients.com/", DailyCount: 5, SuggestDigest: true,
+	},
+	"templates/slot_message.en.tmpl": slotMessageData{
+		CompanyName: "Неваляшка", ServiceName: "Город с инструктором", ServiceID: 7, StaffID: 42, StaffName: "Иван",
+		Start: time.Date(2025, 3, 18, 10, 0, 0, 0, time.UTC), Date: "18.03.2025", Time: "10:00", Zone: "MSK", Weekday: "Вт",
+		PriceMin: 2500, PriceMax: 3000, HasSeats: true, SeatsLeft: 3,
+		BookingURL: "https://n841217.yclients.com/", Lang: "en", DailyCount: 5, SuggestDigest: true,
+	},
+	"templates/welcome_message.tmpl": bot.WelcomeView{
+		Subscribed: true, Missed: &bot.MissedSlots{Total: 5, StillFree: 2},
+	},
+	"templates/current_slots.tmpl": func() currentSlotsData {
+		day := time.Date(2025, 3, 18, 0, 0, 0, 0, time.UTC)
+		entries := []currentSlot{
+			{Start: day.Add(10 * time.Hour), StaffID: 42, StaffName: "Иван", StaffCount: 2},
+			{Start: day.Add(12 * time.Hour), StaffID: 42, StaffName: "Иван", StaffCount: 1, HasSeats: true, SeatsLeft: 2},
+		}
+		return currentSlotsData{Slots: entries, Days: []currentDay{{Date: day, Slots: entries}}}
+	}(),
+	"templates/no_slots.tmpl":        nil,
+	"templates/goodbye_message.tmpl": nil,
+	"templates/settings.tmpl": bot.SettingsView{
+		Services: "Город с инструктором", Weekdays: "Пн, Ср", QuietHours: "22:00–08:00",
+		Digest: "раз в день в 19:00", Reminder: "в 20:00", Horizon: "7 дней", Pause: "до 18.03 10:00",
+		Language: "🇷🇺 Русский",
+	},
+	"templates/seats_decrease.tmpl": slotMessageData{
+		ServiceName: "Город с инструктором", StaffID: 42, StaffName: "Иван", Date: "18.03.2025",
+		Time: "10:00", Zone: "MSK", Weekday: "Вт", HasSeats: true, SeatsLeft: 1,
+	},
+	"templates/status.tmpl": bot.SubscriptionView{
+		Subscribed: true, UntilDate: true, ExpiresAt: "25.12.2025", DaysLeft: 7,
+	},
+	"templates/subscription_expiring.tmpl": bot.SubscriptionView{
+		Subscribed: true, UntilDate: true, ExpiresAt: "25.12.2025", DaysLeft: 2,
+	},
+	"templates/subscription_expired.tmpl": nil,
+	"templates/bulk_publication.tmpl":     bulkMessageData{Period: "18–24 марта", Slots: 37, Dates: 7},
+	"templates/overflow_summary.tmpl":     overflowMessageData{More: 93},
+	"templates/pinned_availability.tmpl": pinnedMessageData{
+		Days:      []pinnedDay{{Date: "18.03", Weekday: "Вт", Times: "10:00, 12:00"}},
+		MoreDays:  2,
+		UpdatedAt: time.Date(2025, 3, 18, 9, 30, 0, 0, time.UTC).Format("15:04"),
+	},
+	"templates/slot_taken.tmpl": slotMessageData{
+		ServiceName: "Город с инструктором", StaffID: 42, StaffName: "Иван", Date: "21.06.2025", Time: "15:00",
+	},
+	"templates/evening_reminder.tmpl": reminderMessageData{
+		Date: "19.03", Weekday: "среда", Slots: []string{"10:00 — Город с инструктором"},
+		BookingURL: "https://n841217.yclients.com/",
+	},
+	"templates/besttime.tmpl": bestTimeMessageData{
+		Windows: []string{"вс 19:00–21:00", "пн 10:00–12:00"}, Zone: "MSK",
+	},
+	"templates/weekly_report.tmpl": weeklyReportData{
+		From: time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC), To: time.Date(2025, 3, 18, 0, 0, 0, 0, time.UTC),
+		SlotsDiscovered: 42, NotificationsSent: 310, DeliveryFailures: 2,
+		Subscribers: 57, Subscriptions: 6, Unsubscriptions: 1,
+		Checks: 10080, AvgCheckDuration: 1200 * time.Millisecond,
+	},
+	"templates/digest.tmpl": digestMessageData{
+		Total: 32, Slots: []string{"18.03.2025 (вторник) 10:00 — Город с инструктором"}, More: 2,
+	},
+}
+
+// mojibakeMarkers are sequences produced when UTF-8 text is decoded as
+// Latin-1 or Windows-1251, plus the Unicode replacement character.
+var mojibakeMarkers = []string{"Ð", "Ñ", "Ã", "â€", "ðŸ", "РЎ", "Рѕ", "�"}
+
+// VerifyTemplates checks the templates in use, including overrides from
+// TemplatesDir and per-service slot templates of the configured services:
+// every file is registered, has sample data, renders without error and
+// passes lints.
+func (n *Notifier) VerifyTemplates() []error {
+	read := func(file string) ([]byte, error) {
+		src, _, err := n.readTemplate(file)
+		return src, err
+	}
+	var services []string
+	for _, id := range n.opts.ServiceIDs {
+		file := serviceTemplateFile(id)
+		if _, err := read(file); err == nil {
+			services = append(services, file)
+		}
+	}
+	return verifyTemplates(read, services)
+}
+
+func verifyTemplates(read func(file string) ([]byte, error), services []string) []error {
+	var errs []error
+
+	embedded, err := fs.Glob(templateFS, "templates/*.tmpl")
+	if err != nil {
+		return []error{err}
+	}
+	for _, file := range embedded {
+		if isServiceTemplate(file) {
+			if !containsString(services, file) {
+				services = append(services, file)
+			}
+			continue
+		}
+		if !containsString(templateFiles, file) {
+			errs = append(errs, fmt.Errorf("%s: embedded but not listed in templateFiles", file))
+		}
+	}
+
+	for _, file := range services {
+		src, err := read(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			continue
+		}
+		for _, e := range verifyTemplate(file, string(src), templateSamples["templates/slot_message.tmpl"]) {
+			errs = append(errs, fmt.Errorf("%s: %w", file, e))
+		}
+	}
+
+	for _, file := range templateFiles {
+		sample, ok := templateSamples[file]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: no sample data registered in templateSamples", file))
+			continue
+		}
+		src, err := read(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			continue
+		}
+		for _, e := range verifyTemplate(file, string(src), sample) {
+			errs = append(errs, fmt.Errorf("%s: %w", file, e))
+		}
+	}
+	return errs
+}
+
+func verifyTemplate(file, src string, sample interface{}) []error {
+	var errs []error
+
+	if strings.Contains(src, "\r") {
+		errs = append(errs, fmt.Errorf("contains Windows line endings"))
+	}
+	for _, m := range mojibakeMarkers {
+		if strings.Contains(src, m) {
+			errs = append(errs, fmt.Errorf("contains mojibake sequence %q", m))
+		}
+	}
+
+	t, err := template.New(path.Base(file)).Funcs(templateFuncs()).Option("missingkey=error").Parse(src)
+	if err != nil {
+		return append(errs, fmt.Errorf("parse: %w", err))
+	}
+
+	for _, field := range undeclaredFields(t.Tree.Root, reflect.TypeOf(sample)) {
+		errs = append(errs, fmt.Errorf("references undeclared field .%s", field))
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, sample); err != nil {
+		errs = append(errs, fmt.Errorf("execute: %w", err))
+	} else if strings.TrimSpace(buf.String()) == "" {
+		errs = append(errs, fmt.Errorf("renders empty output"))
+	}
+	return errs
+}
+
+// undeclaredFields walks the parse tree and returns top-level field names
+// that the data type does not have, including ones in branches the sample
+// does not execute. Fields inside range and with blocks refer to a
+// different dot and are not checked.
+func undeclaredFields(node parse.Node, typ reflect.Type) []string {
+	missing := make(map[string]bool)
+	var walk func(parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			// The else branch runs with the outer dot.
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			if !hasField(typ, n.Ident[0]) {
+				missing[n.Ident[0]] = true
+			}
+		}
+	}
+	walk(node)
+
+	names := make([]string, 0, len(missing))
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func hasField(typ reflect.Type, name string) bool {
+	if typ == nil {
+		return false
+	}
+	if _, ok := typ.MethodByName(name); ok {
+		return true
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return typ.Kind() == reflect.Map
+	}
+	_, ok := typ.FieldByName(name)
+	return ok
+}
+
+func containsString(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+)
+
+func readEmbedded(file string) ([]byte, error) {
+	return templateFS.ReadFile(file)
+}
+
+func TestEmbeddedTemplatesRender(t *testing.T) {
+	for _, file := range templateFiles {
+		t.Run(strings.TrimPrefix(file, "templates/"), func(t *testing.T) {
+			sample, ok := templateSamples[file]
+			if !ok {
+				t.Fatal("no sample data registered in templateSamples")
+			}
+			src, err := readEmbedded(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, err := range verifyTemplate(file, string(src), sample) {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestEmbeddedTemplatesRegistered(t *testing.T) {
+	for _, err := range verifyTemplates(readEmbedded, nil) {
+		t.Error(err)
+	}
+}
+
+func TestVerifyTemplateFindsProblems(t *testing.T) {
+	sample := slotMessageData{StaffName: "Иван"}
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"undeclared field", "{{if .Start.IsZero}}{{.StaffNmae}}{{end}}Сотрудник", "undeclared field .StaffNmae"},
+		{"parse error", "{{if .StaffName}}", "parse"},
+		{"unknown function", "{{formatPrice .PriceMin}}", "parse"},
+		{"execute error", "{{formatMoney .StaffName}}", "execute"},
+		{"empty output", "{{if .HasSeats}}Мест: {{.SeatsLeft}}{{end}}", "renders empty output"},
+		{"windows line endings", "Сотрудник: {{.StaffName}}\r\n", "Windows line endings"},
+		{"mojibake", "Ð¡Ð¾Ñ‚Ñ€ÑƒÐ´Ð½Ð¸Ðº: {{.StaffName}}", "mojibake"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := verifyTemplate("templates/test.tmpl", tt.src, sample)
+			for _, err := range errs {
+				if strings.Contains(err.Error(), tt.want) {
+					return
+				}
+			}
+			t.Errorf("errors = %v, want one mentioning %q", errs, tt.want)
+		})
+	}
+}
+
+func TestVerifyTemplateAcceptsValid(t *testing.T) {
+	src := "Сотрудник: {{.StaffName}}{{if .HasSeats}}, мест: {{.SeatsLeft}}{{end}}"
+	if errs := verifyTemplate("templates/test.tmpl", src, slotMessageData{StaffName: "Иван"}); len(errs) != 0 {
+		t.Errorf("errors = %v", errs)
+	}
+}
@@ -0,0 +1,55 @@
+// Package slots defines the bookable slot value passed between the notifier,
+// the bot and message rendering.
+package slots
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
+)
+
+// Slot is one bookable time for a service and staff member.
+type Slot struct {
+	ServiceID int
+	StaffID   int
+	// Start is the slot start in the configured timezone; zero when the API
+	// reported only a bare time.
+	Start time.Time
+	// Raw is the datetime exactly as returned by the API.
+	Raw       string
+	HasSeats  bool
+	SeatsLeft int
+	// PriceMin and PriceMax are the price range with this staff member;
+	// zero when unknown.
+	PriceMin float64
+	PriceMax float64
+}
+
+// FromTimeslot builds a Slot from a yclients timeslot, converting its start
+// to loc.
+func FromTimeslot(serviceID, staffID int, ts yclients.Timeslot, loc *time.Location) Slot {
+	s := Slot{
+		ServiceID: serviceID,
+		StaffID:   staffID,
+		Raw:       ts.Datetime,
+		HasSeats:  ts.HasSeats,
+		SeatsLeft: ts.SeatsLeft,
+	}
+	if !ts.Start.IsZero() {
+		s.Start = ts.Start.In(loc)
+	}
+	return s
+}
+
+// Key identifies the slot in the seen-slots table. It is built from the raw
+// datetime so keys stay stable across timezone changes. A bare "HH:MM"
+// carries no date, so the start is used instead to keep the same time on
+// different dates apart.
+func (s Slot) Key() string {
+	dt := s.Raw
+	if _, err := time.Parse(time.RFC3339, s.Raw); err != nil && !s.Start.IsZero() {
+		dt = s.Start.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("svc=%d|staff=%d|dt=%s", s.ServiceID, s.StaffID, dt)
+}
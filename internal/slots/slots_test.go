@@ -0,0 +1,62 @@
+package slots
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients/yclientstest"
+)
+
+func TestKeyBareTimeOnDifferentDates(t *testing.T) {
+	srv := yclientstest.NewServer()
+	defer srv.Close()
+	srv.Set(yclientstest.SearchTimeslotsPath, yclientstest.Response{Status: http.StatusOK, Body: yclientstest.SearchTimeslotsBare})
+
+	loc := time.FixedZone("UTC+3", 3*3600)
+	c := srv.Client()
+	c.SetLocation(loc)
+
+	var keys []string
+	for _, date := range []string{"2025-03-18", "2025-03-19"} {
+		got, err := c.GetBookableTimeslots(context.Background(), 1, 15728488, date, 2850522)
+		if err != nil {
+			t.Fatalf("GetBookableTimeslots(%s): %v", date, err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("GetBookableTimeslots(%s) returned %d slots, want 1", date, len(got))
+		}
+		keys = append(keys, FromTimeslot(15728488, 2850522, got[0], loc).Key())
+	}
+
+	want := []string{
+		"svc=15728488|staff=2850522|dt=2025-03-18T10:00:00+03:00",
+		"svc=15728488|staff=2850522|dt=2025-03-19T10:00:00+03:00",
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("key %d = %q, want %q", i, keys[i], want[i])
+		}
+	}
+}
+
+func TestKeyUsesRawDatetime(t *testing.T) {
+	loc := time.FixedZone("UTC+5", 5*3600)
+	ts := yclients.Timeslot{
+		Datetime: "2025-03-18T10:00:00+03:00",
+		Start:    time.Date(2025, 3, 18, 7, 0, 0, 0, time.UTC),
+	}
+	got := FromTimeslot(1, 2, ts, loc).Key()
+	if want := "svc=1|staff=2|dt=2025-03-18T10:00:00+03:00"; got != want {
+		t.Errorf("Key() = %q, want %q", got, want)
+	}
+}
+
+func TestKeyBareTimeWithoutStart(t *testing.T) {
+	s := Slot{ServiceID: 1, StaffID: 2, Raw: "10:00"}
+	if got, want := s.Key(), "svc=1|staff=2|dt=10:00"; got != want {
+		t.Errorf("Key() = %q, want %q", got, want)
+	}
+}
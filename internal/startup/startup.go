@@ -0,0 +1,77 @@
+package startup
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// Group runs independent initialization steps concurrently and logs how
+// long each of them took, so total startup time is bounded by the slowest
+// step rather than the sum of all steps.
+type Group struct {
+	log   *logger.Logger
+	start time.Time
+	wg    sync.WaitGroup
+
+	mu        sync.Mutex
+	err       error
+	durations map[string]time.Duration
+}
+
+func NewGroup(log *logger.Logger) *Group {
+	return &Group{
+		log:       log,
+		start:     time.Now(),
+		durations: make(map[string]time.Duration),
+	}
+}
+
+// Go starts an initialization step in its own goroutine.
+func (g *Group) Go(name string, fn func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+
+		started := time.Now()
+		err := fn()
+		took := time.Since(started)
+
+		g.mu.Lock()
+		g.durations[name] = took
+		if err != nil && g.err == nil {
+			g.err = fmt.Errorf("%s: %w", name, err)
+		}
+		g.mu.Unlock()
+
+		fields := logger.Fields{"component": name, "duration": took.String()}
+		if err != nil {
+			g.log.WithError(err).ErrorWithFields("Component initialization failed", fields)
+			return
+		}
+		g.log.InfoWithFields("Component initialized", fields)
+	}()
+}
+
+// Wait blocks until every step has finished and returns the first error.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.log.InfoWithFields("Initialization finished", logger.Fields{
+		"duration": time.Since(g.start).String(),
+		"steps":    len(g.Durations()),
+	})
+	return g.err
+}
+
+// Durations returns how long each finished step took.
+func (g *Group) Durations() map[string]time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := make(map[string]time.Duration, len(g.durations))
+	for k, v := range g.durations {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package startup
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+func TestGroupRunsStepsConcurrently(t *testing.T) {
+	g := NewGroup(logger.New().WithOutput(io.Discard))
+	steps := map[string]time.Duration{
+		"yclients": 150 * time.Millisecond,
+		"telegram": 200 * time.Millisecond,
+		"storage":  100 * time.Millisecond,
+	}
+	var sum, slowest time.Duration
+	started := time.Now()
+	for name, d := range steps {
+		d := d
+		g.Go(name, func() error {
+			time.Sleep(d)
+			return nil
+		})
+		sum += d
+		slowest = max(slowest, d)
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	took := time.Since(started)
+
+	// Near the slowest step, well short of running them one by one.
+	if took < slowest || took >= sum-50*time.Millisecond {
+		t.Errorf("initialization took %s, want about %s (sum %s)", took, slowest, sum)
+	}
+	durations := g.Durations()
+	for name, d := range steps {
+		if got := durations[name]; got < d {
+			t.Errorf("%s took %s, want at least %s", name, got, d)
+		}
+	}
+}
+
+func TestGroupReturnsFirstError(t *testing.T) {
+	g := NewGroup(logger.New().WithOutput(io.Discard))
+	errAuth := errors.New("auth failed")
+	g.Go("yclients", func() error { return errAuth })
+	g.Go("telegram", func() error {
+		time.Sleep(50 * time.Millisecond)
+		return errors.New("later failure")
+	})
+	g.Go("storage", func() error { return nil })
+
+	err := g.Wait()
+	if !errors.Is(err, errAuth) || !strings.HasPrefix(err.Error(), "yclients: ") {
+		t.Errorf("Wait = %v, want the yclients error", err)
+	}
+	// Failed steps still report how long they took.
+	if n := len(g.Durations()); n != 3 {
+		t.Errorf("%d durations recorded, want 3", n)
+	}
+}
@@ -0,0 +1,34 @@
+package status
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/version"
+)
+
+// BuildInfo describes the running binary using data embedded by the Go toolchain.
+func BuildInfo() map[string]string {
+	info := map[string]string{
+		"go_version": runtime.Version(),
+		"version":    version.Version,
+		"commit":     version.Commit,
+		"build_date": version.BuildDate,
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info["module"] = bi.Main.Path
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info["revision"] = s.Value
+		case "vcs.time":
+			info["revision_time"] = s.Value
+		case "vcs.modified":
+			info["dirty"] = s.Value
+		}
+	}
+	return info
+}
@@ -0,0 +1,28 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HealthFunc reports whether the service is doing its job, with details
+// to include in the response.
+type HealthFunc func() (healthy bool, details map[string]interface{})
+
+// HealthHandler serves 200 while check reports healthy and 503 otherwise,
+// so container health checks notice a process that runs but no longer
+// works.
+func HealthHandler(check HealthFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		healthy, details := check()
+		doc := map[string]interface{}{"healthy": healthy}
+		for k, v := range details {
+			doc[k] = v
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(doc)
+	})
+}
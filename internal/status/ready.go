@@ -0,0 +1,54 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Readiness tracks the components that must be up before the service
+// reports itself ready. Components that are not required never block it.
+type Readiness struct {
+	mu       sync.RWMutex
+	required []string
+	ready    map[string]bool
+}
+
+func NewReadiness(required ...string) *Readiness {
+	return &Readiness{required: required, ready: make(map[string]bool)}
+}
+
+// MarkReady records that a component finished initializing.
+func (r *Readiness) MarkReady(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ready[name] = true
+}
+
+// Pending returns required components that are not ready yet.
+func (r *Readiness) Pending() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var pending []string
+	for _, name := range r.required {
+		if !r.ready[name] {
+			pending = append(pending, name)
+		}
+	}
+	return pending
+}
+
+// Handler serves 200 once every required component is ready and 503 with
+// the list of pending components before that.
+func (r *Readiness) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		pending := r.Pending()
+		w.Header().Set("Content-Type", "application/json")
+		if len(pending) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]interface{}{"ready": false, "pending": pending})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"ready": true})
+	})
+}
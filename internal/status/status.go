@@ -0,0 +1,125 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Reporter is implemented by components that expose their runtime state
+// in the /api/status document. A report that is an error is rendered as
+// {"error": "..."} so one failing component doesn't hide the others.
+type Reporter interface {
+	StatusReport(ctx context.Context) interface{}
+}
+
+// ReporterFunc adapts a plain function to the Reporter interface.
+type ReporterFunc func(ctx context.Context) interface{}
+
+func (f ReporterFunc) StatusReport(ctx context.Context) interface{} {
+	return f(ctx)
+}
+
+// Registry collects named reporters and renders them as one JSON document.
+type Registry struct {
+	mu        sync.RWMutex
+	names     []string
+	reporters map[string]Reporter
+}
+
+func NewRegistry() *Registry {
+	return &Registry{reporters: make(map[string]Reporter)}
+}
+
+// Register adds a section to the status document. Registering the same
+// name twice replaces the previous reporter.
+func (r *Registry) Register(name string, reporter Reporter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.reporters[name]; !exists {
+		r.names = append(r.names, name)
+	}
+	r.reporters[name] = reporter
+}
+
+// Section is one named part of the status document.
+type Section struct {
+	Name   string
+	Report interface{}
+}
+
+// Document is the assembled status document. It marshals to a JSON object
+// with generated_at first and the sections in registration order.
+type Document struct {
+	GeneratedAt time.Time
+	Sections    []Section
+}
+
+// Section returns the report registered under name.
+func (d Document) Section(name string) (interface{}, bool) {
+	for _, s := range d.Sections {
+		if s.Name == name {
+			return s.Report, true
+		}
+	}
+	return nil, false
+}
+
+func (d Document) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"generated_at":`)
+	at, _ := json.Marshal(d.GeneratedAt.UTC().Format(time.RFC3339))
+	buf.Write(at)
+	for _, s := range d.Sections {
+		name, _ := json.Marshal(s.Name)
+		report, err := json.Marshal(s.Report)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteByte(',')
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(report)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Collect queries every reporter and returns the assembled document.
+func (r *Registry) Collect(ctx context.Context) Document {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	doc := Document{GeneratedAt: time.Now(), Sections: make([]Section, 0, len(r.names))}
+	for _, name := range r.names {
+		report := r.reporters[name].StatusReport(ctx)
+		if err, ok := report.(error); ok {
+			report = map[string]string{"error": err.Error()}
+		}
+		doc.Sections = append(doc.Sections, Section{Name: name, Report: report})
+	}
+	return doc
+}
+
+// Handler serves the status document as JSON on GET requests.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r.Collect(ctx)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeReporter returns a fixed report and records how often it was asked.
+type fakeReporter struct {
+	report interface{}
+	calls  int
+}
+
+func (f *fakeReporter) StatusReport(ctx context.Context) interface{} {
+	f.calls++
+	return f.report
+}
+
+func TestCollectKeepsRegistrationOrder(t *testing.T) {
+	r := NewRegistry()
+	r.Register("notifier", &fakeReporter{report: "n"})
+	r.Register("yclients", &fakeReporter{report: "y"})
+	r.Register("storage", &fakeReporter{report: "s"})
+	// Re-registering replaces the reporter but keeps its place.
+	r.Register("notifier", &fakeReporter{report: "n2"})
+
+	doc := r.Collect(context.Background())
+	var names []string
+	for _, s := range doc.Sections {
+		names = append(names, s.Name)
+	}
+	if want := []string{"notifier", "yclients", "storage"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("sections = %v, want %v", names, want)
+	}
+	if got, _ := doc.Section("notifier"); got != "n2" {
+		t.Errorf("notifier report = %v, want the replacement", got)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	s := string(data)
+	order := []string{`"generated_at"`, `"notifier"`, `"yclients"`, `"storage"`}
+	for i := 1; i < len(order); i++ {
+		if strings.Index(s, order[i-1]) > strings.Index(s, order[i]) {
+			t.Errorf("%s comes after %s in %s", order[i-1], order[i], s)
+		}
+	}
+}
+
+func TestCollectRendersReporterError(t *testing.T) {
+	r := NewRegistry()
+	r.Register("storage", &fakeReporter{report: errors.New("database is locked")})
+	healthy := &fakeReporter{report: map[string]int{"subscribers": 3}}
+	r.Register("notifier", healthy)
+
+	doc := r.Collect(context.Background())
+	got, _ := doc.Section("storage")
+	if want := map[string]string{"error": "database is locked"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("storage section = %#v, want %#v", got, want)
+	}
+	if healthy.calls != 1 {
+		t.Errorf("notifier reporter called %d times, want 1 despite the storage error", healthy.calls)
+	}
+}
+
+func TestHandlerServesJSONDocument(t *testing.T) {
+	r := NewRegistry()
+	r.Register("build", ReporterFunc(func(context.Context) interface{} {
+		return map[string]string{"version": "v1.2.3"}
+	}))
+	r.Register("telegram", &fakeReporter{report: map[string]interface{}{"reconnects": 2}})
+	r.Register("storage", &fakeReporter{report: errors.New("disk full")})
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body.String(), err)
+	}
+
+	var generatedAt string
+	if err := json.Unmarshal(doc["generated_at"], &generatedAt); err != nil {
+		t.Fatalf("generated_at: %v", err)
+	}
+	if _, err := time.Parse(time.RFC3339, generatedAt); err != nil {
+		t.Errorf("generated_at = %q, want RFC 3339: %v", generatedAt, err)
+	}
+	for name, want := range map[string]string{
+		"build":    `{"version":"v1.2.3"}`,
+		"telegram": `{"reconnects":2}`,
+		"storage":  `{"error":"disk full"}`,
+	} {
+		var got, wantV interface{}
+		if err := json.Unmarshal(doc[name], &got); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		json.Unmarshal([]byte(want), &wantV)
+		if !reflect.DeepEqual(got, wantV) {
+			t.Errorf("%s = %s, want %s", name, doc[name], want)
+		}
+	}
+	if len(doc) != 4 {
+		t.Errorf("document has %d keys, want generated_at and 3 sections", len(doc))
+	}
+}
+
+func TestHandlerRejectsNonGet(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewRegistry().Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/status", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405", rec.Code)
+	}
+}
+
+func TestHandlerPassesDeadlineToReporters(t *testing.T) {
+	r := NewRegistry()
+	var hasDeadline bool
+	r.Register("slow", ReporterFunc(func(ctx context.Context) interface{} {
+		_, hasDeadline = ctx.Deadline()
+		return nil
+	}))
+	r.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	if !hasDeadline {
+		t.Error("reporter context has no deadline")
+	}
+}
+
+func TestReadinessWaitsForRequiredComponents(t *testing.T) {
+	r := NewReadiness("storage", "telegram")
+	get := func() (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode %q: %v", rec.Body.String(), err)
+		}
+		return rec.Code, body
+	}
+
+	code, body := get()
+	if code != http.StatusServiceUnavailable || !reflect.DeepEqual(body["pending"], []interface{}{"storage", "telegram"}) {
+		t.Fatalf("before startup: %d %v, want 503 with both components pending", code, body)
+	}
+
+	// Optional components, like the interface refresh, don't gate readiness.
+	r.MarkReady("storage")
+	r.MarkReady("interface")
+	if code, body = get(); code != http.StatusServiceUnavailable || !reflect.DeepEqual(body["pending"], []interface{}{"telegram"}) {
+		t.Fatalf("storage ready: %d %v, want 503 pending telegram", code, body)
+	}
+
+	r.MarkReady("telegram")
+	if code, body = get(); code != http.StatusOK || body["ready"] != true {
+		t.Errorf("all ready: %d %v, want 200 ready", code, body)
+	}
+}
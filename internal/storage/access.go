@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// IsAuthorized reports whether chatID has passed the invite code check.
+func (s *Storage) IsAuthorized(chatID int64) (_ bool, err error) {
+	defer s.track("is_authorized", time.Now(), &err)
+	var exists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM authorized_users WHERE chat_id = ?)", chatID).Scan(&exists)
+	return exists, err
+}
+
+// Authorize grants chatID access to the bot, remembering the hash of the
+// code it used.
+func (s *Storage) Authorize(chatID int64, code string) (err error) {
+	defer s.track("authorize", time.Now(), &err)
+	if code != "" {
+		code = hashInviteCode(code)
+	}
+	_, err = s.db.Exec("INSERT OR IGNORE INTO authorized_users (chat_id, code) VALUES (?, ?)", chatID, code)
+	return err
+}
+
+// hashInviteCode returns the form invite codes are stored in, so a leaked
+// database doesn't hand out unused codes. Codes are random, so a plain
+// SHA-256 is enough.
+func hashInviteCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateInviteCode generates a one-off invite code and stores its hash.
+func (s *Storage) CreateInviteCode(createdBy int64) (_ string, err error) {
+	defer s.track("create_invite_code", time.Now(), &err)
+	buf := make([]byte, 5)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := base32.StdEncoding.EncodeToString(buf)
+
+	_, err = s.db.Exec("INSERT INTO invite_codes (code, created_by) VALUES (?, ?)", hashInviteCode(code), createdBy)
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// RedeemInviteCode marks an unused one-off code as used by chatID and
+// reports whether the code was valid.
+func (s *Storage) RedeemInviteCode(code string, chatID int64) (_ bool, err error) {
+	defer s.track("redeem_invite_code", time.Now(), &err)
+	res, err := s.db.Exec(
+		"UPDATE invite_codes SET used_by = ?, used_at = CURRENT_TIMESTAMP WHERE code = ? AND used_by IS NULL",
+		chatID, hashInviteCode(code),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInviteCodesStoredHashed(t *testing.T) {
+	s := newTestStorage(t)
+
+	code, err := s.CreateInviteCode(1)
+	if err != nil {
+		t.Fatalf("CreateInviteCode: %v", err)
+	}
+	var stored string
+	if err := s.db.QueryRow("SELECT code FROM invite_codes").Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored == code || stored != hashInviteCode(code) {
+		t.Errorf("stored code %q, want the hash of %q", stored, code)
+	}
+
+	// Codes are matched case- and space-insensitively, once.
+	if ok, err := s.RedeemInviteCode(" "+strings.ToLower(code)+" ", 2); err != nil || !ok {
+		t.Fatalf("RedeemInviteCode = %v, %v; want the code accepted", ok, err)
+	}
+	if ok, err := s.RedeemInviteCode(code, 3); err != nil || ok {
+		t.Errorf("second RedeemInviteCode = %v, %v; want the used code rejected", ok, err)
+	}
+
+	if err := s.Authorize(2, code); err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if err := s.db.QueryRow("SELECT code FROM authorized_users WHERE chat_id = 2").Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != hashInviteCode(code) {
+		t.Errorf("authorized with code %q, want its hash", stored)
+	}
+}
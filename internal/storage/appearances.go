@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// appearanceRetention bounds the slot appearance history; a year is enough
+// to follow seasonal schedule changes.
+const appearanceRetention = 365 * 24 * time.Hour
+
+const appearanceTimeFormat = "2006-01-02 15:04:05"
+
+// AppearanceCount is the number of slots that first appeared in one hour
+// of one weekday.
+type AppearanceCount struct {
+	Weekday time.Weekday
+	Hour    int
+	Count   int
+}
+
+// RecordSlotAppearance logs when a new slot was first noticed.
+func (s *Storage) RecordSlotAppearance(slotKey string, at time.Time) (err error) {
+	defer s.track("record_slot_appearance", time.Now(), &err)
+	_, err = s.db.Exec("INSERT INTO slot_appearances (slot_key, appeared_at) VALUES (?, ?)",
+		slotKey, at.UTC().Format(appearanceTimeFormat))
+	return err
+}
+
+// FirstAppearance returns when the oldest recorded appearance happened.
+func (s *Storage) FirstAppearance() (time.Time, bool, error) {
+	var first sql.NullString
+	if err := s.db.QueryRow("SELECT MIN(appeared_at) FROM slot_appearances").Scan(&first); err != nil {
+		return time.Time{}, false, err
+	}
+	if !first.Valid {
+		return time.Time{}, false, nil
+	}
+	t, err := time.ParseInLocation(appearanceTimeFormat, first.String, time.UTC)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t, true, nil
+}
+
+// AppearanceCounts groups appearances since the given time by weekday and
+// hour, shifted by utcOffset into local time.
+func (s *Storage) AppearanceCounts(since time.Time, utcOffset time.Duration) ([]AppearanceCount, error) {
+	shift := fmt.Sprintf("%+d seconds", int(utcOffset.Seconds()))
+	rows, err := s.db.Query(`SELECT CAST(strftime('%w', appeared_at, ?) AS INTEGER) AS wd,
+			CAST(strftime('%H', appeared_at, ?) AS INTEGER) AS hr, COUNT(*)
+		FROM slot_appearances WHERE appeared_at >= ?
+		GROUP BY wd, hr`,
+		shift, shift, since.UTC().Format(appearanceTimeFormat))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []AppearanceCount
+	for rows.Next() {
+		var c AppearanceCount
+		if err := rows.Scan(&c.Weekday, &c.Hour, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentWritesDoNotLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	// Two handles on one file, like a migration tool running next to
+	// the bot, so the busy timeout is exercised as well.
+	var handles []*Storage
+	for i := 0; i < 2; i++ {
+		s, err := New(path, testLogger())
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		defer s.Close()
+		handles = append(handles, s)
+	}
+
+	const workers, ops = 8, 50
+	ctx := context.Background()
+	errs := make(chan error, workers*ops*2)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			s := handles[w%len(handles)]
+			for i := 0; i < ops; i++ {
+				key := fmt.Sprintf("svc=1|staff=%d|dt=2025-03-18T%02d:00:00+03:00", w, i%24)
+				if err := s.MarkSlotSeen(ctx, ObservedSlot{Key: fmt.Sprintf("%s#%d", key, i), ServiceID: 1, StaffID: w}); err != nil {
+					errs <- fmt.Errorf("MarkSlotSeen: %w", err)
+				}
+				if err := s.AddSubscriber(ctx, int64(w*ops+i)); err != nil {
+					errs <- fmt.Errorf("AddSubscriber: %w", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	subscribers, err := handles[0].GetSubscribers(ctx)
+	if err != nil {
+		t.Fatalf("GetSubscribers: %v", err)
+	}
+	if len(subscribers) != workers*ops {
+		t.Errorf("%d subscribers stored, want %d", len(subscribers), workers*ops)
+	}
+}
+
+func TestPragmas(t *testing.T) {
+	s := newTestStorage(t)
+	var mode string
+	if err := s.db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		t.Fatal(err)
+	}
+	if mode != "wal" {
+		t.Errorf("journal_mode = %q, want wal", mode)
+	}
+	var timeout int
+	if err := s.db.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil {
+		t.Fatal(err)
+	}
+	if timeout != int(busyTimeout.Milliseconds()) {
+		t.Errorf("busy_timeout = %d, want %d", timeout, busyTimeout.Milliseconds())
+	}
+	var fk bool
+	if err := s.db.QueryRow("PRAGMA foreign_keys").Scan(&fk); err != nil {
+		t.Fatal(err)
+	}
+	if !fk {
+		t.Error("foreign keys are off")
+	}
+}
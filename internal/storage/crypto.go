@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// encryptedPrefix marks values written by the encryption layer. Values
+// without it are plaintext written before encryption was enabled.
+const encryptedPrefix = "enc:v1:"
+
+// ErrEncryptionKey is returned when an encrypted value cannot be decrypted,
+// usually because the configured key differs from the one used to write it.
+var ErrEncryptionKey = errors.New("cannot decrypt value: wrong or missing encryption key")
+
+// encryptedColumns lists columns holding personal data that are encrypted
+// at rest. Chat IDs stay plaintext because lookups use them as keys. There
+// is no phone column: the bot never asks for contacts, and YCLIENTS phone
+// numbers are only read from API responses, never stored. A column added
+// for them must be listed here.
+var encryptedColumns = []struct{ table, key, column string }{
+	{"known_users", "chat_id", "username"},
+	{"known_users", "chat_id", "first_name"},
+	{"user_changes", "id", "old_value"},
+	{"user_changes", "id", "new_value"},
+}
+
+// fieldCipher encrypts individual column values with AES-GCM.
+type fieldCipher struct {
+	aead cipher.AEAD
+}
+
+// newFieldCipher derives an AES-256 key from an arbitrary secret.
+// An empty secret returns nil, which stores values as plaintext.
+func newFieldCipher(secret string) (*fieldCipher, error) {
+	if secret == "" {
+		return nil, nil
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &fieldCipher{aead: aead}, nil
+}
+
+func (c *fieldCipher) encrypt(plain string) (string, error) {
+	if c == nil || plain == "" {
+		return plain, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plain), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *fieldCipher) decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	if c == nil {
+		return "", ErrEncryptionKey
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("decode encrypted value: %w", err)
+	}
+	n := c.aead.NonceSize()
+	if len(sealed) < n {
+		return "", ErrEncryptionKey
+	}
+	plain, err := c.aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return "", ErrEncryptionKey
+	}
+	return string(plain), nil
+}
+
+// SetEncryptionKey enables encryption of personal data columns. Existing
+// plaintext values stay readable and are encrypted on their next write.
+func (s *Storage) SetEncryptionKey(secret string) error {
+	c, err := newFieldCipher(secret)
+	if err != nil {
+		return err
+	}
+	s.cipher.Store(c)
+	return nil
+}
+
+// currentCipher returns the cipher for the next read or write; nil means
+// plaintext.
+func (s *Storage) currentCipher() *fieldCipher {
+	return s.cipher.Load()
+}
+
+// RotateEncryptionKey re-encrypts every sensitive column from oldSecret to
+// newSecret in batches of batchSize rows, one transaction per batch.
+// An empty oldSecret encrypts plaintext data; an empty newSecret decrypts it.
+// It returns the number of rows rewritten.
+func (s *Storage) RotateEncryptionKey(oldSecret, newSecret string, batchSize int) (int, error) {
+	oldCipher, err := newFieldCipher(oldSecret)
+	if err != nil {
+		return 0, err
+	}
+	newCipher, err := newFieldCipher(newSecret)
+	if err != nil {
+		return 0, err
+	}
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	total := 0
+	for _, col := range encryptedColumns {
+		var lastKey int64
+		for {
+			n, next, err := s.rotateBatch(col.table, col.key, col.column, lastKey, batchSize, oldCipher, newCipher)
+			total += n
+			if err != nil {
+				return total, fmt.Errorf("rotate %s.%s: %w", col.table, col.column, err)
+			}
+			if next == lastKey {
+				break
+			}
+			lastKey = next
+		}
+		s.log.InfoWithFields("Column re-encrypted", logger.Fields{
+			"table":  col.table,
+			"column": col.column,
+		})
+	}
+	s.cipher.Store(newCipher)
+	return total, nil
+}
+
+// rotateBatch rewrites up to limit rows with key greater than after and
+// returns the number of rows written and the last key seen.
+func (s *Storage) rotateBatch(table, key, column string, after int64, limit int, from, to *fieldCipher) (int, int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, after, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s > ? ORDER BY %s LIMIT ?", key, column, table, key, key),
+		after, limit,
+	)
+	if err != nil {
+		return 0, after, err
+	}
+	type row struct {
+		id    int64
+		value string
+	}
+	var batch []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.value); err != nil {
+			rows.Close()
+			return 0, after, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, after, err
+	}
+
+	last := after
+	written := 0
+	for _, r := range batch {
+		last = r.id
+		plain, err := from.decrypt(r.value)
+		if err != nil {
+			return 0, after, fmt.Errorf("%s %d: %w", key, r.id, err)
+		}
+		value, err := to.encrypt(plain)
+		if err != nil {
+			return 0, after, err
+		}
+		if value == r.value {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", table, column, key), value, r.id); err != nil {
+			return 0, after, err
+		}
+		written++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, after, err
+	}
+	return written, last, nil
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// rawUsername reads the stored username of chatID without decrypting it.
+func rawUsername(t *testing.T, s *Storage, chatID int64) string {
+	t.Helper()
+	var v string
+	if err := s.db.QueryRow("SELECT username FROM known_users WHERE chat_id = ?", chatID).Scan(&v); err != nil {
+		t.Fatalf("read username: %v", err)
+	}
+	return v
+}
+
+func TestFieldCipherRoundTrip(t *testing.T) {
+	c, err := newFieldCipher("secret")
+	if err != nil {
+		t.Fatalf("newFieldCipher: %v", err)
+	}
+	for _, plain := range []string{"rider42", "Иван", "", strings.Repeat("x", 1000)} {
+		sealed, err := c.encrypt(plain)
+		if err != nil {
+			t.Fatalf("encrypt(%q): %v", plain, err)
+		}
+		if plain != "" && (!strings.HasPrefix(sealed, encryptedPrefix) || strings.Contains(sealed, plain)) {
+			t.Errorf("encrypt(%q) = %q, want an opaque enc:v1: value", plain, sealed)
+		}
+		got, err := c.decrypt(sealed)
+		if err != nil || got != plain {
+			t.Errorf("decrypt(encrypt(%q)) = %q, %v", plain, got, err)
+		}
+	}
+
+	a, _ := c.encrypt("rider42")
+	b, _ := c.encrypt("rider42")
+	if a == b {
+		t.Error("encrypting the same value twice gave the same ciphertext")
+	}
+}
+
+func TestFieldCipherWrongKey(t *testing.T) {
+	right, _ := newFieldCipher("right")
+	wrong, _ := newFieldCipher("wrong")
+	sealed, err := right.encrypt("rider42")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		cipher *fieldCipher
+		value  string
+	}{
+		{"wrong key", wrong, sealed},
+		{"missing key", nil, sealed},
+		{"truncated value", right, encryptedPrefix + "AAAA"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cipher.decrypt(tt.value); !errors.Is(err, ErrEncryptionKey) {
+				t.Errorf("decrypt error = %v, want ErrEncryptionKey", err)
+			}
+		})
+	}
+
+	// Plaintext written before encryption was enabled stays readable.
+	if got, err := wrong.decrypt("rider42"); err != nil || got != "rider42" {
+		t.Errorf("decrypt(plaintext) = %q, %v", got, err)
+	}
+}
+
+func TestEncryptedIdentityRoundTrip(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.SetEncryptionKey("secret"); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+	if err := s.TouchUser(1, &UserIdentity{Username: "rider42", FirstName: "Иван"}); err != nil {
+		t.Fatalf("TouchUser: %v", err)
+	}
+	if err := s.TouchUser(1, &UserIdentity{Username: "rider43", FirstName: "Иван"}); err != nil {
+		t.Fatalf("TouchUser: %v", err)
+	}
+
+	if raw := rawUsername(t, s, 1); !strings.HasPrefix(raw, encryptedPrefix) {
+		t.Errorf("stored username = %q, want it encrypted", raw)
+	}
+	u, err := s.GetKnownUser(1)
+	if err != nil {
+		t.Fatalf("GetKnownUser: %v", err)
+	}
+	if u.Identity.Username != "rider43" || u.Identity.FirstName != "Иван" {
+		t.Errorf("identity = %+v, want rider43/Иван", u.Identity)
+	}
+	changes, err := s.UserChanges(1, 10)
+	if err != nil {
+		t.Fatalf("UserChanges: %v", err)
+	}
+	if len(changes) != 1 || changes[0].OldValue != "rider42" || changes[0].NewValue != "rider43" {
+		t.Errorf("changes = %+v, want rider42 -> rider43", changes)
+	}
+
+	if err := s.SetEncryptionKey("other"); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+	if _, err := s.GetKnownUser(1); !errors.Is(err, ErrEncryptionKey) {
+		t.Errorf("GetKnownUser with the wrong key: err = %v, want ErrEncryptionKey", err)
+	}
+	if err := s.SetEncryptionKey(""); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+	if _, err := s.GetKnownUser(1); !errors.Is(err, ErrEncryptionKey) {
+		t.Errorf("GetKnownUser without a key: err = %v, want ErrEncryptionKey", err)
+	}
+}
+
+func TestRotateEncryptionKey(t *testing.T) {
+	s := newTestStorage(t)
+	// Written before encryption was enabled.
+	if err := s.TouchUser(1, &UserIdentity{Username: "plain", FirstName: "Пётр"}); err != nil {
+		t.Fatalf("TouchUser: %v", err)
+	}
+
+	n, err := s.RotateEncryptionKey("", "first", 1)
+	if err != nil {
+		t.Fatalf("RotateEncryptionKey(plaintext -> first): %v", err)
+	}
+	if n != 2 {
+		t.Errorf("rows rewritten = %d, want 2", n)
+	}
+	if raw := rawUsername(t, s, 1); !strings.HasPrefix(raw, encryptedPrefix) {
+		t.Errorf("stored username = %q after encrypting", raw)
+	}
+
+	if _, err := s.RotateEncryptionKey("wrong", "second", 1); !errors.Is(err, ErrEncryptionKey) {
+		t.Errorf("rotating from the wrong key: err = %v, want ErrEncryptionKey", err)
+	}
+	if _, err := s.RotateEncryptionKey("first", "second", 1); err != nil {
+		t.Fatalf("RotateEncryptionKey(first -> second): %v", err)
+	}
+	// The storage now reads with the new key.
+	u, err := s.GetKnownUser(1)
+	if err != nil || u.Identity.Username != "plain" {
+		t.Errorf("GetKnownUser = %+v, %v; want plain", u.Identity, err)
+	}
+
+	if _, err := s.RotateEncryptionKey("second", "", 0); err != nil {
+		t.Fatalf("RotateEncryptionKey(second -> plaintext): %v", err)
+	}
+	if raw := rawUsername(t, s, 1); raw != "plain" {
+		t.Errorf("stored username = %q after decrypting, want plain", raw)
+	}
+}
+
+func TestSetEncryptionKeyConcurrentWithWrites(t *testing.T) {
+	s := newTestStorage(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(chatID int64) {
+			defer wg.Done()
+			s.TouchUser(chatID, &UserIdentity{Username: "rider"})
+			s.GetKnownUser(chatID)
+		}(int64(i + 1))
+		go func() {
+			defer wg.Done()
+			s.SetEncryptionKey("secret")
+		}()
+	}
+	wg.Wait()
+}
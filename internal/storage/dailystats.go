@@ -0,0 +1,60 @@
+package storage
+
+import "time"
+
+// Daily counters aggregated for the weekly admin report.
+const (
+	StatSlotsDiscovered   = "slots_discovered"
+	StatNotificationsSent = "notifications_sent"
+	StatDeliveryFailures  = "delivery_failures"
+	StatSubscriptions     = "subscriptions"
+	StatUnsubscriptions   = "unsubscriptions"
+	StatCheckSeconds      = "check_seconds"
+)
+
+// dailyStatsRetention matches the appearance history: old enough for any
+// report, small enough to not need attention.
+const dailyStatsRetention = appearanceRetention
+
+const dailyStatDayFormat = "2006-01-02"
+
+// DailyStat is the sum of the values recorded for one counter and how many
+// values were recorded, e.g. total check seconds and number of checks.
+type DailyStat struct {
+	Total   float64
+	Samples int
+}
+
+// AddDailyStat adds value to the named counter of the calendar day of t,
+// taken in t's location.
+func (s *Storage) AddDailyStat(t time.Time, name string, value float64) error {
+	_, err := s.db.Exec(`INSERT INTO daily_stats (day, name, total, samples) VALUES (?, ?, ?, 1)
+		ON CONFLICT(day, name) DO UPDATE SET total = total + excluded.total, samples = samples + 1`,
+		t.Format(dailyStatDayFormat), name, value,
+	)
+	return err
+}
+
+// DailyStatsSince sums every counter over the days from the calendar day
+// of from onwards.
+func (s *Storage) DailyStatsSince(from time.Time) (map[string]DailyStat, error) {
+	rows, err := s.db.Query("SELECT name, SUM(total), SUM(samples) FROM daily_stats WHERE day >= ? GROUP BY name",
+		from.Format(dailyStatDayFormat))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[string]DailyStat)
+	for rows.Next() {
+		var (
+			name string
+			st   DailyStat
+		)
+		if err := rows.Scan(&name, &st.Total, &st.Samples); err != nil {
+			return nil, err
+		}
+		stats[name] = st
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,61 @@
+package storage
+
+import "time"
+
+// DigestItem is a slot waiting for the next daily digest.
+type DigestItem struct {
+	SlotKey  string
+	Excerpt  string
+	QueuedAt time.Time
+}
+
+// QueueDigest adds a slot to chatID's next digest; queuing the same slot
+// twice is a no-op.
+func (s *Storage) QueueDigest(chatID int64, slotKey, excerpt string) (err error) {
+	defer s.track("queue_digest", time.Now(), &err)
+	_, err = s.db.Exec(
+		"INSERT OR IGNORE INTO digest_queue (chat_id, slot_key, excerpt, queued_at) VALUES (?, ?, ?, ?)",
+		chatID, slotKey, excerpt, time.Now().UTC(),
+	)
+	return err
+}
+
+// PendingDigests returns queued slots grouped by chat, oldest first.
+func (s *Storage) PendingDigests() (_ map[int64][]DigestItem, err error) {
+	defer s.track("pending_digests", time.Now(), &err)
+	rows, err := s.db.Query("SELECT chat_id, slot_key, excerpt, queued_at FROM digest_queue ORDER BY queued_at, slot_key")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pending := make(map[int64][]DigestItem)
+	for rows.Next() {
+		var (
+			chatID int64
+			item   DigestItem
+		)
+		if err := rows.Scan(&chatID, &item.SlotKey, &item.Excerpt, &item.QueuedAt); err != nil {
+			return nil, err
+		}
+		pending[chatID] = append(pending[chatID], item)
+	}
+	return pending, rows.Err()
+}
+
+// ClearDigest drops everything queued for chatID.
+func (s *Storage) ClearDigest(chatID int64) error {
+	_, err := s.db.Exec("DELETE FROM digest_queue WHERE chat_id = ?", chatID)
+	return err
+}
+
+// MarkDigestSuggested records that chatID was offered digest mode. It
+// reports false if the offer had already been made.
+func (s *Storage) MarkDigestSuggested(chatID int64) (bool, error) {
+	res, err := s.db.Exec("INSERT OR IGNORE INTO digest_suggestions (chat_id) VALUES (?)", chatID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package storage
+
+func freeDiskBytes(path string) (uint64, bool) {
+	return 0, false
+}
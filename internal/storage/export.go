@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ExportVersion is the format version of documents written by Export.
+// Import accepts this version only.
+const ExportVersion = 1
+
+// ErrExportVersion is returned by Import for documents of an unknown
+// format version.
+var ErrExportVersion = errors.New("unsupported export document version")
+
+// exportDocument is the JSON backup written by Export. Slot service IDs
+// and start times are derived from the keys on import, like the
+// migrations do.
+type exportDocument struct {
+	Version       int                 `json:"version"`
+	SchemaVersion int                 `json:"schema_version"`
+	ExportedAt    time.Time           `json:"exported_at"`
+	Subscribers   []exportSubscriber  `json:"subscribers"`
+	SeenSlots     []exportSeenSlot    `json:"seen_slots"`
+	Preferences   []exportPreferences `json:"preferences"`
+}
+
+type exportSubscriber struct {
+	ChatID    int64      `json:"chat_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Kind      string     `json:"kind"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type exportSeenSlot struct {
+	Key       string    `json:"key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportPreferences struct {
+	ChatID          int64      `json:"chat_id"`
+	ServiceIDs      []int      `json:"service_ids,omitempty"`
+	Weekdays        []int      `json:"weekdays,omitempty"`
+	QuietFrom       string     `json:"quiet_from,omitempty"`
+	QuietTo         string     `json:"quiet_to,omitempty"`
+	Digest          bool       `json:"digest,omitempty"`
+	EveningReminder bool       `json:"evening_reminder,omitempty"`
+	HorizonDays     int        `json:"horizon_days,omitempty"`
+	Language        string     `json:"language,omitempty"`
+	MutedUntil      *time.Time `json:"muted_until,omitempty"`
+}
+
+// ImportResult counts the rows Import added. Rows already present are
+// left untouched and not counted.
+type ImportResult struct {
+	Subscribers int
+	SeenSlots   int
+	Preferences int
+}
+
+// Export writes subscribers, seen slots and preferences as a versioned
+// JSON document. The rows are read in one transaction, so the document is
+// a consistent snapshot.
+func (s *Storage) Export(w io.Writer) error {
+	schema, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	doc := exportDocument{
+		Version:       ExportVersion,
+		SchemaVersion: schema,
+		ExportedAt:    time.Now().UTC(),
+		Subscribers:   []exportSubscriber{},
+		SeenSlots:     []exportSeenSlot{},
+		Preferences:   []exportPreferences{},
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := exportSubscribers(tx, &doc); err != nil {
+		return fmt.Errorf("export subscribers: %w", err)
+	}
+	if err := exportSeenSlots(tx, &doc); err != nil {
+		return fmt.Errorf("export seen slots: %w", err)
+	}
+	if err := exportAllPreferences(tx, &doc); err != nil {
+		return fmt.Errorf("export preferences: %w", err)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func exportSubscribers(tx *sql.Tx, doc *exportDocument) error {
+	rows, err := tx.Query("SELECT chat_id, created_at, subscription_kind, expires_at FROM subscribers WHERE unsubscribed_at IS NULL ORDER BY chat_id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			sub       exportSubscriber
+			createdAt sql.NullTime
+			expiresAt sql.NullTime
+		)
+		if err := rows.Scan(&sub.ChatID, &createdAt, &sub.Kind, &expiresAt); err != nil {
+			return err
+		}
+		sub.CreatedAt = createdAt.Time
+		if expiresAt.Valid {
+			t := expiresAt.Time
+			sub.ExpiresAt = &t
+		}
+		doc.Subscribers = append(doc.Subscribers, sub)
+	}
+	return rows.Err()
+}
+
+func exportSeenSlots(tx *sql.Tx, doc *exportDocument) error {
+	rows, err := tx.Query("SELECT slot_key, created_at FROM seen_slots ORDER BY slot_key")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			slot      exportSeenSlot
+			createdAt sql.NullTime
+		)
+		if err := rows.Scan(&slot.Key, &createdAt); err != nil {
+			return err
+		}
+		slot.CreatedAt = createdAt.Time
+		doc.SeenSlots = append(doc.SeenSlots, slot)
+	}
+	return rows.Err()
+}
+
+func exportAllPreferences(tx *sql.Tx, doc *exportDocument) error {
+	rows, err := tx.Query("SELECT chat_id, " + preferenceColumns + " FROM subscriber_preferences ORDER BY chat_id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var chatID int64
+		p, err := scanPreferences(rows, &chatID)
+		if err != nil {
+			return err
+		}
+		e := exportPreferences{
+			ChatID:          chatID,
+			ServiceIDs:      p.ServiceIDs,
+			Weekdays:        p.Weekdays,
+			QuietFrom:       p.QuietFrom,
+			QuietTo:         p.QuietTo,
+			Digest:          p.Digest,
+			EveningReminder: p.EveningReminder,
+			HorizonDays:     p.HorizonDays,
+			Language:        p.Language,
+		}
+		if !p.MutedUntil.IsZero() {
+			t := p.MutedUntil
+			e.MutedUntil = &t
+		}
+		doc.Preferences = append(doc.Preferences, e)
+	}
+	return rows.Err()
+}
+
+// Import adds the rows of a document written by Export. Existing rows win,
+// so importing the same document twice changes nothing. Everything is
+// imported in one transaction.
+func (s *Storage) Import(r io.Reader) (ImportResult, error) {
+	var res ImportResult
+	var doc exportDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return res, fmt.Errorf("decode export document: %w", err)
+	}
+	if doc.Version != ExportVersion {
+		return res, fmt.Errorf("%w: %d (supported: %d)", ErrExportVersion, doc.Version, ExportVersion)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback()
+
+	for _, sub := range doc.Subscribers {
+		kind := sub.Kind
+		if kind == "" {
+			kind = SubscriptionPermanent
+		}
+		var expiresAt interface{}
+		if sub.ExpiresAt != nil {
+			expiresAt = sub.ExpiresAt.UTC()
+		}
+		n, err := insertIgnore(tx,
+			"INSERT OR IGNORE INTO subscribers (chat_id, created_at, subscription_kind, expires_at) VALUES (?, ?, ?, ?)",
+			sub.ChatID, importTime(sub.CreatedAt), kind, expiresAt)
+		if err != nil {
+			return res, fmt.Errorf("import subscriber %d: %w", sub.ChatID, err)
+		}
+		res.Subscribers += n
+	}
+
+	for _, slot := range doc.SeenSlots {
+		var staffID interface{}
+		if o, ok := observedFromKey(slot.Key); ok {
+			staffID = o.StaffID
+		}
+		n, err := insertIgnore(tx,
+			"INSERT OR IGNORE INTO seen_slots (slot_key, created_at, service_id, staff_id, slot_start) VALUES (?, ?, ?, ?, ?)",
+			slot.Key, importTime(slot.CreatedAt), serviceIDFromKey(slot.Key), staffID, slotStartFromKey(slot.Key))
+		if err != nil {
+			return res, fmt.Errorf("import seen slot %q: %w", slot.Key, err)
+		}
+		res.SeenSlots += n
+	}
+
+	for _, p := range doc.Preferences {
+		var mutedUntil interface{}
+		if p.MutedUntil != nil {
+			mutedUntil = p.MutedUntil.UTC()
+		}
+		n, err := insertIgnore(tx, `INSERT OR IGNORE INTO subscriber_preferences
+			(chat_id, services, weekdays, quiet_from, quiet_to, digest, evening_reminder, horizon_days, language, muted_until)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			p.ChatID, joinInts(p.ServiceIDs), joinInts(p.Weekdays), p.QuietFrom, p.QuietTo,
+			p.Digest, p.EveningReminder, p.HorizonDays, p.Language, mutedUntil)
+		if err != nil {
+			return res, fmt.Errorf("import preferences of %d: %w", p.ChatID, err)
+		}
+		res.Preferences += n
+	}
+
+	// Keep the user count consistent with imported subscribers.
+	if _, err := tx.Exec("INSERT OR IGNORE INTO known_users (chat_id) SELECT chat_id FROM subscribers"); err != nil {
+		return res, err
+	}
+	return res, tx.Commit()
+}
+
+// insertIgnore runs an INSERT OR IGNORE and reports whether it added a row.
+func insertIgnore(tx *sql.Tx, query string, args ...interface{}) (int, error) {
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// importTime formats t like CURRENT_TIMESTAMP, which the created_at
+// columns are compared against; a missing timestamp becomes the import
+// time.
+func importTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format(appearanceTimeFormat)
+}
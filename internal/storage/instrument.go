@@ -0,0 +1,33 @@
+package storage
+
+import "time"
+
+// MetricsRecorder receives storage timings. *metrics.Metrics implements
+// it; storage does not depend on the metrics package.
+type MetricsRecorder interface {
+	ObserveStorageQuery(operation string, seconds float64)
+	RecordStorageError(operation string)
+}
+
+// Option configures a Storage created by New.
+type Option func(*Storage)
+
+// WithMetrics reports the duration and failures of storage operations
+// on the notification and update paths to m.
+func WithMetrics(m MetricsRecorder) Option {
+	return func(s *Storage) {
+		s.metrics = m
+	}
+}
+
+// track records an operation that started at start once it returns;
+// call it as defer s.track("operation", time.Now(), &err).
+func (s *Storage) track(operation string, start time.Time, err *error) {
+	if s.metrics == nil {
+		return
+	}
+	s.metrics.ObserveStorageQuery(operation, time.Since(start).Seconds())
+	if *err != nil {
+		s.metrics.RecordStorageError(operation)
+	}
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// vacuumFreeRatio is the share of free pages above which Maintain
+// rebuilds the file with VACUUM.
+const vacuumFreeRatio = 0.2
+
+// ErrIntegrity is returned by Maintain when the integrity check finds
+// problems.
+var ErrIntegrity = errors.New("database integrity check failed")
+
+// MaintenanceResult describes one Maintain run.
+type MaintenanceResult struct {
+	// Integrity is "ok" or the problems reported by the integrity check.
+	Integrity string
+	// FreeRatio is the share of free pages before the run.
+	FreeRatio float64
+	Vacuumed  bool
+	// SizeBefore and SizeAfter are the database and WAL file sizes.
+	SizeBefore int64
+	SizeAfter  int64
+	Duration   time.Duration
+}
+
+// Maintain checks the database integrity, truncates the WAL and, when
+// more than vacuumFreeRatio of the pages are free, rebuilds the file with
+// VACUUM. A failed integrity check stops before anything is rewritten.
+func (s *Storage) Maintain(ctx context.Context) (res MaintenanceResult, err error) {
+	defer s.track("maintain", time.Now(), &err)
+	start := time.Now()
+	res.SizeBefore = s.filesSize()
+	defer func() {
+		res.SizeAfter = s.filesSize()
+		res.Duration = time.Since(start)
+	}()
+
+	problems, err := s.integrityCheck(ctx)
+	if err != nil {
+		return res, fmt.Errorf("integrity check: %w", err)
+	}
+	res.Integrity = strings.Join(problems, "; ")
+	if res.Integrity != "ok" {
+		return res, fmt.Errorf("%w: %s", ErrIntegrity, res.Integrity)
+	}
+
+	var pages, free int64
+	if err := s.db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pages); err != nil {
+		return res, fmt.Errorf("page count: %w", err)
+	}
+	if err := s.db.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&free); err != nil {
+		return res, fmt.Errorf("freelist count: %w", err)
+	}
+	if pages > 0 {
+		res.FreeRatio = float64(free) / float64(pages)
+	}
+
+	if res.FreeRatio > vacuumFreeRatio {
+		if _, err := s.db.ExecContext(ctx, "VACUUM"); err != nil {
+			return res, fmt.Errorf("vacuum: %w", err)
+		}
+		res.Vacuumed = true
+	}
+	if _, err := s.db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return res, fmt.Errorf("wal checkpoint: %w", err)
+	}
+	return res, nil
+}
+
+// integrityCheck returns the rows of PRAGMA integrity_check: a single
+// "ok" for a healthy database.
+func (s *Storage) integrityCheck(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		out = append(out, line)
+	}
+	return out, rows.Err()
+}
+
+// filesSize returns the combined size of the database file and its WAL.
+func (s *Storage) filesSize() int64 {
+	var total int64
+	for _, p := range []string{s.path, s.path + "-wal"} {
+		if fi, err := os.Stat(p); err == nil {
+			total += fi.Size()
+		}
+	}
+	return total
+}
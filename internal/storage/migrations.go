@@ -0,0 +1,508 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// ErrSchemaTooNew is returned by New when the database was migrated by a
+// newer release than this binary knows about.
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary supports")
+
+// migration is one schema change. Migrations run in order of version, each
+// in its own transaction together with its schema_migrations row, so a
+// failed step leaves the database at the previous version.
+type migration struct {
+	version int
+	name    string
+	up      func(s *Storage, tx *sql.Tx) error
+}
+
+// migrations lists every schema change. Append new steps with the next
+// version; never edit or reorder released ones.
+var migrations = []migration{
+	{1, "baseline schema", (*Storage).migrateBaseline},
+	{2, "preference language and mute", (*Storage).migratePreferenceLanguageMute},
+	{3, "notification delivery status", (*Storage).migrateNotificationStatus},
+	{4, "seen slot staff and indexes", (*Storage).migrateSeenSlotStaff},
+	{5, "unsubscribe reason", (*Storage).migrateUnsubscribeReason},
+	{6, "unsubscribe event history", (*Storage).migrateUnsubscribeHistory},
+	{7, "merge unique_users into known_users", (*Storage).migrateMergeUniqueUsers},
+	{8, "soft-delete subscribers", (*Storage).migrateSoftDeleteSubscribers},
+	{9, "delete preferences with their subscriber", (*Storage).migrateCascadePreferences},
+	{10, "hash invite codes", (*Storage).migrateHashInviteCodes},
+}
+
+// latestSchemaVersion is the schema version this binary produces.
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate applies the migrations the database hasn't seen yet.
+func (s *Storage) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL DEFAULT '',
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if latest := latestSchemaVersion(); current > latest {
+		return fmt.Errorf("%w: database is at version %d, binary supports up to %d", ErrSchemaTooNew, current, latest)
+	}
+
+	from := current
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := s.apply(m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		current = m.version
+	}
+
+	s.log.InfoWithFields("Database schema is up to date", logger.Fields{
+		"schema_version": current,
+		"applied":        current - from,
+	})
+	return nil
+}
+
+func (s *Storage) apply(m migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(s, tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.version, m.name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SchemaVersion returns the version of the newest migration applied to the
+// database, or 0 for a database that predates versioning.
+func (s *Storage) SchemaVersion() (int, error) {
+	var version int
+	if err := s.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// migrateBaseline creates the schema as it was before migrations were
+// versioned. Every statement tolerates existing tables and columns, so it
+// also brings databases created by older releases up to date.
+func (s *Storage) migrateBaseline(tx *sql.Tx) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS subscribers (
+			chat_id INTEGER PRIMARY KEY,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS seen_slots (
+			slot_key TEXT PRIMARY KEY,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS unique_users (
+			chat_id INTEGER PRIMARY KEY,
+			first_seen DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`INSERT OR IGNORE INTO unique_users (chat_id) SELECT chat_id FROM subscribers`,
+		`CREATE TABLE IF NOT EXISTS known_users (
+			chat_id INTEGER PRIMARY KEY,
+			username TEXT NOT NULL DEFAULT '',
+			first_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
+			last_seen DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`INSERT OR IGNORE INTO known_users (chat_id, first_seen, last_seen) SELECT chat_id, first_seen, first_seen FROM unique_users`,
+		`CREATE TABLE IF NOT EXISTS subscriber_preferences (
+			chat_id INTEGER PRIMARY KEY,
+			services TEXT NOT NULL DEFAULT '',
+			weekdays TEXT NOT NULL DEFAULT '',
+			quiet_from TEXT NOT NULL DEFAULT '',
+			quiet_to TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS authorized_users (
+			chat_id INTEGER PRIMARY KEY,
+			code TEXT NOT NULL DEFAULT '',
+			authorized_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS invite_codes (
+			code TEXT PRIMARY KEY,
+			created_by INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			used_by INTEGER,
+			used_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS pinned_messages (
+			chat_id INTEGER PRIMARY KEY,
+			message_id INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS notification_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			slot_key TEXT NOT NULL DEFAULT '',
+			sent_at DATETIME NOT NULL,
+			excerpt TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_notification_log_chat ON notification_log (chat_id, sent_at)`,
+		`CREATE TABLE IF NOT EXISTS user_changes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			field TEXT NOT NULL,
+			old_value TEXT NOT NULL DEFAULT '',
+			new_value TEXT NOT NULL DEFAULT '',
+			changed_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_changes_chat ON user_changes (chat_id, changed_at)`,
+		`CREATE TABLE IF NOT EXISTS unsubscribe_events (
+			chat_id INTEGER PRIMARY KEY,
+			unsubscribed_at DATETIME NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS digest_queue (
+			chat_id INTEGER NOT NULL,
+			slot_key TEXT NOT NULL,
+			excerpt TEXT NOT NULL DEFAULT '',
+			queued_at DATETIME NOT NULL,
+			PRIMARY KEY (chat_id, slot_key)
+		)`,
+		`CREATE TABLE IF NOT EXISTS slot_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			taken_at DATETIME NOT NULL,
+			complete INTEGER NOT NULL DEFAULT 1,
+			seeded INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS snapshot_slots (
+			snapshot_id INTEGER NOT NULL,
+			slot_key TEXT NOT NULL,
+			service_id INTEGER NOT NULL,
+			staff_id INTEGER NOT NULL,
+			raw_datetime TEXT NOT NULL,
+			slot_start DATETIME,
+			PRIMARY KEY (snapshot_id, slot_key)
+		)`,
+		// Replaced by slot_snapshots
+		`DROP TABLE IF EXISTS observed_slots`,
+		`CREATE TABLE IF NOT EXISTS app_settings (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS digest_suggestions (
+			chat_id INTEGER PRIMARY KEY,
+			suggested_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS slot_appearances (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			slot_key TEXT NOT NULL,
+			appeared_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_slot_appearances_at ON slot_appearances (appeared_at)`,
+		`CREATE TABLE IF NOT EXISTS delivery_retries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			slot_key TEXT NOT NULL,
+			excerpt TEXT NOT NULL DEFAULT '',
+			message TEXT NOT NULL,
+			digest_offer INTEGER NOT NULL DEFAULT 0,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_retry_at DATETIME NOT NULL,
+			slot_start DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_delivery_retries_next ON delivery_retries (next_retry_at)`,
+		`CREATE TABLE IF NOT EXISTS daily_stats (
+			day TEXT NOT NULL,
+			name TEXT NOT NULL,
+			total REAL NOT NULL DEFAULT 0,
+			samples INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (day, name)
+		)`,
+		`CREATE TABLE IF NOT EXISTS removed_service_notices (
+			chat_id INTEGER NOT NULL,
+			service_id INTEGER NOT NULL,
+			notified_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (chat_id, service_id)
+		)`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("execute migration: %w", err)
+		}
+	}
+
+	columns := []struct{ table, column, definition string }{
+		{"subscribers", "subscription_kind", "TEXT NOT NULL DEFAULT 'permanent'"},
+		{"subscribers", "expires_at", "DATETIME"},
+		{"subscribers", "expiry_warned_at", "DATETIME"},
+		{"known_users", "first_name", "TEXT NOT NULL DEFAULT ''"},
+		{"subscriber_preferences", "digest", "INTEGER NOT NULL DEFAULT 0"},
+		{"subscriber_preferences", "evening_reminder", "INTEGER NOT NULL DEFAULT 0"},
+		{"subscriber_preferences", "horizon_days", "INTEGER NOT NULL DEFAULT 0"},
+		{"seen_slots", "service_id", "INTEGER"},
+		{"seen_slots", "slot_start", "DATETIME"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfMissing(tx, c.table, c.column, c.definition); err != nil {
+			return fmt.Errorf("add column %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	if err := backfillSeenSlotServices(tx); err != nil {
+		return fmt.Errorf("backfill seen slot services: %w", err)
+	}
+	if err := backfillSeenSlotStarts(tx); err != nil {
+		return fmt.Errorf("backfill seen slot starts: %w", err)
+	}
+	if err := s.seedSnapshot(tx); err != nil {
+		return fmt.Errorf("seed availability snapshot: %w", err)
+	}
+	return nil
+}
+
+// addColumnIfMissing adds a column unless it already exists; SQLite has no
+// ADD COLUMN IF NOT EXISTS.
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+func (s *Storage) migratePreferenceLanguageMute(tx *sql.Tx) error {
+	for _, q := range []string{
+		"ALTER TABLE subscriber_preferences ADD COLUMN language TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE subscriber_preferences ADD COLUMN muted_until DATETIME",
+	} {
+		if _, err := tx.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateNotificationStatus lets notification_log hold failed deliveries.
+// Existing rows were all successful.
+func (s *Storage) migrateNotificationStatus(tx *sql.Tx) error {
+	for _, q := range []string{
+		"ALTER TABLE notification_log ADD COLUMN status TEXT NOT NULL DEFAULT 'sent'",
+		"ALTER TABLE notification_log ADD COLUMN error TEXT NOT NULL DEFAULT ''",
+	} {
+		if _, err := tx.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateSeenSlotStaff completes the structured seen_slots columns with
+// staff_id, backfilled from the keys, and indexes the columns queries
+// filter on.
+func (s *Storage) migrateSeenSlotStaff(tx *sql.Tx) error {
+	if _, err := tx.Exec("ALTER TABLE seen_slots ADD COLUMN staff_id INTEGER"); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT slot_key FROM seen_slots")
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if o, ok := observedFromKey(key); ok {
+			if _, err := tx.Exec("UPDATE seen_slots SET staff_id = ? WHERE slot_key = ?", o.StaffID, key); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, q := range []string{
+		"CREATE INDEX IF NOT EXISTS idx_seen_slots_start ON seen_slots (slot_start)",
+		"CREATE INDEX IF NOT EXISTS idx_seen_slots_service ON seen_slots (service_id, slot_start)",
+	} {
+		if _, err := tx.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateUnsubscribeReason keeps why and since when a chat had been
+// subscribed when it unsubscribes. Earlier events have no reason.
+func (s *Storage) migrateUnsubscribeReason(tx *sql.Tx) error {
+	for _, q := range []string{
+		"ALTER TABLE unsubscribe_events ADD COLUMN reason TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE unsubscribe_events ADD COLUMN subscribed_at DATETIME",
+		"CREATE INDEX IF NOT EXISTS idx_unsubscribe_events_at ON unsubscribe_events (unsubscribed_at)",
+	} {
+		if _, err := tx.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateUnsubscribeHistory drops the one-row-per-chat constraint of
+// unsubscribe_events so every unsubscription is kept. SQLite can't drop a
+// primary key, so the table is rebuilt.
+func (s *Storage) migrateUnsubscribeHistory(tx *sql.Tx) error {
+	for _, q := range []string{
+		`CREATE TABLE unsubscribe_events_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			unsubscribed_at DATETIME NOT NULL,
+			reason TEXT NOT NULL DEFAULT '',
+			subscribed_at DATETIME
+		)`,
+		`INSERT INTO unsubscribe_events_new (chat_id, unsubscribed_at, reason, subscribed_at)
+			SELECT chat_id, unsubscribed_at, reason, subscribed_at FROM unsubscribe_events ORDER BY unsubscribed_at`,
+		"DROP TABLE unsubscribe_events",
+		"ALTER TABLE unsubscribe_events_new RENAME TO unsubscribe_events",
+		"CREATE INDEX idx_unsubscribe_events_at ON unsubscribe_events (unsubscribed_at)",
+		"CREATE INDEX idx_unsubscribe_events_chat ON unsubscribe_events (chat_id, unsubscribed_at)",
+	} {
+		if _, err := tx.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateMergeUniqueUsers folds the legacy unique_users table into
+// known_users, which every user count reads, keeping the earlier first
+// sighting of chats found in both.
+func (s *Storage) migrateMergeUniqueUsers(tx *sql.Tx) error {
+	for _, q := range []string{
+		`INSERT INTO known_users (chat_id, first_seen, last_seen)
+			SELECT chat_id, first_seen, first_seen FROM unique_users WHERE true
+			ON CONFLICT(chat_id) DO UPDATE SET first_seen = MIN(known_users.first_seen, excluded.first_seen)`,
+		"DROP TABLE unique_users",
+	} {
+		if _, err := tx.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateSoftDeleteSubscribers marks unsubscribed chats instead of deleting
+// their row, so a chat that comes back keeps its subscription date and its
+// preferences. Chats deleted by earlier releases are restored as
+// unsubscribed from their latest unsubscribe event.
+func (s *Storage) migrateSoftDeleteSubscribers(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "subscribers", "unsubscribed_at", "DATETIME"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "subscribers", "unsubscribe_reason", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	for _, q := range []string{
+		`INSERT INTO subscribers (chat_id, created_at, unsubscribed_at, unsubscribe_reason)
+			SELECT e.chat_id, COALESCE(e.subscribed_at, e.unsubscribed_at), e.unsubscribed_at, e.reason
+			FROM unsubscribe_events e
+			WHERE e.id = (SELECT MAX(id) FROM unsubscribe_events WHERE chat_id = e.chat_id)
+				AND e.chat_id NOT IN (SELECT chat_id FROM subscribers)`,
+		"CREATE INDEX IF NOT EXISTS idx_subscribers_unsubscribed ON subscribers (unsubscribed_at)",
+	} {
+		if _, err := tx.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateCascadePreferences removes a chat's preferences when its
+// subscriber row is deleted. A foreign key would also reject preferences
+// of chats that never subscribed, which the settings menu allows, so a
+// trigger does the cascade instead. Unsubscribing only marks the row, so
+// preferences survive until the row itself is gone.
+func (s *Storage) migrateCascadePreferences(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE TRIGGER IF NOT EXISTS subscribers_delete_preferences
+		AFTER DELETE ON subscribers
+		BEGIN
+			DELETE FROM subscriber_preferences WHERE chat_id = OLD.chat_id;
+		END`)
+	return err
+}
+
+// migrateHashInviteCodes replaces the plaintext invite codes stored by
+// earlier releases with their hashes. SQLite has no SHA-256 function, so
+// the codes are rewritten one by one.
+func (s *Storage) migrateHashInviteCodes(tx *sql.Tx) error {
+	for _, table := range []string{"invite_codes", "authorized_users"} {
+		rows, err := tx.Query("SELECT DISTINCT code FROM " + table + " WHERE code != ''")
+		if err != nil {
+			return err
+		}
+		var codes []string
+		for rows.Next() {
+			var code string
+			if err := rows.Scan(&code); err != nil {
+				rows.Close()
+				return err
+			}
+			codes = append(codes, code)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, code := range codes {
+			if _, err := tx.Exec("UPDATE "+table+" SET code = ? WHERE code = ?", hashInviteCode(code), code); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
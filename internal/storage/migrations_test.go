@@ -0,0 +1,376 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrateEmptyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	version, err := s.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	if version != latestSchemaVersion() {
+		t.Errorf("SchemaVersion = %d, want %d", version, latestSchemaVersion())
+	}
+	var applied int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("%d migrations recorded, want %d", applied, len(migrations))
+	}
+	s.Close()
+
+	// Reopening applies nothing and keeps the version.
+	s, err = New(path, testLogger())
+	if err != nil {
+		t.Fatalf("New on a migrated database: %v", err)
+	}
+	defer s.Close()
+	if v, _ := s.SchemaVersion(); v != version {
+		t.Errorf("SchemaVersion after reopening = %d, want %d", v, version)
+	}
+}
+
+func TestMigrationVersionsAreSequential(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("migration %q has version %d, want %d", m.name, m.version, i+1)
+		}
+	}
+}
+
+// legacySchema is the schema written by releases before migrations were
+// versioned.
+var legacySchema = []string{
+	`CREATE TABLE subscribers (chat_id INTEGER PRIMARY KEY, created_at DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+	`CREATE TABLE seen_slots (slot_key TEXT PRIMARY KEY, created_at DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+	`CREATE TABLE unique_users (chat_id INTEGER PRIMARY KEY, first_seen DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+	`INSERT INTO subscribers (chat_id, created_at) VALUES (5, '2025-01-05 10:00:00')`,
+	`INSERT INTO unique_users (chat_id, first_seen) VALUES (5, '2025-01-05 10:00:00'), (9, '2025-01-09 10:00:00')`,
+	`INSERT INTO seen_slots (slot_key, created_at) VALUES
+		('svc=15728488|staff=2850522|dt=2099-03-18T10:00:00+03:00', '2025-03-01 10:00:00'),
+		('svc=15728488|staff=2850522|dt=12:00', '2025-03-01 10:00:00')`,
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range legacySchema {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	db.Close()
+
+	s, err := New(path, testLogger())
+	if err != nil {
+		t.Fatalf("New on a legacy database: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if v, _ := s.SchemaVersion(); v != latestSchemaVersion() {
+		t.Errorf("SchemaVersion = %d, want %d", v, latestSchemaVersion())
+	}
+	subscribed, err := s.IsSubscribed(ctx, 5)
+	if err != nil || !subscribed {
+		t.Errorf("IsSubscribed(5) = %v, %v; want the legacy subscriber kept", subscribed, err)
+	}
+	if n, err := s.GetUniqueUsersCount(); err != nil || n != 2 {
+		t.Errorf("GetUniqueUsersCount = %d, %v; want 2", n, err)
+	}
+
+	var serviceID sql.NullInt64
+	var staffID sql.NullInt64
+	var start sql.NullTime
+	err = s.db.QueryRow("SELECT service_id, staff_id, slot_start FROM seen_slots WHERE slot_key LIKE '%T10:00:00+03:00'").
+		Scan(&serviceID, &staffID, &start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStart := time.Date(2099, 3, 18, 7, 0, 0, 0, time.UTC)
+	if serviceID.Int64 != 15728488 || staffID.Int64 != 2850522 || !start.Time.Equal(wantStart) {
+		t.Errorf("dated slot backfilled as service %v staff %v start %v", serviceID, staffID, start)
+	}
+	err = s.db.QueryRow("SELECT slot_start FROM seen_slots WHERE slot_key LIKE '%dt=12:00'").Scan(&start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start.Valid {
+		t.Errorf("bare-time slot got start %s, want NULL", start.Time)
+	}
+
+	snap, err := s.LatestSnapshot()
+	if err != nil {
+		t.Fatalf("LatestSnapshot: %v", err)
+	}
+	if !snap.Seeded || len(snap.Slots) != 2 {
+		t.Errorf("snapshot seeded=%v with %d slots, want a seeded snapshot of the 2 seen slots", snap.Seeded, len(snap.Slots))
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := s.db.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, 'from the future')", latestSchemaVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	_, err = New(path, testLogger())
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("New error = %v, want ErrSchemaTooNew", err)
+	}
+}
+
+func TestMigrateUnsubscribeHistoryKeepsEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	// Roll back to version 5, when unsubscribe_events held one row per
+	// chat.
+	for _, q := range []string{
+		"DELETE FROM schema_migrations WHERE version >= 6",
+		"DROP TABLE unsubscribe_events",
+		`CREATE TABLE unsubscribe_events (chat_id INTEGER PRIMARY KEY, unsubscribed_at DATETIME NOT NULL,
+			reason TEXT NOT NULL DEFAULT '', subscribed_at DATETIME)`,
+		"INSERT INTO unsubscribe_events (chat_id, unsubscribed_at, reason) VALUES (3, '2025-03-01 10:00:00', 'user')",
+		"CREATE TABLE unique_users (chat_id INTEGER PRIMARY KEY, first_seen DATETIME DEFAULT CURRENT_TIMESTAMP)",
+	} {
+		if _, err := s.db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	s.Close()
+
+	s, err = New(path, testLogger())
+	if err != nil {
+		t.Fatalf("New after rollback: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	if err := s.AddSubscriber(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveSubscriber(ctx, 3, UnsubscribeExpired); err != nil {
+		t.Fatalf("RemoveSubscriber: %v", err)
+	}
+	var events int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM unsubscribe_events WHERE chat_id = 3").Scan(&events); err != nil {
+		t.Fatal(err)
+	}
+	if events != 2 {
+		t.Errorf("%d unsubscribe events for chat 3, want the migrated one and the new one", events)
+	}
+}
+
+func TestMigrateSoftDeleteRestoresArchivedChats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	// Roll back to version 7, when unsubscribing deleted the row and
+	// only the archive remembered the chat.
+	for _, q := range []string{
+		"DELETE FROM schema_migrations WHERE version >= 8",
+		"DROP INDEX idx_subscribers_unsubscribed",
+		"ALTER TABLE subscribers DROP COLUMN unsubscribed_at",
+		"ALTER TABLE subscribers DROP COLUMN unsubscribe_reason",
+		"INSERT INTO subscribers (chat_id) VALUES (1)",
+		`INSERT INTO unsubscribe_events (chat_id, unsubscribed_at, reason, subscribed_at) VALUES
+			(2, '2025-02-01 10:00:00', 'user', '2025-01-01 10:00:00'),
+			(2, '2025-03-01 10:00:00', 'expired', '2025-02-15 10:00:00')`,
+	} {
+		if _, err := s.db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	s.Close()
+
+	s, err = New(path, testLogger())
+	if err != nil {
+		t.Fatalf("New after rollback: %v", err)
+	}
+	defer s.Close()
+	ids, err := s.GetSubscribers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("GetSubscribers = %v, want only the active chat 1", ids)
+	}
+	var created, reason string
+	if err := s.db.QueryRow("SELECT created_at, unsubscribe_reason FROM subscribers WHERE chat_id = 2 AND unsubscribed_at IS NOT NULL").
+		Scan(&created, &reason); err != nil {
+		t.Fatalf("archived chat 2 not restored: %v", err)
+	}
+	if !strings.HasPrefix(created, "2025-02-15") || reason != UnsubscribeExpired {
+		t.Errorf("restored chat 2 = created %s, reason %q; want the latest event", created, reason)
+	}
+}
+
+func TestMigrateHashesInviteCodes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	// Roll back to version 9, when invite codes were stored as issued.
+	for _, q := range []string{
+		"DELETE FROM schema_migrations WHERE version >= 10",
+		"INSERT INTO invite_codes (code, created_by) VALUES ('UNUSEDCODE', 1), ('USEDCODE12', 1)",
+		"UPDATE invite_codes SET used_by = 2, used_at = CURRENT_TIMESTAMP WHERE code = 'USEDCODE12'",
+		"INSERT INTO authorized_users (chat_id, code) VALUES (2, 'USEDCODE12'), (3, '')",
+	} {
+		if _, err := s.db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	s.Close()
+
+	s, err = New(path, testLogger())
+	if err != nil {
+		t.Fatalf("New after rollback: %v", err)
+	}
+	defer s.Close()
+	var plain int
+	if err := s.db.QueryRow(`SELECT (SELECT COUNT(*) FROM invite_codes WHERE code LIKE '%CODE%')
+		+ (SELECT COUNT(*) FROM authorized_users WHERE code LIKE '%CODE%')`).Scan(&plain); err != nil {
+		t.Fatal(err)
+	}
+	if plain != 0 {
+		t.Errorf("%d plaintext codes left after the migration", plain)
+	}
+	if ok, err := s.RedeemInviteCode("unusedcode", 4); err != nil || !ok {
+		t.Errorf("RedeemInviteCode(unused) = %v, %v; want the migrated code to work", ok, err)
+	}
+	if ok, err := s.RedeemInviteCode("USEDCODE12", 4); err != nil || ok {
+		t.Errorf("RedeemInviteCode(used) = %v, %v; want it rejected", ok, err)
+	}
+	var code string
+	if err := s.db.QueryRow("SELECT code FROM authorized_users WHERE chat_id = 3").Scan(&code); err != nil || code != "" {
+		t.Errorf("code of a chat authorized without one = %q, %v; want it left empty", code, err)
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	s := newTestStorage(t)
+	bad := migration{latestSchemaVersion() + 1, "broken", func(s *Storage, tx *sql.Tx) error {
+		if _, err := tx.Exec("CREATE TABLE half_done (id INTEGER)"); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}}
+	if err := s.apply(bad); err == nil {
+		t.Fatal("apply of a failing migration succeeded")
+	}
+	if v, _ := s.SchemaVersion(); v != latestSchemaVersion() {
+		t.Errorf("SchemaVersion = %d after a failed migration, want %d", v, latestSchemaVersion())
+	}
+	var tables int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Error("the failed migration's table was kept")
+	}
+}
+
+// schemaDump returns the SQL of every table and index in the database.
+func schemaDump(t *testing.T, s *Storage) []string {
+	t.Helper()
+	rows, err := s.db.Query("SELECT name, COALESCE(sql, '') FROM sqlite_master ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var name, def string
+		if err := rows.Scan(&name, &def); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, name+": "+def)
+	}
+	return out
+}
+
+func TestMigrateTwiceIsIdempotent(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	if err := s.AddSubscriber(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	before := schemaDump(t, s)
+
+	if err := s.migrate(); err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+	var applied int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("%d migrations recorded after migrating twice, want %d", applied, len(migrations))
+	}
+	if after := schemaDump(t, s); !reflect.DeepEqual(after, before) {
+		t.Errorf("schema changed by migrating again:\n%q\nwant\n%q", after, before)
+	}
+	if ok, err := s.IsSubscribed(ctx, 7); err != nil || !ok {
+		t.Errorf("IsSubscribed(7) = %v, %v; want the subscriber kept", ok, err)
+	}
+}
+
+func TestMigrationsRecordEachVersion(t *testing.T) {
+	db, err := sql.Open("sqlite3", dsn(filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Storage{db: db, log: testLogger()}
+	defer s.Close()
+	if _, err := s.db.Exec(`CREATE TABLE schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL DEFAULT '',
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range migrations {
+		if err := s.apply(m); err != nil {
+			t.Fatalf("migration %d (%s): %v", m.version, m.name, err)
+		}
+		if v, err := s.SchemaVersion(); err != nil || v != m.version {
+			t.Fatalf("SchemaVersion after %q = %d, %v; want %d", m.name, v, err, m.version)
+		}
+		var name string
+		if err := s.db.QueryRow("SELECT name FROM schema_migrations WHERE version = ?", m.version).Scan(&name); err != nil {
+			t.Fatalf("no schema_migrations row for version %d: %v", m.version, err)
+		}
+		if name != m.name {
+			t.Errorf("version %d recorded as %q, want %q", m.version, name, m.name)
+		}
+	}
+}
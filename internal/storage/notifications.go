@@ -0,0 +1,154 @@
+package storage
+
+import "time"
+
+// Notification statuses in notification_log.
+const (
+	NotificationSent   = "sent"
+	NotificationFailed = "failed"
+)
+
+// maxNotificationError caps the stored delivery error text.
+const maxNotificationError = 500
+
+// DefaultNotificationRetention is how long delivered notifications are kept.
+const DefaultNotificationRetention = 30 * 24 * time.Hour
+
+// NotificationRecord is one delivered notification.
+type NotificationRecord struct {
+	SlotKey string
+	SentAt  time.Time
+	Excerpt string
+}
+
+// NotificationStats summarizes delivery attempts in a period.
+type NotificationStats struct {
+	Sent   int
+	Failed int
+	// Chats is the number of distinct chats that received at least one
+	// notification.
+	Chats int
+}
+
+// SetNotificationRetention sets how long notification_log rows survive CleanOldSlots.
+func (s *Storage) SetNotificationRetention(d time.Duration) {
+	if d > 0 {
+		s.notificationRetention = d
+	}
+}
+
+// LogNotification records a notification delivered to chatID.
+func (s *Storage) LogNotification(chatID int64, slotKey, excerpt string) (err error) {
+	defer s.track("log_notification", time.Now(), &err)
+	_, err = s.db.Exec(
+		"INSERT INTO notification_log (chat_id, slot_key, sent_at, excerpt, status) VALUES (?, ?, ?, ?, ?)",
+		chatID, slotKey, time.Now().UTC(), excerpt, NotificationSent,
+	)
+	return err
+}
+
+// LogNotificationFailure records a failed attempt to notify chatID. Failed
+// rows are kept for auditing and ignored by every other query here.
+func (s *Storage) LogNotificationFailure(chatID int64, slotKey, excerpt string, sendErr error) (err error) {
+	defer s.track("log_notification_failure", time.Now(), &err)
+	msg := ""
+	if sendErr != nil {
+		msg = sendErr.Error()
+		if len(msg) > maxNotificationError {
+			msg = msg[:maxNotificationError]
+		}
+	}
+	_, err = s.db.Exec(
+		"INSERT INTO notification_log (chat_id, slot_key, sent_at, excerpt, status, error) VALUES (?, ?, ?, ?, ?, ?)",
+		chatID, slotKey, time.Now().UTC(), excerpt, NotificationFailed, msg,
+	)
+	return err
+}
+
+// NotificationStats counts delivery attempts at or after since.
+func (s *Storage) NotificationStats(since time.Time) (NotificationStats, error) {
+	var st NotificationStats
+	err := s.db.QueryRow(`SELECT
+			COALESCE(SUM(status = ?), 0),
+			COALESCE(SUM(status = ?), 0),
+			COUNT(DISTINCT CASE WHEN status = ? THEN chat_id END)
+		FROM notification_log WHERE sent_at >= ?`,
+		NotificationSent, NotificationFailed, NotificationSent, since.UTC(),
+	).Scan(&st.Sent, &st.Failed, &st.Chats)
+	return st, err
+}
+
+// RecentNotifications returns the latest notifications sent to chatID, newest first.
+func (s *Storage) RecentNotifications(chatID int64, limit int) ([]NotificationRecord, error) {
+	rows, err := s.db.Query(
+		"SELECT slot_key, sent_at, excerpt FROM notification_log WHERE chat_id = ? AND status = 'sent' ORDER BY sent_at DESC, id DESC LIMIT ?",
+		chatID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []NotificationRecord
+	for rows.Next() {
+		var r NotificationRecord
+		if err := rows.Scan(&r.SlotKey, &r.SentAt, &r.Excerpt); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// CountNotificationsSince returns how many notifications chatID received at or after since.
+func (s *Storage) CountNotificationsSince(chatID int64, since time.Time) (_ int, err error) {
+	defer s.track("count_notifications_since", time.Now(), &err)
+	var count int
+	err = s.db.QueryRow(
+		"SELECT COUNT(*) FROM notification_log WHERE chat_id = ? AND status = 'sent' AND sent_at >= ?",
+		chatID, since.UTC(),
+	).Scan(&count)
+	return count, err
+}
+
+// ChatsNotifiedAbout returns the chats that were sent a notification for slotKey.
+func (s *Storage) ChatsNotifiedAbout(slotKey string) ([]int64, error) {
+	rows, err := s.db.Query("SELECT DISTINCT chat_id FROM notification_log WHERE slot_key = ? AND status = 'sent'", slotKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, err
+		}
+		chats = append(chats, chatID)
+	}
+	return chats, rows.Err()
+}
+
+// NotifiedSlotKeysSince returns the slot keys chatID was notified about at or after since.
+func (s *Storage) NotifiedSlotKeysSince(chatID int64, since time.Time) (_ map[string]bool, err error) {
+	defer s.track("notified_slot_keys_since", time.Now(), &err)
+	rows, err := s.db.Query(
+		"SELECT DISTINCT slot_key FROM notification_log WHERE chat_id = ? AND status = 'sent' AND sent_at >= ?",
+		chatID, since.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys[key] = true
+	}
+	return keys, rows.Err()
+}
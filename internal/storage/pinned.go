@@ -0,0 +1,41 @@
+package storage
+
+// SetPinnedMessage remembers the availability message pinned in chatID.
+func (s *Storage) SetPinnedMessage(chatID int64, messageID int) error {
+	_, err := s.db.Exec(`INSERT INTO pinned_messages (chat_id, message_id) VALUES (?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET message_id = excluded.message_id, created_at = CURRENT_TIMESTAMP`,
+		chatID, messageID)
+	return err
+}
+
+// GetPinnedMessage returns the pinned availability message of chatID, or 0.
+func (s *Storage) GetPinnedMessage(chatID int64) (int, error) {
+	var messageID int
+	err := s.db.QueryRow("SELECT COALESCE(MAX(message_id), 0) FROM pinned_messages WHERE chat_id = ?", chatID).Scan(&messageID)
+	return messageID, err
+}
+
+// GetPinnedMessages returns pinned availability message IDs keyed by chat.
+func (s *Storage) GetPinnedMessages() (map[int64]int, error) {
+	rows, err := s.db.Query("SELECT chat_id, message_id FROM pinned_messages")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pinned := make(map[int64]int)
+	for rows.Next() {
+		var chatID int64
+		var messageID int
+		if err := rows.Scan(&chatID, &messageID); err != nil {
+			return nil, err
+		}
+		pinned[chatID] = messageID
+	}
+	return pinned, rows.Err()
+}
+
+func (s *Storage) RemovePinnedMessage(chatID int64) error {
+	_, err := s.db.Exec("DELETE FROM pinned_messages WHERE chat_id = ?", chatID)
+	return err
+}
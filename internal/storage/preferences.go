@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Preferences holds per-chat notification settings.
+// Zero values mean "no restriction".
+type Preferences struct {
+	ServiceIDs []int // empty means all configured services
+	Weekdays   []int // time.Weekday values; empty means every day
+	QuietFrom  string
+	QuietTo    string
+	Digest     bool // collect new slots into one daily message instead of instant ones
+	// EveningReminder opts in to an evening list of slots still free tomorrow.
+	EveningReminder bool
+	// HorizonDays limits notifications to slots within this many days,
+	// today included; zero means the global lookahead window.
+	HorizonDays int
+	// Language is the chat's message language; empty means the bot's
+	// default.
+	Language string
+	// MutedUntil pauses instant notifications until then; zero means not
+	// muted.
+	MutedUntil time.Time
+}
+
+// DefaultPreferences returns settings used when a chat has not customized anything.
+func DefaultPreferences() Preferences {
+	return Preferences{}
+}
+
+// WithinHorizon reports whether a slot starting at start is inside the
+// horizon as seen at now; both should be in the configured timezone.
+// Slots with an unknown start always are.
+func (p Preferences) WithinHorizon(start, now time.Time) bool {
+	if p.HorizonDays <= 0 || start.IsZero() {
+		return true
+	}
+	y, m, d := now.Date()
+	end := time.Date(y, m, d+p.HorizonDays, 0, 0, 0, 0, now.Location())
+	return start.Before(end)
+}
+
+// Muted reports whether notifications are paused at now.
+func (p Preferences) Muted(now time.Time) bool {
+	return now.Before(p.MutedUntil)
+}
+
+// HasQuietHours reports whether a quiet hours window is configured.
+func (p Preferences) HasQuietHours() bool {
+	return p.QuietFrom != "" && p.QuietTo != ""
+}
+
+// preferenceColumns are the subscriber_preferences columns read by
+// scanPreferences, in order.
+const preferenceColumns = "services, weekdays, quiet_from, quiet_to, digest, evening_reminder, horizon_days, language, muted_until"
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPreferences(row rowScanner, extra ...interface{}) (Preferences, error) {
+	var (
+		services, weekdays, quietFrom, quietTo, language string
+		digest, reminder                                 bool
+		horizon                                          int
+		mutedUntil                                       sql.NullTime
+	)
+	dest := append(extra, &services, &weekdays, &quietFrom, &quietTo, &digest, &reminder, &horizon, &language, &mutedUntil)
+	if err := row.Scan(dest...); err != nil {
+		return DefaultPreferences(), err
+	}
+	prefs := Preferences{
+		ServiceIDs:      splitInts(services),
+		Weekdays:        splitInts(weekdays),
+		QuietFrom:       quietFrom,
+		QuietTo:         quietTo,
+		Digest:          digest,
+		EveningReminder: reminder,
+		HorizonDays:     horizon,
+		Language:        language,
+	}
+	if mutedUntil.Valid {
+		prefs.MutedUntil = mutedUntil.Time
+	}
+	return prefs, nil
+}
+
+// GetPreferences returns the settings of chatID, or DefaultPreferences when
+// it has not customized anything.
+func (s *Storage) GetPreferences(chatID int64) (_ Preferences, err error) {
+	defer s.track("get_preferences", time.Now(), &err)
+	prefs, err := scanPreferences(s.db.QueryRow(
+		"SELECT "+preferenceColumns+" FROM subscriber_preferences WHERE chat_id = ?", chatID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultPreferences(), nil
+	}
+	return prefs, err
+}
+
+func (s *Storage) SetPreferences(chatID int64, prefs Preferences) (err error) {
+	defer s.track("set_preferences", time.Now(), &err)
+	var mutedUntil interface{}
+	if !prefs.MutedUntil.IsZero() {
+		mutedUntil = prefs.MutedUntil.UTC()
+	}
+	_, err = s.db.Exec(`INSERT INTO subscriber_preferences (chat_id, services, weekdays, quiet_from, quiet_to, digest, evening_reminder, horizon_days, language, muted_until, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(chat_id) DO UPDATE SET
+			services = excluded.services,
+			weekdays = excluded.weekdays,
+			quiet_from = excluded.quiet_from,
+			quiet_to = excluded.quiet_to,
+			digest = excluded.digest,
+			evening_reminder = excluded.evening_reminder,
+			horizon_days = excluded.horizon_days,
+			language = excluded.language,
+			muted_until = excluded.muted_until,
+			updated_at = excluded.updated_at`,
+		chatID, joinInts(prefs.ServiceIDs), joinInts(prefs.Weekdays), prefs.QuietFrom, prefs.QuietTo, prefs.Digest, prefs.EveningReminder, prefs.HorizonDays,
+		prefs.Language, mutedUntil,
+	)
+	return err
+}
+
+// AllPreferences returns the stored preferences of every chat that has any.
+func (s *Storage) AllPreferences() (_ map[int64]Preferences, err error) {
+	defer s.track("all_preferences", time.Now(), &err)
+	rows, err := s.db.Query("SELECT chat_id, " + preferenceColumns + " FROM subscriber_preferences")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := make(map[int64]Preferences)
+	for rows.Next() {
+		var chatID int64
+		prefs, err := scanPreferences(rows, &chatID)
+		if err != nil {
+			return nil, err
+		}
+		all[chatID] = prefs
+	}
+	return all, rows.Err()
+}
+
+func (s *Storage) ResetPreferences(chatID int64) (err error) {
+	defer s.track("reset_preferences", time.Now(), &err)
+	_, err = s.db.Exec("DELETE FROM subscriber_preferences WHERE chat_id = ?", chatID)
+	return err
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, strconv.Itoa(v))
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitInts(s string) []int {
+	if s == "" {
+		return nil
+	}
+	var out []int
+	for _, p := range strings.Split(s, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(p)); err == nil {
+			out = append(out, n)
+		}
+	}
+	return out
+}
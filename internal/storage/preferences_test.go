@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetPreferencesDefaults(t *testing.T) {
+	s := newTestStorage(t)
+	prefs, err := s.GetPreferences(42)
+	if err != nil {
+		t.Fatalf("GetPreferences: %v", err)
+	}
+	if !reflect.DeepEqual(prefs, DefaultPreferences()) {
+		t.Errorf("GetPreferences = %+v, want defaults", prefs)
+	}
+}
+
+func TestSetPreferencesRoundTrip(t *testing.T) {
+	s := newTestStorage(t)
+	want := Preferences{
+		ServiceIDs:      []int{15728488, 15728490},
+		Weekdays:        []int{int(time.Saturday), int(time.Sunday)},
+		QuietFrom:       "22:00",
+		QuietTo:         "08:00",
+		Digest:          true,
+		EveningReminder: true,
+		HorizonDays:     3,
+		Language:        "en",
+		MutedUntil:      time.Date(2025, 3, 18, 9, 30, 0, 0, time.UTC),
+	}
+	if err := s.SetPreferences(42, want); err != nil {
+		t.Fatalf("SetPreferences: %v", err)
+	}
+	got, err := s.GetPreferences(42)
+	if err != nil {
+		t.Fatalf("GetPreferences: %v", err)
+	}
+	if !got.MutedUntil.Equal(want.MutedUntil) {
+		t.Errorf("MutedUntil = %s, want %s", got.MutedUntil, want.MutedUntil)
+	}
+	got.MutedUntil, want.MutedUntil = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetPreferences = %+v, want %+v", got, want)
+	}
+}
+
+func TestSetPreferencesOverwrites(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.SetPreferences(42, Preferences{ServiceIDs: []int{1}, Digest: true, MutedUntil: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPreferences(42, Preferences{Weekdays: []int{1}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetPreferences(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Preferences{Weekdays: []int{1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetPreferences = %+v, want %+v", got, want)
+	}
+}
+
+func TestResetPreferences(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.SetPreferences(42, Preferences{Digest: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResetPreferences(42); err != nil {
+		t.Fatalf("ResetPreferences: %v", err)
+	}
+	got, err := s.GetPreferences(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, DefaultPreferences()) {
+		t.Errorf("GetPreferences after reset = %+v, want defaults", got)
+	}
+}
+
+func TestAllPreferences(t *testing.T) {
+	s := newTestStorage(t)
+	stored := map[int64]Preferences{
+		1: {Digest: true},
+		2: {ServiceIDs: []int{7}, HorizonDays: 2},
+	}
+	for chatID, p := range stored {
+		if err := s.SetPreferences(chatID, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	all, err := s.AllPreferences()
+	if err != nil {
+		t.Fatalf("AllPreferences: %v", err)
+	}
+	if !reflect.DeepEqual(all, stored) {
+		t.Errorf("AllPreferences = %+v, want %+v", all, stored)
+	}
+}
+
+// Preferences outlive a subscription on purpose: /stop followed by /start
+// must not lose the chat's filters.
+func TestPreferencesSurviveUnsubscribe(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	if err := s.AddSubscriber(ctx, 42); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPreferences(42, Preferences{Digest: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveSubscriber(ctx, 42, UnsubscribeByUser); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetPreferences(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Digest {
+		t.Error("preferences were dropped with the subscription")
+	}
+}
+
+func TestDeletingSubscriberDeletesPreferences(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	for _, chatID := range []int64{42, 43} {
+		if err := s.AddSubscriber(ctx, chatID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetPreferences(chatID, Preferences{Digest: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.db.Exec("DELETE FROM subscribers WHERE chat_id = 42"); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := s.AllPreferences()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := all[42]; ok {
+		t.Error("preferences of the deleted subscriber are still stored")
+	}
+	if !all[43].Digest {
+		t.Error("preferences of another subscriber were deleted")
+	}
+}
+
+func TestPreferencesWithinHorizon(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*3600)
+	now := time.Date(2025, 3, 18, 23, 0, 0, 0, loc)
+	tests := []struct {
+		name    string
+		horizon int
+		start   time.Time
+		want    bool
+	}{
+		{"no horizon", 0, now.AddDate(0, 0, 30), true},
+		{"unknown start", 1, time.Time{}, true},
+		{"today", 1, time.Date(2025, 3, 18, 23, 30, 0, 0, loc), true},
+		{"tomorrow with one day", 1, time.Date(2025, 3, 19, 0, 30, 0, 0, loc), false},
+		{"tomorrow with two days", 2, time.Date(2025, 3, 19, 18, 0, 0, 0, loc), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Preferences{HorizonDays: tt.horizon}
+			if got := p.WithinHorizon(tt.start, now); got != tt.want {
+				t.Errorf("WithinHorizon = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreferencesMutedAndQuietHours(t *testing.T) {
+	now := time.Date(2025, 3, 18, 12, 0, 0, 0, time.UTC)
+	if (Preferences{}).Muted(now) {
+		t.Error("zero MutedUntil is muted")
+	}
+	if !(Preferences{MutedUntil: now.Add(time.Minute)}).Muted(now) {
+		t.Error("chat muted until later is not muted")
+	}
+	if (Preferences{MutedUntil: now}).Muted(now) {
+		t.Error("mute still active at its end")
+	}
+	if (Preferences{QuietFrom: "22:00"}).HasQuietHours() {
+		t.Error("half a quiet hours window counts as configured")
+	}
+	if !(Preferences{QuietFrom: "22:00", QuietTo: "08:00"}).HasQuietHours() {
+		t.Error("quiet hours window not detected")
+	}
+}
+
+func TestSplitInts(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int
+	}{
+		{"", nil},
+		{"1", []int{1}},
+		{"1,2, 3", []int{1, 2, 3}},
+		{"1,x,3", []int{1, 3}},
+	}
+	for _, tt := range tests {
+		if got := splitInts(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitInts(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	if got := joinInts([]int{1, 2, 3}); got != "1,2,3" {
+		t.Errorf("joinInts = %q, want 1,2,3", got)
+	}
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DeliveryRetry is a notification that failed to send and waits for
+// another attempt. SlotStart is zero when the slot key carries no date.
+type DeliveryRetry struct {
+	ID          int64
+	ChatID      int64
+	SlotKey     string
+	Excerpt     string
+	Message     string
+	DigestOffer bool
+	Attempts    int
+	NextRetryAt time.Time
+	SlotStart   time.Time
+}
+
+// QueueRetry stores a failed delivery for a retry at r.NextRetryAt.
+func (s *Storage) QueueRetry(r DeliveryRetry) (err error) {
+	defer s.track("queue_retry", time.Now(), &err)
+	_, err = s.db.Exec(
+		`INSERT INTO delivery_retries (chat_id, slot_key, excerpt, message, digest_offer, attempts, next_retry_at, slot_start)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.ChatID, r.SlotKey, r.Excerpt, r.Message, r.DigestOffer, r.Attempts, r.NextRetryAt.UTC(), slotStartFromKey(r.SlotKey),
+	)
+	return err
+}
+
+// DueRetries returns up to limit deliveries whose retry time has come,
+// oldest first.
+func (s *Storage) DueRetries(now time.Time, limit int) (_ []DeliveryRetry, err error) {
+	defer s.track("due_retries", time.Now(), &err)
+	rows, err := s.db.Query(
+		`SELECT id, chat_id, slot_key, excerpt, message, digest_offer, attempts, next_retry_at, slot_start
+		FROM delivery_retries WHERE next_retry_at <= ? ORDER BY next_retry_at, id LIMIT ?`,
+		now.UTC(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []DeliveryRetry
+	for rows.Next() {
+		var (
+			r     DeliveryRetry
+			start sql.NullTime
+		)
+		if err := rows.Scan(&r.ID, &r.ChatID, &r.SlotKey, &r.Excerpt, &r.Message, &r.DigestOffer, &r.Attempts, &r.NextRetryAt, &start); err != nil {
+			return nil, err
+		}
+		if start.Valid {
+			r.SlotStart = start.Time
+		}
+		due = append(due, r)
+	}
+	return due, rows.Err()
+}
+
+// RescheduleRetry records another failed attempt.
+func (s *Storage) RescheduleRetry(id int64, attempts int, next time.Time) error {
+	_, err := s.db.Exec("UPDATE delivery_retries SET attempts = ?, next_retry_at = ? WHERE id = ?", attempts, next.UTC(), id)
+	return err
+}
+
+// DeleteRetry removes a delivery from the queue once it was sent or
+// abandoned.
+func (s *Storage) DeleteRetry(id int64) error {
+	_, err := s.db.Exec("DELETE FROM delivery_retries WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// serviceIDFromKey extracts the service ID from a "svc=<id>|..." slot key.
+func serviceIDFromKey(slotKey string) sql.NullInt64 {
+	rest, ok := strings.CutPrefix(slotKey, "svc=")
+	if !ok {
+		return sql.NullInt64{}
+	}
+	if i := strings.IndexByte(rest, '|'); i >= 0 {
+		rest = rest[:i]
+	}
+	id, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: id, Valid: true}
+}
+
+// backfillSeenSlotServices fills service_id for rows stored before the
+// column existed.
+func backfillSeenSlotServices(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT slot_key FROM seen_slots WHERE service_id IS NULL")
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if id := serviceIDFromKey(key); id.Valid {
+			if _, err := tx.Exec("UPDATE seen_slots SET service_id = ? WHERE slot_key = ?", id, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SeenSlotServiceIDs returns the distinct services that have seen slots.
+func (s *Storage) SeenSlotServiceIDs() ([]int, error) {
+	rows, err := s.db.Query("SELECT DISTINCT service_id FROM seen_slots WHERE service_id IS NOT NULL ORDER BY service_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PurgeSeenSlots forgets seen slots of the given services and returns how
+// many were removed.
+func (s *Storage) PurgeSeenSlots(serviceIDs []int) (int64, error) {
+	var removed int64
+	for _, id := range serviceIDs {
+		res, err := s.db.Exec("DELETE FROM seen_slots WHERE service_id = ?", id)
+		if err != nil {
+			return removed, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+	return removed, nil
+}
+
+// MarkRemovedServiceNoticed records that chatID was told serviceID is no
+// longer tracked. It reports false if the chat had already been told.
+func (s *Storage) MarkRemovedServiceNoticed(chatID int64, serviceID int) (bool, error) {
+	res, err := s.db.Exec("INSERT OR IGNORE INTO removed_service_notices (chat_id, service_id) VALUES (?, ?)", chatID, serviceID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
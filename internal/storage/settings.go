@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// GetSetting returns an application-wide value stored under key.
+func (s *Storage) GetSetting(key string) (string, bool, error) {
+	var value string
+	err := s.db.QueryRow("SELECT value FROM app_settings WHERE key = ?", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// SetSetting stores an application-wide value under key.
+func (s *Storage) SetSetting(key, value string) error {
+	_, err := s.db.Exec(`INSERT INTO app_settings (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`, key, value)
+	return err
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// slotStartFromKey extracts the start time from the "dt=" part of a slot
+// key, formatted for the slot_start column. Keys holding a bare "HH:MM"
+// have no date and yield NULL.
+func slotStartFromKey(slotKey string) sql.NullString {
+	i := strings.Index(slotKey, "|dt=")
+	if i < 0 {
+		return sql.NullString{}
+	}
+	t, err := time.Parse(time.RFC3339, slotKey[i+len("|dt="):])
+	if err != nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(appearanceTimeFormat), Valid: true}
+}
+
+// backfillSeenSlotStarts fills slot_start for dated rows stored before the
+// column existed.
+func backfillSeenSlotStarts(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT slot_key FROM seen_slots WHERE slot_start IS NULL AND slot_key LIKE '%|dt=____-__-__T%'")
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if start := slotStartFromKey(key); start.Valid {
+			if _, err := tx.Exec("UPDATE seen_slots SET slot_start = ? WHERE slot_key = ?", start, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
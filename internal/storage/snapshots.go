@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// snapshotRetention is how many availability snapshots are kept; only the
+// latest one is diffed against, the rest help when investigating reports.
+const snapshotRetention = 5
+
+// ObservedSlot is one bookable slot recorded in an availability snapshot.
+type ObservedSlot struct {
+	Key       string
+	ServiceID int
+	StaffID   int
+	Raw       string    // datetime as returned by the API
+	Start     time.Time // zero when only a bare time was known
+}
+
+// Snapshot is the set of slots bookable after one check.
+type Snapshot struct {
+	ID      int64 // zero when no snapshot has been saved yet
+	TakenAt time.Time
+	// Complete is false when some requests of the check failed, so slots
+	// missing from it may still be bookable.
+	Complete bool
+	// Seeded marks the snapshot built from seen_slots on upgrade. It holds
+	// every slot ever announced rather than what was bookable, so slots
+	// missing from the next check were not necessarily just taken.
+	Seeded bool
+	Slots  []ObservedSlot
+}
+
+// LatestSnapshot returns the most recently saved snapshot, or a zero
+// Snapshot when there is none.
+func (s *Storage) LatestSnapshot() (_ Snapshot, err error) {
+	defer s.track("latest_snapshot", time.Now(), &err)
+	var snap Snapshot
+	err = s.db.QueryRow("SELECT id, taken_at, complete, seeded FROM slot_snapshots ORDER BY id DESC LIMIT 1").
+		Scan(&snap.ID, &snap.TakenAt, &snap.Complete, &snap.Seeded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Snapshot{}, nil
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	rows, err := s.db.Query("SELECT slot_key, service_id, staff_id, raw_datetime, slot_start FROM snapshot_slots WHERE snapshot_id = ?", snap.ID)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			o     ObservedSlot
+			start sql.NullTime
+		)
+		if err := rows.Scan(&o.Key, &o.ServiceID, &o.StaffID, &o.Raw, &start); err != nil {
+			return Snapshot{}, err
+		}
+		if start.Valid {
+			o.Start = start.Time
+		}
+		snap.Slots = append(snap.Slots, o)
+	}
+	return snap, rows.Err()
+}
+
+// SaveSnapshot stores the slots bookable after a check taken at at and
+// prunes snapshots beyond the retention count.
+func (s *Storage) SaveSnapshot(at time.Time, complete bool, observed []ObservedSlot) (err error) {
+	defer s.track("save_snapshot", time.Now(), &err)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := writeSnapshot(tx, at, complete, false, observed); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func writeSnapshot(tx *sql.Tx, at time.Time, complete, seeded bool, observed []ObservedSlot) error {
+	res, err := tx.Exec("INSERT INTO slot_snapshots (taken_at, complete, seeded) VALUES (?, ?, ?)", at.UTC(), complete, seeded)
+	if err != nil {
+		return fmt.Errorf("insert snapshot: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO snapshot_slots
+		(snapshot_id, slot_key, service_id, staff_id, raw_datetime, slot_start) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, o := range observed {
+		var start interface{}
+		if !o.Start.IsZero() {
+			start = o.Start.UTC()
+		}
+		if _, err := stmt.Exec(id, o.Key, o.ServiceID, o.StaffID, o.Raw, start); err != nil {
+			return fmt.Errorf("insert snapshot slot: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM slot_snapshots WHERE id <= ?", id-snapshotRetention); err != nil {
+		return fmt.Errorf("prune snapshots: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM snapshot_slots WHERE snapshot_id <= ?", id-snapshotRetention); err != nil {
+		return fmt.Errorf("prune snapshot slots: %w", err)
+	}
+	return nil
+}
+
+// seedSnapshot builds the first snapshot from seen_slots when upgrading
+// from the seen-slot bookkeeping, so slots announced before the upgrade
+// are not announced again. Slots that already started are left out.
+func (s *Storage) seedSnapshot(tx *sql.Tx) error {
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM slot_snapshots)").Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	rows, err := tx.Query("SELECT slot_key FROM seen_slots WHERE slot_start IS NULL OR slot_start >= ?",
+		now.Format(appearanceTimeFormat))
+	if err != nil {
+		return err
+	}
+	var observed []ObservedSlot
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return err
+		}
+		if o, ok := observedFromKey(key); ok {
+			observed = append(observed, o)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(observed) == 0 {
+		return nil
+	}
+
+	if err := writeSnapshot(tx, now, true, true, observed); err != nil {
+		return err
+	}
+	s.log.InfoWithFields("Seeded availability snapshot from seen slots", logger.Fields{
+		"slots": len(observed),
+	})
+	return nil
+}
+
+// observedFromKey rebuilds a snapshot entry from a "svc=|staff=|dt=" slot
+// key.
+func observedFromKey(slotKey string) (ObservedSlot, bool) {
+	parts := strings.SplitN(slotKey, "|", 3)
+	if len(parts) != 3 {
+		return ObservedSlot{}, false
+	}
+	svc, ok1 := strings.CutPrefix(parts[0], "svc=")
+	staff, ok2 := strings.CutPrefix(parts[1], "staff=")
+	raw, ok3 := strings.CutPrefix(parts[2], "dt=")
+	if !ok1 || !ok2 || !ok3 {
+		return ObservedSlot{}, false
+	}
+	serviceID, err := strconv.Atoi(svc)
+	if err != nil {
+		return ObservedSlot{}, false
+	}
+	staffID, err := strconv.Atoi(staff)
+	if err != nil {
+		return ObservedSlot{}, false
+	}
+	o := ObservedSlot{Key: slotKey, ServiceID: serviceID, StaffID: staffID, Raw: raw}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		o.Start = t
+	}
+	return o, true
+}
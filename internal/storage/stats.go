@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Stats is a breakdown of what the database holds.
+type Stats struct {
+	Subscribers int
+	// SubscribersAdded7d and SubscribersAdded30d count current subscribers
+	// that subscribed within the last 7 and 30 days.
+	SubscribersAdded7d  int
+	SubscribersAdded30d int
+	KnownUsers          int
+	// Churned7d and Churned30d count chats that unsubscribed within the
+	// last 7 and 30 days and have not subscribed again.
+	Churned7d  int
+	Churned30d int
+
+	SeenSlots int
+	// SeenSlotsByService counts seen slots per service; slots whose key
+	// names no service are left out.
+	SeenSlotsByService map[int]int
+
+	// NotificationsToday and NotificationsThisWeek count delivered
+	// notifications since midnight and since Monday midnight, in the
+	// location of the time passed to DetailedStats.
+	NotificationsToday    int
+	NotificationsThisWeek int
+
+	// FileSizeBytes is the size of the database file, without the WAL;
+	// zero for in-memory databases.
+	FileSizeBytes int64
+}
+
+// DetailedStats collects Stats as of now.
+func (s *Storage) DetailedStats(ctx context.Context, now time.Time) (_ Stats, err error) {
+	defer s.track("detailed_stats", time.Now(), &err)
+	st := Stats{SeenSlotsByService: make(map[int]int)}
+
+	utc := now.UTC()
+	err = s.db.QueryRowContext(ctx, `SELECT COUNT(*),
+			COALESCE(SUM(created_at >= ?), 0),
+			COALESCE(SUM(created_at >= ?), 0)
+		FROM subscribers WHERE unsubscribed_at IS NULL`,
+		utc.AddDate(0, 0, -7).Format(appearanceTimeFormat),
+		utc.AddDate(0, 0, -30).Format(appearanceTimeFormat),
+	).Scan(&st.Subscribers, &st.SubscribersAdded7d, &st.SubscribersAdded30d)
+	if err != nil {
+		return st, err
+	}
+	err = s.db.QueryRowContext(ctx, `SELECT
+			COALESCE(SUM(unsubscribed_at >= ?), 0),
+			COALESCE(SUM(unsubscribed_at >= ?), 0)
+		FROM subscribers WHERE unsubscribed_at IS NOT NULL`,
+		utc.AddDate(0, 0, -7).Format(appearanceTimeFormat),
+		utc.AddDate(0, 0, -30).Format(appearanceTimeFormat),
+	).Scan(&st.Churned7d, &st.Churned30d)
+	if err != nil {
+		return st, err
+	}
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM known_users").Scan(&st.KnownUsers); err != nil {
+		return st, err
+	}
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM seen_slots").Scan(&st.SeenSlots); err != nil {
+		return st, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT service_id, COUNT(*) FROM seen_slots WHERE service_id IS NOT NULL GROUP BY service_id")
+	if err != nil {
+		return st, err
+	}
+	for rows.Next() {
+		var id, count int
+		if err := rows.Scan(&id, &count); err != nil {
+			rows.Close()
+			return st, err
+		}
+		st.SeenSlotsByService[id] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return st, err
+	}
+
+	y, m, d := now.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	monday := midnight.AddDate(0, 0, -((int(now.Weekday()) + 6) % 7))
+	err = s.db.QueryRowContext(ctx, `SELECT
+			COALESCE(SUM(sent_at >= ?), 0),
+			COALESCE(SUM(sent_at >= ?), 0)
+		FROM notification_log WHERE status = ? AND sent_at >= ?`,
+		midnight.UTC(), monday.UTC(), NotificationSent, monday.UTC(),
+	).Scan(&st.NotificationsToday, &st.NotificationsThisWeek)
+	if err != nil {
+		return st, err
+	}
+
+	if fi, err := os.Stat(s.path); err == nil {
+		st.FileSizeBytes = fi.Size()
+	}
+	return st, nil
+}
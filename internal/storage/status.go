@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// StatusReport implements status.Reporter.
+func (s *Storage) StatusReport(ctx context.Context) interface{} {
+	report := map[string]interface{}{
+		"path": s.path,
+	}
+
+	start := time.Now()
+	if err := s.db.PingContext(ctx); err != nil {
+		report["healthy"] = false
+		report["error"] = err.Error()
+		return report
+	}
+	report["healthy"] = true
+	report["ping_latency"] = time.Since(start).String()
+
+	if st, err := s.DetailedStats(ctx, time.Now()); err == nil {
+		report["rows"] = map[string]int{
+			"subscribers":  st.Subscribers,
+			"seen_slots":   st.SeenSlots,
+			"unique_users": st.KnownUsers,
+		}
+	}
+	if version, err := s.SchemaVersion(); err == nil {
+		report["schema_version"] = version
+	}
+	if fi, err := os.Stat(s.path); err == nil {
+		report["file_size_bytes"] = fi.Size()
+	}
+	if free, ok := freeDiskBytes(s.path); ok {
+		report["free_disk_bytes"] = free
+	}
+	return report
+}
@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -10,131 +14,251 @@ import (
 )
 
 type Storage struct {
-	db  *sql.DB
-	log *logger.Logger
+	db     *sql.DB
+	log    *logger.Logger
+	path   string
+	cipher atomic.Pointer[fieldCipher] // nil stores plaintext
+
+	notificationRetention time.Duration
+	metrics               MetricsRecorder
 }
 
-func New(dbPath string, log *logger.Logger) (*Storage, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+// busyTimeout is how long a statement waits for a lock held by another
+// connection before failing with "database is locked".
+const busyTimeout = 5 * time.Second
+
+func New(dbPath string, log *logger.Logger, opts ...Option) (*Storage, error) {
+	db, err := sql.Open("sqlite3", dsn(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
+	// SQLite serializes writers anyway; a single connection turns lock
+	// contention between the bot and the notifier into queueing in
+	// database/sql instead of busy errors.
+	db.SetMaxOpenConns(1)
+	if err := checkPragmas(db); err != nil {
+		db.Close()
+		return nil, err
+	}
 
 	s := &Storage{
-		db:  db,
-		log: log,
+		db:   db,
+		log:  log,
+		path: dbPath,
+
+		notificationRetention: DefaultNotificationRetention,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 
 	if err := s.migrate(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("migrate database: %w", err)
 	}
 
 	return s, nil
 }
 
-func (s *Storage) migrate() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS subscribers (
-			chat_id INTEGER PRIMARY KEY,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS seen_slots (
-			slot_key TEXT PRIMARY KEY,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS unique_users (
-			chat_id INTEGER PRIMARY KEY,
-			first_seen DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`INSERT OR IGNORE INTO unique_users (chat_id) SELECT chat_id FROM subscribers`,
-	}
-
-	for _, query := range queries {
-		if _, err := s.db.Exec(query); err != nil {
-			return fmt.Errorf("execute migration: %w", err)
-		}
+// dsn adds the connection parameters every connection needs to dbPath.
+func dsn(dbPath string) string {
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
 	}
+	return fmt.Sprintf("%s%s_journal_mode=WAL&_busy_timeout=%d&_foreign_keys=on",
+		dbPath, sep, busyTimeout.Milliseconds())
+}
 
-	s.log.Info("Database migrated successfully")
+// checkPragmas verifies the DSN parameters took effect. In-memory databases
+// can't use WAL and report "memory", which is fine.
+func checkPragmas(db *sql.DB) error {
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		return fmt.Errorf("read journal mode: %w", err)
+	}
+	if mode != "wal" && mode != "memory" {
+		return fmt.Errorf("journal mode is %q, want wal", mode)
+	}
+	var fk bool
+	if err := db.QueryRow("PRAGMA foreign_keys").Scan(&fk); err != nil {
+		return fmt.Errorf("read foreign keys: %w", err)
+	}
+	if !fk {
+		return errors.New("foreign keys are not enabled")
+	}
 	return nil
 }
 
-func (s *Storage) AddSubscriber(chatID int64) error {
-	_, err := s.db.Exec("INSERT OR IGNORE INTO subscribers (chat_id) VALUES (?)", chatID)
+func (s *Storage) AddSubscriber(ctx context.Context, chatID int64) (err error) {
+	defer s.track("add_subscriber", time.Now(), &err)
+	_, err = s.db.ExecContext(ctx, `INSERT INTO subscribers (chat_id) VALUES (?)
+		ON CONFLICT(chat_id) DO UPDATE SET
+			unsubscribed_at = NULL,
+			unsubscribe_reason = '',
+			subscription_kind = 'permanent',
+			expires_at = NULL,
+			expiry_warned_at = NULL
+		WHERE subscribers.unsubscribed_at IS NOT NULL`, chatID)
 	return err
 }
 
-func (s *Storage) RemoveSubscriber(chatID int64) error {
-	_, err := s.db.Exec("DELETE FROM subscribers WHERE chat_id = ?", chatID)
-	return err
+// Reasons recorded by RemoveSubscriber.
+const (
+	UnsubscribeByUser  = "user"
+	UnsubscribeExpired = "expired"
+)
+
+// RemoveSubscriber ends chatID's subscription. The row is kept with
+// unsubscribed_at and the reason set, so AddSubscriber reactivates it with
+// its original subscription date and preferences. Every unsubscription is
+// also appended to unsubscribe_events, which the re-subscribe summary
+// reads.
+func (s *Storage) RemoveSubscriber(ctx context.Context, chatID int64, reason string) (err error) {
+	defer s.track("remove_subscriber", time.Now(), &err)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var subscribedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, "SELECT created_at FROM subscribers WHERE chat_id = ? AND unsubscribed_at IS NULL", chatID).Scan(&subscribedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE subscribers SET unsubscribed_at = CURRENT_TIMESTAMP, unsubscribe_reason = ? WHERE chat_id = ?",
+		reason, chatID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO unsubscribe_events (chat_id, unsubscribed_at, reason, subscribed_at)
+		VALUES (?, CURRENT_TIMESTAMP, ?, ?)`, chatID, reason, subscribedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (s *Storage) GetSubscribers() ([]int64, error) {
-	rows, err := s.db.Query("SELECT chat_id FROM subscribers")
+// LastUnsubscribedAt returns when chatID last unsubscribed, if ever.
+func (s *Storage) LastUnsubscribedAt(chatID int64) (time.Time, bool, error) {
+	var at time.Time
+	err := s.db.QueryRow("SELECT unsubscribed_at FROM unsubscribe_events WHERE chat_id = ? ORDER BY unsubscribed_at DESC, id DESC LIMIT 1", chatID).Scan(&at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return at, true, nil
+}
+
+// SeenSlotsSince returns keys of slots first seen after t.
+func (s *Storage) SeenSlotsSince(t time.Time) (_ []string, err error) {
+	defer s.track("seen_slots_since", time.Now(), &err)
+	rows, err := s.db.Query("SELECT slot_key FROM seen_slots WHERE created_at > ?", t.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *Storage) GetSubscribers(ctx context.Context) (_ []int64, err error) {
+	defer s.track("get_subscribers", time.Now(), &err)
+	rows, err := s.db.QueryContext(ctx, "SELECT chat_id FROM subscribers WHERE unsubscribed_at IS NULL")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	// A partial list would silently stop notifications for the missing
+	// chats, so any failure fails the whole call.
 	var subscribers []int64
 	for rows.Next() {
 		var chatID int64
 		if err := rows.Scan(&chatID); err != nil {
-			continue
+			s.log.WithError(err).ErrorWithFields("Failed to scan subscriber row", logger.Fields{
+				"row": len(subscribers) + 1,
+			})
+			return nil, fmt.Errorf("scan subscriber: %w", err)
 		}
 		subscribers = append(subscribers, chatID)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read subscribers: %w", err)
+	}
 	return subscribers, nil
 }
 
-func (s *Storage) IsSlotSeen(slotKey string) (bool, error) {
-	var exists bool
-	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM seen_slots WHERE slot_key = ?)", slotKey).Scan(&exists)
-	return exists, err
-}
-
-func (s *Storage) MarkSlotSeen(slotKey string) error {
-	_, err := s.db.Exec("INSERT OR IGNORE INTO seen_slots (slot_key) VALUES (?)", slotKey)
+// MarkSlotSeen records that o was seen. The structured columns are
+// stored alongside the key so queries need not parse it.
+func (s *Storage) MarkSlotSeen(ctx context.Context, o ObservedSlot) (err error) {
+	defer s.track("mark_slot_seen", time.Now(), &err)
+	var start interface{}
+	if !o.Start.IsZero() {
+		start = o.Start.UTC().Format(appearanceTimeFormat)
+	}
+	_, err = s.db.ExecContext(ctx, "INSERT OR IGNORE INTO seen_slots (slot_key, service_id, staff_id, slot_start) VALUES (?, ?, ?, ?)",
+		o.Key, o.ServiceID, o.StaffID, start)
 	return err
 }
 
-func (s *Storage) IsSubscribed(chatID int64) (bool, error) {
+func (s *Storage) IsSubscribed(ctx context.Context, chatID int64) (_ bool, err error) {
+	defer s.track("is_subscribed", time.Now(), &err)
 	var exists bool
-	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM subscribers WHERE chat_id = ?)", chatID).Scan(&exists)
+	err = s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM subscribers WHERE chat_id = ? AND unsubscribed_at IS NULL)", chatID).Scan(&exists)
 	return exists, err
 }
 
-func (s *Storage) CleanOldSlots(olderThan time.Duration) error {
-	cutoff := time.Now().Add(-olderThan)
-	_, err := s.db.Exec("DELETE FROM seen_slots WHERE created_at < ?", cutoff)
-	return err
-}
-
-func (s *Storage) AddUniqueUser(chatID int64) error {
-	_, err := s.db.Exec("INSERT OR IGNORE INTO unique_users (chat_id) VALUES (?)", chatID)
+// CleanOldSlots forgets slots that started more than olderThan ago, so a
+// slot far in the future stays seen however long ago it was discovered.
+// Slots without a date fall back to when they were seen. It also drops
+// notification log, appearance and daily statistics rows past their
+// retention periods.
+func (s *Storage) CleanOldSlots(ctx context.Context, olderThan time.Duration) (err error) {
+	defer s.track("clean_old_slots", time.Now(), &err)
+	cutoff := time.Now().UTC().Add(-olderThan).Format(appearanceTimeFormat)
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM seen_slots WHERE COALESCE(slot_start, created_at) < ?", cutoff); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM notification_log WHERE sent_at < ?", time.Now().UTC().Add(-s.notificationRetention)); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM slot_appearances WHERE appeared_at < ?", time.Now().UTC().Add(-appearanceRetention).Format(appearanceTimeFormat)); err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, "DELETE FROM daily_stats WHERE day < ?", time.Now().Add(-dailyStatsRetention).Format(dailyStatDayFormat))
 	return err
 }
 
+// GetUniqueUsersCount returns how many chats ever used the bot.
 func (s *Storage) GetUniqueUsersCount() (int, error) {
 	var count int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM unique_users").Scan(&count)
+	err := s.db.QueryRow("SELECT COUNT(*) FROM known_users").Scan(&count)
 	return count, err
 }
 
-func (s *Storage) GetStats() (subscriberCount int, seenSlotsCount int, uniqueUsersCount int, err error) {
-	err = s.db.QueryRow("SELECT COUNT(*) FROM subscribers").Scan(&subscriberCount)
+// GetStats returns the three headline counts of DetailedStats.
+//
+// Deprecated: use DetailedStats.
+func (s *Storage) GetStats(ctx context.Context) (subscriberCount int, seenSlotsCount int, uniqueUsersCount int, err error) {
+	st, err := s.DetailedStats(ctx, time.Now())
 	if err != nil {
 		return 0, 0, 0, err
 	}
-	err = s.db.QueryRow("SELECT COUNT(*) FROM seen_slots").Scan(&seenSlotsCount)
-	if err != nil {
-		return subscriberCount, 0, 0, err
-	}
-	err = s.db.QueryRow("SELECT COUNT(*) FROM unique_users").Scan(&uniqueUsersCount)
-	if err != nil {
-		return subscriberCount, seenSlotsCount, 0, err
-	}
-	return subscriberCount, seenSlotsCount, uniqueUsersCount, nil
+	return st.Subscribers, st.SeenSlots, st.KnownUsers, nil
 }
 
 func (s *Storage) Close() error {
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	s, err := New(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func testLogger() *logger.Logger {
+	return logger.New().WithOutput(io.Discard)
+}
+
+func TestCanceledContextAbortsQuery(t *testing.T) {
+	s := newTestStorage(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.AddSubscriber(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("AddSubscriber error = %v, want context.Canceled", err)
+	}
+	if _, err := s.IsSubscribed(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("IsSubscribed error = %v, want context.Canceled", err)
+	}
+	if _, err := s.GetSubscribers(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetSubscribers error = %v, want context.Canceled", err)
+	}
+
+	subscribed, err := s.IsSubscribed(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("IsSubscribed: %v", err)
+	}
+	if subscribed {
+		t.Error("canceled AddSubscriber still subscribed the chat")
+	}
+}
+
+// recordingMetrics remembers the operations storage reported.
+type recordingMetrics struct {
+	mu       sync.Mutex
+	observed map[string]int
+	failed   map[string]int
+}
+
+func (m *recordingMetrics) ObserveStorageQuery(operation string, seconds float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observed[operation]++
+}
+
+func (m *recordingMetrics) RecordStorageError(operation string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failed[operation]++
+}
+
+func TestOperationsAreTracked(t *testing.T) {
+	m := &recordingMetrics{observed: make(map[string]int), failed: make(map[string]int)}
+	s, err := New(filepath.Join(t.TempDir(), "test.db"), testLogger(), WithMetrics(m))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if err := s.AddSubscriber(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	s.SetPreferences(1, DefaultPreferences())
+	s.ResetPreferences(1)
+	s.Authorize(1, "")
+	s.CreateInviteCode(1)
+	s.SeenSlotsSince(time.Now().Add(-time.Hour))
+	s.GetSubscription(1)
+	s.SetSubscriptionExpiry(1, nil)
+	s.SubscriptionsExpiringBefore(time.Now())
+	s.ExpiredSubscriptions(time.Now())
+	s.MarkExpiryWarned(1)
+
+	for _, op := range []string{
+		"add_subscriber", "set_preferences", "reset_preferences", "authorize", "create_invite_code",
+		"seen_slots_since", "get_subscription", "set_subscription_expiry",
+		"subscriptions_expiring_before", "expired_subscriptions", "mark_expiry_warned",
+	} {
+		if m.observed[op] != 1 {
+			t.Errorf("%s observed %d times, want 1", op, m.observed[op])
+		}
+	}
+	if len(m.failed) != 0 {
+		t.Errorf("errors recorded: %v", m.failed)
+	}
+
+	if err := s.SetSubscriptionExpiry(2, nil); err == nil {
+		t.Fatal("SetSubscriptionExpiry of a chat that isn't subscribed succeeded")
+	}
+	if m.failed["set_subscription_expiry"] != 1 {
+		t.Errorf("failed operations = %v, want set_subscription_expiry counted", m.failed)
+	}
+}
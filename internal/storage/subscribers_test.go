@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRemoveSubscriberArchivesEveryUnsubscription(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	for _, reason := range []string{UnsubscribeByUser, UnsubscribeExpired} {
+		if err := s.AddSubscriber(ctx, 42); err != nil {
+			t.Fatalf("AddSubscriber: %v", err)
+		}
+		if err := s.RemoveSubscriber(ctx, 42, reason); err != nil {
+			t.Fatalf("RemoveSubscriber: %v", err)
+		}
+	}
+
+	rows, err := s.db.Query("SELECT reason FROM unsubscribe_events WHERE chat_id = 42 ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reasons []string
+	for rows.Next() {
+		var r string
+		if err := rows.Scan(&r); err != nil {
+			t.Fatal(err)
+		}
+		reasons = append(reasons, r)
+	}
+	rows.Close()
+	if len(reasons) != 2 || reasons[0] != UnsubscribeByUser || reasons[1] != UnsubscribeExpired {
+		t.Errorf("archived reasons = %v, want [%s %s]", reasons, UnsubscribeByUser, UnsubscribeExpired)
+	}
+}
+
+func TestResubscribeReactivatesRow(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	if err := s.AddSubscriber(ctx, 42); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.Exec("UPDATE subscribers SET created_at = '2025-01-01 10:00:00' WHERE chat_id = 42"); err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Now().Add(24 * time.Hour)
+	if err := s.SetSubscriptionExpiry(42, &expires); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPreferences(42, Preferences{Weekdays: []int{6}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RemoveSubscriber(ctx, 42, UnsubscribeByUser); err != nil {
+		t.Fatalf("RemoveSubscriber: %v", err)
+	}
+	if ok, err := s.IsSubscribed(ctx, 42); err != nil || ok {
+		t.Fatalf("IsSubscribed after removal = %v, %v", ok, err)
+	}
+	if ids, err := s.GetSubscribers(ctx); err != nil || len(ids) != 0 {
+		t.Fatalf("GetSubscribers after removal = %v, %v", ids, err)
+	}
+	if _, err := s.GetSubscription(42); err != ErrNotSubscribed {
+		t.Fatalf("GetSubscription after removal: err = %v, want ErrNotSubscribed", err)
+	}
+
+	if err := s.AddSubscriber(ctx, 42); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := s.GetSubscription(42)
+	if err != nil {
+		t.Fatalf("GetSubscription: %v", err)
+	}
+	if want := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC); !sub.CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %s, want the original %s", sub.CreatedAt, want)
+	}
+	if sub.Kind != "permanent" || sub.ExpiresAt != nil {
+		t.Errorf("reactivated subscription = %s until %v, want permanent", sub.Kind, sub.ExpiresAt)
+	}
+	prefs, err := s.GetPreferences(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prefs.Weekdays) != 1 || prefs.Weekdays[0] != 6 {
+		t.Errorf("Weekdays = %v, want the preferences kept across the gap", prefs.Weekdays)
+	}
+}
+
+func TestRemoveSubscriberNotSubscribed(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.RemoveSubscriber(context.Background(), 42, UnsubscribeByUser); err != nil {
+		t.Fatalf("RemoveSubscriber: %v", err)
+	}
+	if _, ok, err := s.LastUnsubscribedAt(42); err != nil || ok {
+		t.Errorf("LastUnsubscribedAt = %v, %v; want no event", ok, err)
+	}
+}
+
+func TestLastUnsubscribedAtReturnsLatest(t *testing.T) {
+	s := newTestStorage(t)
+	older := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	newer := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{newer, older} {
+		if _, err := s.db.Exec("INSERT INTO unsubscribe_events (chat_id, unsubscribed_at, reason) VALUES (42, ?, ?)",
+			at.Format(appearanceTimeFormat), UnsubscribeByUser); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	at, ok, err := s.LastUnsubscribedAt(42)
+	if err != nil || !ok {
+		t.Fatalf("LastUnsubscribedAt = %v, %v", ok, err)
+	}
+	if !at.Equal(newer) {
+		t.Errorf("LastUnsubscribedAt = %s, want %s", at, newer)
+	}
+}
+
+func TestChurnCountsChatsNotResubscribed(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	now := time.Now()
+	left := []struct {
+		chatID int64
+		ago    time.Duration
+	}{
+		{1, 2 * 24 * time.Hour},  // left this week
+		{2, 20 * 24 * time.Hour}, // left this month
+		{3, 3 * 24 * time.Hour},  // came back
+		{4, 40 * 24 * time.Hour}, // too long ago
+	}
+	for _, l := range left {
+		if err := s.AddSubscriber(ctx, l.chatID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RemoveSubscriber(ctx, l.chatID, UnsubscribeByUser); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.db.Exec("UPDATE subscribers SET unsubscribed_at = ? WHERE chat_id = ?",
+			now.Add(-l.ago).UTC().Format(appearanceTimeFormat), l.chatID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddSubscriber(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := s.DetailedStats(ctx, now)
+	if err != nil {
+		t.Fatalf("DetailedStats: %v", err)
+	}
+	if st.Churned7d != 1 || st.Churned30d != 2 {
+		t.Errorf("churn = %d/%d (7d/30d), want 1/2", st.Churned7d, st.Churned30d)
+	}
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Subscription kinds stored in subscribers.subscription_kind.
+const (
+	SubscriptionPermanent = "permanent"
+	SubscriptionUntilDate = "until_date"
+	SubscriptionTrial     = "trial"
+)
+
+// Subscription describes an active subscriber row.
+type Subscription struct {
+	ChatID    int64
+	Kind      string
+	CreatedAt time.Time
+	ExpiresAt *time.Time
+}
+
+// ErrNotSubscribed is returned when a chat has no active subscription.
+var ErrNotSubscribed = errors.New("storage: chat is not subscribed")
+
+func (s *Storage) GetSubscription(chatID int64) (_ Subscription, err error) {
+	defer s.track("get_subscription", time.Now(), &err)
+	var (
+		sub       Subscription
+		createdAt sql.NullTime
+		expiresAt sql.NullTime
+	)
+	err = s.db.QueryRow(
+		"SELECT chat_id, subscription_kind, created_at, expires_at FROM subscribers WHERE chat_id = ? AND unsubscribed_at IS NULL",
+		chatID,
+	).Scan(&sub.ChatID, &sub.Kind, &createdAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Subscription{}, ErrNotSubscribed
+	}
+	if err != nil {
+		return Subscription{}, err
+	}
+	sub.CreatedAt = createdAt.Time
+	if expiresAt.Valid {
+		t := expiresAt.Time
+		sub.ExpiresAt = &t
+	}
+	return sub, nil
+}
+
+// SetSubscriptionExpiry makes the subscription end at expiresAt, or turns it
+// back into a permanent one when expiresAt is nil. The expiry warning is re-armed.
+func (s *Storage) SetSubscriptionExpiry(chatID int64, expiresAt *time.Time) (err error) {
+	defer s.track("set_subscription_expiry", time.Now(), &err)
+	kind := SubscriptionPermanent
+	var value interface{}
+	if expiresAt != nil {
+		kind = SubscriptionUntilDate
+		value = expiresAt.UTC()
+	}
+	res, err := s.db.Exec(
+		"UPDATE subscribers SET subscription_kind = ?, expires_at = ?, expiry_warned_at = NULL WHERE chat_id = ? AND unsubscribed_at IS NULL",
+		kind, value, chatID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotSubscribed
+	}
+	return nil
+}
+
+// SubscriptionsExpiringBefore returns subscriptions that expire before t and
+// have not been warned yet.
+func (s *Storage) SubscriptionsExpiringBefore(t time.Time) (_ []Subscription, err error) {
+	defer s.track("subscriptions_expiring_before", time.Now(), &err)
+	return s.querySubscriptions(
+		"SELECT chat_id, subscription_kind, expires_at FROM subscribers WHERE unsubscribed_at IS NULL AND expires_at IS NOT NULL AND expires_at <= ? AND expiry_warned_at IS NULL",
+		t.UTC(),
+	)
+}
+
+// ExpiredSubscriptions returns subscriptions whose expiry time has passed.
+func (s *Storage) ExpiredSubscriptions(now time.Time) (_ []Subscription, err error) {
+	defer s.track("expired_subscriptions", time.Now(), &err)
+	return s.querySubscriptions(
+		"SELECT chat_id, subscription_kind, expires_at FROM subscribers WHERE unsubscribed_at IS NULL AND expires_at IS NOT NULL AND expires_at <= ?",
+		now.UTC(),
+	)
+}
+
+func (s *Storage) MarkExpiryWarned(chatID int64) (err error) {
+	defer s.track("mark_expiry_warned", time.Now(), &err)
+	_, err = s.db.Exec("UPDATE subscribers SET expiry_warned_at = CURRENT_TIMESTAMP WHERE chat_id = ?", chatID)
+	return err
+}
+
+func (s *Storage) querySubscriptions(query string, args ...interface{}) ([]Subscription, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Subscription
+	for rows.Next() {
+		var (
+			sub       Subscription
+			expiresAt sql.NullTime
+		)
+		if err := rows.Scan(&sub.ChatID, &sub.Kind, &expiresAt); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			t := expiresAt.Time
+			sub.ExpiresAt = &t
+		}
+		out = append(out, sub)
+	}
+	return out, rows.Err()
+}
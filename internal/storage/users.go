@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// UserIdentity is the Telegram identity attached to a chat's messages.
+type UserIdentity struct {
+	Username  string
+	FirstName string
+}
+
+// KnownUser is a chat that has interacted with the bot.
+type KnownUser struct {
+	ChatID    int64
+	Identity  UserIdentity
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// UserChange is one recorded change of a chat's identity.
+type UserChange struct {
+	ChatID    int64
+	Field     string
+	OldValue  string
+	NewValue  string
+	ChangedAt time.Time
+}
+
+// chatTables lists tables keyed by chat ID that follow a chat migration.
+var chatTables = []string{
+	"subscribers", "known_users", "subscriber_preferences",
+	"authorized_users", "pinned_messages", "notification_log", "user_changes",
+	"unsubscribe_events", "digest_queue", "digest_suggestions",
+}
+
+// TouchUser records that chatID interacted with the bot. When the identity
+// differs from the stored one the row is updated and every changed field is
+// appended to user_changes. A nil identity (messages without a sender, such
+// as channel posts) only refreshes last_seen.
+func (s *Storage) TouchUser(chatID int64, identity *UserIdentity) (err error) {
+	defer s.track("touch_user", time.Now(), &err)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var storedUsername, storedFirstName string
+	err = tx.QueryRow("SELECT username, first_name FROM known_users WHERE chat_id = ?", chatID).
+		Scan(&storedUsername, &storedFirstName)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		var current UserIdentity
+		if identity != nil {
+			current = *identity
+		}
+		username, firstName, err := s.encryptIdentity(current)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO known_users (chat_id, username, first_name) VALUES (?, ?, ?)",
+			chatID, username, firstName); err != nil {
+			return err
+		}
+		return tx.Commit()
+	case err != nil:
+		return err
+	}
+
+	if identity != nil {
+		old, err := s.decryptIdentity(storedUsername, storedFirstName)
+		if err != nil {
+			return err
+		}
+		changed := false
+		for _, f := range []struct{ field, old, new string }{
+			{"username", old.Username, identity.Username},
+			{"first_name", old.FirstName, identity.FirstName},
+		} {
+			if f.old == f.new {
+				continue
+			}
+			changed = true
+			// An empty old value is a first observation, not a change.
+			if f.old == "" {
+				continue
+			}
+			if err := s.recordChange(tx, chatID, f.field, f.old, f.new); err != nil {
+				return err
+			}
+		}
+		if changed {
+			username, firstName, err := s.encryptIdentity(*identity)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec("UPDATE known_users SET username = ?, first_name = ? WHERE chat_id = ?",
+				username, firstName, chatID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE known_users SET last_seen = CURRENT_TIMESTAMP WHERE chat_id = ?", chatID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrateChat moves all data of a group chat that Telegram migrated to a
+// supergroup to its new ID.
+func (s *Storage) MigrateChat(oldChatID, newChatID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range chatTables {
+		if _, err := tx.Exec(fmt.Sprintf("UPDATE OR REPLACE %s SET chat_id = ? WHERE chat_id = ?", table),
+			newChatID, oldChatID); err != nil {
+			return fmt.Errorf("migrate %s: %w", table, err)
+		}
+	}
+	if err := s.recordChange(tx, newChatID, "chat_id", fmt.Sprint(oldChatID), fmt.Sprint(newChatID)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetKnownUser returns what is known about chatID.
+func (s *Storage) GetKnownUser(chatID int64) (KnownUser, error) {
+	u := KnownUser{ChatID: chatID}
+	var username, firstName string
+	err := s.db.QueryRow("SELECT username, first_name, first_seen, last_seen FROM known_users WHERE chat_id = ?", chatID).
+		Scan(&username, &firstName, &u.FirstSeen, &u.LastSeen)
+	if err != nil {
+		return u, err
+	}
+	u.Identity, err = s.decryptIdentity(username, firstName)
+	return u, err
+}
+
+// UserChanges returns the identity change history of chatID, newest first.
+func (s *Storage) UserChanges(chatID int64, limit int) ([]UserChange, error) {
+	rows, err := s.db.Query(
+		"SELECT field, old_value, new_value, changed_at FROM user_changes WHERE chat_id = ? ORDER BY changed_at DESC, id DESC LIMIT ?",
+		chatID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	c := s.currentCipher()
+	var changes []UserChange
+	for rows.Next() {
+		change := UserChange{ChatID: chatID}
+		var oldValue, newValue string
+		if err := rows.Scan(&change.Field, &oldValue, &newValue, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		if change.OldValue, err = c.decrypt(oldValue); err != nil {
+			return nil, err
+		}
+		if change.NewValue, err = c.decrypt(newValue); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (s *Storage) recordChange(tx *sql.Tx, chatID int64, field, oldValue, newValue string) error {
+	if field != "chat_id" {
+		c := s.currentCipher()
+		var err error
+		if oldValue, err = c.encrypt(oldValue); err != nil {
+			return err
+		}
+		if newValue, err = c.encrypt(newValue); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec("INSERT INTO user_changes (chat_id, field, old_value, new_value, changed_at) VALUES (?, ?, ?, ?, ?)",
+		chatID, field, oldValue, newValue, time.Now().UTC())
+	return err
+}
+
+func (s *Storage) encryptIdentity(id UserIdentity) (string, string, error) {
+	c := s.currentCipher()
+	username, err := c.encrypt(id.Username)
+	if err != nil {
+		return "", "", fmt.Errorf("encrypt username: %w", err)
+	}
+	firstName, err := c.encrypt(id.FirstName)
+	if err != nil {
+		return "", "", fmt.Errorf("encrypt first name: %w", err)
+	}
+	return username, firstName, nil
+}
+
+func (s *Storage) decryptIdentity(username, firstName string) (UserIdentity, error) {
+	c := s.currentCipher()
+	var id UserIdentity
+	var err error
+	if id.Username, err = c.decrypt(username); err != nil {
+		return id, err
+	}
+	id.FirstName, err = c.decrypt(firstName)
+	return id, err
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeUniqueUsersMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	// Roll back to version 6 with a legacy unique_users table holding a
+	// chat known_users lacks and an earlier sighting of one it has.
+	for _, q := range []string{
+		"DELETE FROM schema_migrations WHERE version >= 7",
+		"CREATE TABLE unique_users (chat_id INTEGER PRIMARY KEY, first_seen DATETIME DEFAULT CURRENT_TIMESTAMP)",
+		"INSERT INTO known_users (chat_id, first_seen, last_seen) VALUES (1, '2025-03-10 00:00:00', '2025-03-10 00:00:00')",
+		"INSERT INTO unique_users (chat_id, first_seen) VALUES (1, '2025-01-01 00:00:00'), (2, '2025-02-01 00:00:00')",
+	} {
+		if _, err := s.db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	s.Close()
+
+	s, err = New(path, testLogger())
+	if err != nil {
+		t.Fatalf("New after rollback: %v", err)
+	}
+	defer s.Close()
+
+	count, err := s.GetUniqueUsersCount()
+	if err != nil {
+		t.Fatalf("GetUniqueUsersCount: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("GetUniqueUsersCount = %d, want 2", count)
+	}
+	var firstSeen string
+	if err := s.db.QueryRow("SELECT first_seen FROM known_users WHERE chat_id = 1").Scan(&firstSeen); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(firstSeen, "2025-01-01") {
+		t.Errorf("first_seen of chat 1 = %s, want the earlier 2025-01-01", firstSeen)
+	}
+	var tables int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'unique_users'").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Error("unique_users still exists")
+	}
+}
+
+func TestImportCountsUsers(t *testing.T) {
+	doc := `{"version":1,"subscribers":[{"chat_id":7,"created_at":"2025-03-01T10:00:00Z","kind":"permanent"}]}`
+	s := newTestStorage(t)
+	if _, err := s.Import(strings.NewReader(doc)); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	count, err := s.GetUniqueUsersCount()
+	if err != nil {
+		t.Fatalf("GetUniqueUsersCount: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("GetUniqueUsersCount after import = %d, want 1", count)
+	}
+}
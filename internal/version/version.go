@@ -0,0 +1,57 @@
+// Package version holds build information injected at link time:
+//
+//	go build -ldflags "-X github.com/thatguy/moto_gorod-notifier/internal/version.Version=v1.2.3 \
+//		-X github.com/thatguy/moto_gorod-notifier/internal/version.Commit=abc1234 \
+//		-X github.com/thatguy/moto_gorod-notifier/internal/version.BuildDate=2025-01-01T00:00:00Z"
+package version
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+func init() {
+	// Fall back to VCS data stamped by the Go toolchain for plain go builds.
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if Commit == "" {
+				Commit = s.Value
+			}
+		case "vcs.time":
+			if BuildDate == "" {
+				BuildDate = s.Value
+			}
+		}
+	}
+}
+
+// ShortCommit returns the first 7 characters of the commit hash.
+func ShortCommit() string {
+	if len(Commit) > 7 {
+		return Commit[:7]
+	}
+	if Commit == "" {
+		return "unknown"
+	}
+	return Commit
+}
+
+// String returns a one-line description like "v1.2.3 (abc1234, 2025-01-01T00:00:00Z)".
+func String() string {
+	date := BuildDate
+	if date == "" {
+		date = "unknown"
+	}
+	return fmt.Sprintf("%s (%s, %s)", Version, ShortCommit(), date)
+}
@@ -0,0 +1,98 @@
+package yclients_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients/yclientstest"
+)
+
+// parallelSearches runs n staff searches at once and returns their errors.
+func parallelSearches(c *yclients.Client, n int) []error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = c.GetBookableStaff(context.Background(), testLocationID, testServiceID)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestConcurrentRequestsAuthenticateOnce(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.AuthPath, yclientstest.Response{
+		Status: http.StatusCreated,
+		Body:   yclientstest.AuthOK,
+		Delay:  100 * time.Millisecond,
+	})
+
+	const n = 20
+	for i, err := range parallelSearches(srv.Client(), n) {
+		if err != nil {
+			t.Errorf("request %d: %v", i, err)
+		}
+	}
+	if got := srv.Calls(yclientstest.AuthPath); got != 1 {
+		t.Errorf("%d auth requests for %d parallel searches, want 1", got, n)
+	}
+	if got := srv.Calls(yclientstest.SearchStaffPath); got != n {
+		t.Errorf("%d search requests, want %d", got, n)
+	}
+}
+
+func TestConcurrentRequestsShareAuthFailure(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.AuthPath, yclientstest.Response{
+		Status: http.StatusUnauthorized,
+		Body:   yclientstest.AuthFailed,
+		Delay:  100 * time.Millisecond,
+	})
+
+	const n = 20
+	for i, err := range parallelSearches(srv.Client(), n) {
+		if !errors.Is(err, yclients.ErrAuth) {
+			t.Errorf("request %d: err = %v, want ErrAuth", i, err)
+		}
+	}
+	if got := srv.Calls(yclientstest.AuthPath); got != 1 {
+		t.Errorf("%d auth requests for %d parallel searches, want 1", got, n)
+	}
+}
+
+func TestAuthWaiterStopsOnContext(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.AuthPath, yclientstest.Response{
+		Status: http.StatusCreated,
+		Body:   yclientstest.AuthOK,
+		Delay:  time.Second,
+	})
+	c := srv.Client()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.GetBookableStaff(ctx, testLocationID, testServiceID)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if waited := time.Since(start); waited > 500*time.Millisecond {
+		t.Errorf("caller waited %s for the authentication it gave up on", waited)
+	}
+
+	// The abandoned authentication still completes and is reused
+	if _, err := c.GetBookableStaff(context.Background(), testLocationID, testServiceID); err != nil {
+		t.Fatalf("GetBookableStaff: %v", err)
+	}
+	if got := srv.Calls(yclientstest.AuthPath); got != 1 {
+		t.Errorf("%d auth requests, want 1", got)
+	}
+}
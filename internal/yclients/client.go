@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"strings"
@@ -22,13 +24,55 @@ type Client struct {
 	partnerToken string
 	userToken    string
 	tokenExp     time.Time
+	auth         *authCall // in-flight authentication, guarded by mu
 	companyID    string
 	formID       string
 
 	http    *http.Client
-	baseURL *url.URL
+	baseURL *url.URL // platform host for availability searches
+	apiURL  string   // REST API host, without a trailing slash
 	log     *logger.Logger
 	mu      sync.RWMutex
+
+	stateMu       sync.RWMutex
+	lastRequestAt time.Time
+	lastStatus    int
+	rateLimit     map[string]string
+
+	retryAttempts  int
+	retryBaseDelay time.Duration
+	requestTimeout time.Duration
+	tokenTTL       time.Duration
+	loc            *time.Location
+	dumpDir        string
+	limiter        *rateLimiter
+	metrics        MetricsRecorder
+}
+
+// Retry defaults for availability searches.
+const (
+	DefaultRetryAttempts  = 3
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+)
+
+// maxRetryAfterWait is the longest Retry-After a request waits out itself;
+// longer throttling is returned to the caller, which backs off instead.
+const maxRetryAfterWait = 30 * time.Second
+
+// MetricsRecorder receives client-level metrics.
+type MetricsRecorder interface {
+	RecordYClientsRetry(endpoint string)
+	ObserveYClientsRateLimitWait(seconds float64)
+	// ObserveYClientsRequest is called for every HTTP request; status is
+	// the status class ("2xx", "4xx", ...) or "error" without a response.
+	ObserveYClientsRequest(endpoint, status string, seconds float64)
+	// RecordYClientsError counts a failed request: network, 4xx, 5xx or
+	// parse.
+	RecordYClientsError(endpoint, errType string)
+	// RecordYClientsThrottled counts a 429 response.
+	RecordYClientsThrottled(endpoint string)
+	// RecordYClientsAuth counts an authentication request.
+	RecordYClientsAuth(success bool)
 }
 
 // --- Typed response models and helpers (based on provided samples) ---
@@ -41,6 +85,7 @@ type apiObject[T any] struct {
 
 type apiResponse[T any] struct {
 	Data []apiObject[T] `json:"data"`
+	pagination
 }
 
 type StaffAttributes struct {
@@ -52,20 +97,82 @@ type StaffAttributes struct {
 type DateAttributes struct {
 	Date       string `json:"date"`
 	IsBookable bool   `json:"is_bookable"`
+	// ServiceID names the service of the date in a search for several
+	// services; zero when the API doesn't say.
+	ServiceID int `json:"service_id,omitempty"`
 }
 
 type TimeslotAttributes struct {
 	Datetime   string `json:"datetime"`
 	Time       string `json:"time"`
 	IsBookable bool   `json:"is_bookable"`
+	// Group lessons report seat counts; both are absent for individual lessons.
+	Capacity     *int `json:"capacity,omitempty"`
+	RecordsCount *int `json:"records_count,omitempty"`
+	// ServiceID is as in DateAttributes.
+	ServiceID int `json:"service_id,omitempty"`
 }
 
-func parseStaffIDs(data []byte) ([]int, error) {
+// Timeslot is a bookable time returned by search-timeslots.
+type Timeslot struct {
+	Datetime string // RFC3339 when provided by the API, otherwise bare "HH:MM"
+	// TimeOnly is the bare "HH:MM" when the API sent no datetime.
+	TimeOnly string
+	// Start is the parsed datetime, or the requested date combined with
+	// TimeOnly in the client's location. Zero when neither can be parsed
+	// or no location is set.
+	Start     time.Time
+	HasSeats  bool // whether capacity was reported for this slot
+	SeatsLeft int
+	// ServiceID is the service the API attributed the slot to in a search
+	// for several services; zero otherwise.
+	ServiceID int
+}
+
+// newTimeslot builds a timeslot from the datetime and time attributes of a
+// response for date. ok is false when both are empty; unparsed is set when
+// a value was present but could not be turned into a start time.
+func newTimeslot(datetime, bare, date string, loc *time.Location) (ts Timeslot, ok, unparsed bool) {
+	switch {
+	case datetime != "":
+		ts.Datetime = datetime
+		t, err := time.Parse(time.RFC3339, datetime)
+		if err != nil {
+			return ts, true, true
+		}
+		ts.Start = t
+	case bare != "":
+		ts.Datetime = bare
+		ts.TimeOnly = bare
+		if loc == nil {
+			return ts, true, false
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04", date+" "+bare, loc)
+		if err != nil {
+			return ts, true, true
+		}
+		ts.Start = t
+	default:
+		return ts, false, false
+	}
+	return ts, true, false
+}
+
+// StaffAvailability is a bookable staff member of a service with the
+// price range of the service when booked with them. Prices are zero when
+// the API doesn't report them.
+type StaffAvailability struct {
+	ID       int
+	PriceMin float64
+	PriceMax float64
+}
+
+func parseStaffAvailability(data []byte) ([]StaffAvailability, error) {
 	var resp apiResponse[StaffAttributes]
 	if err := json.Unmarshal(data, &resp); err != nil {
-		return nil, fmt.Errorf("parse staff: %w", err)
+		return nil, badResponse("staff", err)
 	}
-	ids := make([]int, 0, len(resp.Data))
+	staff := make([]StaffAvailability, 0, len(resp.Data))
 	for _, it := range resp.Data {
 		if !it.Attributes.IsBookable {
 			continue
@@ -75,7 +182,23 @@ func parseStaffIDs(data []byte) ([]int, error) {
 		if _, err := fmt.Sscanf(it.ID, "%d", &sid); err != nil {
 			continue
 		}
-		ids = append(ids, sid)
+		staff = append(staff, StaffAvailability{
+			ID:       sid,
+			PriceMin: it.Attributes.PriceMin,
+			PriceMax: it.Attributes.PriceMax,
+		})
+	}
+	return staff, nil
+}
+
+func parseStaffIDs(data []byte) ([]int, error) {
+	staff, err := parseStaffAvailability(data)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(staff))
+	for i, s := range staff {
+		ids[i] = s.ID
 	}
 	return ids, nil
 }
@@ -83,7 +206,7 @@ func parseStaffIDs(data []byte) ([]int, error) {
 func parseDates(data []byte) ([]string, error) {
 	var resp apiResponse[DateAttributes]
 	if err := json.Unmarshal(data, &resp); err != nil {
-		return nil, fmt.Errorf("parse dates: %w", err)
+		return nil, badResponse("dates", err)
 	}
 	out := make([]string, 0, len(resp.Data))
 	for _, it := range resp.Data {
@@ -94,60 +217,188 @@ func parseDates(data []byte) ([]string, error) {
 	return out, nil
 }
 
-func parseTimeslots(data []byte) ([]string, error) {
+// ServiceDate is a bookable date of one service.
+type ServiceDate struct {
+	ServiceID int
+	Date      string
+}
+
+func parseServiceDates(data []byte) ([]ServiceDate, error) {
+	var resp apiResponse[DateAttributes]
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, badResponse("dates", err)
+	}
+	out := make([]ServiceDate, 0, len(resp.Data))
+	for _, it := range resp.Data {
+		if it.Attributes.IsBookable && it.Attributes.Date != "" {
+			out = append(out, ServiceDate{ServiceID: it.Attributes.ServiceID, Date: it.Attributes.Date})
+		}
+	}
+	return out, nil
+}
+
+// parseTimeslots parses a search-timeslots response for date. Entries
+// whose time could not be parsed are kept with a zero Start and returned in
+// unparsed as well.
+func parseTimeslots(data []byte, date string, loc *time.Location) (out []Timeslot, unparsed []string, err error) {
 	var resp apiResponse[TimeslotAttributes]
 	if err := json.Unmarshal(data, &resp); err != nil {
-		return nil, fmt.Errorf("parse timeslots: %w", err)
+		return nil, nil, badResponse("timeslots", err)
 	}
-	out := make([]string, 0, len(resp.Data))
+	out = make([]Timeslot, 0, len(resp.Data))
 	for _, it := range resp.Data {
-		if it.Attributes.IsBookable {
-			if it.Attributes.Datetime != "" {
-				out = append(out, it.Attributes.Datetime)
-			} else if it.Attributes.Time != "" {
-				out = append(out, it.Attributes.Time)
+		a := it.Attributes
+		if !a.IsBookable {
+			continue
+		}
+		ts, ok, bad := newTimeslot(a.Datetime, a.Time, date, loc)
+		if !ok {
+			continue
+		}
+		if bad {
+			unparsed = append(unparsed, ts.Datetime)
+		}
+		ts.ServiceID = a.ServiceID
+		if a.Capacity != nil && *a.Capacity > 0 {
+			booked := 0
+			if a.RecordsCount != nil {
+				booked = *a.RecordsCount
+			}
+			ts.HasSeats = true
+			ts.SeatsLeft = *a.Capacity - booked
+			if ts.SeatsLeft <= 0 {
+				continue
 			}
 		}
+		out = append(out, ts)
+	}
+	return out, unparsed, nil
+}
+
+// timeslotParser adapts parseTimeslots to searchAll, logging entries with
+// unparseable times together with the raw response.
+func (c *Client) timeslotParser(ctx context.Context, endpoint, date string, parse func([]byte, string, *time.Location) ([]Timeslot, []string, error)) func([]byte) ([]Timeslot, error) {
+	return func(data []byte) ([]Timeslot, error) {
+		out, unparsed, err := parse(data, date, c.loc)
+		if len(unparsed) > 0 {
+			c.logger(ctx).WarnWithFields("YCLIENTS returned timeslots with unparseable times", logger.Fields{
+				"endpoint": endpoint,
+				"date":     date,
+				"values":   unparsed,
+				"body":     truncateForLog(data, 600),
+			})
+		}
+		return out, err
 	}
-	return out, nil
 }
 
 // --- Convenience methods that build payload, call, and parse ---
 
-func (c *Client) GetBookableStaffIDs(ctx context.Context, locationID, serviceID int) ([]int, error) {
+// GetBookableStaff returns the bookable staff of a service with their
+// prices.
+func (c *Client) GetBookableStaff(ctx context.Context, locationID, serviceID int) ([]StaffAvailability, error) {
 	body, err := BuildSearchStaffPayload(locationID, serviceID, nil)
 	if err != nil {
 		return nil, err
 	}
-	raw, _, err := c.SearchStaff(ctx, body)
+	return searchAll(ctx, c, searchStaffEndpoint, body, parseStaffAvailability)
+}
+
+// GetBookableDatesAnyStaff returns the dates on which any staff member of
+// the service can be booked, in one request.
+func (c *Client) GetBookableDatesAnyStaff(ctx context.Context, locationID, serviceID int, dateFrom, dateTo string) ([]string, error) {
+	return c.GetBookableDates(ctx, locationID, serviceID, dateFrom, dateTo, nil)
+}
+
+// GetBookableDatesServices returns the dates on which a visit combining all
+// the services can be booked, in one request. The answer doesn't say which
+// service a date belongs to: a date means every service is bookable that
+// day, while no dates doesn't mean any single service is fully booked.
+func (c *Client) GetBookableDatesServices(ctx context.Context, locationID int, serviceIDs []int, dateFrom, dateTo string) ([]string, error) {
+	body, err := BuildSearchDatesPayloadServices(locationID, serviceIDs, dateFrom, dateTo, nil)
 	if err != nil {
 		return nil, err
 	}
-	return parseStaffIDs(raw)
+	return searchAll(ctx, c, searchDatesEndpoint, body, parseDates)
 }
 
-func (c *Client) GetBookableDates(ctx context.Context, locationID, serviceID int, dateFrom, dateTo string, staffID *int) ([]string, error) {
-	body, err := BuildSearchDatesPayload(locationID, serviceID, dateFrom, dateTo, staffID)
+// GetBookableDatesByService returns, in one request, the dates on which
+// staffID can be booked for each of the services. It fails with
+// ErrUnattributed when the answer doesn't name the service of every date;
+// the caller then asks for each service on its own.
+func (c *Client) GetBookableDatesByService(ctx context.Context, locationID int, serviceIDs []int, dateFrom, dateTo string, staffID int) (map[int][]string, error) {
+	sid := staffID
+	body, err := BuildSearchDatesPayloadServices(locationID, serviceIDs, dateFrom, dateTo, &sid)
 	if err != nil {
 		return nil, err
 	}
-	raw, _, err := c.SearchDates(ctx, body)
+	found, err := searchAll(ctx, c, searchDatesEndpoint, body, parseServiceDates)
 	if err != nil {
 		return nil, err
 	}
-	return parseDates(raw)
+	byService := make(map[int][]string, len(serviceIDs))
+	for _, d := range found {
+		if !containsID(serviceIDs, d.ServiceID) {
+			return nil, ErrUnattributed
+		}
+		byService[d.ServiceID] = append(byService[d.ServiceID], d.Date)
+	}
+	return byService, nil
 }
 
-func (c *Client) GetBookableTimeslots(ctx context.Context, locationID, serviceID int, date string, staffID int) ([]string, error) {
-	body, err := BuildSearchTimeslotsPayload(locationID, serviceID, date, staffID)
+// GetBookableTimeslotsByService is GetBookableDatesByService for the
+// timeslots of one date.
+func (c *Client) GetBookableTimeslotsByService(ctx context.Context, locationID int, serviceIDs []int, date string, staffID int) (map[int][]Timeslot, error) {
+	body, err := BuildSearchTimeslotsPayloadServices(locationID, serviceIDs, date, staffID)
+	if err != nil {
+		return nil, err
+	}
+	found, err := searchAll(ctx, c, searchTimeslotsEndpoint, body, c.timeslotParser(ctx, searchTimeslotsEndpoint, date, parseTimeslots))
 	if err != nil {
 		return nil, err
 	}
-	raw, _, err := c.SearchTimeslots(ctx, body)
+	byService := make(map[int][]Timeslot, len(serviceIDs))
+	for _, ts := range found {
+		if !containsID(serviceIDs, ts.ServiceID) {
+			return nil, ErrUnattributed
+		}
+		byService[ts.ServiceID] = append(byService[ts.ServiceID], ts)
+	}
+	return byService, nil
+}
+
+func containsID(ids []int, id int) bool {
+	for _, x := range ids {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBookableStaffIDs is GetBookableStaff without prices.
+func (c *Client) GetBookableStaffIDs(ctx context.Context, locationID, serviceID int) ([]int, error) {
+	body, err := BuildSearchStaffPayload(locationID, serviceID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return searchAll(ctx, c, searchStaffEndpoint, body, parseStaffIDs)
+}
+
+func (c *Client) GetBookableDates(ctx context.Context, locationID, serviceID int, dateFrom, dateTo string, staffID *int) ([]string, error) {
+	body, err := BuildSearchDatesPayload(locationID, serviceID, dateFrom, dateTo, staffID)
+	if err != nil {
+		return nil, err
+	}
+	return searchAll(ctx, c, searchDatesEndpoint, body, parseDates)
+}
+
+func (c *Client) GetBookableTimeslots(ctx context.Context, locationID, serviceID int, date string, staffID int) ([]Timeslot, error) {
+	body, err := BuildSearchTimeslotsPayload(locationID, serviceID, date, staffID)
 	if err != nil {
 		return nil, err
 	}
-	return parseTimeslots(raw)
+	return searchAll(ctx, c, searchTimeslotsEndpoint, body, c.timeslotParser(ctx, searchTimeslotsEndpoint, date, parseTimeslots))
 }
 
 // --- Typed payload builders (based on provided widget payloads) ---
@@ -187,8 +438,37 @@ type searchPayload[T any] struct {
 	Filter  T              `json:"filter"`
 }
 
+// searchDateLayout is the date format of availability searches.
+const searchDateLayout = "2006-01-02"
+
+// validateSearch checks the parameters shared by all availability
+// searches; staffID may be nil.
+func validateSearch(locationID, serviceID int, staffID *int) error {
+	if locationID <= 0 {
+		return fmt.Errorf("%w: location ID must be positive, got %d", ErrBadRequest, locationID)
+	}
+	if serviceID <= 0 {
+		return fmt.Errorf("%w: service ID must be positive, got %d", ErrBadRequest, serviceID)
+	}
+	if staffID != nil && *staffID <= 0 {
+		return fmt.Errorf("%w: staff ID must be positive, got %d", ErrBadRequest, *staffID)
+	}
+	return nil
+}
+
+func parseSearchDate(name, value string) (time.Time, error) {
+	t, err := time.Parse(searchDateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s %q is not a YYYY-MM-DD date", ErrBadRequest, name, value)
+	}
+	return t, nil
+}
+
 // BuildSearchStaffPayload builds JSON for availability/search-staff.
 func BuildSearchStaffPayload(locationID int, serviceID int, staffID *int) ([]byte, error) {
+	if err := validateSearch(locationID, serviceID, staffID); err != nil {
+		return nil, err
+	}
 	p := searchPayload[filterStaff]{
 		Context: payloadContext{LocationID: locationID},
 		Filter: filterStaff{
@@ -209,20 +489,37 @@ func BuildSearchStaffPayload(locationID int, serviceID int, staffID *int) ([]byt
 
 // BuildSearchDatesPayload builds JSON for availability/search-dates.
 func BuildSearchDatesPayload(locationID int, serviceID int, dateFrom, dateTo string, staffID *int) ([]byte, error) {
+	return BuildSearchDatesPayloadServices(locationID, []int{serviceID}, dateFrom, dateTo, staffID)
+}
+
+// BuildSearchDatesPayloadServices is BuildSearchDatesPayload for a visit
+// made of several services. YCLIENTS searches for times when all of them
+// can be booked together, and its answer doesn't say which service a date
+// belongs to; see GetBookableDatesServices.
+func BuildSearchDatesPayloadServices(locationID int, serviceIDs []int, dateFrom, dateTo string, staffID *int) ([]byte, error) {
+	if err := validateServices(locationID, serviceIDs, staffID); err != nil {
+		return nil, err
+	}
+	from, err := parseSearchDate("date_from", dateFrom)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseSearchDate("date_to", dateTo)
+	if err != nil {
+		return nil, err
+	}
+	if from.After(to) {
+		return nil, fmt.Errorf("%w: date_from %s is after date_to %s", ErrBadRequest, dateFrom, dateTo)
+	}
 	p := searchPayload[filterDates]{
 		Context: payloadContext{LocationID: locationID},
 		Filter: filterDates{
 			DateFrom: dateFrom,
 			DateTo:   dateTo,
-			Records: []record{
-				{
-					StaffID: staffID,
-					AttendanceServiceItems: []attendanceServiceItem{{
-						Type: "service",
-						ID:   serviceID,
-					}},
-				},
-			},
+			Records: []record{{
+				StaffID:                staffID,
+				AttendanceServiceItems: serviceItems(serviceIDs),
+			}},
 		},
 	}
 	return json.Marshal(p)
@@ -230,29 +527,135 @@ func BuildSearchDatesPayload(locationID int, serviceID int, dateFrom, dateTo str
 
 // BuildSearchTimeslotsPayload builds JSON for availability/search-timeslots.
 func BuildSearchTimeslotsPayload(locationID int, serviceID int, date string, staffID int) ([]byte, error) {
+	return BuildSearchTimeslotsPayloadServices(locationID, []int{serviceID}, date, staffID)
+}
+
+// BuildSearchTimeslotsPayloadServices is BuildSearchTimeslotsPayload for a
+// visit made of several services; see BuildSearchDatesPayloadServices.
+func BuildSearchTimeslotsPayloadServices(locationID int, serviceIDs []int, date string, staffID int) ([]byte, error) {
+	if err := validateServices(locationID, serviceIDs, &staffID); err != nil {
+		return nil, err
+	}
+	if _, err := parseSearchDate("date", date); err != nil {
+		return nil, err
+	}
 	sid := staffID
 	p := searchPayload[filterTimeslots]{
 		Context: payloadContext{LocationID: locationID},
 		Filter: filterTimeslots{
 			Date: date,
-			Records: []record{
-				{
-					StaffID: &sid,
-					AttendanceServiceItems: []attendanceServiceItem{{
-						Type: "service",
-						ID:   serviceID,
-					}},
-				},
-			},
+			Records: []record{{
+				StaffID:                &sid,
+				AttendanceServiceItems: serviceItems(serviceIDs),
+			}},
 		},
 	}
 	return json.Marshal(p)
 }
 
-// makeRequest is a common method for making HTTP requests to YCLIENTS API
+// validateServices is validateSearch for a non-empty list of services.
+func validateServices(locationID int, serviceIDs []int, staffID *int) error {
+	if len(serviceIDs) == 0 {
+		return fmt.Errorf("%w: no service IDs", ErrBadRequest)
+	}
+	for _, id := range serviceIDs {
+		if err := validateSearch(locationID, id, staffID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func serviceItems(serviceIDs []int) []attendanceServiceItem {
+	items := make([]attendanceServiceItem, len(serviceIDs))
+	for i, id := range serviceIDs {
+		items[i] = attendanceServiceItem{Type: "service", ID: id}
+	}
+	return items
+}
+
+// makeRequest is a common method for making HTTP requests to YCLIENTS API.
+// It is only used for the availability searches, which are safe to repeat,
+// so network errors, 429 and 5xx responses are retried with backoff.
 func (c *Client) makeRequest(ctx context.Context, endpoint string, body []byte) ([]byte, *http.Response, error) {
+	reauthed := false
+	for attempt := 1; ; attempt++ {
+		// Authentication and the rate limiter wait run outside the request
+		// timeout, bounded only by ctx and the HTTP client timeout
+		token, err := c.getToken(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("get auth token: %w", err)
+		}
+		if err := c.waitRateLimit(ctx, endpoint); err != nil {
+			return nil, nil, err
+		}
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if c.requestTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		}
+		data, resp, retry, err := c.doRequest(attemptCtx, endpoint, token, body)
+		cancel()
+		err = classifyTimeout(ctx, err)
+		// A rejected user token has expired before its TTL: authenticate
+		// again and repeat the request once, outside the retry budget
+		var apiErr *APIError
+		if !reauthed && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+			reauthed = true
+			c.invalidateToken(token)
+			attempt--
+			continue
+		}
+		if err == nil || !retry || attempt >= c.retryAttempts || ctx.Err() != nil {
+			return data, resp, err
+		}
+		delay := c.retryDelay(attempt)
+		// A 429 says how long to wait; retrying sooner only gets throttled
+		// again, and a long wait is left to the caller
+		if wait, ok := RetryAfter(err); ok && wait > delay {
+			if wait > maxRetryAfterWait {
+				return data, resp, err
+			}
+			delay = wait
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return data, resp, err
+		}
+		c.logger(ctx).DebugWithFields("Retrying YCLIENTS request", logger.Fields{
+			"endpoint":     endpoint,
+			"attempt":      attempt + 1,
+			"max_attempts": c.retryAttempts,
+			"delay":        delay.String(),
+			"error":        err.Error(),
+		})
+		if c.metrics != nil {
+			c.metrics.RecordYClientsRetry(requestLabel(endpoint))
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return data, resp, err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryDelay is the wait before retry number attempt: exponential from
+// retryBaseDelay, with up to half of it shaved off at random so parallel
+// scan requests don't retry in lockstep.
+func (c *Client) retryDelay(attempt int) time.Duration {
+	d := c.retryBaseDelay << (attempt - 1)
+	if d <= 0 {
+		return 0
+	}
+	return d - time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// doRequest makes one attempt; retry reports whether the failure may be
+// transient.
+func (c *Client) doRequest(ctx context.Context, endpoint, token string, body []byte) (data []byte, resp *http.Response, retry bool, err error) {
 	if c.http == nil || c.baseURL == nil {
-		return nil, nil, fmt.Errorf("yclients: http client not initialized")
+		return nil, nil, false, fmt.Errorf("yclients: http client not initialized")
 	}
 
 	rel, _ := url.Parse(endpoint)
@@ -260,13 +663,9 @@ func (c *Client) makeRequest(ctx context.Context, endpoint string, body []byte)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fullURL, bytes.NewReader(body))
 	if err != nil {
-		return nil, nil, fmt.Errorf("yclients: build request: %w", err)
+		return nil, nil, false, fmt.Errorf("yclients: build request: %w", err)
 	}
 
-	token, err := c.getToken(ctx)
-	if err != nil {
-		return nil, nil, fmt.Errorf("get auth token: %w", err)
-	}
 	if token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.partnerToken+", User "+token)
 	} else {
@@ -277,97 +676,240 @@ func (c *Client) makeRequest(ctx context.Context, endpoint string, body []byte)
 	req.Header.Set("X-YCLIENTS-Application-Name", "client.booking")
 	req.Header.Set("X-YCLIENTS-Application-Action", "company")
 	req.Header.Set("X-YCLIENTS-Application-Platform", "go-client")
+	if id := RequestID(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
 
-	c.log.DebugWithFields("Sending request to YCLIENTS API", logger.Fields{
+	c.logger(ctx).DebugWithFields("Sending request to YCLIENTS API", logger.Fields{
 		"endpoint":  fullURL,
 		"body_size": len(body),
 	})
 
 	start := time.Now()
-	resp, err := c.http.Do(req)
+	resp, err = c.http.Do(req)
+	if err != nil && c.dumpDir != "" {
+		c.dump(ctx, endpoint, body, 0, []byte(err.Error()))
+	}
+	c.observeRequest(requestLabel(endpoint), resp, time.Since(start))
 	dur := time.Since(start).Truncate(time.Millisecond)
 
 	if err != nil {
-		c.log.ErrorWithFields("YCLIENTS request failed", logger.Fields{
+		c.logger(ctx).ErrorWithFields("YCLIENTS request failed", logger.Fields{
 			"endpoint": fullURL,
 			"duration": dur.String(),
 			"error":    err.Error(),
 		})
-		return nil, resp, fmt.Errorf("yclients: request failed after %s: %w", dur, err)
+		return nil, resp, true, fmt.Errorf("yclients: request failed after %s: %w", dur, err)
 	}
 	defer func() { _ = resp.Body.Close() }()
+	c.recordResponse(resp)
 
 	data, readErr := io.ReadAll(resp.Body)
+	if c.dumpDir != "" {
+		c.dump(ctx, endpoint, body, resp.StatusCode, data)
+	}
 	if readErr != nil {
-		c.log.ErrorWithFields("Failed to read response body", logger.Fields{
+		c.logger(ctx).ErrorWithFields("Failed to read response body", logger.Fields{
 			"endpoint": fullURL,
 			"error":    readErr.Error(),
 		})
-		return nil, resp, fmt.Errorf("yclients: read body: %w", readErr)
+		return nil, resp, true, fmt.Errorf("yclients: read body: %w", readErr)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		c.log.WarnWithFields("YCLIENTS API returned non-2xx status", logger.Fields{
-			"endpoint":  fullURL,
-			"status":    resp.StatusCode,
-			"duration":  dur.String(),
-			"body":      truncateForLog(data, 600),
-			"body_size": len(data),
+		apiErr := newAPIError(resp, data)
+		c.logger(ctx).WarnWithFields("YCLIENTS API returned non-2xx status", logger.Fields{
+			"endpoint":   fullURL,
+			"status":     resp.StatusCode,
+			"error_code": apiErr.Code,
+			"message":    apiErr.Message,
+			"duration":   dur.String(),
+			"body":       truncateForLog(data, 600),
+			"body_size":  len(data),
 		})
-		return data, resp, fmt.Errorf("yclients: non-2xx status %d", resp.StatusCode)
+		return data, resp, apiErr.temporary(), apiErr
 	}
 
-	c.log.DebugWithFields("YCLIENTS API request successful", logger.Fields{
+	c.logger(ctx).DebugWithFields("YCLIENTS API request successful", logger.Fields{
 		"endpoint":  fullURL,
 		"status":    resp.StatusCode,
 		"duration":  dur.String(),
 		"body_size": len(data),
 	})
-	return data, resp, nil
+	return data, resp, false, nil
 }
 
 // SearchStaff posts to /api/v1/b2c/booking/availability/search-staff.
 func (c *Client) SearchStaff(ctx context.Context, body []byte) ([]byte, *http.Response, error) {
-	return c.makeRequest(ctx, "/api/v1/b2c/booking/availability/search-staff", body)
+	return c.makeRequest(ctx, searchStaffEndpoint, body)
 }
 
 // SearchDates posts to /api/v1/b2c/booking/availability/search-dates.
 func (c *Client) SearchDates(ctx context.Context, body []byte) ([]byte, *http.Response, error) {
-	return c.makeRequest(ctx, "/api/v1/b2c/booking/availability/search-dates", body)
+	return c.makeRequest(ctx, searchDatesEndpoint, body)
 }
 
 // SearchTimeslots posts to /api/v1/b2c/booking/availability/search-timeslots.
 func (c *Client) SearchTimeslots(ctx context.Context, body []byte) ([]byte, *http.Response, error) {
-	return c.makeRequest(ctx, "/api/v1/b2c/booking/availability/search-timeslots", body)
+	return c.makeRequest(ctx, searchTimeslotsEndpoint, body)
 }
 
 // SearchTimes posts to /api/v1/b2c/booking/availability/search-times.
 func (c *Client) SearchTimes(ctx context.Context, body []byte) ([]byte, *http.Response, error) {
-	return c.makeRequest(ctx, "/api/v1/b2c/booking/availability/search-times", body)
+	return c.makeRequest(ctx, searchTimesEndpoint, body)
 }
 
-func New(login, password, partnerToken, companyID, formID string) *Client {
-	u, _ := url.Parse("https://platform.yclients.com")
+// New creates a client for creds with the defaults of this package,
+// adjusted by opts.
+func New(creds Credentials, opts ...Option) (*Client, error) {
+	u, _ := url.Parse(DefaultPlatformBaseURL)
 	log := logger.New().WithField("component", "yclients_client")
-	
-	return &Client{
-		login:        login,
-		password:     password,
-		partnerToken: partnerToken,
-		companyID:    companyID,
-		formID:       formID,
-		http:         &http.Client{Timeout: 10 * time.Second},
-		baseURL:      u,
-		log:          log,
+
+	c := &Client{
+		login:          creds.Login,
+		password:       creds.Password,
+		partnerToken:   creds.PartnerToken,
+		companyID:      creds.CompanyID,
+		formID:         creds.FormID,
+		http:           &http.Client{Timeout: DefaultHTTPTimeout},
+		baseURL:        u,
+		apiURL:         DefaultAPIBaseURL,
+		log:            log,
+		retryAttempts:  DefaultRetryAttempts,
+		retryBaseDelay: DefaultRetryBaseDelay,
+		tokenTTL:       DefaultTokenTTL,
+		limiter:        newRateLimiter(DefaultRateLimit, DefaultRateBurst),
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// NewFromStrings creates a client with default settings.
+//
+// Deprecated: use New with Credentials and options.
+func NewFromStrings(login, password, partnerToken, companyID, formID string) *Client {
+	c, _ := New(Credentials{
+		Login:        login,
+		Password:     password,
+		PartnerToken: partnerToken,
+		CompanyID:    companyID,
+		FormID:       formID,
+	})
+	return c
+}
+
+// SetBaseURLs points the client at other hosts, e.g. a fake server in
+// development. Empty values keep the current host. Call it before the
+// client is used.
+func (c *Client) SetBaseURLs(platform, api string) error {
+	if platform != "" {
+		u, err := url.Parse(platform)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("yclients: invalid platform base URL %q", platform)
+		}
+		c.baseURL = u
+	}
+	if api != "" {
+		u, err := url.Parse(api)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("yclients: invalid API base URL %q", api)
+		}
+		c.apiURL = strings.TrimRight(api, "/")
+	}
+	return nil
+}
+
+// DefaultHTTPTimeout bounds a single HTTP request to YCLIENTS.
+const DefaultHTTPTimeout = 10 * time.Second
+
+// SetHTTPClient replaces the HTTP client used for all requests, e.g. to add
+// tracing headers or record requests through a custom RoundTripper. The
+// client is shared by concurrent scan requests, so it and its transport must
+// be safe for concurrent use. A nil client restores the default. Call it
+// before the client is used.
+func (c *Client) SetHTTPClient(hc *http.Client) {
+	if hc == nil {
+		hc = &http.Client{Timeout: DefaultHTTPTimeout}
+	}
+	c.http = hc
+}
+
+// SetTimeout sets the per-request timeout of the current HTTP client; zero
+// means no timeout. Call it before the client is used.
+func (c *Client) SetTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	hc := *c.http
+	hc.Timeout = d
+	c.http = &hc
+}
+
+// DefaultTokenTTL is how long a user token is reused before authenticating
+// again. YCLIENTS user tokens live much longer; a token rejected earlier
+// is replaced on the first 401.
+const DefaultTokenTTL = time.Hour
+
+// SetTokenTTL sets how long a user token is reused; values below a minute
+// are raised to one minute.
+func (c *Client) SetTokenTTL(d time.Duration) {
+	if d < time.Minute {
+		d = time.Minute
 	}
+	c.tokenTTL = d
 }
 
-// Status describes current client configuration for debugging purposes.
+// SetRequestTimeout bounds each attempt of an availability search, on top
+// of the HTTP client timeout, which still applies to authentication and
+// catalog requests. Zero disables it. The caller's context caps the
+// request either way; a request running out of this timeout fails with
+// ErrTimeout and is retried like other network errors.
+func (c *Client) SetRequestTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	c.requestTimeout = d
+}
+
+// SetLocation sets the company timezone, used to place bare "HH:MM"
+// timeslots on their date. Without it such timeslots have no Start.
+func (c *Client) SetLocation(loc *time.Location) {
+	c.loc = loc
+}
+
+// SetRetryPolicy sets how many times an availability search is attempted
+// in total and the delay before the first retry. Values below 1 attempt
+// mean a single attempt.
+func (c *Client) SetRetryPolicy(attempts int, baseDelay time.Duration) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	c.retryAttempts = attempts
+	c.retryBaseDelay = baseDelay
+}
+
+func (c *Client) SetMetrics(m MetricsRecorder) {
+	c.metrics = m
+}
+
+// Status describes current client configuration and, when probed, whether
+// YCLIENTS answered.
 type Status struct {
 	AuthConfigured bool
 	CompanyID      string
 	FormID         string
 	Notes          string
+
+	// Set only by a probe.
+	Probed    bool
+	Reachable bool // YCLIENTS answered, even if with an error
+	AuthOK    bool // the user token was obtained
+	LatencyMS int64
+	LastError string
 }
 
 type AuthResponse struct {
@@ -385,17 +927,11 @@ type AuthResponse struct {
 	Success bool `json:"success"`
 }
 
-func (c *Client) authenticate(ctx context.Context) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	
-	if time.Now().Before(c.tokenExp) {
-		return nil
-	}
+// authenticate requests a new user token. Callers go through getToken.
+func (c *Client) authenticate(ctx context.Context) (string, error) {
+	c.logger(ctx).Debug("Authenticating with YCLIENTS API")
 	
-	c.log.Debug("Authenticating with YCLIENTS API")
-	
-	endpoint := "https://api.yclients.com/api/v1/auth"
+	endpoint := c.apiURL + "/api/v1/auth"
 	
 	payload := map[string]string{
 		"login":    c.login,
@@ -404,111 +940,207 @@ func (c *Client) authenticate(ctx context.Context) error {
 	
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("marshal auth payload: %w", err)
+		return "", fmt.Errorf("marshal auth payload: %w", err)
 	}
 	
-	c.log.DebugWithFields("Sending auth request", logger.Fields{"endpoint": endpoint})
+	c.logger(ctx).DebugWithFields("Sending auth request", logger.Fields{"endpoint": endpoint})
 	
 	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("create auth request: %w", err)
+		return "", fmt.Errorf("create auth request: %w", err)
 	}
 	
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/vnd.api.v2+json")
 	req.Header.Set("Authorization", "Bearer "+c.partnerToken)
+	if id := RequestID(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
 	
+	start := time.Now()
 	resp, err := c.http.Do(req)
+	c.observeRequest(authEndpointLabel, resp, time.Since(start))
 	if err != nil {
-		return fmt.Errorf("auth request failed: %w", err)
+		return "", fmt.Errorf("auth request failed: %w", err)
 	}
 	defer resp.Body.Close()
 	
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("read auth response: %w", err)
+		return "", fmt.Errorf("read auth response: %w", err)
 	}
 	
 	if resp.StatusCode != 201 {
-		c.log.WarnWithFields("Auth request failed", logger.Fields{
+		c.logger(ctx).WarnWithFields("Auth request failed", logger.Fields{
 			"status": resp.StatusCode,
 			"body":   truncateForLog(respBody, 300),
 		})
 		
-		// Try to parse error response for more details
-		var errorResp map[string]interface{}
-		if json.Unmarshal(respBody, &errorResp) == nil {
-			if meta, ok := errorResp["meta"].(map[string]interface{}); ok {
-				if msg, ok := meta["message"].(string); ok {
-					return fmt.Errorf("auth failed: %s", msg)
-				}
-			}
+		apiErr := newAPIError(resp, respBody)
+		// Client errors other than rate limiting mean the credentials are
+		// wrong; anything else may pass on the next attempt
+		if resp.StatusCode < 400 || resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return "", fmt.Errorf("auth request: %w", apiErr)
 		}
-		return fmt.Errorf("auth failed with status %d", resp.StatusCode)
+		return "", fmt.Errorf("%w: %w", ErrAuth, apiErr)
 	}
 	
 	var authResp AuthResponse
 	if err := json.Unmarshal(respBody, &authResp); err != nil {
-		return fmt.Errorf("parse auth response: %w", err)
+		c.observeParse(authEndpointLabel, err)
+		return "", badResponse("auth response", err)
 	}
 	
 	if !authResp.Success || authResp.Data.UserToken == "" {
-		return fmt.Errorf("auth unsuccessful: no user token")
+		return "", fmt.Errorf("%w: no user token in response", ErrAuth)
 	}
 	
-	c.userToken = authResp.Data.UserToken
-	c.tokenExp = time.Now().Add(4*time.Minute + 30*time.Second) // 4.5 min to refresh before expiry
-	
-	c.log.InfoWithFields("Successfully authenticated", logger.Fields{
+	c.logger(ctx).InfoWithFields("Successfully authenticated", logger.Fields{
 		"user_id":          authResp.Data.ID,
 		"user_name":        authResp.Data.Name,
-		"token_expires_in": "5m",
+		"token_ttl":        c.tokenTTL.String(),
 	})
 	
-	return nil
+	return authResp.Data.UserToken, nil
+}
+
+// authCall is an authentication request shared by every caller that
+// needs a token while it runs.
+type authCall struct {
+	done  chan struct{}
+	token string
+	err   error
 }
 
+// getToken returns a valid user token. When it has expired, one
+// authentication request is made and concurrent callers wait for it
+// instead of sending their own; each caller stops waiting when its ctx is
+// done.
 func (c *Client) getToken(ctx context.Context) (string, error) {
-	if err := c.authenticate(ctx); err != nil {
-		return "", err
+	c.mu.Lock()
+	if time.Now().Before(c.tokenExp) {
+		token := c.userToken
+		c.mu.Unlock()
+		return token, nil
 	}
-	
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.userToken, nil
+	call := c.auth
+	if call == nil {
+		call = &authCall{done: make(chan struct{})}
+		c.auth = call
+		// The request outlives a caller that gives up; the HTTP client
+		// timeout bounds it
+		go c.runAuth(context.WithoutCancel(ctx), call)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// invalidateToken drops token so the next request authenticates again,
+// unless another caller has already replaced it.
+func (c *Client) invalidateToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.userToken == token {
+		c.tokenExp = time.Time{}
+	}
+}
+
+func (c *Client) runAuth(ctx context.Context, call *authCall) {
+	call.token, call.err = c.authenticate(ctx)
+
+	c.mu.Lock()
+	if call.err == nil {
+		c.userToken = call.token
+		c.tokenExp = time.Now().Add(c.tokenTTL)
+	}
+	if c.metrics != nil {
+		c.metrics.RecordYClientsAuth(call.err == nil)
+	}
+	c.auth = nil
+	c.mu.Unlock()
+	close(call.done)
 }
 
 // GetStatus returns a summary of current configuration, useful for logs.
-func (c *Client) GetStatus(ctx context.Context) Status {
-	_ = ctx
+// With probe set it also obtains a user token and fetches the company,
+// which checks the credentials and both API hosts in two light requests.
+func (c *Client) GetStatus(ctx context.Context, probe bool) Status {
 	s := Status{
 		AuthConfigured: c.login != "" && c.password != "" && c.partnerToken != "",
 		CompanyID:      c.companyID,
 		FormID:         c.formID,
 		Notes:          "full client with login/password auth",
 	}
+	if !probe {
+		return s
+	}
+
+	s.Probed = true
+	start := time.Now()
+	_, err := c.getToken(ctx)
+	if err == nil {
+		s.AuthOK = true
+		_, err = c.GetCompany(ctx, c.companyID)
+	}
+	s.LatencyMS = time.Since(start).Milliseconds()
+
+	var apiErr *APIError
+	s.Reachable = err == nil || errors.As(err, &apiErr) || errors.Is(err, ErrAuth) || errors.Is(err, ErrBadResponse)
+	if err != nil {
+		s.LastError = err.Error()
+	}
 	return s
 }
 
-// HasNewSlots simulates checking for new available time slots.
-func (c *Client) HasNewSlots(ctx context.Context) (bool, string, error) {
-	start := time.Now()
-	c.log.InfoWithFields("Starting slot availability check", logger.Fields{
-		"company_id":    c.companyID,
-		"form_id":       c.formID,
-		"auth_set":      c.userToken != "",
-	})
-	
-	defer func() {
-		c.log.InfoWithFields("Slot availability check completed", logger.Fields{
-			"duration": time.Since(start).Truncate(time.Millisecond).String(),
-		})
-	}()
+// recordResponse keeps the latest status code and rate-limit headers for StatusReport.
+func (c *Client) recordResponse(resp *http.Response) {
+	limits := make(map[string]string)
+	for name, values := range resp.Header {
+		if strings.Contains(strings.ToLower(name), "ratelimit") && len(values) > 0 {
+			limits[name] = values[0]
+		}
+	}
+
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.lastRequestAt = time.Now()
+	c.lastStatus = resp.StatusCode
+	c.rateLimit = limits
+}
+
+// StatusReport implements status.Reporter.
+func (c *Client) StatusReport(ctx context.Context) interface{} {
+	st := c.GetStatus(ctx, false)
+
+	c.mu.RLock()
+	tokenExp := c.tokenExp
+	c.mu.RUnlock()
 
-	_ = ctx
-	// Placeholder behavior: no new slots
-	desc := fmt.Sprintf("stub @ %s", time.Now().Format("15:04:05"))
-	return false, desc, nil
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	report := map[string]interface{}{
+		"auth_configured": st.AuthConfigured,
+		"auth_mode":       "partner+user",
+		"company_id":      st.CompanyID,
+		"form_id":         st.FormID,
+		"base_url":        c.baseURL.String(),
+		"rate_limit":      c.rateLimit,
+	}
+	if ttl := time.Until(tokenExp); ttl > 0 {
+		report["token_ttl"] = ttl.Truncate(time.Second).String()
+	}
+	if !c.lastRequestAt.IsZero() {
+		report["last_request_at"] = c.lastRequestAt.UTC().Format(time.RFC3339)
+		report["last_status"] = c.lastStatus
+	}
+	return report
 }
 
 // truncateForLog returns a compact preview for logging error responses.
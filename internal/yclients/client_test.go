@@ -0,0 +1,423 @@
+package yclients_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients/yclientstest"
+)
+
+const (
+	testLocationID = 780413
+	testServiceID  = 15728488
+	testStaffID    = 2850522
+)
+
+func newTestServer(t *testing.T) *yclientstest.Server {
+	t.Helper()
+	srv := yclientstest.NewServer()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// lastRequest returns the last request the server received for path.
+func lastRequest(t *testing.T, srv *yclientstest.Server, path string) yclientstest.Request {
+	t.Helper()
+	reqs := srv.Requests()
+	for i := len(reqs) - 1; i >= 0; i-- {
+		if reqs[i].Path == path {
+			return reqs[i]
+		}
+	}
+	t.Fatalf("no request for %s", path)
+	return yclientstest.Request{}
+}
+
+func TestAvailabilityRequestHeaders(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.Client()
+	ctx := yclients.WithRequestID(context.Background(), "check-42")
+
+	if _, err := c.GetBookableStaff(ctx, testLocationID, testServiceID); err != nil {
+		t.Fatalf("GetBookableStaff: %v", err)
+	}
+
+	auth := lastRequest(t, srv, yclientstest.AuthPath)
+	if auth.Method != http.MethodPost {
+		t.Errorf("auth method = %s, want POST", auth.Method)
+	}
+	if got := auth.Header.Get("Authorization"); got != "Bearer test-partner-token" {
+		t.Errorf("auth Authorization = %q", got)
+	}
+	var creds map[string]string
+	if err := json.Unmarshal([]byte(auth.Body), &creds); err != nil {
+		t.Fatalf("auth body %q: %v", auth.Body, err)
+	}
+	if creds["login"] != "test" || creds["password"] != "secret" {
+		t.Errorf("auth body = %v, want the configured credentials", creds)
+	}
+
+	search := lastRequest(t, srv, yclientstest.SearchStaffPath)
+	want := map[string]string{
+		"Authorization":                 "Bearer test-partner-token, User test-user-token",
+		"Content-Type":                  "application/json",
+		"X-Yclients-Application-Name":   "client.booking",
+		"X-Yclients-Application-Action": "company",
+		yclients.RequestIDHeader:        "check-42",
+	}
+	for name, value := range want {
+		if got := search.Header.Get(name); got != value {
+			t.Errorf("search header %s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestGetBookableDatesPayload(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.Client()
+	staffID := testStaffID
+
+	if _, err := c.GetBookableDates(context.Background(), testLocationID, testServiceID, "2025-03-18", "2025-03-25", &staffID); err != nil {
+		t.Fatalf("GetBookableDates: %v", err)
+	}
+
+	var body struct {
+		Context struct {
+			LocationID int `json:"location_id"`
+		} `json:"context"`
+		Filter struct {
+			DateFrom string `json:"date_from"`
+			DateTo   string `json:"date_to"`
+			Records  []struct {
+				StaffID *int `json:"staff_id"`
+				Items   []struct {
+					Type string `json:"type"`
+					ID   int    `json:"id"`
+				} `json:"attendance_service_items"`
+			} `json:"records"`
+		} `json:"filter"`
+	}
+	req := lastRequest(t, srv, yclientstest.SearchDatesPath)
+	if err := json.Unmarshal([]byte(req.Body), &body); err != nil {
+		t.Fatalf("search body %q: %v", req.Body, err)
+	}
+	if body.Context.LocationID != testLocationID {
+		t.Errorf("location_id = %d", body.Context.LocationID)
+	}
+	if body.Filter.DateFrom != "2025-03-18" || body.Filter.DateTo != "2025-03-25" {
+		t.Errorf("dates = %s..%s", body.Filter.DateFrom, body.Filter.DateTo)
+	}
+	if len(body.Filter.Records) != 1 {
+		t.Fatalf("%d records, want 1", len(body.Filter.Records))
+	}
+	r := body.Filter.Records[0]
+	if r.StaffID == nil || *r.StaffID != testStaffID {
+		t.Errorf("staff_id = %v, want %d", r.StaffID, testStaffID)
+	}
+	if len(r.Items) != 1 || r.Items[0].Type != "service" || r.Items[0].ID != testServiceID {
+		t.Errorf("attendance_service_items = %+v", r.Items)
+	}
+}
+
+func TestGetBookableStaff(t *testing.T) {
+	srv := newTestServer(t)
+
+	staff, err := srv.Client().GetBookableStaff(context.Background(), testLocationID, testServiceID)
+	if err != nil {
+		t.Fatalf("GetBookableStaff: %v", err)
+	}
+	want := []yclients.StaffAvailability{{ID: testStaffID, PriceMin: 2500, PriceMax: 3000}}
+	if len(staff) != 1 || staff[0] != want[0] {
+		t.Errorf("staff = %+v, want only the bookable %+v", staff, want)
+	}
+}
+
+func TestGetBookableDates(t *testing.T) {
+	srv := newTestServer(t)
+
+	dates, err := srv.Client().GetBookableDatesAnyStaff(context.Background(), testLocationID, testServiceID, "2025-03-18", "2025-03-25")
+	if err != nil {
+		t.Fatalf("GetBookableDatesAnyStaff: %v", err)
+	}
+	if len(dates) != 1 || dates[0] != "2025-03-18" {
+		t.Errorf("dates = %v, want only the bookable 2025-03-18", dates)
+	}
+}
+
+func TestGetBookableDatesServices(t *testing.T) {
+	srv := newTestServer(t)
+	services := []int{testServiceID, testServiceID + 1}
+
+	dates, err := srv.Client().GetBookableDatesServices(context.Background(), testLocationID, services, "2025-03-18", "2025-03-25")
+	if err != nil {
+		t.Fatalf("GetBookableDatesServices: %v", err)
+	}
+	if len(dates) != 1 || dates[0] != "2025-03-18" {
+		t.Errorf("dates = %v, want only the bookable 2025-03-18", dates)
+	}
+	want, err := yclients.BuildSearchDatesPayloadServices(testLocationID, services, "2025-03-18", "2025-03-25", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lastRequest(t, srv, yclientstest.SearchDatesPath).Body; got != string(want) {
+		t.Errorf("search body = %s, want one record with both services: %s", got, want)
+	}
+	if got := srv.Calls(yclientstest.SearchDatesPath); got != 1 {
+		t.Errorf("%d dates requests, want 1", got)
+	}
+}
+
+func TestGetBookableDatesByService(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.SearchDatesPath, yclientstest.Response{Status: http.StatusOK, Body: `{"data":[
+		{"type":"booking_search_result_dates","id":"1","attributes":{"date":"2025-03-18","is_bookable":true,"service_id":15728488}},
+		{"type":"booking_search_result_dates","id":"2","attributes":{"date":"2025-03-19","is_bookable":true,"service_id":15728489}},
+		{"type":"booking_search_result_dates","id":"3","attributes":{"date":"2025-03-20","is_bookable":false,"service_id":15728489}}
+	]}`})
+	services := []int{testServiceID, testServiceID + 1}
+
+	dates, err := srv.Client().GetBookableDatesByService(context.Background(), testLocationID, services, "2025-03-18", "2025-03-25", testStaffID)
+	if err != nil {
+		t.Fatalf("GetBookableDatesByService: %v", err)
+	}
+	want := map[int][]string{testServiceID: {"2025-03-18"}, testServiceID + 1: {"2025-03-19"}}
+	if !reflect.DeepEqual(dates, want) {
+		t.Errorf("dates = %v, want %v", dates, want)
+	}
+	if got := srv.Calls(yclientstest.SearchDatesPath); got != 1 {
+		t.Errorf("%d dates requests, want 1", got)
+	}
+}
+
+func TestGetBookableByServiceUnattributed(t *testing.T) {
+	srv := newTestServer(t)
+	services := []int{testServiceID, testServiceID + 1}
+
+	if _, err := srv.Client().GetBookableDatesByService(context.Background(), testLocationID, services, "2025-03-18", "2025-03-25", testStaffID); !errors.Is(err, yclients.ErrUnattributed) {
+		t.Errorf("dates without services: err = %v, want ErrUnattributed", err)
+	}
+	if _, err := srv.Client().GetBookableTimeslotsByService(context.Background(), testLocationID, services, "2025-03-18", testStaffID); !errors.Is(err, yclients.ErrUnattributed) {
+		t.Errorf("timeslots without services: err = %v, want ErrUnattributed", err)
+	}
+}
+
+func TestGetBookableTimeslotsByService(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.SearchTimeslotsPath, yclientstest.Response{Status: http.StatusOK, Body: `{"data":[
+		{"type":"booking_search_result_timeslots","id":"1","attributes":{"datetime":"2025-03-18T10:00:00+03:00","is_bookable":true,"service_id":15728488}},
+		{"type":"booking_search_result_timeslots","id":"2","attributes":{"datetime":"2025-03-18T12:00:00+03:00","is_bookable":true,"service_id":15728489}}
+	]}`})
+	services := []int{testServiceID, testServiceID + 1}
+
+	times, err := srv.Client().GetBookableTimeslotsByService(context.Background(), testLocationID, services, "2025-03-18", testStaffID)
+	if err != nil {
+		t.Fatalf("GetBookableTimeslotsByService: %v", err)
+	}
+	if len(times[testServiceID]) != 1 || times[testServiceID][0].Datetime != "2025-03-18T10:00:00+03:00" {
+		t.Errorf("timeslots of service %d = %v", testServiceID, times[testServiceID])
+	}
+	if len(times[testServiceID+1]) != 1 || times[testServiceID+1][0].Datetime != "2025-03-18T12:00:00+03:00" {
+		t.Errorf("timeslots of service %d = %v", testServiceID+1, times[testServiceID+1])
+	}
+}
+
+func TestGetBookableTimeslots(t *testing.T) {
+	srv := newTestServer(t)
+
+	slots, err := srv.Client().GetBookableTimeslots(context.Background(), testLocationID, testServiceID, "2025-03-18", testStaffID)
+	if err != nil {
+		t.Fatalf("GetBookableTimeslots: %v", err)
+	}
+	if len(slots) != 2 {
+		t.Fatalf("%d timeslots, want the 2 bookable ones", len(slots))
+	}
+	if slots[0].Datetime != "2025-03-18T10:00:00+03:00" || slots[0].Start.IsZero() || slots[0].HasSeats {
+		t.Errorf("first timeslot = %+v", slots[0])
+	}
+	if !slots[1].HasSeats || slots[1].SeatsLeft != 2 {
+		t.Errorf("group timeslot = %+v, want 2 seats left", slots[1])
+	}
+}
+
+func TestTokenIsReused(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.Client()
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetBookableStaff(context.Background(), testLocationID, testServiceID); err != nil {
+			t.Fatalf("GetBookableStaff: %v", err)
+		}
+	}
+	if got := srv.Calls(yclientstest.AuthPath); got != 1 {
+		t.Errorf("%d auth requests, want 1", got)
+	}
+}
+
+func TestTokenRefreshedAfterUnauthorized(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.SearchStaffPath,
+		yclientstest.Response{Status: http.StatusUnauthorized, Body: yclientstest.ErrorUnauthorized},
+		yclientstest.Response{Status: http.StatusOK, Body: yclientstest.SearchStaff},
+	)
+	c := srv.Client()
+
+	if _, err := c.GetBookableStaff(context.Background(), testLocationID, testServiceID); err != nil {
+		t.Fatalf("GetBookableStaff: %v", err)
+	}
+	if got := srv.Calls(yclientstest.AuthPath); got != 2 {
+		t.Errorf("%d auth requests, want a second one after the 401", got)
+	}
+	if got := srv.Calls(yclientstest.SearchStaffPath); got != 2 {
+		t.Errorf("%d search requests, want 2", got)
+	}
+}
+
+func TestAuthRejected(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.AuthPath, yclientstest.Response{Status: http.StatusUnauthorized, Body: yclientstest.AuthFailed})
+
+	_, err := srv.Client().GetBookableStaff(context.Background(), testLocationID, testServiceID)
+	if !errors.Is(err, yclients.ErrAuth) {
+		t.Fatalf("err = %v, want ErrAuth", err)
+	}
+	if got := srv.Calls(yclientstest.SearchStaffPath); got != 0 {
+		t.Errorf("%d search requests sent without a token", got)
+	}
+}
+
+func TestServerErrorIsRetried(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.SearchDatesPath,
+		yclientstest.Response{Status: http.StatusInternalServerError, Body: yclientstest.ErrorServer},
+		yclientstest.Response{Status: http.StatusOK, Body: yclientstest.SearchDates},
+	)
+
+	dates, err := srv.Client().GetBookableDatesAnyStaff(context.Background(), testLocationID, testServiceID, "2025-03-18", "2025-03-25")
+	if err != nil {
+		t.Fatalf("GetBookableDatesAnyStaff: %v", err)
+	}
+	if len(dates) != 1 {
+		t.Errorf("dates = %v", dates)
+	}
+	if got := srv.Calls(yclientstest.SearchDatesPath); got != 2 {
+		t.Errorf("%d search requests, want 2", got)
+	}
+}
+
+func TestServerErrorAfterRetries(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.SearchDatesPath, yclientstest.Response{Status: http.StatusInternalServerError, Body: yclientstest.ErrorServer})
+
+	_, err := srv.Client().GetBookableDatesAnyStaff(context.Background(), testLocationID, testServiceID, "2025-03-18", "2025-03-25")
+	var apiErr *yclients.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusInternalServerError || apiErr.Message != "Internal server error" {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+	if got := srv.Calls(yclientstest.SearchDatesPath); got != yclients.DefaultRetryAttempts {
+		t.Errorf("%d search requests, want %d", got, yclients.DefaultRetryAttempts)
+	}
+}
+
+func TestClientErrorIsNotRetried(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.SearchTimeslotsPath, yclientstest.Response{
+		Status: http.StatusUnprocessableEntity,
+		Body:   `{"errors":[{"code":"422","detail":"запись на это время недоступна"}]}`,
+	})
+
+	_, err := srv.Client().GetBookableTimeslots(context.Background(), testLocationID, testServiceID, "2025-03-18", testStaffID)
+	var apiErr *yclients.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("err = %v, want a 422 APIError", err)
+	}
+	if apiErr.Message != "запись на это время недоступна" {
+		t.Errorf("message = %q", apiErr.Message)
+	}
+	if got := srv.Calls(yclientstest.SearchTimeslotsPath); got != 1 {
+		t.Errorf("%d search requests, want 1", got)
+	}
+}
+
+func TestMalformedResponse(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.SearchStaffPath, yclientstest.Response{Status: http.StatusOK, Body: `<html>maintenance</html>`})
+
+	_, err := srv.Client().GetBookableStaff(context.Background(), testLocationID, testServiceID)
+	if !errors.Is(err, yclients.ErrBadResponse) {
+		t.Fatalf("err = %v, want ErrBadResponse", err)
+	}
+	if got := srv.Calls(yclientstest.SearchStaffPath); got != 1 {
+		t.Errorf("%d search requests, want 1", got)
+	}
+}
+
+func TestRateLimitedBeyondWaitIsReturned(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.SearchStaffPath, yclientstest.Response{
+		Status: http.StatusTooManyRequests,
+		Body:   yclientstest.ErrorRateLimited,
+		Header: http.Header{"Retry-After": {"120"}},
+	})
+
+	_, err := srv.Client().GetBookableStaff(context.Background(), testLocationID, testServiceID)
+	if !errors.Is(err, yclients.ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	if wait, ok := yclients.RetryAfter(err); !ok || wait != 2*time.Minute {
+		t.Errorf("RetryAfter = %s, %v, want 2m", wait, ok)
+	}
+	if got := srv.Calls(yclientstest.SearchStaffPath); got != 1 {
+		t.Errorf("%d search requests, want 1: a long Retry-After is left to the caller", got)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	srv := newTestServer(t)
+
+	_, err := srv.Client().GetCompany(context.Background(), "1")
+	if !errors.Is(err, yclients.ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestInvalidSearchIsNotSent(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.Client()
+
+	_, err := c.GetBookableDatesAnyStaff(context.Background(), testLocationID, 0, "2025-03-18", "2025-03-25")
+	if !errors.Is(err, yclients.ErrBadRequest) {
+		t.Errorf("zero service: err = %v, want ErrBadRequest", err)
+	}
+	_, err = c.GetBookableTimeslots(context.Background(), testLocationID, testServiceID, "tomorrow", testStaffID)
+	if !errors.Is(err, yclients.ErrBadRequest) {
+		t.Errorf("bad date: err = %v, want ErrBadRequest", err)
+	}
+	if reqs := srv.Requests(); len(reqs) != 0 {
+		t.Errorf("%d requests sent for invalid searches, want none", len(reqs))
+	}
+}
+
+func TestGetStaff(t *testing.T) {
+	srv := newTestServer(t)
+
+	staff, err := srv.Client().GetStaff(context.Background(), strconv.Itoa(testLocationID))
+	if err != nil {
+		t.Fatalf("GetStaff: %v", err)
+	}
+	if len(staff) != 2 || !staff[0].Bookable || staff[1].Bookable || staff[1].Name != "Пётр" {
+		t.Errorf("staff = %+v, want Иван bookable and Пётр listed but not bookable", staff)
+	}
+	if got := srv.Calls(yclientstest.StaffPath); got != 1 {
+		t.Errorf("%d requests to %s, want 1", got, yclientstest.StaffPath)
+	}
+}
@@ -0,0 +1,158 @@
+package yclients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Default hosts. The REST API serves authentication and company data;
+// availability searches go through the platform host.
+const (
+	DefaultAPIBaseURL      = "https://api.yclients.com"
+	DefaultPlatformBaseURL = "https://platform.yclients.com"
+)
+
+var (
+	// ErrFormMismatch means the booking form belongs to another company.
+	ErrFormMismatch = errors.New("yclients: booking form does not belong to the configured company")
+	// ErrFormUnverifiable means the form metadata names neither a company nor a chain.
+	ErrFormUnverifiable = errors.New("yclients: booking form does not reference a company")
+)
+
+// Company is the part of the company record used for consistency checks.
+type Company struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	MainGroupID int    `json:"main_group_id"`
+}
+
+// BookingForm is the part of the booking form settings used for consistency
+// checks. Chain-wide forms reference a group instead of a company.
+type BookingForm struct {
+	ID        int    `json:"id"`
+	Title     string `json:"title"`
+	CompanyID int    `json:"company_id"`
+	GroupID   int    `json:"group_id"`
+}
+
+type dataEnvelope[T any] struct {
+	Success bool `json:"success"`
+	Data    T    `json:"data"`
+}
+
+func parseCompany(data []byte) (Company, error) {
+	var resp dataEnvelope[Company]
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return Company{}, badResponse("company", err)
+	}
+	if !resp.Success || resp.Data.ID == 0 {
+		return Company{}, errors.New("parse company: empty response")
+	}
+	return resp.Data, nil
+}
+
+func parseBookingForm(data []byte) (BookingForm, error) {
+	var resp dataEnvelope[BookingForm]
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return BookingForm{}, badResponse("booking form", err)
+	}
+	if !resp.Success || resp.Data.ID == 0 {
+		return BookingForm{}, errors.New("parse booking form: empty response")
+	}
+	return resp.Data, nil
+}
+
+// GetCompany fetches company info for companyID.
+func (c *Client) GetCompany(ctx context.Context, companyID string) (Company, error) {
+	data, err := c.getAPI(ctx, "/api/v1/company/"+companyID)
+	if err != nil {
+		return Company{}, err
+	}
+	company, err := parseCompany(data)
+	c.observeParse(endpointLabel("/api/v1/company/"+companyID), err)
+	return company, err
+}
+
+// GetBookingForm fetches booking form settings. Both "n841217" (as used in
+// booking URLs) and bare "841217" are accepted.
+func (c *Client) GetBookingForm(ctx context.Context, formID string) (BookingForm, error) {
+	data, err := c.getAPI(ctx, "/api/v1/bookform/"+strings.TrimPrefix(formID, "n"))
+	if err != nil {
+		return BookingForm{}, err
+	}
+	form, err := parseBookingForm(data)
+	c.observeParse(endpointLabel("/api/v1/bookform/"+formID), err)
+	return form, err
+}
+
+// VerifyCompanyForm checks that the configured booking form belongs to the
+// configured company. It returns the fetched records even on mismatch.
+func (c *Client) VerifyCompanyForm(ctx context.Context) (Company, BookingForm, error) {
+	company, err := c.GetCompany(ctx, c.companyID)
+	if err != nil {
+		return Company{}, BookingForm{}, fmt.Errorf("get company %s: %w", c.companyID, err)
+	}
+	form, err := c.GetBookingForm(ctx, c.formID)
+	if err != nil {
+		return company, BookingForm{}, fmt.Errorf("get booking form %s: %w", c.formID, err)
+	}
+
+	switch {
+	case form.CompanyID != 0:
+		if form.CompanyID != company.ID {
+			return company, form, fmt.Errorf("%w: form %s belongs to company %d, configured %d",
+				ErrFormMismatch, c.formID, form.CompanyID, company.ID)
+		}
+	case form.GroupID != 0:
+		if form.GroupID != company.MainGroupID {
+			return company, form, fmt.Errorf("%w: form %s belongs to chain %d, company %d is in chain %d",
+				ErrFormMismatch, c.formID, form.GroupID, company.ID, company.MainGroupID)
+		}
+	default:
+		return company, form, ErrFormUnverifiable
+	}
+	return company, form, nil
+}
+
+// getAPI performs a GET against the REST API authorized with the partner token.
+func (c *Client) getAPI(ctx context.Context, path string) ([]byte, error) {
+	if c.http == nil {
+		return nil, fmt.Errorf("yclients: http client not initialized")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("yclients: build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.api.v2+json")
+	req.Header.Set("Authorization", "Bearer "+c.partnerToken)
+	if id := RequestID(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	if err := c.waitRateLimit(ctx, path); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	c.observeRequest(endpointLabel(path), resp, time.Since(start))
+	if err != nil {
+		return nil, classifyTimeout(ctx, fmt.Errorf("yclients: request failed after %s: %w", time.Since(start).Truncate(time.Millisecond), err))
+	}
+	defer func() { _ = resp.Body.Close() }()
+	c.recordResponse(resp)
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("yclients: read body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, data)
+	}
+	return data, nil
+}
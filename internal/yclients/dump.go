@@ -0,0 +1,120 @@
+package yclients
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// Limits of the debug dump: bodies are truncated to maxDumpBody bytes and
+// only the newest maxDumpFiles files are kept.
+const (
+	maxDumpBody  = 256 << 10
+	maxDumpFiles = 500
+)
+
+// SetDumpDir makes the client write every availability request and its
+// response to a file under dir, for investigating changes in the response
+// format. An empty dir disables dumping. Call it before the client is used.
+func (c *Client) SetDumpDir(dir string) error {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("yclients: create dump dir: %w", err)
+		}
+	}
+	c.dumpDir = dir
+	return nil
+}
+
+// dump writes one exchange to the dump directory and returns the file
+// path, or "" when dumping is off or failed. status is zero when no
+// response was received.
+func (c *Client) dump(ctx context.Context, endpoint string, reqBody []byte, status int, respBody []byte) string {
+	if c.dumpDir == "" {
+		return ""
+	}
+	now := time.Now().UTC()
+	name := fmt.Sprintf("%s-%s", now.Format("20060102T150405.000000000"), dumpSlug(endpoint))
+	if id := RequestID(ctx); id != "" {
+		name += "-" + id
+	}
+	path := filepath.Join(c.dumpDir, name+".txt")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "time: %s\nendpoint: %s\n", now.Format(time.RFC3339Nano), endpoint)
+	if id := RequestID(ctx); id != "" {
+		fmt.Fprintf(&b, "request_id: %s\n", id)
+	}
+	fmt.Fprintf(&b, "status: %d\n\n--- request ---\n%s\n\n--- response ---\n%s\n",
+		status, dumpBody(reqBody), dumpBody(respBody))
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		c.logger(ctx).WithError(err).Warn("Failed to write YCLIENTS debug dump")
+		return ""
+	}
+	if last, ok := ctx.Value(lastDumpKey{}).(*string); ok {
+		*last = path
+	}
+	c.pruneDumps()
+	return path
+}
+
+type lastDumpKey struct{}
+
+// withLastDump returns a context in which dump records the path of the
+// latest file it wrote into *path, so a caller can point to the response
+// it failed to parse.
+func withLastDump(ctx context.Context, path *string) context.Context {
+	return context.WithValue(ctx, lastDumpKey{}, path)
+}
+
+func dumpBody(b []byte) string {
+	if len(b) > maxDumpBody {
+		return string(b[:maxDumpBody]) + fmt.Sprintf("\n... truncated, %d bytes total", len(b))
+	}
+	return string(b)
+}
+
+// dumpSlug turns an endpoint into a file name part, e.g. "search-timeslots".
+func dumpSlug(endpoint string) string {
+	endpoint = requestLabel(endpoint)
+	slug := endpoint[strings.LastIndex(endpoint, "/")+1:]
+	if slug == "" {
+		slug = "request"
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, slug)
+}
+
+// pruneDumps deletes the oldest dump files beyond maxDumpFiles. File names
+// start with the timestamp, so name order is age order.
+func (c *Client) pruneDumps() {
+	entries, err := os.ReadDir(c.dumpDir)
+	if err != nil {
+		return
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".txt") {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) <= maxDumpFiles {
+		return
+	}
+	sort.Strings(names)
+	for _, name := range names[:len(names)-maxDumpFiles] {
+		if err := os.Remove(filepath.Join(c.dumpDir, name)); err != nil {
+			c.log.WithError(err).WarnWithFields("Failed to prune YCLIENTS debug dump", logger.Fields{"file": name})
+		}
+	}
+}
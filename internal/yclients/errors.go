@@ -0,0 +1,194 @@
+package yclients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Failure classes callers can match with errors.Is.
+var (
+	// ErrAuth means YCLIENTS rejected the configured credentials.
+	ErrAuth = errors.New("yclients: authentication failed")
+	// ErrRateLimited means YCLIENTS answered 429; RetryAfter tells how
+	// long it asked to wait.
+	ErrRateLimited = errors.New("yclients: rate limited")
+	// ErrBadResponse means a response could not be parsed.
+	ErrBadResponse = errors.New("yclients: malformed response")
+	// ErrNotFound means the requested company, form or resource does not
+	// exist.
+	ErrNotFound = errors.New("yclients: not found")
+	// ErrTimeout means a request ran out of its own time budget: the
+	// request timeout or the HTTP client timeout, not the caller's
+	// context.
+	ErrTimeout = errors.New("yclients: request timed out")
+	// ErrBadRequest means a request was not sent because its parameters
+	// are invalid, e.g. a zero service ID; repeating it can't help.
+	ErrBadRequest = errors.New("yclients: invalid request")
+	// ErrUnattributed means a search for several services at once
+	// answered without naming the service of each result, so the answer
+	// can't be split per service.
+	ErrUnattributed = errors.New("yclients: results not attributed to services")
+)
+
+// APIError is a non-2xx response from YCLIENTS. It matches ErrAuth,
+// ErrRateLimited and ErrNotFound by status code.
+type APIError struct {
+	StatusCode int
+	// Code and Message come from the error envelope of the body; empty
+	// when the body has none.
+	Code    string
+	Message string
+	Raw     string // body, truncated for logs
+	// RetryAfter is the delay requested by a Retry-After header; zero
+	// when absent.
+	RetryAfter time.Duration
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Raw:        truncateForLog(body, 200),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	e.Code, e.Message = parseErrorBody(body)
+	return e
+}
+
+// errorEnvelope covers both error shapes YCLIENTS uses: the REST API's
+// meta object and the JSON:API errors list of the booking platform.
+type errorEnvelope struct {
+	Meta *struct {
+		Message string          `json:"message"`
+		Code    json.RawMessage `json:"code"`
+	} `json:"meta"`
+	Errors json.RawMessage `json:"errors"`
+}
+
+type jsonAPIError struct {
+	Code   json.RawMessage `json:"code"`
+	Title  string          `json:"title"`
+	Detail string          `json:"detail"`
+}
+
+// parseErrorBody extracts the error code and message from a response
+// body, if it has any.
+func parseErrorBody(body []byte) (code, message string) {
+	var env errorEnvelope
+	if json.Unmarshal(body, &env) != nil {
+		return "", ""
+	}
+	if env.Meta != nil && env.Meta.Message != "" {
+		return rawString(env.Meta.Code), env.Meta.Message
+	}
+
+	var list []jsonAPIError
+	if json.Unmarshal(env.Errors, &list) != nil {
+		var single jsonAPIError
+		if json.Unmarshal(env.Errors, &single) != nil {
+			return "", ""
+		}
+		list = []jsonAPIError{single}
+	}
+	for _, e := range list {
+		msg := e.Detail
+		if msg == "" {
+			msg = e.Title
+		}
+		if msg != "" {
+			return rawString(e.Code), msg
+		}
+	}
+	return "", ""
+}
+
+// rawString renders a JSON string or number without quotes.
+func rawString(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case e.Message != "" && e.Code != "":
+		return fmt.Sprintf("yclients: status %d: %s (code %s)", e.StatusCode, e.Message, e.Code)
+	case e.Message != "":
+		return fmt.Sprintf("yclients: status %d: %s", e.StatusCode, e.Message)
+	case e.Raw != "":
+		return fmt.Sprintf("yclients: non-2xx status %d: %s", e.StatusCode, e.Raw)
+	}
+	return fmt.Sprintf("yclients: non-2xx status %d", e.StatusCode)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrAuth:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// temporary reports whether repeating the request may succeed.
+func (e *APIError) temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// RetryAfter returns the delay YCLIENTS asked for in a rate-limited
+// response wrapped by err, if any.
+func RetryAfter(err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter, true
+	}
+	return 0, false
+}
+
+// parseRetryAfter reads a Retry-After value given in seconds or as an HTTP
+// date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// classifyTimeout wraps err with ErrTimeout when the request timed out
+// while the caller's ctx was still live.
+func classifyTimeout(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() != nil || errors.Is(err, ErrTimeout) {
+		return err
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
+
+func badResponse(what string, err error) error {
+	return fmt.Errorf("%w: parse %s: %w", ErrBadResponse, what, err)
+}
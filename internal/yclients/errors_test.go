@@ -0,0 +1,173 @@
+package yclients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	sentinels := []error{ErrAuth, ErrRateLimited, ErrNotFound, ErrBadResponse, ErrTimeout, ErrBadRequest}
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusUnauthorized, ErrAuth},
+		{http.StatusForbidden, ErrAuth},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusBadRequest, nil},
+		{http.StatusInternalServerError, nil},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			err := fmt.Errorf("get staff: %w", &APIError{StatusCode: tt.status})
+			for _, s := range sentinels {
+				if got := errors.Is(err, s); got != (s == tt.want) {
+					t.Errorf("errors.Is(%d, %v) = %v", tt.status, s, got)
+				}
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Errorf("errors.As did not find the APIError in %v", err)
+			}
+		})
+	}
+}
+
+func TestAPIErrorTemporary(t *testing.T) {
+	for status, want := range map[int]bool{
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+		http.StatusBadGateway:          true,
+		http.StatusBadRequest:          false,
+		http.StatusNotFound:            false,
+	} {
+		if got := (&APIError{StatusCode: status}).temporary(); got != want {
+			t.Errorf("temporary(%d) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 3, 18, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"-5", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"30"}}}
+	err := fmt.Errorf("search: %w", newAPIError(resp, nil))
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("errors.Is(%v, ErrRateLimited) = false", err)
+	}
+	if wait, ok := RetryAfter(err); !ok || wait != 30*time.Second {
+		t.Errorf("RetryAfter = %s, %v, want 30s", wait, ok)
+	}
+	if _, ok := RetryAfter(errors.New("other")); ok {
+		t.Error("RetryAfter reported a delay for a plain error")
+	}
+}
+
+func TestClassifyTimeout(t *testing.T) {
+	live := context.Background()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := classifyTimeout(live, fmt.Errorf("request: %w", context.DeadlineExceeded))
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("own deadline: err = %v, want ErrTimeout wrapping the cause", err)
+	}
+	if err := classifyTimeout(canceled, context.DeadlineExceeded); errors.Is(err, ErrTimeout) {
+		t.Errorf("caller's ctx done: err = %v, want it left alone", err)
+	}
+	if err := classifyTimeout(live, errors.New("connection refused")); errors.Is(err, ErrTimeout) {
+		t.Errorf("other error: err = %v, want it left alone", err)
+	}
+	if err := classifyTimeout(live, nil); err != nil {
+		t.Errorf("nil error became %v", err)
+	}
+}
+
+func TestBadResponse(t *testing.T) {
+	cause := errors.New("unexpected end of JSON input")
+	err := badResponse("dates", cause)
+	if !errors.Is(err, ErrBadResponse) || !errors.Is(err, cause) {
+		t.Errorf("err = %v, want ErrBadResponse wrapping the cause", err)
+	}
+}
+
+func TestParseErrorBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantCode    string
+		wantMessage string
+	}{
+		{"rest meta", `{"success":false,"data":null,"meta":{"message":"Partner token invalid"}}`, "", "Partner token invalid"},
+		{"rest meta with code", `{"success":false,"meta":{"message":"Компания не найдена","code":404}}`, "404", "Компания не найдена"},
+		{"json:api list", `{"errors":[{"code":"timeslot_unavailable","title":"Unprocessable","detail":"запись на это время недоступна"}]}`, "timeslot_unavailable", "запись на это время недоступна"},
+		{"json:api title only", `{"errors":[{"code":422,"title":"Unprocessable Entity"}]}`, "422", "Unprocessable Entity"},
+		{"json:api skips empty entries", `{"errors":[{"code":1},{"code":2,"detail":"second"}]}`, "2", "second"},
+		{"json:api single object", `{"errors":{"code":"bad_staff","detail":"staff not found"}}`, "bad_staff", "staff not found"},
+		{"empty meta message", `{"meta":{"message":""}}`, "", ""},
+		{"html", `<html><body>502 Bad Gateway</body></html>`, "", ""},
+		{"empty", ``, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, msg := parseErrorBody([]byte(tt.body))
+			if code != tt.wantCode || msg != tt.wantMessage {
+				t.Errorf("parseErrorBody = %q, %q, want %q, %q", code, msg, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  *APIError
+		want string
+	}{
+		{&APIError{StatusCode: 422, Code: "timeslot_unavailable", Message: "запись на это время недоступна"},
+			"yclients: status 422: запись на это время недоступна (code timeslot_unavailable)"},
+		{&APIError{StatusCode: 401, Message: "Partner token invalid", Raw: "{}"},
+			"yclients: status 401: Partner token invalid"},
+		{&APIError{StatusCode: 502, Raw: "<html>"}, "yclients: non-2xx status 502: <html>"},
+		{&APIError{StatusCode: 500}, "yclients: non-2xx status 500"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestNewAPIErrorTruncatesRaw(t *testing.T) {
+	body := []byte(`{"meta":{"message":"Too many requests"},"padding":"` + strings.Repeat("x", 500) + `"}`)
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+
+	e := newAPIError(resp, body)
+	if e.Message != "Too many requests" {
+		t.Errorf("Message = %q", e.Message)
+	}
+	if len(e.Raw) >= len(body) {
+		t.Errorf("Raw is %d bytes, want it truncated from %d", len(e.Raw), len(body))
+	}
+}
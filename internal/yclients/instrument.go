@@ -0,0 +1,72 @@
+package yclients
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Endpoints of the availability search, also used as metric labels.
+const (
+	searchStaffEndpoint     = "/api/v1/b2c/booking/availability/search-staff"
+	searchDatesEndpoint     = "/api/v1/b2c/booking/availability/search-dates"
+	searchTimeslotsEndpoint = "/api/v1/b2c/booking/availability/search-timeslots"
+	searchTimesEndpoint     = "/api/v1/b2c/booking/availability/search-times"
+
+	authEndpointLabel = "/api/v1/auth"
+)
+
+// observeRequest records the duration of one HTTP request and, when it
+// failed, the error type. resp is nil when no response was received.
+func (c *Client) observeRequest(endpoint string, resp *http.Response, d time.Duration) {
+	if c.metrics == nil {
+		return
+	}
+	status := "error"
+	if resp != nil {
+		status = statusClass(resp.StatusCode)
+	}
+	c.metrics.ObserveYClientsRequest(endpoint, status, d.Seconds())
+	switch {
+	case resp == nil:
+		c.metrics.RecordYClientsError(endpoint, "network")
+	case resp.StatusCode >= 500:
+		c.metrics.RecordYClientsError(endpoint, "5xx")
+	case resp.StatusCode >= 400:
+		c.metrics.RecordYClientsError(endpoint, "4xx")
+		if resp.StatusCode == http.StatusTooManyRequests {
+			c.metrics.RecordYClientsThrottled(endpoint)
+		}
+	}
+}
+
+// observeParse counts a response that could not be parsed.
+func (c *Client) observeParse(endpoint string, err error) {
+	if c.metrics != nil && err != nil {
+		c.metrics.RecordYClientsError(endpoint, "parse")
+	}
+}
+
+// requestLabel drops the query, e.g. a page number, from a request
+// endpoint.
+func requestLabel(endpoint string) string {
+	if i := strings.IndexByte(endpoint, '?'); i >= 0 {
+		return endpoint[:i]
+	}
+	return endpoint
+}
+
+func statusClass(code int) string {
+	return string(rune('0'+code/100)) + "xx"
+}
+
+// endpointLabel replaces a trailing company, form or other ID in path with
+// ":id" so metric labels stay bounded.
+func endpointLabel(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i < 0 || !strings.ContainsFunc(path[i+1:], unicode.IsDigit) {
+		return path
+	}
+	return path[:i+1] + ":id"
+}
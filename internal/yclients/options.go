@@ -0,0 +1,120 @@
+package yclients
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// Credentials identify the partner, the user and the booking form the
+// client works with.
+type Credentials struct {
+	Login        string
+	Password     string
+	PartnerToken string
+	CompanyID    string
+	FormID       string
+}
+
+// Option configures a Client in New. Options are applied in order, so
+// WithTimeout after WithHTTPClient changes the timeout of the given client.
+type Option func(*Client) error
+
+// WithLogger sets the logger; by default the client logs through a new
+// logger with component=yclients_client.
+func WithLogger(log *logger.Logger) Option {
+	return func(c *Client) error {
+		if log != nil {
+			c.log = log
+		}
+		return nil
+	}
+}
+
+// WithHTTPClient replaces the HTTP client; see SetHTTPClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) error {
+		c.SetHTTPClient(hc)
+		return nil
+	}
+}
+
+// WithTimeout sets the per-request timeout of the HTTP client, default
+// DefaultHTTPTimeout; see SetTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		c.SetTimeout(d)
+		return nil
+	}
+}
+
+// WithRequestTimeout bounds each availability request attempt; see
+// SetRequestTimeout.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		c.SetRequestTimeout(d)
+		return nil
+	}
+}
+
+// WithTokenTTL sets how long a user token is reused, default
+// DefaultTokenTTL; see SetTokenTTL.
+func WithTokenTTL(d time.Duration) Option {
+	return func(c *Client) error {
+		c.SetTokenTTL(d)
+		return nil
+	}
+}
+
+// WithBaseURL points the client at other platform and REST API hosts;
+// see SetBaseURLs.
+func WithBaseURL(platform, api string) Option {
+	return func(c *Client) error {
+		return c.SetBaseURLs(platform, api)
+	}
+}
+
+// WithMetrics sets the recorder of request, retry and rate limiter
+// metrics; without it no metrics are recorded.
+func WithMetrics(m MetricsRecorder) Option {
+	return func(c *Client) error {
+		c.SetMetrics(m)
+		return nil
+	}
+}
+
+// WithRateLimit sets the client-side request rate and burst, default
+// DefaultRateLimit and DefaultRateBurst; see SetRateLimit.
+func WithRateLimit(rate float64, burst int) Option {
+	return func(c *Client) error {
+		c.SetRateLimit(rate, burst)
+		return nil
+	}
+}
+
+// WithRetryPolicy sets the attempts per availability request and the
+// first retry delay, default DefaultRetryAttempts and
+// DefaultRetryBaseDelay; see SetRetryPolicy.
+func WithRetryPolicy(attempts int, baseDelay time.Duration) Option {
+	return func(c *Client) error {
+		c.SetRetryPolicy(attempts, baseDelay)
+		return nil
+	}
+}
+
+// WithLocation sets the company timezone; see SetLocation.
+func WithLocation(loc *time.Location) Option {
+	return func(c *Client) error {
+		c.SetLocation(loc)
+		return nil
+	}
+}
+
+// WithDumpDir turns on the debug dump of availability exchanges; see
+// SetDumpDir.
+func WithDumpDir(dir string) Option {
+	return func(c *Client) error {
+		return c.SetDumpDir(dir)
+	}
+}
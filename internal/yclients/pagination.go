@@ -0,0 +1,99 @@
+package yclients
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// maxSearchPages bounds how many pages of one availability search are
+// fetched, in case the API keeps returning a next page.
+const maxSearchPages = 20
+
+// pageMeta is the pagination part of a response. Availability searches
+// have not been seen paginated so far; both the meta counters used by other
+// YCLIENTS endpoints and a JSON:API next link are understood.
+type pageMeta struct {
+	Page       int `json:"page"`
+	TotalPages int `json:"total_pages"`
+}
+
+type pageLinks struct {
+	Next string `json:"next"`
+}
+
+type pagination struct {
+	Meta  *pageMeta  `json:"meta"`
+	Links *pageLinks `json:"links"`
+}
+
+// nextPage returns the endpoint of the page after page, or false when the
+// response is the last or only page. Only the path and query of a next link
+// are used so credentials are never sent to another host.
+func nextPage(data []byte, endpoint string, page int) (string, bool) {
+	var p pagination
+	if json.Unmarshal(data, &p) != nil {
+		return "", false
+	}
+	if p.Links != nil && p.Links.Next != "" {
+		u, err := url.Parse(p.Links.Next)
+		if err != nil || u.Path == "" {
+			return "", false
+		}
+		next := u.Path
+		if u.RawQuery != "" {
+			next += "?" + u.RawQuery
+		}
+		return next, true
+	}
+	if p.Meta != nil && p.Meta.TotalPages > page {
+		return endpoint + "?page=" + strconv.Itoa(page+1), true
+	}
+	return "", false
+}
+
+// searchAll posts an availability search and follows its pages, parsing
+// each one. Non-paginated responses are a single page.
+func searchAll[T any](ctx context.Context, c *Client, endpoint string, body []byte, parse func([]byte) ([]T, error)) ([]T, error) {
+	var (
+		out      []T
+		dumpPath string
+	)
+	if c.dumpDir != "" {
+		ctx = withLastDump(ctx, &dumpPath)
+	}
+	next := endpoint
+	for page := 1; ; page++ {
+		raw, _, err := c.makeRequest(ctx, next, body)
+		if err != nil {
+			return nil, err
+		}
+		items, err := parse(raw)
+		c.observeParse(endpoint, err)
+		if err != nil {
+			if c.dumpDir != "" {
+				c.logger(ctx).WithError(err).WarnWithFields("Unparseable YCLIENTS response dumped", logger.Fields{
+					"endpoint": endpoint,
+					"file":     dumpPath,
+				})
+			}
+			return nil, err
+		}
+		out = append(out, items...)
+
+		var ok bool
+		if next, ok = nextPage(raw, endpoint, page); !ok {
+			return out, nil
+		}
+		if page >= maxSearchPages {
+			c.logger(ctx).WarnWithFields("YCLIENTS search has more pages than allowed, ignoring the rest", logger.Fields{
+				"endpoint":  endpoint,
+				"max_pages": maxSearchPages,
+			})
+			return out, nil
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package yclients_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients/yclientstest"
+)
+
+// datesPage is a search-dates page holding one bookable date; pagination is
+// added after the data.
+func datesPage(date, pagination string) yclientstest.Response {
+	body := fmt.Sprintf(`{"data":[{"type":"booking_search_result_dates","id":%[1]q,"attributes":{"date":%[1]q,"is_bookable":true}}]%s}`, date, pagination)
+	return yclientstest.Response{Status: http.StatusOK, Body: body}
+}
+
+func searchDates(t *testing.T, srv *yclientstest.Server) []string {
+	t.Helper()
+	dates, err := srv.Client().GetBookableDatesAnyStaff(context.Background(), testLocationID, testServiceID, "2025-03-18", "2025-03-31")
+	if err != nil {
+		t.Fatalf("GetBookableDatesAnyStaff: %v", err)
+	}
+	return dates
+}
+
+func TestSearchFollowsMetaPages(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.SearchDatesPath,
+		datesPage("2025-03-18", `,"meta":{"page":1,"total_pages":2}`),
+		datesPage("2025-03-25", `,"meta":{"page":2,"total_pages":2}`),
+	)
+
+	dates := searchDates(t, srv)
+	if want := []string{"2025-03-18", "2025-03-25"}; !reflect.DeepEqual(dates, want) {
+		t.Errorf("dates = %v, want both pages %v", dates, want)
+	}
+	if got := srv.Calls(yclientstest.SearchDatesPath); got != 2 {
+		t.Fatalf("%d search requests, want 2", got)
+	}
+	reqs := srv.Requests()
+	last := reqs[len(reqs)-1]
+	if last.Query != "page=2" {
+		t.Errorf("second request query = %q, want page=2", last.Query)
+	}
+	first := reqs[len(reqs)-2]
+	if last.Body != first.Body {
+		t.Errorf("second page body = %s, want the search repeated", last.Body)
+	}
+}
+
+func TestSearchFollowsNextLinkOnSameHost(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.SearchDatesPath,
+		datesPage("2025-03-18", `,"links":{"next":"https://evil.example`+yclientstest.SearchDatesPath+`?cursor=abc"}`),
+		datesPage("2025-03-25", `,"links":{"next":null}`),
+	)
+
+	dates := searchDates(t, srv)
+	if len(dates) != 2 {
+		t.Errorf("dates = %v, want both pages", dates)
+	}
+	if got := srv.Calls(yclientstest.SearchDatesPath); got != 2 {
+		t.Fatalf("%d search requests on the fake server, want 2", got)
+	}
+	reqs := srv.Requests()
+	if q := reqs[len(reqs)-1].Query; q != "cursor=abc" {
+		t.Errorf("second request query = %q, want cursor=abc", q)
+	}
+}
+
+func TestSearchWithoutPagination(t *testing.T) {
+	srv := newTestServer(t)
+
+	if dates := searchDates(t, srv); len(dates) != 1 {
+		t.Errorf("dates = %v", dates)
+	}
+	if got := srv.Calls(yclientstest.SearchDatesPath); got != 1 {
+		t.Errorf("%d search requests, want 1", got)
+	}
+}
+
+func TestSearchStopsAtPageLimit(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.SearchDatesPath, datesPage("2025-03-18", `,"meta":{"page":1,"total_pages":1000}`))
+
+	dates := searchDates(t, srv)
+	calls := srv.Calls(yclientstest.SearchDatesPath)
+	if calls >= 1000 || calls < 2 {
+		t.Fatalf("%d search requests, want the page limit", calls)
+	}
+	if len(dates) != calls {
+		t.Errorf("%d dates from %d pages", len(dates), calls)
+	}
+}
+
+func TestSearchPageFailure(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Set(yclientstest.SearchDatesPath,
+		datesPage("2025-03-18", `,"meta":{"page":1,"total_pages":2}`),
+		yclientstest.Response{Status: http.StatusOK, Body: `{"data":[`},
+	)
+
+	_, err := srv.Client().GetBookableDatesAnyStaff(context.Background(), testLocationID, testServiceID, "2025-03-18", "2025-03-31")
+	if err == nil || !strings.Contains(err.Error(), "parse dates") {
+		t.Errorf("err = %v, want the second page's parse error", err)
+	}
+}
@@ -0,0 +1,150 @@
+package yclients
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewTimeslot(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	tests := []struct {
+		name         string
+		datetime     string
+		bare         string
+		loc          *time.Location
+		wantStart    time.Time
+		wantOK       bool
+		wantUnparsed bool
+	}{
+		{"datetime", "2025-03-18T10:00:00+03:00", "10:00", nil, time.Date(2025, 3, 18, 10, 0, 0, 0, moscow), true, false},
+		{"bare with location", "", "10:00", moscow, time.Date(2025, 3, 18, 10, 0, 0, 0, moscow), true, false},
+		{"bare without location", "", "10:00", nil, time.Time{}, true, false},
+		{"bad datetime", "18.03.2025 10:00", "", nil, time.Time{}, true, true},
+		{"bad bare", "", "10h", moscow, time.Time{}, true, true},
+		{"empty", "", "", moscow, time.Time{}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, ok, unparsed := newTimeslot(tt.datetime, tt.bare, "2025-03-18", tt.loc)
+			if ok != tt.wantOK || unparsed != tt.wantUnparsed {
+				t.Fatalf("ok, unparsed = %v, %v, want %v, %v", ok, unparsed, tt.wantOK, tt.wantUnparsed)
+			}
+			if !ts.Start.Equal(tt.wantStart) {
+				t.Errorf("Start = %s, want %s", ts.Start, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestParseTimeslotsSkipsFullGroups(t *testing.T) {
+	data := []byte(`{"data":[
+		{"id":"1","attributes":{"datetime":"2025-03-18T10:00:00+03:00","is_bookable":true,"capacity":6,"records_count":6}},
+		{"id":"2","attributes":{"datetime":"2025-03-18T12:00:00+03:00","is_bookable":true,"capacity":6}},
+		{"id":"3","attributes":{"datetime":"2025-03-18 14:00","is_bookable":true}}
+	]}`)
+
+	out, unparsed, err := parseTimeslots(data, "2025-03-18", nil)
+	if err != nil {
+		t.Fatalf("parseTimeslots: %v", err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("%d timeslots, want the open group and the unparsed one", len(out))
+	}
+	if !out[0].HasSeats || out[0].SeatsLeft != 6 {
+		t.Errorf("group timeslot = %+v, want 6 seats left", out[0])
+	}
+	if !reflect.DeepEqual(unparsed, []string{"2025-03-18 14:00"}) {
+		t.Errorf("unparsed = %v", unparsed)
+	}
+}
+
+func TestParseStaffAvailabilitySkipsBadIDs(t *testing.T) {
+	data := []byte(`{"data":[
+		{"id":"abc","attributes":{"is_bookable":true}},
+		{"id":"7","attributes":{"is_bookable":true,"price_min":100,"price_max":200}}
+	]}`)
+
+	staff, err := parseStaffAvailability(data)
+	if err != nil {
+		t.Fatalf("parseStaffAvailability: %v", err)
+	}
+	if want := []StaffAvailability{{ID: 7, PriceMin: 100, PriceMax: 200}}; !reflect.DeepEqual(staff, want) {
+		t.Errorf("staff = %+v, want %+v", staff, want)
+	}
+}
+
+func TestParseDates(t *testing.T) {
+	data := []byte(`{"data":[
+		{"id":"1","attributes":{"date":"2025-03-18","is_bookable":true}},
+		{"id":"2","attributes":{"date":"2025-03-19","is_bookable":false}},
+		{"id":"3","attributes":{"date":"","is_bookable":true}}
+	]}`)
+
+	dates, err := parseDates(data)
+	if err != nil {
+		t.Fatalf("parseDates: %v", err)
+	}
+	if !reflect.DeepEqual(dates, []string{"2025-03-18"}) {
+		t.Errorf("dates = %v", dates)
+	}
+}
+
+func TestParseHelpersRejectMalformedJSON(t *testing.T) {
+	body := []byte(`{"data":`)
+	if _, err := parseDates(body); !errors.Is(err, ErrBadResponse) {
+		t.Errorf("parseDates: err = %v, want ErrBadResponse", err)
+	}
+	if _, err := parseStaffAvailability(body); !errors.Is(err, ErrBadResponse) {
+		t.Errorf("parseStaffAvailability: err = %v, want ErrBadResponse", err)
+	}
+	if _, _, err := parseTimeslots(body, "2025-03-18", nil); !errors.Is(err, ErrBadResponse) {
+		t.Errorf("parseTimeslots: err = %v, want ErrBadResponse", err)
+	}
+	if _, _, err := parseTimes(body, "2025-03-18", nil); !errors.Is(err, ErrBadResponse) {
+		t.Errorf("parseTimes: err = %v, want ErrBadResponse", err)
+	}
+}
+
+// staffResponse is a book_staff response as recorded from the API, extra
+// fields included.
+const staffResponse = `{"success":true,"data":[
+	{"id":2850522,"name":"Иван Петров","company_id":780413,"specialization":"Инструктор по вождению",
+		"avatar":"https://assets.yclients.com/masters/origin/2/2850522.jpg",
+		"avatar_big":"https://assets.yclients.com/masters/origin/2/2850522_big.jpg",
+		"rating":4.9,"votes_count":37,"bookable":true,"information":"<p>Стаж 10 лет</p>",
+		"seance_date":"2025-03-18","seances":[]},
+	{"id":2850523,"name":"Пётр Сидоров","company_id":780413,"specialization":"Инструктор",
+		"avatar":"","rating":0,"votes_count":0,"bookable":false,"seance_date":null,"seances":[]}
+],"meta":[]}`
+
+func TestParseStaff(t *testing.T) {
+	want := []Staff{
+		{ID: 2850522, Name: "Иван Петров", Specialization: "Инструктор по вождению",
+			Avatar: "https://assets.yclients.com/masters/origin/2/2850522.jpg", Bookable: true},
+		// Not bookable right now, but the name is still valid.
+		{ID: 2850523, Name: "Пётр Сидоров", Specialization: "Инструктор"},
+	}
+	for _, tt := range []struct {
+		name string
+		data string
+	}{
+		{"enveloped", staffResponse},
+		{"bare array", staffResponse[len(`{"success":true,"data":`) : len(staffResponse)-len(`,"meta":[]}`)]},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			staff, err := parseStaff([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("parseStaff: %v", err)
+			}
+			if !reflect.DeepEqual(staff, want) {
+				t.Errorf("staff = %+v, want %+v", staff, want)
+			}
+		})
+	}
+
+	if _, err := parseStaff([]byte(`{"success":false,"data":null}`)); !errors.Is(err, ErrBadResponse) {
+		t.Errorf("failed response: err = %v, want ErrBadResponse", err)
+	}
+}
@@ -0,0 +1,115 @@
+package yclients
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestPayloadBuilders(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() ([]byte, error)
+		want  string
+	}{
+		{
+			name:  "staff",
+			build: func() ([]byte, error) { return BuildSearchStaffPayload(780413, 15728488, nil) },
+			want:  `{"context":{"location_id":780413},"filter":{"datetime":null,"records":[{"staff_id":null,"attendance_service_items":[{"type":"service","id":15728488}]}]}}`,
+		},
+		{
+			name: "dates with staff",
+			build: func() ([]byte, error) {
+				return BuildSearchDatesPayload(780413, 15728488, "2025-03-18", "2025-03-25", intPtr(2850522))
+			},
+			want: `{"context":{"location_id":780413},"filter":{"date_from":"2025-03-18","date_to":"2025-03-25","records":[{"staff_id":2850522,"attendance_service_items":[{"type":"service","id":15728488}]}]}}`,
+		},
+		{
+			name: "dates any staff",
+			build: func() ([]byte, error) {
+				return BuildSearchDatesPayload(780413, 15728488, "2025-03-18", "2025-03-18", nil)
+			},
+			want: `{"context":{"location_id":780413},"filter":{"date_from":"2025-03-18","date_to":"2025-03-18","records":[{"staff_id":null,"attendance_service_items":[{"type":"service","id":15728488}]}]}}`,
+		},
+		{
+			name:  "timeslots",
+			build: func() ([]byte, error) { return BuildSearchTimeslotsPayload(780413, 15728488, "2025-03-18", 2850522) },
+			want:  `{"context":{"location_id":780413},"filter":{"date":"2025-03-18","records":[{"staff_id":2850522,"attendance_service_items":[{"type":"service","id":15728488}]}]}}`,
+		},
+		{
+			name:  "times",
+			build: func() ([]byte, error) { return BuildSearchTimesPayload(780413, 15728488, "2025-03-18") },
+			want:  `{"context":{"location_id":780413},"filter":{"date":"2025-03-18","records":[{"staff_id":null,"attendance_service_items":[{"type":"service","id":15728488}]}]}}`,
+		},
+		{
+			name: "timeslots for a visit of two services",
+			build: func() ([]byte, error) {
+				return BuildSearchTimeslotsPayloadServices(780413, []int{1, 2}, "2025-03-18", 2850522)
+			},
+			want: `{"context":{"location_id":780413},"filter":{"date":"2025-03-18","records":[{"staff_id":2850522,"attendance_service_items":[{"type":"service","id":1},{"type":"service","id":2}]}]}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.build()
+			if err != nil {
+				t.Fatalf("build: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("payload =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayloadBuildersRejectInvalidInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() ([]byte, error)
+		want  string
+	}{
+		{"zero location", func() ([]byte, error) { return BuildSearchStaffPayload(0, 1, nil) }, "location ID must be positive"},
+		{"negative service", func() ([]byte, error) { return BuildSearchStaffPayload(1, -1, nil) }, "service ID must be positive"},
+		{"zero staff", func() ([]byte, error) { return BuildSearchStaffPayload(1, 1, intPtr(0)) }, "staff ID must be positive"},
+		{"empty date_from", func() ([]byte, error) {
+			return BuildSearchDatesPayload(1, 1, "", "2025-03-18", nil)
+		}, `date_from "" is not a YYYY-MM-DD date`},
+		{"bad date_to", func() ([]byte, error) {
+			return BuildSearchDatesPayload(1, 1, "2025-03-18", "18.03.2025", nil)
+		}, `date_to "18.03.2025" is not a YYYY-MM-DD date`},
+		{"reversed range", func() ([]byte, error) {
+			return BuildSearchDatesPayload(1, 1, "2025-03-19", "2025-03-18", nil)
+		}, "date_from 2025-03-19 is after date_to 2025-03-18"},
+		{"no services", func() ([]byte, error) {
+			return BuildSearchDatesPayloadServices(1, nil, "2025-03-18", "2025-03-18", nil)
+		}, "no service IDs"},
+		{"one bad service of several", func() ([]byte, error) {
+			return BuildSearchDatesPayloadServices(1, []int{1, 0}, "2025-03-18", "2025-03-18", nil)
+		}, "service ID must be positive, got 0"},
+		{"timeslots zero staff", func() ([]byte, error) {
+			return BuildSearchTimeslotsPayload(1, 1, "2025-03-18", 0)
+		}, "staff ID must be positive"},
+		{"timeslots bad date", func() ([]byte, error) {
+			return BuildSearchTimeslotsPayload(1, 1, "2025-02-30", 1)
+		}, `date "2025-02-30" is not a YYYY-MM-DD date`},
+		{"times zero location", func() ([]byte, error) {
+			return BuildSearchTimesPayload(0, 1, "2025-03-18")
+		}, "location ID must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := tt.build()
+			if !errors.Is(err, ErrBadRequest) {
+				t.Fatalf("err = %v, want ErrBadRequest", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %q, want it to mention %q", err, tt.want)
+			}
+			if body != nil {
+				t.Errorf("payload %s built for invalid input", body)
+			}
+		})
+	}
+}
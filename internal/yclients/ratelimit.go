@@ -0,0 +1,94 @@
+package yclients
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// Rate limit defaults for requests to YCLIENTS, kept well below what the
+// partner API tolerates.
+const (
+	DefaultRateLimit = 5.0
+	DefaultRateBurst = 5
+)
+
+// rateLimiter is a token bucket refilled at rate tokens per second up to
+// burst. A zero rate disables it.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait takes a token, blocking until one is available or ctx ends, and
+// returns how long it waited. A cancelled wait returns its token.
+func (l *rateLimiter) wait(ctx context.Context) (time.Duration, error) {
+	if l == nil || l.rate <= 0 {
+		return 0, nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return 0, nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return delay, nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return time.Since(now), ctx.Err()
+	}
+}
+
+// SetRateLimit limits requests to YCLIENTS to rate per second with bursts
+// of up to burst requests; a rate of zero or less disables the limit.
+func (c *Client) SetRateLimit(rate float64, burst int) {
+	c.limiter = newRateLimiter(rate, burst)
+}
+
+// waitRateLimit blocks until the rate limiter lets a request through.
+func (c *Client) waitRateLimit(ctx context.Context, endpoint string) error {
+	waited, err := c.limiter.wait(ctx)
+	if waited > 0 {
+		if c.metrics != nil {
+			c.metrics.ObserveYClientsRateLimitWait(waited.Seconds())
+		}
+		c.logger(ctx).DebugWithFields("Waited for YCLIENTS rate limiter", logger.Fields{
+			"endpoint": endpoint,
+			"waited":   waited.String(),
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("yclients: rate limiter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package yclients
+
+import (
+	"context"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/logger"
+)
+
+// RequestIDHeader carries the correlation ID of a request to YCLIENTS.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose requests to YCLIENTS are tagged
+// with id, both in the X-Request-ID header and in the client's logs.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the correlation ID set by WithRequestID, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// logger returns the client logger tagged with the request ID of ctx.
+func (c *Client) logger(ctx context.Context) *logger.Logger {
+	if id := RequestID(ctx); id != "" {
+		return c.log.WithRequestID(id)
+	}
+	return c.log
+}
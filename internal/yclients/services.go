@@ -0,0 +1,43 @@
+package yclients
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Service is the part of a service record used to show service names.
+type Service struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+// parseServices accepts the booking endpoint's {"services": [...]} object
+// as well as a bare array of services.
+func parseServices(data []byte) ([]Service, error) {
+	var catalog dataEnvelope[struct {
+		Services []Service `json:"services"`
+	}]
+	if err := json.Unmarshal(data, &catalog); err == nil && catalog.Success {
+		return catalog.Data.Services, nil
+	}
+	var list dataEnvelope[[]Service]
+	if err := json.Unmarshal(data, &list); err == nil && list.Success {
+		return list.Data, nil
+	}
+	var services []Service
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, badResponse("services", err)
+	}
+	return services, nil
+}
+
+// GetServices lists the services offered in the company's online booking.
+func (c *Client) GetServices(ctx context.Context, companyID string) ([]Service, error) {
+	data, err := c.getAPI(ctx, "/api/v1/book_services/"+companyID)
+	if err != nil {
+		return nil, err
+	}
+	services, err := parseServices(data)
+	c.observeParse(endpointLabel("/api/v1/book_services/"+companyID), err)
+	return services, err
+}
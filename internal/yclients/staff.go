@@ -0,0 +1,42 @@
+package yclients
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Staff is the part of a staff record used to show instructors.
+// The list also holds staff who currently take no bookings; Bookable is
+// false for them but their names are still valid.
+type Staff struct {
+	ID             int    `json:"id"`
+	Name           string `json:"name"`
+	Specialization string `json:"specialization"`
+	Avatar         string `json:"avatar"`
+	Bookable       bool   `json:"bookable"`
+}
+
+// parseStaff accepts both the enveloped response and the bare array the
+// booking endpoints return for older API versions.
+func parseStaff(data []byte) ([]Staff, error) {
+	var resp dataEnvelope[[]Staff]
+	if err := json.Unmarshal(data, &resp); err == nil && resp.Success {
+		return resp.Data, nil
+	}
+	var staff []Staff
+	if err := json.Unmarshal(data, &staff); err != nil {
+		return nil, badResponse("staff list", err)
+	}
+	return staff, nil
+}
+
+// GetStaff lists the staff members shown in the company's online booking.
+func (c *Client) GetStaff(ctx context.Context, companyID string) ([]Staff, error) {
+	data, err := c.getAPI(ctx, "/api/v1/book_staff/"+companyID)
+	if err != nil {
+		return nil, err
+	}
+	staff, err := parseStaff(data)
+	c.observeParse(endpointLabel("/api/v1/book_staff/"+companyID), err)
+	return staff, err
+}
@@ -0,0 +1,81 @@
+package yclients
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// TimeAttributes is a time returned by search-times. Unlike search-timeslots
+// it is not tied to a staff member: a time is bookable when anyone can take
+// it.
+type TimeAttributes struct {
+	Datetime   string `json:"datetime"`
+	Time       string `json:"time"`
+	IsBookable bool   `json:"is_bookable"`
+}
+
+type filterTimes struct {
+	Date    string   `json:"date"`
+	Records []record `json:"records"`
+}
+
+// BuildSearchTimesPayload builds JSON for availability/search-times with no
+// staff filter.
+func BuildSearchTimesPayload(locationID int, serviceID int, date string) ([]byte, error) {
+	if err := validateSearch(locationID, serviceID, nil); err != nil {
+		return nil, err
+	}
+	if _, err := parseSearchDate("date", date); err != nil {
+		return nil, err
+	}
+	p := searchPayload[filterTimes]{
+		Context: payloadContext{LocationID: locationID},
+		Filter: filterTimes{
+			Date: date,
+			Records: []record{
+				{
+					AttendanceServiceItems: []attendanceServiceItem{{
+						Type: "service",
+						ID:   serviceID,
+					}},
+				},
+			},
+		},
+	}
+	return json.Marshal(p)
+}
+
+func parseTimes(data []byte, date string, loc *time.Location) (out []Timeslot, unparsed []string, err error) {
+	var resp apiResponse[TimeAttributes]
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, nil, badResponse("times", err)
+	}
+	out = make([]Timeslot, 0, len(resp.Data))
+	for _, it := range resp.Data {
+		a := it.Attributes
+		if !a.IsBookable {
+			continue
+		}
+		ts, ok, bad := newTimeslot(a.Datetime, a.Time, date, loc)
+		if !ok {
+			continue
+		}
+		if bad {
+			unparsed = append(unparsed, ts.Datetime)
+		}
+		out = append(out, ts)
+	}
+	return out, unparsed, nil
+}
+
+// GetBookableTimes returns the times of a date bookable with any staff
+// member of the service. It costs one request per date instead of one per
+// staff member, but the result can't be attributed to an instructor.
+func (c *Client) GetBookableTimes(ctx context.Context, locationID, serviceID int, date string) ([]Timeslot, error) {
+	body, err := BuildSearchTimesPayload(locationID, serviceID, date)
+	if err != nil {
+		return nil, err
+	}
+	return searchAll(ctx, c, searchTimesEndpoint, body, c.timeslotParser(ctx, searchTimesEndpoint, date, parseTimes))
+}
@@ -0,0 +1,53 @@
+package yclientstest
+
+// Canned responses modelled on real YCLIENTS answers. IDs match the
+// defaults of Server: company 780413, form n841217, service 15728488,
+// staff 2850522.
+const (
+	AuthOK = `{"success":true,"data":{"id":1,"user_token":"test-user-token","name":"Test User","login":"test"}}`
+
+	AuthFailed = `{"success":false,"data":null,"meta":{"message":"Неверный логин или пароль"}}`
+
+	SearchStaff = `{"data":[
+		{"type":"booking_search_result_staff","id":"2850522","attributes":{"is_bookable":true,"price_min":2500,"price_max":3000}},
+		{"type":"booking_search_result_staff","id":"2850523","attributes":{"is_bookable":false,"price_min":0,"price_max":0}}
+	]}`
+
+	SearchDates = `{"data":[
+		{"type":"booking_search_result_dates","id":"2025-03-18","attributes":{"date":"2025-03-18","is_bookable":true}},
+		{"type":"booking_search_result_dates","id":"2025-03-19","attributes":{"date":"2025-03-19","is_bookable":false}}
+	]}`
+
+	SearchTimeslots = `{"data":[
+		{"type":"booking_search_result_timeslots","id":"1","attributes":{"datetime":"2025-03-18T10:00:00+03:00","time":"10:00","is_bookable":true}},
+		{"type":"booking_search_result_timeslots","id":"2","attributes":{"datetime":"2025-03-18T12:00:00+03:00","time":"12:00","is_bookable":true,"capacity":6,"records_count":4}},
+		{"type":"booking_search_result_timeslots","id":"3","attributes":{"datetime":"2025-03-18T14:00:00+03:00","time":"14:00","is_bookable":false}}
+	]}`
+
+	// SearchTimeslotsBare reports bare times only, as some forms do; the
+	// client takes the date from the request.
+	SearchTimeslotsBare = `{"data":[
+		{"type":"booking_search_result_timeslots","id":"1","attributes":{"time":"10:00","is_bookable":true}}
+	]}`
+
+	SearchTimes = `{"data":[
+		{"type":"booking_search_result_times","id":"1","attributes":{"datetime":"2025-03-18T10:00:00+03:00","time":"10:00","is_bookable":true}}
+	]}`
+
+	Company = `{"success":true,"data":{"id":780413,"title":"Мото Город","main_group_id":0}}`
+
+	BookingForm = `{"success":true,"data":{"id":841217,"title":"Онлайн-запись","company_id":780413,"group_id":0}}`
+
+	Staff = `{"success":true,"data":[
+		{"id":2850522,"name":"Иван","specialization":"Инструктор","avatar":"","bookable":true},
+		{"id":2850523,"name":"Пётр","specialization":"Инструктор","avatar":"","bookable":false}
+	]}`
+
+	Services = `{"success":true,"data":{"services":[{"id":15728488,"title":"Вождение мотоцикла"}]}}`
+
+	ErrorUnauthorized = `{"success":false,"data":null,"meta":{"message":"Authentication required"}}`
+
+	ErrorRateLimited = `{"success":false,"data":null,"meta":{"message":"Too many requests"}}`
+
+	ErrorServer = `{"success":false,"data":null,"meta":{"message":"Internal server error"}}`
+)
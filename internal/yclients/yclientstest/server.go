@@ -0,0 +1,165 @@
+// Package yclientstest provides a fake YCLIENTS server for exercising the
+// yclients client without network access.
+package yclientstest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thatguy/moto_gorod-notifier/internal/yclients"
+)
+
+// Paths served by default.
+const (
+	AuthPath            = "/api/v1/auth"
+	SearchStaffPath     = "/api/v1/b2c/booking/availability/search-staff"
+	SearchDatesPath     = "/api/v1/b2c/booking/availability/search-dates"
+	SearchTimeslotsPath = "/api/v1/b2c/booking/availability/search-timeslots"
+	SearchTimesPath     = "/api/v1/b2c/booking/availability/search-times"
+	CompanyPath         = "/api/v1/company/780413"
+	BookingFormPath     = "/api/v1/bookform/841217"
+	StaffPath           = "/api/v1/book_staff/780413"
+	ServicesPath        = "/api/v1/book_services/780413"
+)
+
+// Response is a canned answer. Header and Delay are optional; Delay holds
+// the answer back, e.g. to keep an authentication request in flight.
+type Response struct {
+	Status int
+	Body   string
+	Header http.Header
+	Delay  time.Duration
+}
+
+// Request is a request the server received.
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   string
+}
+
+// Server is a fake YCLIENTS serving both the platform and the REST API
+// host. Responses can be replaced per path while it runs.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses map[string][]Response
+	requests  []Request
+}
+
+// NewServer starts a server answering every known path with a successful
+// fixture. Close it when done.
+func NewServer() *Server {
+	s := &Server{responses: map[string][]Response{
+		AuthPath:            {{Status: http.StatusCreated, Body: AuthOK}},
+		SearchStaffPath:     {{Status: http.StatusOK, Body: SearchStaff}},
+		SearchDatesPath:     {{Status: http.StatusOK, Body: SearchDates}},
+		SearchTimeslotsPath: {{Status: http.StatusOK, Body: SearchTimeslots}},
+		SearchTimesPath:     {{Status: http.StatusOK, Body: SearchTimes}},
+		CompanyPath:         {{Status: http.StatusOK, Body: Company}},
+		BookingFormPath:     {{Status: http.StatusOK, Body: BookingForm}},
+		StaffPath:           {{Status: http.StatusOK, Body: Staff}},
+		ServicesPath:        {{Status: http.StatusOK, Body: Services}},
+	}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Set replaces the responses of path. With several responses they are
+// served in order and the last one repeats, e.g. a 500 followed by a 200
+// to exercise retries.
+func (s *Server) Set(path string, responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[path] = responses
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Calls counts the requests received for path.
+func (s *Server) Calls(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.requests {
+		if r.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// Client returns a yclients client pointed at the server for company
+// 780413 and form n841217, without client-side rate limiting or retry
+// delays.
+func (s *Server) Client() *yclients.Client {
+	c, err := yclients.New(yclients.Credentials{
+		Login:        "test",
+		Password:     "secret",
+		PartnerToken: "test-partner-token",
+		CompanyID:    "780413",
+		FormID:       "n841217",
+	},
+		yclients.WithBaseURL(s.URL, s.URL),
+		yclients.WithHTTPClient(s.Server.Client()),
+		yclients.WithRateLimit(0, 0),
+		yclients.WithRetryPolicy(yclients.DefaultRetryAttempts, 0),
+	)
+	if err != nil {
+		panic("yclientstest: " + err.Error())
+	}
+	return c
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Header: r.Header.Clone(),
+		Body:   string(body),
+	})
+	queue := s.responses[strings.TrimRight(r.URL.Path, "/")]
+	var resp Response
+	switch len(queue) {
+	case 0:
+		resp = Response{Status: http.StatusNotFound, Body: `{"success":false,"data":null,"meta":{"message":"Not found"}}`}
+	case 1:
+		resp = queue[0]
+	default:
+		resp = queue[0]
+		s.responses[strings.TrimRight(r.URL.Path, "/")] = queue[1:]
+	}
+	s.mu.Unlock()
+
+	if resp.Delay > 0 {
+		select {
+		case <-time.After(resp.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	for name, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	_, _ = io.WriteString(w, resp.Body)
+}